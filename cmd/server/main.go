@@ -12,10 +12,13 @@ import (
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/trogers1052/stock-alert-system/internal/alerts"
 	"github.com/trogers1052/stock-alert-system/internal/api"
 	"github.com/trogers1052/stock-alert-system/internal/config"
 	"github.com/trogers1052/stock-alert-system/internal/database"
 	"github.com/trogers1052/stock-alert-system/internal/kafka"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+	"github.com/trogers1052/stock-alert-system/internal/notify"
 	"github.com/trogers1052/stock-alert-system/internal/redis"
 )
 
@@ -37,6 +40,8 @@ func main() {
 	}
 
 	defer db.Close()
+	db.SetMaxAlertRulesPerSymbol(cfg.Alerts.MaxRulesPerSymbol)
+	db.SetDefaultAlertCooldown(cfg.Alerts.DefaultCooldownMinutes)
 	log.Println("Connected to PostgreSQL database")
 
 	// Connect to Redis
@@ -58,21 +63,58 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Notification channels configured for alerts
+	var notifiers []notify.Notifier
+	if cfg.Telegram.Enabled() {
+		notifiers = append(notifiers, notify.NewTelegramNotifier(cfg.Telegram.APIURL, cfg.Telegram.BotToken, cfg.Telegram.ChatID))
+	}
+	if cfg.Pushover.Enabled() {
+		notifiers = append(notifiers, notify.NewPushoverNotifier(cfg.Pushover.APIURL, cfg.Pushover.AppToken, cfg.Pushover.UserKey))
+	}
+	if cfg.SMTP.Enabled() {
+		notifiers = append(notifiers, notify.NewSMTPNotifier(cfg.SMTP.Host, cfg.SMTP.Port,
+			cfg.SMTP.Username, cfg.SMTP.Password, cfg.SMTP.From, cfg.SMTP.To))
+	}
+	var dispatcher *notify.Dispatcher
+	if len(notifiers) > 0 {
+		dispatcher = notify.NewDispatcher(notifiers...)
+	}
+
 	// Create and start Kafka consumer for trade events
 	consumer := kafka.NewConsumer(
 		cfg.Kafka.Brokers,
 		cfg.Kafka.TradesTopic,
 		cfg.Kafka.ConsumerGroup,
+		cfg.Kafka.MaxBytes,
 		db,
 	)
-	go func() {
-		log.Printf("Starting Kafka consumer for topic: %s (group: %s)",
-			cfg.Kafka.TradesTopic, cfg.Kafka.ConsumerGroup)
-		if err := consumer.Start(ctx); err != nil {
-			log.Printf("Kafka consumer error: %v", err)
+	consumer.SetCircuitBreaker(cfg.Kafka.FailureThreshold, cfg.Kafka.FailureCooldown)
+	consumer.SetRetry(cfg.Kafka.RetryAttempts, cfg.Kafka.RetryBaseDelay)
+	consumer.SetReprocessWindow(cfg.Kafka.ReprocessWindow)
+	consumer.SetNotionalTolerance(cfg.Kafka.NotionalTolerance)
+	consumer.SetBatchSize(cfg.Kafka.BatchSize, cfg.Kafka.BatchWait)
+	consumer.SetSymbolAliases(cfg.SymbolAliases)
+	if hours, err := models.NewMarketHours(cfg.Kafka.MarketTimezone, cfg.Kafka.MarketOpen, cfg.Kafka.MarketClose); err != nil {
+		log.Printf("Warning: extended-hours tagging disabled: %v", err)
+	} else {
+		consumer.SetMarketHours(hours, cfg.Kafka.RejectExtendedHours)
+	}
+	costBasis := kafka.CostBasisMode(cfg.Kafka.CostBasis)
+	if costBasis == kafka.CostBasisFIFO {
+		consumer.SetCostBasisMode(costBasis, db)
+		consumer.SetFeeMode(kafka.FeeMode(cfg.Kafka.PnlFees))
+		if cfg.Alerts.NotifyPositionClose {
+			// Snapshots don't record closes in FIFO mode, so sells summarize them
+			consumer.SetCloseNotifications(cfg.Alerts.PositionCloseChannel, db, dispatcher, db)
 		}
-	}()
-
+		log.Println("Recording closed trades from FIFO lot matching")
+	}
+	if cfg.Kafka.DeadLetterTopic != "" {
+		dlq := kafka.NewDeadLetterWriter(cfg.Kafka.Brokers, cfg.Kafka.DeadLetterTopic)
+		defer dlq.Close()
+		consumer.SetDeadLetterWriter(dlq)
+		log.Printf("Oversized and malformed trade messages will be routed to %s", cfg.Kafka.DeadLetterTopic)
+	}
 	// Create and start Kafka consumer for position snapshots
 	positionsConsumer := kafka.NewPositionsConsumer(
 		cfg.Kafka.Brokers,
@@ -80,13 +122,46 @@ func main() {
 		cfg.Kafka.ConsumerGroup,
 		db,
 	)
-	go func() {
-		log.Printf("Starting Kafka positions consumer for topic: %s (group: %s-positions)",
-			cfg.Kafka.PositionsTopic, cfg.Kafka.ConsumerGroup)
-		if err := positionsConsumer.Start(ctx); err != nil {
-			log.Printf("Kafka positions consumer error: %v", err)
-		}
-	}()
+	positionsConsumer.SetAlertRepository(db)
+	positionsConsumer.SetPositionOpenAlerts(cfg.Alerts.NotifyPositionOpen)
+	if cfg.Alerts.NotifyPositionClose {
+		positionsConsumer.SetCloseNotifications(cfg.Alerts.PositionCloseChannel, dispatcher, db)
+	}
+	positionsConsumer.SetReopenWindow(cfg.Kafka.PositionReopenWindow)
+	positionsConsumer.SetSymbolAliases(cfg.SymbolAliases)
+	positionsConsumer.SetClosesFromTrades(costBasis == kafka.CostBasisFIFO)
+	positionsConsumer.SetEntryPriceFromTrades(cfg.Kafka.EntryFromTrades, cfg.Kafka.EntryIncludeFees)
+	positionsConsumer.SetEntryRSISource(kafka.EntryRSISource(cfg.Kafka.EntryRSISource), db)
+	positionsConsumer.SetDefaultEntryReason(cfg.Kafka.DefaultEntryReason)
+	positionsConsumer.SetMaxLeverage(cfg.Alerts.MaxLeverage)
+
+	var combinedConsumer *kafka.CombinedConsumer
+	if cfg.Kafka.Combined {
+		combinedConsumer = kafka.NewCombinedConsumer(cfg.Kafka.Brokers, cfg.Kafka.TradesTopic, cfg.Kafka.PositionsTopic,
+			cfg.Kafka.ConsumerGroup, consumer, positionsConsumer)
+		go func() {
+			log.Printf("Starting combined Kafka consumer for topics: %s, %s (group: %s)",
+				cfg.Kafka.TradesTopic, cfg.Kafka.PositionsTopic, cfg.Kafka.ConsumerGroup)
+			if err := combinedConsumer.Start(ctx); err != nil {
+				log.Printf("Kafka combined consumer error: %v", err)
+			}
+		}()
+	} else {
+		go func() {
+			log.Printf("Starting Kafka consumer for topic: %s (group: %s)",
+				cfg.Kafka.TradesTopic, cfg.Kafka.ConsumerGroup)
+			if err := consumer.Start(ctx); err != nil {
+				log.Printf("Kafka consumer error: %v", err)
+			}
+		}()
+		go func() {
+			log.Printf("Starting Kafka positions consumer for topic: %s (group: %s-positions)",
+				cfg.Kafka.PositionsTopic, cfg.Kafka.ConsumerGroup)
+			if err := positionsConsumer.Start(ctx); err != nil {
+				log.Printf("Kafka positions consumer error: %v", err)
+			}
+		}()
+	}
 
 	// Create and start Kafka consumer for watchlist events
 	watchlistConsumer := kafka.NewWatchlistConsumer(
@@ -103,9 +178,37 @@ func main() {
 		}
 	}()
 
+	// Alert evaluator, run periodically and on demand through the API
+	evaluator := alerts.NewEvaluator(db)
+	evaluator.SetRSIMinDataPoints(cfg.Alerts.RSIMinDataPoints)
+	evaluator.SetMaxQuoteAge(cfg.Alerts.MaxQuoteAge)
+	evaluator.SetPriorityEscalation(models.PriorityEscalation{
+		HighAfter:     cfg.Alerts.EscalateHighAfter,
+		CriticalAfter: cfg.Alerts.EscalateCriticalAfter,
+	})
+	evaluator.SetBigDayAlert(db, cfg.Alerts.BigDayChange, cfg.Alerts.BigDayPct)
+	evaluator.SetRSIOversoldDefault(db, cfg.Alerts.RSIOversoldDefault)
+	if dispatcher != nil {
+		evaluator.SetNotifiers(dispatcher, db)
+	}
+	if cfg.Alerts.EvaluationInterval > 0 {
+		go func() {
+			log.Printf("Evaluating alert rules every %s", cfg.Alerts.EvaluationInterval)
+			if err := evaluator.Start(ctx, cfg.Alerts.EvaluationInterval); err != nil && err != context.Canceled {
+				log.Printf("Alert evaluator error: %v", err)
+			}
+		}()
+	}
+
 	// Set up HTTP handler and routes
 	handler := api.NewHandler(db, producer, redisClient)
-	router := api.SetupRoutes(handler)
+	handler.SetSymbolAliases(cfg.SymbolAliases)
+	handler.SetEvaluator(evaluator)
+	handler.SetDisplayPrecision(api.DisplayPrecision{
+		Quantity: int32(cfg.Server.QuantityPrecision),
+		Price:    int32(cfg.Server.PricePrecision),
+	})
+	router := api.SetupRoutes(handler, cfg.Server.APIKey)
 
 	// Create HTTP server
 	addr := cfg.Server.Host + ":" + cfg.Server.Port
@@ -144,6 +247,11 @@ func main() {
 	}
 
 	// Close Kafka consumers
+	if combinedConsumer != nil {
+		if err := combinedConsumer.Close(); err != nil {
+			log.Printf("Error closing Kafka combined consumer: %v", err)
+		}
+	}
 	if err := consumer.Close(); err != nil {
 		log.Printf("Error closing Kafka consumer: %v", err)
 	}
@@ -0,0 +1,2 @@
+-- Running realized P&L from partial closes on a still-open position
+ALTER TABLE positions ADD COLUMN IF NOT EXISTS realized_pnl DECIMAL(18, 4) DEFAULT 0;
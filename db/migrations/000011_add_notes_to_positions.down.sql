@@ -0,0 +1,2 @@
+ALTER TABLE positions DROP COLUMN IF EXISTS tags;
+ALTER TABLE positions DROP COLUMN IF EXISTS notes;
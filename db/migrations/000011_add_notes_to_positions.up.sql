@@ -0,0 +1,3 @@
+-- Free-form notes and tags on open positions
+ALTER TABLE positions ADD COLUMN IF NOT EXISTS notes TEXT;
+ALTER TABLE positions ADD COLUMN IF NOT EXISTS tags TEXT[] DEFAULT '{}';
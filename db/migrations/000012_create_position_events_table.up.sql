@@ -0,0 +1,13 @@
+-- Append-only audit of position lifecycle transitions detected between snapshots
+CREATE TABLE IF NOT EXISTS position_events (
+    id SERIAL PRIMARY KEY,
+    symbol VARCHAR(10) NOT NULL,
+    event_type VARCHAR(20) NOT NULL CHECK (event_type IN ('OPEN', 'ADD', 'PARTIAL_SELL', 'CLOSE')),
+    quantity DECIMAL(18, 8) NOT NULL,          -- Shares opened, added or sold
+    position_quantity DECIMAL(18, 8) NOT NULL, -- Shares held afterwards
+    price DECIMAL(18, 4) NOT NULL,
+    occurred_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_position_events_symbol_occurred_at ON position_events(symbol, occurred_at);
@@ -0,0 +1 @@
+ALTER TABLE raw_trades DROP COLUMN IF EXISTS extended_hours;
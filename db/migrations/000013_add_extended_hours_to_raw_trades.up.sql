@@ -0,0 +1,2 @@
+-- Flag trades executed outside the regular market session
+ALTER TABLE raw_trades ADD COLUMN IF NOT EXISTS extended_hours BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,14 @@
+-- Unrealized P&L of each open position, recorded on every positions snapshot or update
+CREATE TABLE IF NOT EXISTS position_pnl_history (
+    id SERIAL PRIMARY KEY,
+    symbol VARCHAR(10) NOT NULL,
+    quantity DECIMAL(18, 8) NOT NULL,
+    entry_price DECIMAL(18, 4) NOT NULL,
+    current_price DECIMAL(18, 4) NOT NULL,
+    unrealized_pnl DECIMAL(18, 4) NOT NULL,
+    unrealized_pnl_pct DECIMAL(10, 4) NOT NULL,
+    recorded_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_position_pnl_history_symbol_recorded_at ON position_pnl_history(symbol, recorded_at);
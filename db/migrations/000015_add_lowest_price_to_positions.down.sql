@@ -0,0 +1 @@
+ALTER TABLE positions DROP COLUMN IF EXISTS lowest_price;
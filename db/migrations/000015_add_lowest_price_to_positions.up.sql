@@ -0,0 +1,2 @@
+-- Lowest price seen while a position is open, for max drawdown on close
+ALTER TABLE positions ADD COLUMN IF NOT EXISTS lowest_price DECIMAL(18, 4);
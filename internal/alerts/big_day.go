@@ -0,0 +1,84 @@
+package alerts
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/shopspring/decimal"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+// PortfolioRepository provides the portfolio-wide daily change.
+// *database.DB satisfies it.
+type PortfolioRepository interface {
+	GetPortfolioDailyChange() (*models.PortfolioDailyChange, error)
+}
+
+// bigDay holds the thresholds for the portfolio "big day" alert
+type bigDay struct {
+	repo      PortfolioRepository
+	minChange decimal.Decimal // absolute dollar move, zero = unused
+	minPct    decimal.Decimal // absolute percent move, zero = unused
+
+	alertedOn string // date of the last alert, so it fires once per day
+}
+
+// SetBigDayAlert records a BIG_DAY alert when the portfolio's daily change
+// reaches minChange dollars or minPct percent in either direction. A zero
+// threshold is ignored; both zero disables the alert.
+func (e *Evaluator) SetBigDayAlert(repo PortfolioRepository, minChange, minPct float64) {
+	if minChange <= 0 && minPct <= 0 {
+		e.bigDay = nil
+		return
+	}
+	e.bigDay = &bigDay{
+		repo:      repo,
+		minChange: decimal.NewFromFloat(minChange),
+		minPct:    decimal.NewFromFloat(minPct),
+	}
+}
+
+// EvaluatePortfolio checks the portfolio's daily change against the big day
+// thresholds and returns the alert if one fired. It fires at most once per day.
+func (e *Evaluator) EvaluatePortfolio() (*models.AlertHistory, error) {
+	b := e.bigDay
+	if b == nil {
+		return nil, nil
+	}
+
+	now := e.now()
+	today := now.Format("2006-01-02")
+	if b.alertedOn == today {
+		return nil, nil
+	}
+
+	c, err := b.repo.GetPortfolioDailyChange()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load portfolio daily change: %w", err)
+	}
+
+	dollars := b.minChange.IsPositive() && c.Change.Abs().GreaterThanOrEqual(b.minChange)
+	pct := b.minPct.IsPositive() && c.ChangePct.Abs().GreaterThanOrEqual(b.minPct)
+	if !dollars && !pct {
+		return nil, nil
+	}
+
+	direction := "up"
+	if c.Change.IsNegative() {
+		direction = "down"
+	}
+	h := &models.AlertHistory{
+		Symbol:         models.PortfolioSymbol,
+		RuleType:       models.RuleTypeBigDay,
+		TriggeredValue: c.Change.Round(2),
+		Message: fmt.Sprintf("Portfolio %s $%s (%s%%) today, now $%s",
+			direction, c.Change.Abs().StringFixed(2), c.ChangePct.StringFixed(2), c.Value.StringFixed(2)),
+		TriggeredAt: now,
+	}
+	if err := e.repo.CreateAlertHistory(h); err != nil {
+		return nil, fmt.Errorf("failed to record big day alert: %w", err)
+	}
+	b.alertedOn = today
+	log.Printf("Big day: %s", h.Message)
+	return h, nil
+}
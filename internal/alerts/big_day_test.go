@@ -0,0 +1,89 @@
+package alerts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+// mockPortfolio serves a fixed daily change
+type mockPortfolio struct {
+	change *models.PortfolioDailyChange
+}
+
+func (m *mockPortfolio) GetPortfolioDailyChange() (*models.PortfolioDailyChange, error) {
+	return m.change, nil
+}
+
+func dailyChange(previous, value string) *models.PortfolioDailyChange {
+	prev, cur := decimal.RequireFromString(previous), decimal.RequireFromString(value)
+	change := cur.Sub(prev)
+	return &models.PortfolioDailyChange{
+		Value:         cur,
+		PreviousValue: prev,
+		Change:        change,
+		ChangePct:     change.Div(prev).Mul(decimal.NewFromInt(100)).Round(4),
+	}
+}
+
+func TestEvaluatePortfolio_AlertsOnBigDay(t *testing.T) {
+	now := time.Date(2026, 4, 1, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		minChange float64
+		minPct    float64
+		change    *models.PortfolioDailyChange
+		message   string
+	}{
+		{"dollar loss", 1000, 0, dailyChange("50000", "48500"), "Portfolio down $1500.00 (-3.00%) today, now $48500.00"},
+		{"percent gain", 0, 2.5, dailyChange("20000", "20600"), "Portfolio up $600.00 (3.00%) today, now $20600.00"},
+		{"below both", 1000, 5, dailyChange("20000", "20600"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMockRepo()
+			e := NewEvaluator(repo)
+			e.now = func() time.Time { return now }
+			e.SetBigDayAlert(&mockPortfolio{change: tt.change}, tt.minChange, tt.minPct)
+
+			h, err := e.EvaluatePortfolio()
+			require.NoError(t, err)
+			if tt.message == "" {
+				assert.Nil(t, h)
+				assert.Empty(t, repo.history)
+				return
+			}
+			require.NotNil(t, h)
+			assert.Equal(t, models.RuleTypeBigDay, h.RuleType)
+			assert.Equal(t, models.PortfolioSymbol, h.Symbol)
+			assert.Equal(t, tt.message, h.Message)
+			assert.Equal(t, []*models.AlertHistory{h}, repo.history)
+		})
+	}
+}
+
+func TestEvaluatePortfolio_FiresOncePerDay(t *testing.T) {
+	now := time.Date(2026, 4, 1, 15, 0, 0, 0, time.UTC)
+	repo := newMockRepo()
+	e := NewEvaluator(repo)
+	e.now = func() time.Time { return now }
+	e.SetBigDayAlert(&mockPortfolio{change: dailyChange("10000", "9000")}, 500, 0)
+
+	for i := 0; i < 3; i++ {
+		_, err := e.EvaluatePortfolio()
+		require.NoError(t, err)
+	}
+	assert.Len(t, repo.history, 1)
+
+	// Re-arms the next day
+	now = now.Add(24 * time.Hour)
+	h, err := e.EvaluatePortfolio()
+	require.NoError(t, err)
+	assert.NotNil(t, h)
+	assert.Len(t, repo.history, 2)
+}
@@ -0,0 +1,379 @@
+// Package alerts evaluates alert rules against current market data and records
+// the ones that fire.
+package alerts
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+	"github.com/trogers1052/stock-alert-system/internal/notify"
+)
+
+// errUnsupportedRuleType marks a rule whose type can't be evaluated from stored data
+var errUnsupportedRuleType = errors.New("unsupported rule type")
+
+// errStaleQuote marks a price rule skipped because the stock's quote is too old
+var errStaleQuote = errors.New("stale quote")
+
+// unsupportedRuleSkips counts rules skipped for an unsupported type, by rule type.
+// It is published through expvar and served at /api/v1/debug/vars.
+var unsupportedRuleSkips = expvar.NewMap("alerts_unsupported_rule_skips")
+
+// UnsupportedRuleSkips returns how many times rules of ruleType were skipped
+// because the evaluator can't check them
+func UnsupportedRuleSkips(ruleType string) int64 {
+	counter, ok := unsupportedRuleSkips.Get(ruleType).(*expvar.Int)
+	if !ok {
+		return 0
+	}
+	return counter.Value()
+}
+
+// Repository defines the database operations the evaluator needs.
+// *database.DB satisfies it.
+type Repository interface {
+	GetEnabledAlertRules() ([]*models.AlertRule, error)
+	GetEnabledAlertRulesBySymbol(symbol string) ([]*models.AlertRule, error)
+	GetStock(symbol string) (*models.Stock, error)
+	GetLatestRSIReading(symbol string) (*models.RSIReading, error)
+	MarkAlertTriggered(id int) error
+	CreateAlertHistory(h *models.AlertHistory) error
+}
+
+// Evaluator checks enabled alert rules and records those whose condition is met
+type Evaluator struct {
+	repo Repository
+
+	// rsiMinDataPoints skips RSI rules backed by too few daily candles
+	rsiMinDataPoints int
+	escalation       models.PriorityEscalation
+
+	// maxQuoteAge skips price rules whose quote is older than this (0 = no limit)
+	maxQuoteAge time.Duration
+
+	// bigDay alerts on large portfolio-wide daily moves when set
+	bigDay *bigDay
+
+	// rsiOversold alerts on monitored stocks whose RSI is oversold when set
+	rsiOversold *rsiOversold
+
+	// notifiers sends fired alerts on the channels it has a notifier for, when set
+	notifiers     *notify.Dispatcher
+	notifications notify.SentMarker
+
+	now func() time.Time
+}
+
+// NewEvaluator creates an evaluator backed by repo
+func NewEvaluator(repo Repository) *Evaluator {
+	return &Evaluator{repo: repo, now: time.Now}
+}
+
+// SetRSIMinDataPoints skips RSI rules when fewer than n daily candles backed the
+// latest reading (0 = no minimum)
+func (e *Evaluator) SetRSIMinDataPoints(n int) {
+	e.rsiMinDataPoints = n
+}
+
+// SetMaxQuoteAge skips price rules when the stock's quote was last updated more
+// than maxAge ago, so alerts don't fire on outdated prices (0 = no limit)
+func (e *Evaluator) SetMaxQuoteAge(maxAge time.Duration) {
+	e.maxQuoteAge = maxAge
+}
+
+// SetNotifiers sends alerts through d as they fire, picking the notifier by the
+// rule's notification channel, and marks each one sent in repo once it's
+// delivered. Alerts on channels d has no notifier for aren't sent.
+func (e *Evaluator) SetNotifiers(d *notify.Dispatcher, repo notify.SentMarker) {
+	e.notifiers = d
+	e.notifications = repo
+}
+
+// SetPriorityEscalation raises the priority reported for rules that keep firing
+func (e *Evaluator) SetPriorityEscalation(p models.PriorityEscalation) {
+	e.escalation = p
+}
+
+// Start evaluates every enabled rule, and the big day and monitored stock RSI
+// alerts if set, each interval until ctx is cancelled
+func (e *Evaluator) Start(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if _, err := e.EvaluateAll(); err != nil {
+				log.Printf("Alert evaluation failed: %v", err)
+			}
+			if _, err := e.EvaluatePortfolio(); err != nil {
+				log.Printf("Portfolio alert evaluation failed: %v", err)
+			}
+			if _, err := e.EvaluateMonitoredStocks(); err != nil {
+				log.Printf("Monitored stock alert evaluation failed: %v", err)
+			}
+		}
+	}
+}
+
+// EvaluateAll checks every enabled rule and returns the alerts that fired
+func (e *Evaluator) EvaluateAll() ([]*models.AlertHistory, error) {
+	rules, err := e.repo.GetEnabledAlertRules()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load alert rules: %w", err)
+	}
+	fired, _ := e.evaluate(rules, make(map[string]*symbolData))
+	return fired, nil
+}
+
+// EvaluateSymbol checks the enabled rules for one symbol and returns the alerts that fired
+func (e *Evaluator) EvaluateSymbol(symbol string) ([]*models.AlertHistory, error) {
+	rules, err := e.repo.GetEnabledAlertRulesBySymbol(symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load alert rules for %s: %w", symbol, err)
+	}
+	fired, _ := e.evaluate(rules, make(map[string]*symbolData))
+	return fired, nil
+}
+
+// EvaluationResult summarizes one evaluation pass
+type EvaluationResult struct {
+	Checked    int `json:"checked"`                // enabled rules considered
+	Fired      int `json:"fired"`                  // alerts recorded
+	Suppressed int `json:"suppressed_by_cooldown"` // rules still in their cooldown
+	Notified   int `json:"notified"`               // fired alerts that were sent
+}
+
+// RunEvaluation checks the enabled rules for stocks in one pass, using the given
+// stocks as their quotes, and summarizes what happened
+func (e *Evaluator) RunEvaluation(stocks []*models.Stock) (EvaluationResult, error) {
+	rules, err := e.repo.GetEnabledAlertRules()
+	if err != nil {
+		return EvaluationResult{}, fmt.Errorf("failed to load alert rules: %w", err)
+	}
+
+	data := make(map[string]*symbolData, len(stocks))
+	for _, stock := range stocks {
+		data[stock.Symbol] = &symbolData{stock: stock}
+	}
+	var selected []*models.AlertRule
+	for _, rule := range rules {
+		if _, ok := data[rule.Symbol]; ok {
+			selected = append(selected, rule)
+		}
+	}
+
+	_, result := e.evaluate(selected, data)
+	return result, nil
+}
+
+// evaluate checks rules, loading each symbol's data into data at most once. A
+// rule whose data can't be loaded is logged and skipped so one bad symbol
+// doesn't stop the rest.
+func (e *Evaluator) evaluate(rules []*models.AlertRule, data map[string]*symbolData) ([]*models.AlertHistory, EvaluationResult) {
+	now := e.now()
+	result := EvaluationResult{Checked: len(rules)}
+
+	var fired []*models.AlertHistory
+	for _, rule := range rules {
+		if !rule.CanTrigger(now) {
+			result.Suppressed++
+			continue
+		}
+
+		d, ok := data[rule.Symbol]
+		if !ok {
+			d = &symbolData{}
+			data[rule.Symbol] = d
+		}
+
+		value, met, err := e.check(rule, d, now)
+		if err != nil {
+			if errors.Is(err, errUnsupportedRuleType) {
+				unsupportedRuleSkips.Add(rule.RuleType, 1)
+			}
+			log.Printf("Warning: skipping alert rule %d (%s %s): %v", rule.ID, rule.Symbol, rule.RuleType, err)
+			continue
+		}
+		if !met {
+			continue
+		}
+
+		h, err := e.fire(rule, value, now)
+		if err != nil {
+			log.Printf("Warning: failed to record alert rule %d for %s: %v", rule.ID, rule.Symbol, err)
+			continue
+		}
+		fired = append(fired, h)
+		if h.NotificationSent {
+			result.Notified++
+		}
+	}
+	result.Fired = len(fired)
+	return fired, result
+}
+
+// symbolData caches what's been loaded for a symbol during one evaluation pass
+type symbolData struct {
+	stock *models.Stock
+	rsi   *models.RSIReading
+}
+
+// check returns the observed value for rule and whether its condition is met.
+// Rule types that can't be evaluated from stored data return errUnsupportedRuleType,
+// and price rules on a quote older than the max quote age return errStaleQuote.
+func (e *Evaluator) check(rule *models.AlertRule, d *symbolData, now time.Time) (decimal.Decimal, bool, error) {
+	switch rule.RuleType {
+	case models.RuleTypePriceTarget:
+		stock, err := e.stock(rule.Symbol, d)
+		if err != nil {
+			return decimal.Zero, false, err
+		}
+		if e.maxQuoteAge > 0 && now.Sub(stock.LastUpdated) > e.maxQuoteAge {
+			return decimal.Zero, false, fmt.Errorf("%w: last updated %s", errStaleQuote, stock.LastUpdated.Format(time.RFC3339))
+		}
+		price := decimal.NewFromFloat(stock.CurrentPrice)
+		return price, stock.CurrentPrice > 0 && rule.ConditionMet(price), nil
+
+	case models.RuleTypeRSIOversold, models.RuleTypeRSIOverbought:
+		if d.rsi == nil {
+			r, err := e.repo.GetLatestRSIReading(rule.Symbol)
+			if err != nil {
+				return decimal.Zero, false, err
+			}
+			d.rsi = r
+		}
+		return d.rsi.Value, rule.RSIConditionMet(d.rsi, e.rsiMinDataPoints), nil
+
+	case models.RuleTypeVolumeSpike:
+		// ConditionValue is a multiple of average volume, e.g. 2 for twice the average
+		stock, err := e.stock(rule.Symbol, d)
+		if err != nil {
+			return decimal.Zero, false, err
+		}
+		if stock.AverageVolume <= 0 {
+			return decimal.Zero, false, nil
+		}
+		ratio := decimal.NewFromInt(stock.Volume).Div(decimal.NewFromInt(stock.AverageVolume)).Round(2)
+		return ratio, rule.ConditionMet(ratio), nil
+	}
+	return decimal.Zero, false, fmt.Errorf("%w: %s", errUnsupportedRuleType, rule.RuleType)
+}
+
+func (e *Evaluator) stock(symbol string, d *symbolData) (*models.Stock, error) {
+	if d.stock == nil {
+		s, err := e.repo.GetStock(symbol)
+		if err != nil {
+			return nil, err
+		}
+		d.stock = s
+	}
+	return d.stock, nil
+}
+
+// fire marks rule triggered and records the alert
+func (e *Evaluator) fire(rule *models.AlertRule, value decimal.Decimal, now time.Time) (*models.AlertHistory, error) {
+	if err := e.repo.MarkAlertTriggered(rule.ID); err != nil {
+		return nil, err
+	}
+	rule.TriggeredCount++
+	rule.LastTriggeredAt = &now
+
+	h := &models.AlertHistory{
+		AlertRuleID:         rule.ID,
+		Symbol:              rule.Symbol,
+		RuleType:            rule.RuleType,
+		TriggeredValue:      value,
+		Message:             e.message(rule, value),
+		NotificationChannel: rule.NotificationChannel,
+		Priority:            rule.EffectivePriority(e.escalation),
+		TriggeredAt:         now,
+	}
+	if err := e.repo.CreateAlertHistory(h); err != nil {
+		return nil, err
+	}
+	e.notify(h)
+	return h, nil
+}
+
+// notify sends h through the notifier for its channel. A failed send is logged
+// and h stays unsent.
+func (e *Evaluator) notify(h *models.AlertHistory) {
+	if e.notifiers == nil {
+		return
+	}
+	err := e.notifiers.Deliver(context.Background(), h, e.notifications)
+	if err != nil && !errors.Is(err, notify.ErrNoNotifier) {
+		log.Printf("Warning: failed to send alert %d for %s via %s: %v", h.ID, h.Symbol, h.NotificationChannel, err)
+	}
+}
+
+// messageData is what a rule's text/template message can refer to, e.g.
+// "{{.Symbol}} at {{.TriggeredValue}}"
+type messageData struct {
+	Symbol         string
+	RuleType       string
+	Comparison     string
+	Priority       string
+	TriggeredValue decimal.Decimal
+	ConditionValue decimal.Decimal
+}
+
+// message renders the rule's template, or builds a default message when the rule
+// has none. Templates containing "{{" are text/templates over messageData and
+// fall back to the default message when they don't parse or render; others
+// replace {symbol}, {value}, {threshold} and {priority}.
+func (e *Evaluator) message(rule *models.AlertRule, value decimal.Decimal) string {
+	priority := rule.EffectivePriority(e.escalation)
+	fallback := fmt.Sprintf("[%s] %s %s: %s %s %s",
+		strings.ToUpper(priority), rule.Symbol, rule.RuleType,
+		value.String(), strings.ToLower(rule.Comparison), rule.ConditionValue.String())
+	if rule.MessageTemplate == "" {
+		return fallback
+	}
+
+	if strings.Contains(rule.MessageTemplate, "{{") {
+		msg, err := renderMessage(rule.MessageTemplate, messageData{
+			Symbol:         rule.Symbol,
+			RuleType:       rule.RuleType,
+			Comparison:     rule.Comparison,
+			Priority:       priority,
+			TriggeredValue: value,
+			ConditionValue: rule.ConditionValue,
+		})
+		if err != nil {
+			log.Printf("Warning: alert rule %d message template: %v; using the default message", rule.ID, err)
+			return fallback
+		}
+		return msg
+	}
+
+	return strings.NewReplacer(
+		"{symbol}", rule.Symbol,
+		"{value}", value.String(),
+		"{threshold}", rule.ConditionValue.String(),
+		"{priority}", priority,
+	).Replace(rule.MessageTemplate)
+}
+
+func renderMessage(text string, data messageData) (string, error) {
+	tmpl, err := template.New("message").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
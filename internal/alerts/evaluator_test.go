@@ -0,0 +1,389 @@
+package alerts
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+	"github.com/trogers1052/stock-alert-system/internal/notify"
+)
+
+// mockRepo implements Repository with in-memory rules and market data
+type mockRepo struct {
+	rules   []*models.AlertRule
+	stocks  map[string]*models.Stock
+	rsi     map[string]*models.RSIReading
+	marked  []int
+	history []*models.AlertHistory
+}
+
+func newMockRepo() *mockRepo {
+	return &mockRepo{
+		stocks: make(map[string]*models.Stock),
+		rsi:    make(map[string]*models.RSIReading),
+	}
+}
+
+func (m *mockRepo) GetEnabledAlertRules() ([]*models.AlertRule, error) {
+	return m.rules, nil
+}
+
+func (m *mockRepo) GetEnabledAlertRulesBySymbol(symbol string) ([]*models.AlertRule, error) {
+	var rules []*models.AlertRule
+	for _, r := range m.rules {
+		if r.Symbol == symbol {
+			rules = append(rules, r)
+		}
+	}
+	return rules, nil
+}
+
+func (m *mockRepo) GetStock(symbol string) (*models.Stock, error) {
+	if s, ok := m.stocks[symbol]; ok {
+		return s, nil
+	}
+	return nil, errors.New("stock not found: " + symbol)
+}
+
+func (m *mockRepo) GetLatestRSIReading(symbol string) (*models.RSIReading, error) {
+	if r, ok := m.rsi[symbol]; ok {
+		return r, nil
+	}
+	return nil, errors.New("no RSI data found for " + symbol)
+}
+
+func (m *mockRepo) MarkAlertTriggered(id int) error {
+	m.marked = append(m.marked, id)
+	return nil
+}
+
+func (m *mockRepo) CreateAlertHistory(h *models.AlertHistory) error {
+	h.ID = len(m.history) + 1
+	m.history = append(m.history, h)
+	return nil
+}
+
+func rule(id int, symbol, ruleType, comparison, value string) *models.AlertRule {
+	return &models.AlertRule{
+		ID:             id,
+		Symbol:         symbol,
+		RuleType:       ruleType,
+		Comparison:     comparison,
+		ConditionValue: decimal.RequireFromString(value),
+		Enabled:        true,
+	}
+}
+
+func TestEvaluateAll_FiresMatchingRules(t *testing.T) {
+	repo := newMockRepo()
+	repo.stocks["AAPL"] = &models.Stock{Symbol: "AAPL", CurrentPrice: 201.5, Volume: 3_000_000, AverageVolume: 1_000_000}
+	repo.rsi["AAPL"] = &models.RSIReading{Symbol: "AAPL", Value: decimal.NewFromInt(27), DataPoints: 200}
+	repo.rules = []*models.AlertRule{
+		rule(1, "AAPL", models.RuleTypePriceTarget, models.ComparisonAbove, "200"),
+		rule(2, "AAPL", models.RuleTypePriceTarget, models.ComparisonBelow, "150"),
+		rule(3, "AAPL", models.RuleTypeRSIOversold, models.ComparisonBelow, "30"),
+		rule(4, "AAPL", models.RuleTypeRSIOverbought, models.ComparisonAbove, "70"),
+		rule(5, "AAPL", models.RuleTypeVolumeSpike, models.ComparisonAbove, "2.5"),
+	}
+
+	fired, err := NewEvaluator(repo).EvaluateAll()
+	require.NoError(t, err)
+
+	assert.Equal(t, []int{1, 3, 5}, repo.marked)
+	require.Len(t, fired, 3)
+	assert.Equal(t, "201.5", fired[0].TriggeredValue.String())
+	assert.Equal(t, "27", fired[1].TriggeredValue.String())
+	assert.Equal(t, "3", fired[2].TriggeredValue.String())
+	assert.Equal(t, fired, repo.history)
+}
+
+func TestEvaluateSymbol_OnlyChecksThatSymbol(t *testing.T) {
+	repo := newMockRepo()
+	repo.stocks["AAPL"] = &models.Stock{Symbol: "AAPL", CurrentPrice: 210}
+	repo.stocks["MSFT"] = &models.Stock{Symbol: "MSFT", CurrentPrice: 500}
+	repo.rules = []*models.AlertRule{
+		rule(1, "AAPL", models.RuleTypePriceTarget, models.ComparisonAbove, "200"),
+		rule(2, "MSFT", models.RuleTypePriceTarget, models.ComparisonAbove, "400"),
+	}
+
+	fired, err := NewEvaluator(repo).EvaluateSymbol("MSFT")
+	require.NoError(t, err)
+	require.Len(t, fired, 1)
+	assert.Equal(t, "MSFT", fired[0].Symbol)
+	assert.Equal(t, []int{2}, repo.marked)
+}
+
+func TestEvaluate_HonorsCooldown(t *testing.T) {
+	now := time.Date(2026, 4, 1, 15, 0, 0, 0, time.UTC)
+	recent := now.Add(-10 * time.Minute)
+	old := now.Add(-2 * time.Hour)
+
+	repo := newMockRepo()
+	repo.stocks["AAPL"] = &models.Stock{Symbol: "AAPL", CurrentPrice: 210}
+	cooling := rule(1, "AAPL", models.RuleTypePriceTarget, models.ComparisonAbove, "200")
+	cooling.CooldownMinutes = 60
+	cooling.LastTriggeredAt = &recent
+	expired := rule(2, "AAPL", models.RuleTypePriceTarget, models.ComparisonAbove, "200")
+	expired.CooldownMinutes = 60
+	expired.LastTriggeredAt = &old
+	repo.rules = []*models.AlertRule{cooling, expired}
+
+	e := NewEvaluator(repo)
+	e.now = func() time.Time { return now }
+
+	_, err := e.EvaluateAll()
+	require.NoError(t, err)
+	assert.Equal(t, []int{2}, repo.marked)
+
+	// Having just fired, the second rule is now cooling down too
+	_, err = e.EvaluateAll()
+	require.NoError(t, err)
+	assert.Equal(t, []int{2}, repo.marked)
+}
+
+func TestEvaluate_SkipsUnreliableRSIAndMissingData(t *testing.T) {
+	repo := newMockRepo()
+	repo.rsi["NEWCO"] = &models.RSIReading{Symbol: "NEWCO", Value: decimal.NewFromInt(12), DataPoints: 5}
+	repo.rules = []*models.AlertRule{
+		rule(1, "NEWCO", models.RuleTypeRSIOversold, models.ComparisonBelow, "30"),
+		rule(2, "GONE", models.RuleTypePriceTarget, models.ComparisonAbove, "1"),
+		rule(3, "NEWCO", models.RuleTypeSupportBounce, models.ComparisonAbove, "1"),
+	}
+
+	e := NewEvaluator(repo)
+	e.SetRSIMinDataPoints(15)
+
+	fired, err := e.EvaluateAll()
+	require.NoError(t, err)
+	assert.Empty(t, fired)
+	assert.Empty(t, repo.marked)
+}
+
+func TestEvaluate_RendersMessage(t *testing.T) {
+	repo := newMockRepo()
+	repo.stocks["AAPL"] = &models.Stock{Symbol: "AAPL", CurrentPrice: 210}
+	templated := rule(1, "AAPL", models.RuleTypePriceTarget, models.ComparisonAbove, "200")
+	templated.MessageTemplate = "{symbol} hit {value} (target {threshold}, {priority})"
+	templated.TriggeredCount = 2
+	plain := rule(2, "AAPL", models.RuleTypePriceTarget, models.ComparisonAbove, "205")
+	repo.rules = []*models.AlertRule{templated, plain}
+
+	e := NewEvaluator(repo)
+	e.SetPriorityEscalation(models.PriorityEscalation{HighAfter: 3})
+
+	fired, err := e.EvaluateAll()
+	require.NoError(t, err)
+	require.Len(t, fired, 2)
+	assert.Equal(t, "AAPL hit 210 (target 200, high)", fired[0].Message)
+	assert.Equal(t, "[NORMAL] AAPL PRICE_TARGET: 210 above 205", fired[1].Message)
+}
+
+func TestEvaluate_PriceTargetRespectsCooldownWhileAboveThreshold(t *testing.T) {
+	now := time.Date(2026, 4, 1, 15, 0, 0, 0, time.UTC)
+
+	repo := newMockRepo()
+	repo.stocks["AAPL"] = &models.Stock{Symbol: "AAPL", CurrentPrice: 210}
+	r := rule(1, "AAPL", models.RuleTypePriceTarget, models.ComparisonAbove, "200")
+	r.CooldownMinutes = 30
+	repo.rules = []*models.AlertRule{r}
+
+	e := NewEvaluator(repo)
+	e.now = func() time.Time { return now }
+
+	fired, err := e.EvaluateAll()
+	require.NoError(t, err)
+	assert.Len(t, fired, 1)
+
+	// Still above target inside the window: no repeat
+	now = now.Add(29 * time.Minute)
+	fired, err = e.EvaluateAll()
+	require.NoError(t, err)
+	assert.Empty(t, fired)
+
+	// Window elapsed: fires again
+	now = now.Add(time.Minute)
+	fired, err = e.EvaluateAll()
+	require.NoError(t, err)
+	assert.Len(t, fired, 1)
+	assert.Equal(t, []int{1, 1}, repo.marked)
+}
+
+func TestEvaluate_SkipsAndCountsUnsupportedRuleTypes(t *testing.T) {
+	repo := newMockRepo()
+	repo.stocks["AAPL"] = &models.Stock{Symbol: "AAPL", CurrentPrice: 210}
+	repo.rules = []*models.AlertRule{
+		rule(1, "AAPL", "MOON_PHASE", models.ComparisonAbove, "1"),
+		rule(2, "AAPL", models.RuleTypePriceTarget, models.ComparisonAbove, "200"),
+		rule(3, "AAPL", "MOON_PHASE", models.ComparisonBelow, "1"),
+	}
+	before := UnsupportedRuleSkips("MOON_PHASE")
+
+	fired, err := NewEvaluator(repo).EvaluateAll()
+	require.NoError(t, err)
+
+	// The bogus rules are skipped without stopping the rest of the pass
+	require.Len(t, fired, 1)
+	assert.Equal(t, []int{2}, repo.marked)
+	assert.Equal(t, before+2, UnsupportedRuleSkips("MOON_PHASE"))
+}
+
+func TestEvaluate_SkipsPriceRulesOnStaleQuotes(t *testing.T) {
+	now := time.Date(2026, 4, 1, 15, 0, 0, 0, time.UTC)
+
+	repo := newMockRepo()
+	repo.stocks["AAPL"] = &models.Stock{Symbol: "AAPL", CurrentPrice: 210, LastUpdated: now.Add(-2 * time.Hour)}
+	repo.stocks["MSFT"] = &models.Stock{Symbol: "MSFT", CurrentPrice: 410, LastUpdated: now.Add(-5 * time.Minute)}
+	repo.rules = []*models.AlertRule{
+		rule(1, "AAPL", models.RuleTypePriceTarget, models.ComparisonAbove, "200"),
+		rule(2, "MSFT", models.RuleTypePriceTarget, models.ComparisonAbove, "400"),
+	}
+
+	e := NewEvaluator(repo)
+	e.now = func() time.Time { return now }
+	e.SetMaxQuoteAge(15 * time.Minute)
+
+	fired, err := e.EvaluateAll()
+	require.NoError(t, err)
+
+	// The two-hour-old AAPL quote is ignored even though it's above target
+	require.Len(t, fired, 1)
+	assert.Equal(t, "MSFT", fired[0].Symbol)
+	assert.Equal(t, []int{2}, repo.marked)
+
+	// Without a limit the stale quote still fires
+	e.SetMaxQuoteAge(0)
+	fired, err = e.EvaluateAll()
+	require.NoError(t, err)
+	require.Len(t, fired, 2)
+}
+
+// mockNotificationRepo records which alerts were marked sent
+type mockNotificationRepo struct {
+	sent []int
+}
+
+func (m *mockNotificationRepo) MarkNotificationSent(id int) error {
+	m.sent = append(m.sent, id)
+	return nil
+}
+
+func TestEvaluate_SendsTelegramNotifications(t *testing.T) {
+	var messages []string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			ChatID string `json:"chat_id"`
+			Text   string `json:"text"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "/bottoken/sendMessage", r.URL.Path)
+		assert.Equal(t, "42", body.ChatID)
+		messages = append(messages, body.Text)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	repo := newMockRepo()
+	repo.stocks["AAPL"] = &models.Stock{Symbol: "AAPL", CurrentPrice: 210}
+	telegram := rule(1, "AAPL", models.RuleTypePriceTarget, models.ComparisonAbove, "200")
+	telegram.NotificationChannel = models.ChannelTelegram
+	telegram.MessageTemplate = "{symbol} hit {value}"
+	email := rule(2, "AAPL", models.RuleTypePriceTarget, models.ComparisonAbove, "200")
+	email.NotificationChannel = models.ChannelEmail
+	repo.rules = []*models.AlertRule{telegram, email}
+
+	notifications := &mockNotificationRepo{}
+	e := NewEvaluator(repo)
+	e.SetNotifiers(notify.NewDispatcher(notify.NewTelegramNotifier(server.URL, "token", "42")), notifications)
+
+	fired, err := e.EvaluateAll()
+	require.NoError(t, err)
+	require.Len(t, fired, 2)
+
+	// Only the telegram rule's alert is sent, and it's marked sent after the 200
+	assert.Equal(t, []string{"AAPL hit 210"}, messages)
+	assert.Equal(t, []int{fired[0].ID}, notifications.sent)
+	assert.True(t, fired[0].NotificationSent)
+	assert.False(t, fired[1].NotificationSent)
+
+	// A failed send leaves the alert unsent
+	status = http.StatusInternalServerError
+	fired, err = e.EvaluateAll()
+	require.NoError(t, err)
+	require.Len(t, fired, 2)
+	assert.Len(t, messages, 2)
+	assert.Len(t, notifications.sent, 1)
+	assert.False(t, fired[0].NotificationSent)
+}
+
+func TestEvaluate_RendersTextTemplates(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{"valid template", "{{.Symbol}} {{.RuleType}} at {{.TriggeredValue}} vs {{.ConditionValue}} ({{.Priority}})",
+			"AAPL PRICE_TARGET at 210 vs 200 (normal)"},
+		{"empty template", "", "[NORMAL] AAPL PRICE_TARGET: 210 above 200"},
+		{"unknown field", "{{.Symbol}} hit {{.Price}}", "[NORMAL] AAPL PRICE_TARGET: 210 above 200"},
+		{"unparseable template", "{{.Symbol", "[NORMAL] AAPL PRICE_TARGET: 210 above 200"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMockRepo()
+			repo.stocks["AAPL"] = &models.Stock{Symbol: "AAPL", CurrentPrice: 210}
+			r := rule(1, "AAPL", models.RuleTypePriceTarget, models.ComparisonAbove, "200")
+			r.MessageTemplate = tt.template
+			repo.rules = []*models.AlertRule{r}
+
+			fired, err := NewEvaluator(repo).EvaluateAll()
+			require.NoError(t, err)
+			require.Len(t, fired, 1)
+			assert.Equal(t, tt.expected, fired[0].Message)
+		})
+	}
+}
+
+func TestRunEvaluation_SummarizesThePass(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	now := time.Date(2026, 4, 1, 15, 0, 0, 0, time.UTC)
+	recent := now.Add(-10 * time.Minute)
+
+	repo := newMockRepo()
+	above := rule(1, "AAPL", models.RuleTypePriceTarget, models.ComparisonAbove, "200")
+	above.NotificationChannel = models.ChannelTelegram
+	below := rule(2, "AAPL", models.RuleTypePriceTarget, models.ComparisonBelow, "150")
+	cooling := rule(3, "AAPL", models.RuleTypePriceTarget, models.ComparisonAbove, "100")
+	cooling.CooldownMinutes = 60
+	cooling.LastTriggeredAt = &recent
+	email := rule(4, "MSFT", models.RuleTypePriceTarget, models.ComparisonAbove, "400")
+	email.NotificationChannel = models.ChannelEmail
+	notGiven := rule(5, "TSLA", models.RuleTypePriceTarget, models.ComparisonAbove, "1")
+	repo.rules = []*models.AlertRule{above, below, cooling, email, notGiven}
+
+	e := NewEvaluator(repo)
+	e.now = func() time.Time { return now }
+	e.SetNotifiers(notify.NewDispatcher(notify.NewTelegramNotifier(server.URL, "token", "42")), &mockNotificationRepo{})
+
+	// Quotes come from the given stocks, not the repository
+	result, err := e.RunEvaluation([]*models.Stock{
+		{Symbol: "AAPL", CurrentPrice: 210},
+		{Symbol: "MSFT", CurrentPrice: 500},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, EvaluationResult{Checked: 4, Fired: 2, Suppressed: 1, Notified: 1}, result)
+	assert.Equal(t, []int{1, 4}, repo.marked)
+}
@@ -0,0 +1,85 @@
+package alerts
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/shopspring/decimal"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+// MonitoredStockRepository provides the monitored stocks whose RSI is oversold.
+// *database.DB satisfies it.
+type MonitoredStockRepository interface {
+	GetStocksRSIOversold(defaultThreshold float64) ([]*models.MonitoredStock, error)
+}
+
+// rsiOversold holds the settings for oversold alerts on monitored stocks
+type rsiOversold struct {
+	repo             MonitoredStockRepository
+	defaultThreshold float64 // for stocks without their own threshold (0 = none)
+
+	alertedOn map[string]string // symbol -> date of its last alert, so each fires once per day
+}
+
+// SetRSIOversoldDefault records an RSI_OVERSOLD alert for monitored stocks with
+// alert_on_rsi_oversold set once their latest RSI reaches their oversold
+// threshold. Stocks without a threshold use defaultThreshold; when it is zero
+// or less only stocks with their own threshold alert.
+func (e *Evaluator) SetRSIOversoldDefault(repo MonitoredStockRepository, defaultThreshold float64) {
+	e.rsiOversold = &rsiOversold{
+		repo:             repo,
+		defaultThreshold: defaultThreshold,
+		alertedOn:        make(map[string]string),
+	}
+}
+
+// EvaluateMonitoredStocks checks monitored stocks for oversold RSI and returns
+// the alerts that fired. Each stock alerts at most once per day, and readings
+// backed by fewer than the RSI minimum data points are skipped.
+func (e *Evaluator) EvaluateMonitoredStocks() ([]*models.AlertHistory, error) {
+	o := e.rsiOversold
+	if o == nil {
+		return nil, nil
+	}
+
+	stocks, err := o.repo.GetStocksRSIOversold(o.defaultThreshold)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load RSI oversold stocks: %w", err)
+	}
+
+	now := e.now()
+	today := now.Format("2006-01-02")
+
+	var fired []*models.AlertHistory
+	for _, stock := range stocks {
+		if o.alertedOn[stock.Symbol] == today {
+			continue
+		}
+		reading, err := e.repo.GetLatestRSIReading(stock.Symbol)
+		if err != nil {
+			log.Printf("Warning: skipping RSI oversold alert for %s: %v", stock.Symbol, err)
+			continue
+		}
+		if !reading.IsReliable(e.rsiMinDataPoints) {
+			continue
+		}
+
+		threshold := decimal.NewFromFloat(stock.EffectiveRSIOversoldThreshold(o.defaultThreshold))
+		h := &models.AlertHistory{
+			Symbol:         stock.Symbol,
+			RuleType:       models.RuleTypeRSIOversold,
+			TriggeredValue: reading.Value,
+			Message: fmt.Sprintf("%s RSI %s at or below oversold threshold %s",
+				stock.Symbol, reading.Value.StringFixed(2), threshold.String()),
+			TriggeredAt: now,
+		}
+		if err := e.repo.CreateAlertHistory(h); err != nil {
+			log.Printf("Warning: failed to record RSI oversold alert for %s: %v", stock.Symbol, err)
+			continue
+		}
+		o.alertedOn[stock.Symbol] = today
+		fired = append(fired, h)
+	}
+	return fired, nil
+}
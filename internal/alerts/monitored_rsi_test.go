@@ -0,0 +1,97 @@
+package alerts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+// mockMonitored filters its stocks by the latest RSI in rsi, like GetStocksRSIOversold
+type mockMonitored struct {
+	stocks []*models.MonitoredStock
+	rsi    map[string]*models.RSIReading
+}
+
+func (m *mockMonitored) GetStocksRSIOversold(defaultThreshold float64) ([]*models.MonitoredStock, error) {
+	var oversold []*models.MonitoredStock
+	for _, s := range m.stocks {
+		r, ok := m.rsi[s.Symbol]
+		if !ok || !s.AlertOnRSIOversold {
+			continue
+		}
+		if s.RSIOversoldThreshold == nil && defaultThreshold <= 0 {
+			continue
+		}
+		if s.IsRSIOversold(r.Value.InexactFloat64(), defaultThreshold) {
+			oversold = append(oversold, s)
+		}
+	}
+	return oversold, nil
+}
+
+func TestEvaluateMonitoredStocks_UsesDefaultForNullThreshold(t *testing.T) {
+	now := time.Date(2026, 4, 1, 15, 0, 0, 0, time.UTC)
+	own := 20.0
+
+	repo := newMockRepo()
+	repo.rsi["AAPL"] = &models.RSIReading{Symbol: "AAPL", Value: decimal.RequireFromString("28.5"), DataPoints: 200}
+	repo.rsi["MSFT"] = &models.RSIReading{Symbol: "MSFT", Value: decimal.RequireFromString("25"), DataPoints: 200}
+	monitored := &mockMonitored{
+		stocks: []*models.MonitoredStock{
+			{Symbol: "AAPL", Enabled: true, AlertOnRSIOversold: true},
+			{Symbol: "MSFT", Enabled: true, AlertOnRSIOversold: true, RSIOversoldThreshold: &own},
+		},
+		rsi: repo.rsi,
+	}
+
+	e := NewEvaluator(repo)
+	e.now = func() time.Time { return now }
+	e.SetRSIOversoldDefault(monitored, 30)
+
+	fired, err := e.EvaluateMonitoredStocks()
+	require.NoError(t, err)
+
+	// AAPL has no threshold, so 28.5 is checked against the default of 30;
+	// MSFT's own threshold of 20 keeps 25 from firing
+	require.Len(t, fired, 1)
+	assert.Equal(t, "AAPL", fired[0].Symbol)
+	assert.Equal(t, models.RuleTypeRSIOversold, fired[0].RuleType)
+	assert.Equal(t, "AAPL RSI 28.50 at or below oversold threshold 30", fired[0].Message)
+	assert.Equal(t, fired, repo.history)
+
+	// Already alerted today
+	fired, err = e.EvaluateMonitoredStocks()
+	require.NoError(t, err)
+	assert.Empty(t, fired)
+	assert.Len(t, repo.history, 1)
+}
+
+func TestEvaluateMonitoredStocks_ZeroDefaultKeepsOwnThresholds(t *testing.T) {
+	own := 30.0
+
+	repo := newMockRepo()
+	repo.rsi["AAPL"] = &models.RSIReading{Symbol: "AAPL", Value: decimal.RequireFromString("5"), DataPoints: 200}
+	repo.rsi["MSFT"] = &models.RSIReading{Symbol: "MSFT", Value: decimal.RequireFromString("25"), DataPoints: 200}
+	monitored := &mockMonitored{
+		stocks: []*models.MonitoredStock{
+			{Symbol: "AAPL", Enabled: true, AlertOnRSIOversold: true},
+			{Symbol: "MSFT", Enabled: true, AlertOnRSIOversold: true, RSIOversoldThreshold: &own},
+		},
+		rsi: repo.rsi,
+	}
+
+	e := NewEvaluator(repo)
+	e.SetRSIOversoldDefault(monitored, 0)
+
+	fired, err := e.EvaluateMonitoredStocks()
+	require.NoError(t, err)
+
+	// Without a default only MSFT, which sets its own threshold, is checked
+	require.Len(t, fired, 1)
+	assert.Equal(t, "MSFT", fired[0].Symbol)
+	assert.Equal(t, "MSFT RSI 25.00 at or below oversold threshold 30", fired[0].Message)
+}
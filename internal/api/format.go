@@ -0,0 +1,108 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/shopspring/decimal"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+// DisplayPrecision sets how many decimal places quantities and dollar amounts
+// are rounded to in API responses. Stored values are never changed; a negative
+// value leaves that field at full precision.
+type DisplayPrecision struct {
+	Quantity int32
+	Price    int32
+}
+
+// DefaultDisplayPrecision rounds quantities to 4 places and prices to 2
+var DefaultDisplayPrecision = DisplayPrecision{Quantity: 4, Price: 2}
+
+func roundTo(d decimal.Decimal, places int32) decimal.Decimal {
+	if places < 0 {
+		return d
+	}
+	return d.Round(places)
+}
+
+// FormatPosition returns a copy of p rounded for display
+func (dp DisplayPrecision) FormatPosition(p *models.Position) *models.Position {
+	out := *p
+	out.Quantity = roundTo(p.Quantity, dp.Quantity)
+	out.EntryPrice = roundTo(p.EntryPrice, dp.Price)
+	out.CurrentPrice = roundTo(p.CurrentPrice, dp.Price)
+	out.RealizedPnl = roundTo(p.RealizedPnl, dp.Price)
+	return &out
+}
+
+// FormatTrade returns a copy of t rounded for display
+func (dp DisplayPrecision) FormatTrade(t *models.TradeHistory) *models.TradeHistory {
+	out := *t
+	out.Quantity = roundTo(t.Quantity, dp.Quantity)
+	out.Price = roundTo(t.Price, dp.Price)
+	out.TotalCost = roundTo(t.TotalCost, dp.Price)
+	out.Fee = roundTo(t.Fee, dp.Price)
+	out.RealizedPnl = roundTo(t.RealizedPnl, dp.Price)
+	if t.EntryPrice != nil {
+		entry := roundTo(*t.EntryPrice, dp.Price)
+		out.EntryPrice = &entry
+	}
+	return &out
+}
+
+// TradeDetail is a closed trade with its entry and exit prices and holding period
+// worked out from the stored history
+type TradeDetail struct {
+	*models.TradeHistory
+	EntryPrice        *decimal.Decimal `json:"entry_price,omitempty"`
+	ExitPrice         *decimal.Decimal `json:"exit_price,omitempty"`
+	HoldingPeriodDays *int             `json:"holding_period_days,omitempty"`
+}
+
+// TradeDetail returns t rounded for display with its computed fields. A sell's
+// price is the exit and its entry is the price implied by the realized P&L and
+// fees; any other trade's price is its entry. Holding days are whole days, from
+// the recorded holding period or else the entry and exit dates.
+func (dp DisplayPrecision) TradeDetail(t *models.TradeHistory) *TradeDetail {
+	d := &TradeDetail{TradeHistory: dp.FormatTrade(t)}
+
+	if t.TradeType == models.TradeTypeSell {
+		exit := roundTo(t.Price, dp.Price)
+		d.ExitPrice = &exit
+		if entry, ok := t.ImpliedEntryPrice(); ok {
+			entry = roundTo(entry, dp.Price)
+			d.EntryPrice = &entry
+		}
+	} else {
+		entry := roundTo(t.Price, dp.Price)
+		d.EntryPrice = &entry
+	}
+
+	switch {
+	case t.HoldingPeriodHours != nil:
+		days := *t.HoldingPeriodHours / 24
+		d.HoldingPeriodDays = &days
+	case t.EntryDate != nil && t.ExitDate != nil:
+		days := int(t.ExitDate.Sub(*t.EntryDate).Hours()) / 24
+		d.HoldingPeriodDays = &days
+	}
+	return d
+}
+
+// respondPositions writes positions as JSON using the handler's display precision
+func (h *Handler) respondPositions(w http.ResponseWriter, status int, positions []*models.Position) {
+	out := make([]*models.Position, len(positions))
+	for i, p := range positions {
+		out[i] = h.precision.FormatPosition(p)
+	}
+	respondJSON(w, status, out)
+}
+
+// respondTrades writes trade history as JSON using the handler's display precision
+func (h *Handler) respondTrades(w http.ResponseWriter, status int, trades []*models.TradeHistory) {
+	out := make([]*models.TradeHistory, len(trades))
+	for i, t := range trades {
+		out[i] = h.precision.FormatTrade(t)
+	}
+	respondJSON(w, status, out)
+}
@@ -3,10 +3,15 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/trogers1052/stock-alert-system/internal/alerts"
 	"github.com/trogers1052/stock-alert-system/internal/database"
 	"github.com/trogers1052/stock-alert-system/internal/kafka"
 	"github.com/trogers1052/stock-alert-system/internal/models"
@@ -15,20 +20,39 @@ import (
 
 // Handler holds dependencies for HTTP handlers
 type Handler struct {
-	db       *database.DB
-	producer *kafka.Producer
-	redis    *redis.Client
+	db        *database.DB
+	producer  *kafka.Producer
+	redis     *redis.Client
+	precision DisplayPrecision
+	aliases   models.SymbolAliases
+	evaluator *alerts.Evaluator
 }
 
 // NewHandler creates a new Handler
 func NewHandler(db *database.DB, producer *kafka.Producer, redisClient *redis.Client) *Handler {
 	return &Handler{
-		db:       db,
-		producer: producer,
-		redis:    redisClient,
+		db:        db,
+		producer:  producer,
+		redis:     redisClient,
+		precision: DefaultDisplayPrecision,
 	}
 }
 
+// SetSymbolAliases canonicalizes symbols added through the API using aliases
+func (h *Handler) SetSymbolAliases(aliases models.SymbolAliases) {
+	h.aliases = aliases
+}
+
+// SetEvaluator enables POST /alerts/evaluate using e
+func (h *Handler) SetEvaluator(e *alerts.Evaluator) {
+	h.evaluator = e
+}
+
+// SetDisplayPrecision overrides the rounding applied to position and trade responses
+func (h *Handler) SetDisplayPrecision(p DisplayPrecision) {
+	h.precision = p
+}
+
 // GetAllStocks handles GET /stocks
 func (h *Handler) GetAllStocks(w http.ResponseWriter, r *http.Request) {
 	stocks, err := h.db.GetAllStocks()
@@ -69,6 +93,21 @@ func (h *Handler) AddStock(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "symbol is required", http.StatusBadRequest)
 		return
 	}
+	req.Symbol = h.aliases.Canonical(req.Symbol)
+
+	// monitored_stocks references stocks, so a brand-new symbol gets a
+	// placeholder stock row until stock data arrives
+	exists, err := h.db.StockExists(req.Symbol)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		if err := h.db.UpsertStockBasic(req.Symbol, req.Symbol); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
 
 	monitoredStock := &models.MonitoredStock{
 		Symbol:  req.Symbol,
@@ -79,6 +118,13 @@ func (h *Handler) AddStock(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !exists {
+		// No stock data yet; publish the symbol alone and return the monitored stock
+		h.publishStockAdded(r, &models.Stock{Symbol: req.Symbol})
+		respondJSON(w, http.StatusCreated, monitoredStock)
+		return
+	}
+
 	// Get the stock to return and publish event
 	stock, err := h.db.GetStock(req.Symbol)
 	if err != nil {
@@ -86,15 +132,18 @@ func (h *Handler) AddStock(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Publish Kafka event
+	h.publishStockAdded(r, stock)
+	respondJSON(w, http.StatusCreated, stock)
+}
+
+// publishStockAdded publishes a stock-added event when a producer is configured
+func (h *Handler) publishStockAdded(r *http.Request, stock *models.Stock) {
 	if h.producer != nil {
 		if err := h.producer.PublishStockAdded(r.Context(), stock); err != nil {
 			// Log error but don't fail the request
 			// In production, you'd use a proper logger here
 		}
 	}
-
-	respondJSON(w, http.StatusCreated, stock)
 }
 
 // RemoveStock handles DELETE /stocks/{symbol}
@@ -117,6 +166,407 @@ func (h *Handler) RemoveStock(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// GetAllPositions handles GET /positions, newest entry first
+func (h *Handler) GetAllPositions(w http.ResponseWriter, r *http.Request) {
+	positions, err := h.db.GetAllPositions()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.respondPositions(w, http.StatusOK, positions)
+}
+
+// GetPosition handles GET /positions/{symbol}
+func (h *Handler) GetPosition(w http.ResponseWriter, r *http.Request) {
+	symbol := h.aliases.Canonical(strings.ToUpper(mux.Vars(r)["symbol"]))
+
+	position, err := h.db.GetPositionBySymbol(symbol)
+	if errors.Is(err, database.ErrPositionNotFound) {
+		http.Error(w, "no open position for "+symbol, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, h.precision.FormatPosition(position))
+}
+
+// defaultTradesLimit caps GET /trades when no limit is given
+const defaultTradesLimit = 100
+
+// GetTrades handles GET /trades. One filter may be given: ?symbol=, ?strategy=,
+// or a ?start=/?end= date range (YYYY-MM-DD or RFC3339; a date-only end includes
+// that whole day). ?limit= caps the results, newest first.
+func (h *Handler) GetTrades(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	limit := defaultTradesLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	symbol := h.aliases.Canonical(strings.ToUpper(strings.TrimSpace(q.Get("symbol"))))
+	strategy := strings.TrimSpace(q.Get("strategy"))
+	startParam, endParam := q.Get("start"), q.Get("end")
+	hasRange := startParam != "" || endParam != ""
+
+	filters := 0
+	for _, set := range []bool{symbol != "", strategy != "", hasRange} {
+		if set {
+			filters++
+		}
+	}
+	if filters > 1 {
+		http.Error(w, "only one of symbol, strategy or start/end may be given", http.StatusBadRequest)
+		return
+	}
+
+	var trades []*models.TradeHistory
+	var err error
+	switch {
+	case symbol != "":
+		trades, err = h.db.GetTradeHistoryBySymbol(symbol, limit)
+	case strategy != "":
+		trades, err = h.db.GetTradeHistoryByStrategy(strategy, limit)
+	case hasRange:
+		start, end, rangeErr := parseDateRange(startParam, endParam)
+		if rangeErr != nil {
+			http.Error(w, rangeErr.Error(), http.StatusBadRequest)
+			return
+		}
+		trades, err = h.db.GetTradeHistoryByDateRange(start, end, limit)
+	default:
+		trades, err = h.db.GetAllTradeHistory(limit)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.respondTrades(w, http.StatusOK, trades)
+}
+
+// GetTradeStats handles GET /trades/stats, optionally narrowed by ?symbol= or ?strategy=
+func (h *Handler) GetTradeStats(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	symbol := h.aliases.Canonical(strings.ToUpper(strings.TrimSpace(q.Get("symbol"))))
+	strategy := strings.TrimSpace(q.Get("strategy"))
+
+	var stats *database.TradeStats
+	var err error
+	switch {
+	case symbol != "" && strategy != "":
+		http.Error(w, "only one of symbol or strategy may be given", http.StatusBadRequest)
+		return
+	case symbol != "":
+		stats, err = h.db.GetTradeStatsBySymbol(symbol)
+	case strategy != "":
+		stats, err = h.db.GetTradeStatsByStrategy(strategy)
+	default:
+		stats, err = h.db.GetTradeStats()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, stats)
+}
+
+// GetTrade handles GET /trades/{id}, returning one closed trade with its
+// computed entry and exit prices and holding period
+func (h *Handler) GetTrade(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid trade id", http.StatusBadRequest)
+		return
+	}
+
+	trade, err := h.db.GetTradeHistoryByID(id)
+	if errors.Is(err, database.ErrTradeNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, h.precision.TradeDetail(trade))
+}
+
+// GetTradeExecutions handles GET /trades/{id}/executions, returning the raw fills
+// linked to a closed trade
+func (h *Handler) GetTradeExecutions(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid trade id", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.GetTradeHistoryByID(id); err != nil {
+		if errors.Is(err, database.ErrTradeNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	executions, err := h.db.GetRawTradesByTradeHistoryID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if executions == nil {
+		executions = []*models.RawTrade{}
+	}
+
+	respondJSON(w, http.StatusOK, executions)
+}
+
+// GetAlertRules handles GET /alerts. With ?symbol= it returns every rule for
+// that symbol, enabled or not; otherwise all enabled rules.
+func (h *Handler) GetAlertRules(w http.ResponseWriter, r *http.Request) {
+	symbol := h.aliases.Canonical(strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("symbol"))))
+
+	var rules []*models.AlertRule
+	var err error
+	if symbol != "" {
+		rules, err = h.db.GetAlertRulesBySymbol(symbol)
+	} else {
+		rules, err = h.db.GetEnabledAlertRules()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if rules == nil {
+		rules = []*models.AlertRule{}
+	}
+
+	respondJSON(w, http.StatusOK, rules)
+}
+
+// GetAlertRule handles GET /alerts/{id}
+func (h *Handler) GetAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, ok := alertRuleID(w, r)
+	if !ok {
+		return
+	}
+
+	rule, err := h.db.GetAlertRuleByID(id)
+	if err != nil {
+		respondAlertRuleError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, rule)
+}
+
+// CreateAlertRule handles POST /alerts. Rules are enabled unless the body sets
+// "enabled": false, and notify via telegram at normal priority by default.
+func (h *Handler) CreateAlertRule(w http.ResponseWriter, r *http.Request) {
+	rule := &models.AlertRule{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(rule); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Trigger state is owned by the evaluator
+	rule.ID, rule.TriggeredCount, rule.LastTriggeredAt = 0, 0, nil
+
+	rule.Symbol = h.aliases.Canonical(strings.ToUpper(strings.TrimSpace(rule.Symbol)))
+	if rule.Symbol == "" {
+		http.Error(w, "symbol is required", http.StatusBadRequest)
+		return
+	}
+	if err := normalizeAlertRule(rule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// alert_rules references stocks, so an unknown symbol would fail the insert
+	exists, err := h.db.StockExists(rule.Symbol)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "unknown symbol "+rule.Symbol, http.StatusNotFound)
+		return
+	}
+
+	if err := h.db.CreateAlertRule(rule); err != nil {
+		if errors.Is(err, database.ErrAlertRuleLimitExceeded) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, rule)
+}
+
+// EvaluateAlerts handles POST /alerts/evaluate. It checks the enabled rules
+// against every stored stock's current quote and returns a summary of the pass.
+func (h *Handler) EvaluateAlerts(w http.ResponseWriter, r *http.Request) {
+	if h.evaluator == nil {
+		http.Error(w, "alert evaluation is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	stocks, err := h.db.GetAllStocks()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result, err := h.evaluator.RunEvaluation(stocks)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, result)
+}
+
+// UpdateAlertRule handles PUT /alerts/{id}. Fields missing from the body keep
+// their current values; the symbol and trigger state can't be changed.
+func (h *Handler) UpdateAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, ok := alertRuleID(w, r)
+	if !ok {
+		return
+	}
+
+	rule, err := h.db.GetAlertRuleByID(id)
+	if err != nil {
+		respondAlertRuleError(w, err)
+		return
+	}
+	existing := *rule
+
+	if err := json.NewDecoder(r.Body).Decode(rule); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	rule.ID = existing.ID
+	rule.Symbol = existing.Symbol
+	rule.TriggeredCount = existing.TriggeredCount
+	rule.LastTriggeredAt = existing.LastTriggeredAt
+	rule.CreatedAt = existing.CreatedAt
+
+	if err := normalizeAlertRule(rule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.UpdateAlertRule(rule); err != nil {
+		respondAlertRuleError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, rule)
+}
+
+// DeleteAlertRule handles DELETE /alerts/{id}
+func (h *Handler) DeleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, ok := alertRuleID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteAlertRule(id); err != nil {
+		respondAlertRuleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// alertRuleID parses the {id} path variable, writing a 400 if it isn't a positive integer
+func alertRuleID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid alert rule id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// respondAlertRuleError maps a missing rule to 404 and anything else to 500
+func respondAlertRuleError(w http.ResponseWriter, err error) {
+	if errors.Is(err, database.ErrAlertRuleNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// normalizeAlertRule canonicalizes the case of enumerated fields, fills in the
+// default channel and priority, and validates the result
+func normalizeAlertRule(rule *models.AlertRule) error {
+	rule.RuleType = strings.ToUpper(strings.TrimSpace(rule.RuleType))
+	rule.Comparison = strings.ToUpper(strings.TrimSpace(rule.Comparison))
+	rule.NotificationChannel = strings.ToLower(strings.TrimSpace(rule.NotificationChannel))
+	rule.Priority = strings.ToLower(strings.TrimSpace(rule.Priority))
+
+	if rule.NotificationChannel == "" {
+		rule.NotificationChannel = models.ChannelTelegram
+	}
+	if rule.Priority == "" {
+		rule.Priority = models.PriorityNormal
+	}
+
+	return rule.Validate()
+}
+
+// parseDateRange parses optional start and end bounds. A missing start is
+// unbounded and a missing end is now.
+func parseDateRange(startParam, endParam string) (time.Time, time.Time, error) {
+	start := time.Time{}
+	end := time.Now()
+	if startParam != "" {
+		t, _, err := parseDate(startParam)
+		if err != nil {
+			return start, end, fmt.Errorf("invalid start date %q", startParam)
+		}
+		start = t
+	}
+	if endParam != "" {
+		t, dateOnly, err := parseDate(endParam)
+		if err != nil {
+			return start, end, fmt.Errorf("invalid end date %q", endParam)
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		end = t
+	}
+	if end.Before(start) {
+		return start, end, fmt.Errorf("end date is before start date")
+	}
+	return start, end, nil
+}
+
+// parseDate accepts YYYY-MM-DD or RFC3339, reporting whether it was date-only
+func parseDate(s string) (time.Time, bool, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	return t, false, err
+}
+
 // HealthCheck handles GET /health
 func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
@@ -168,6 +618,40 @@ func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, health)
 }
 
+// DBStats is the JSON view of the database connection pool statistics
+type DBStats struct {
+	MaxOpenConnections int    `json:"max_open_connections"`
+	OpenConnections    int    `json:"open_connections"`
+	InUse              int    `json:"in_use"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"wait_count"`
+	WaitDuration       string `json:"wait_duration"`
+	MaxIdleClosed      int64  `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64  `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64  `json:"max_lifetime_closed"`
+}
+
+// GetDBStats handles GET /debug/dbstats
+func (h *Handler) GetDBStats(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		http.Error(w, "database not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	stats := h.db.Stats()
+	respondJSON(w, http.StatusOK, DBStats{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDuration:       stats.WaitDuration.String(),
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	})
+}
+
 func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -0,0 +1,858 @@
+package api
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trogers1052/stock-alert-system/internal/alerts"
+	"github.com/trogers1052/stock-alert-system/internal/database"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+func newTestRouter(t *testing.T, apiKey string) http.Handler {
+	t.Helper()
+	router, _ := newMockRouter(t, apiKey)
+	return router
+}
+
+// newMockRouter returns a router backed by sqlmock so tests can script queries
+func newMockRouter(t *testing.T, apiKey string) (http.Handler, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	handler := NewHandler(database.NewWithConn(sqlDB), nil, nil)
+	return SetupRoutes(handler, apiKey), mock
+}
+
+func TestGetDBStats_ReturnsPoolStats(t *testing.T) {
+	router := newTestRouter(t, "secret")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/debug/dbstats", nil)
+	req.Header.Set("X-API-Key", "secret")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	for _, field := range []string{"max_open_connections", "open_connections", "in_use", "idle", "wait_count", "wait_duration"} {
+		assert.Contains(t, body, field)
+	}
+}
+
+func TestGetDBStats_RequiresAPIKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		provided   string
+		expected   int
+	}{
+		{"missing key", "secret", "", http.StatusUnauthorized},
+		{"wrong key", "secret", "nope", http.StatusUnauthorized},
+		{"no key configured", "", "anything", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestRouter(t, tt.configured)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/debug/dbstats", nil)
+			if tt.provided != "" {
+				req.Header.Set("X-API-Key", tt.provided)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expected, rec.Code)
+		})
+	}
+}
+
+func TestDebugVars_RequiresAPIKey(t *testing.T) {
+	router := newTestRouter(t, "secret")
+
+	serve := func(path, key string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, serve("/api/v1/debug/vars", ""))
+	assert.Equal(t, http.StatusOK, serve("/api/v1/debug/vars", "secret"))
+	assert.Equal(t, http.StatusNotFound, serve("/debug/vars", "secret"), "not served outside the guarded subrouter")
+}
+
+func TestRespondPositions_RoundsForDisplay(t *testing.T) {
+	h := NewHandler(nil, nil, nil)
+	positions := []*models.Position{{
+		Symbol:       "AAPL",
+		Quantity:     decimal.RequireFromString("1.23456789"),
+		EntryPrice:   decimal.RequireFromString("150.123456"),
+		CurrentPrice: decimal.RequireFromString("155.987"),
+		Notes:        "Trim into earnings",
+		Tags:         []string{"core"},
+	}}
+
+	rec := httptest.NewRecorder()
+	h.respondPositions(rec, http.StatusOK, positions)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body []map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body, 1)
+	assert.Equal(t, "1.2346", body[0]["quantity"])
+	assert.Equal(t, "150.12", body[0]["entry_price"])
+	assert.Equal(t, "155.99", body[0]["current_price"])
+	assert.Equal(t, "Trim into earnings", body[0]["notes"])
+	assert.Equal(t, []interface{}{"core"}, body[0]["tags"])
+
+	// Source values are left at full precision
+	assert.Equal(t, "1.23456789", positions[0].Quantity.String())
+}
+
+func TestRespondTrades_UsesConfiguredPrecision(t *testing.T) {
+	h := NewHandler(nil, nil, nil)
+	h.SetDisplayPrecision(DisplayPrecision{Quantity: -1, Price: 3})
+
+	rec := httptest.NewRecorder()
+	h.respondTrades(rec, http.StatusOK, []*models.TradeHistory{{
+		Symbol:    "MSFT",
+		Quantity:  decimal.RequireFromString("0.12345678"),
+		Price:     decimal.RequireFromString("410.12345"),
+		TotalCost: decimal.RequireFromString("50.6321"),
+	}})
+
+	var body []map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body, 1)
+	assert.Equal(t, "0.12345678", body[0]["quantity"])
+	assert.Equal(t, "410.123", body[0]["price"])
+	assert.Equal(t, "50.632", body[0]["total_cost"])
+}
+
+func TestAddStock_NewSymbolWithoutStockRow(t *testing.T) {
+	router, mock := newMockRouter(t, "")
+
+	mock.ExpectQuery("SELECT EXISTS").WithArgs("NEWCO").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec("INSERT INTO stocks").WithArgs("NEWCO", "NEWCO").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO monitored_stocks").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/stocks", strings.NewReader(`{"symbol":"NEWCO"}`))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "NEWCO", body["symbol"])
+	assert.Equal(t, true, body["enabled"])
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddStock_DatabaseErrorOnLookupStillFails(t *testing.T) {
+	router, mock := newMockRouter(t, "")
+
+	mock.ExpectQuery("SELECT EXISTS").WithArgs("AAPL").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec("INSERT INTO monitored_stocks").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("FROM stocks").WithArgs("AAPL").WillReturnError(errors.New("connection reset"))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/stocks", strings.NewReader(`{"symbol":"AAPL"}`))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddStock_CanonicalizesAliasedSymbol(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	handler := NewHandler(database.NewWithConn(sqlDB), nil, nil)
+	handler.SetSymbolAliases(models.SymbolAliases{"BRK.B": "BRK-B"})
+	router := SetupRoutes(handler, "")
+
+	insertArgs := []driver.Value{"BRK-B"}
+	for i := 0; i < 13; i++ {
+		insertArgs = append(insertArgs, sqlmock.AnyArg())
+	}
+	mock.ExpectQuery("SELECT EXISTS").WithArgs("BRK-B").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec("INSERT INTO stocks").WithArgs("BRK-B", "BRK-B").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO monitored_stocks").WithArgs(insertArgs...).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/stocks", strings.NewReader(`{"symbol":"brk.b"}`))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "BRK-B", body["symbol"])
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+var tradeColumns = []string{
+	"id", "symbol", "trade_type", "quantity", "price", "total_cost", "fee",
+	"entry_date", "exit_date", "holding_period_hours",
+	"entry_rsi", "exit_rsi", "realized_pnl", "realized_pnl_pct", "max_drawdown_pct",
+	"entry_reason", "exit_reason", "emotional_state", "conviction_level",
+	"market_conditions", "what_went_right", "what_went_wrong",
+	"trade_grade", "strategy_tag", "notes", "executed_at", "created_at",
+}
+
+// tradeRows returns sqlmock rows for closed trades with the given IDs and symbol
+func tradeRows(symbol string, ids ...int) *sqlmock.Rows {
+	rows := sqlmock.NewRows(tradeColumns)
+	executed := time.Date(2026, 2, 3, 15, 0, 0, 0, time.UTC)
+	for _, id := range ids {
+		rows.AddRow(
+			id, symbol, "SELL", "10", "150.123", "1501.23", "0",
+			nil, nil, nil,
+			nil, nil, "25.5", "1.7", nil,
+			nil, nil, nil, nil,
+			nil, nil, nil,
+			nil, "breakout", nil, executed, executed,
+		)
+	}
+	return rows
+}
+
+func TestGetTrades(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		expect func(mock sqlmock.Sqlmock)
+	}{
+		{"default limit", "", func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery("FROM trades_history").WithArgs(100).WillReturnRows(tradeRows("AAPL", 1, 2))
+		}},
+		{"symbol", "?symbol=aapl&limit=5", func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery("WHERE symbol = \\$1").WithArgs("AAPL", 5).WillReturnRows(tradeRows("AAPL", 1, 2))
+		}},
+		{"strategy", "?strategy=breakout", func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery("WHERE strategy_tag = \\$1").WithArgs("breakout", 100).WillReturnRows(tradeRows("AAPL", 1, 2))
+		}},
+		{"date range", "?start=2026-02-01&end=2026-02-03", func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery("WHERE executed_at >= \\$1 AND executed_at <= \\$2").
+				WithArgs(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 2, 3, 23, 59, 59, 999999999, time.UTC), 100).
+				WillReturnRows(tradeRows("AAPL", 1, 2))
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mock := newMockRouter(t, "")
+			tt.expect(mock)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/trades"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			var body []map[string]interface{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			require.Len(t, body, 2)
+			assert.Equal(t, "AAPL", body[0]["symbol"])
+			assert.Equal(t, "150.12", body[0]["price"])
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetTrades_DateRangeHonorsLimit(t *testing.T) {
+	router, mock := newMockRouter(t, "")
+	mock.ExpectQuery("WHERE executed_at >= \\$1 AND executed_at <= \\$2\\s+ORDER BY executed_at DESC\\s+LIMIT \\$3").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 2).
+		WillReturnRows(tradeRows("AAPL", 1, 2))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/trades?start=2026-02-01T00:00:00Z&limit=2", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var body []map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Len(t, body, 2)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTrades_EmptyReturnsArray(t *testing.T) {
+	router, mock := newMockRouter(t, "")
+	mock.ExpectQuery("FROM trades_history").WillReturnRows(sqlmock.NewRows(tradeColumns))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/trades", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, "[]", rec.Body.String())
+}
+
+func TestGetTrades_BadRequest(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"malformed start", "?start=02/01/2026"},
+		{"malformed end", "?end=yesterday"},
+		{"end before start", "?start=2026-02-03&end=2026-02-01"},
+		{"non-numeric limit", "?limit=ten"},
+		{"zero limit", "?limit=0"},
+		{"two filters", "?symbol=AAPL&strategy=breakout"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mock := newMockRouter(t, "")
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/trades"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetTrade(t *testing.T) {
+	t.Run("computes entry, exit and holding days", func(t *testing.T) {
+		router, mock := newMockRouter(t, "")
+		entered := time.Date(2026, 2, 1, 14, 30, 0, 0, time.UTC)
+		exited := time.Date(2026, 2, 3, 16, 30, 0, 0, time.UTC)
+		mock.ExpectQuery("FROM trades_history").WithArgs(7).WillReturnRows(
+			sqlmock.NewRows(tradeColumns).AddRow(
+				7, "AAPL", "SELL", "10", "150.123", "1501.23", "1.2",
+				entered, exited, 50,
+				nil, nil, "25.5", "1.7", "3.2",
+				nil, nil, nil, nil,
+				nil, nil, nil,
+				nil, "breakout", nil, exited, exited,
+			))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/trades/7", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, float64(7), body["id"])
+		assert.Equal(t, "AAPL", body["symbol"])
+		assert.Equal(t, "150.12", body["exit_price"])
+		// 150.123 - (25.5 + 1.2) / 10
+		assert.Equal(t, "147.45", body["entry_price"])
+		assert.Equal(t, float64(2), body["holding_period_days"])
+		assert.Equal(t, "3.2", body["max_drawdown_pct"])
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown trade", func(t *testing.T) {
+		router, mock := newMockRouter(t, "")
+		mock.ExpectQuery("FROM trades_history").WithArgs(99).WillReturnError(sql.ErrNoRows)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/trades/99", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		router, mock := newMockRouter(t, "")
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/trades/abc", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+var rawTradeColumns = []string{
+	"id", "order_id", "source", "symbol", "side", "quantity", "price", "total_cost", "fees",
+	"executed_at", "position_id", "trade_history_id", "extended_hours", "created_at",
+}
+
+func TestGetTradeExecutions(t *testing.T) {
+	router, mock := newMockRouter(t, "")
+	executed := time.Date(2026, 2, 3, 15, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("FROM trades_history").WithArgs(7).WillReturnRows(tradeRows("AAPL", 7))
+	mock.ExpectQuery("WHERE trade_history_id = \\$1").WithArgs(7).WillReturnRows(
+		sqlmock.NewRows(rawTradeColumns).
+			AddRow(1, "ord-1", "robinhood", "AAPL", "BUY", "10", "140", "1400", "0", executed.Add(-48*time.Hour), nil, 7, false, executed).
+			AddRow(2, "ord-2", "robinhood", "AAPL", "SELL", "10", "150.123", "1501.23", "0", executed, nil, 7, false, executed))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/trades/7/executions", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var body []map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body, 2)
+	assert.Equal(t, "ord-1", body[0]["order_id"])
+	assert.Equal(t, "SELL", body[1]["side"])
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTradeExecutions_Errors(t *testing.T) {
+	t.Run("unknown trade", func(t *testing.T) {
+		router, mock := newMockRouter(t, "")
+		mock.ExpectQuery("FROM trades_history").WithArgs(99).WillReturnError(sql.ErrNoRows)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/trades/99/executions", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		router, mock := newMockRouter(t, "")
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/trades/abc/executions", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+var positionColumns = []string{
+	"id", "symbol", "quantity", "entry_price", "entry_date", "current_price",
+	"unrealized_pnl_pct", "days_held", "entry_rsi", "entry_reason",
+	"sector", "industry", "position_size_pct", "realized_pnl", "notes", "tags", "lowest_price", "created_at", "updated_at",
+}
+
+func positionRow(rows *sqlmock.Rows, id int, symbol string, entryDate time.Time) *sqlmock.Rows {
+	return rows.AddRow(
+		id, symbol, "10.123456", "150.5555", entryDate, "160.009",
+		"6.28", 3, nil, nil,
+		"Technology", nil, nil, "0", nil, "{}", nil, entryDate, entryDate,
+	)
+}
+
+func TestGetAllPositions(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		router, mock := newMockRouter(t, "")
+		mock.ExpectQuery("FROM positions").WillReturnRows(sqlmock.NewRows(positionColumns))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/positions", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, "[]", rec.Body.String())
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("populated", func(t *testing.T) {
+		router, mock := newMockRouter(t, "")
+		newer := time.Date(2026, 3, 10, 14, 0, 0, 0, time.UTC)
+		rows := sqlmock.NewRows(positionColumns)
+		positionRow(rows, 2, "MSFT", newer)
+		positionRow(rows, 1, "AAPL", newer.AddDate(0, 0, -7))
+		mock.ExpectQuery("FROM positions\\s+ORDER BY entry_date DESC").WillReturnRows(rows)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/positions", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var body []map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Len(t, body, 2)
+		assert.Equal(t, "MSFT", body[0]["symbol"])
+		assert.Equal(t, "AAPL", body[1]["symbol"])
+		assert.Equal(t, "10.1235", body[0]["quantity"])
+		assert.Equal(t, "150.56", body[0]["entry_price"])
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetPosition(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		router, mock := newMockRouter(t, "")
+		mock.ExpectQuery("WHERE symbol = \\$1").WithArgs("AAPL").
+			WillReturnRows(positionRow(sqlmock.NewRows(positionColumns), 1, "AAPL", time.Now()))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/positions/aapl", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, "AAPL", body["symbol"])
+		assert.Equal(t, "160.01", body["current_price"])
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("missing symbol", func(t *testing.T) {
+		router, mock := newMockRouter(t, "")
+		mock.ExpectQuery("WHERE symbol = \\$1").WithArgs("NVDA").WillReturnError(sql.ErrNoRows)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/positions/NVDA", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Contains(t, rec.Body.String(), "no open position for NVDA")
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetTradeStats(t *testing.T) {
+	statsColumns := []string{"total_trades", "winning_trades", "losing_trades", "total_pnl", "avg_pnl_pct", "avg_win", "avg_loss"}
+
+	tests := []struct {
+		name  string
+		query string
+		args  []driver.Value
+	}{
+		{"unfiltered", "", nil},
+		{"symbol", "?symbol=aapl", []driver.Value{"AAPL"}},
+		{"strategy", "?strategy=breakout", []driver.Value{"breakout"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mock := newMockRouter(t, "")
+			mock.ExpectQuery("FROM trades_history").WithArgs(tt.args...).WillReturnRows(
+				sqlmock.NewRows(statsColumns).AddRow(4, 3, 1, "250", "2.5", "100", "-50"))
+			mock.ExpectQuery("SELECT realized_pnl").WithArgs(tt.args...).WillReturnRows(
+				sqlmock.NewRows([]string{"realized_pnl"}).AddRow("-50").AddRow("100").AddRow("100").AddRow("100"))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/trades/stats"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			var body map[string]interface{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			// Same win-rate math on every path: 3 of 4
+			assert.Equal(t, "75", body["win_rate"])
+			assert.Equal(t, float64(3), body["current_win_streak"])
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetTradeStats_RejectsBothFilters(t *testing.T) {
+	router, mock := newMockRouter(t, "")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/trades/stats?symbol=AAPL&strategy=breakout", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+var alertRuleColumns = []string{
+	"id", "symbol", "rule_type", "condition_value", "comparison", "enabled",
+	"triggered_count", "last_triggered_at", "cooldown_minutes",
+	"notification_channel", "message_template", "priority", "created_at", "updated_at",
+}
+
+func alertRuleRow(rows *sqlmock.Rows, id int, symbol, ruleType, comparison string, enabled bool) *sqlmock.Rows {
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	return rows.AddRow(id, symbol, ruleType, "200", comparison, enabled,
+		2, created.Add(time.Hour), 60, "telegram", nil, "normal", created, created)
+}
+
+func TestCreateAlertRule(t *testing.T) {
+	router, mock := newMockRouter(t, "")
+	mock.ExpectQuery("SELECT EXISTS").WithArgs("AAPL").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO alert_rules").
+		WithArgs("AAPL", "PRICE_TARGET", "200", "ABOVE", true, 30, "telegram", "", "normal", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(12))
+	mock.ExpectCommit()
+
+	body := `{"symbol":"aapl","rule_type":"price_target","comparison":"above","condition_value":"200","cooldown_minutes":30,"triggered_count":9}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/alerts", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var rule models.AlertRule
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rule))
+	assert.Equal(t, 12, rule.ID)
+	assert.Equal(t, "AAPL", rule.Symbol)
+	assert.True(t, rule.Enabled)
+	assert.Equal(t, models.PriorityNormal, rule.Priority)
+	assert.Zero(t, rule.TriggeredCount, "trigger state can't be set by the client")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEvaluateAlerts(t *testing.T) {
+	t.Run("summarizes the pass", func(t *testing.T) {
+		sqlDB, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer sqlDB.Close()
+
+		db := database.NewWithConn(sqlDB)
+		handler := NewHandler(db, nil, nil)
+		handler.SetEvaluator(alerts.NewEvaluator(db))
+		router := SetupRoutes(handler, "")
+
+		now := time.Now()
+		mock.ExpectQuery("FROM stocks").WillReturnRows(sqlmock.NewRows([]string{
+			"id", "symbol", "name", "exchange", "sector", "industry",
+			"current_price", "previous_close", "change_amount", "change_percent",
+			"day_high", "day_low", "volume", "average_volume",
+			"week_52_high", "week_52_low", "market_cap", "shares_outstanding",
+			"last_updated", "created_at",
+		}).AddRow(1, "AAPL", "Apple Inc.", "NASDAQ", "Technology", "Hardware",
+			150.0, 149.0, 1.0, 0.67, 151.0, 148.0, 1000, 1000, 200.0, 120.0, 1, 1, now, now))
+		mock.ExpectQuery("FROM alert_rules").WillReturnRows(
+			alertRuleRow(sqlmock.NewRows(alertRuleColumns), 5, "AAPL", "PRICE_TARGET", "ABOVE", true))
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/alerts/evaluate", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var body map[string]int
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, map[string]int{"checked": 1, "fired": 0, "suppressed_by_cooldown": 0, "notified": 0}, body)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unavailable without an evaluator", func(t *testing.T) {
+		router, mock := newMockRouter(t, "")
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/alerts/evaluate", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCreateAlertRule_UnknownSymbol(t *testing.T) {
+	router, mock := newMockRouter(t, "")
+	mock.ExpectQuery("SELECT EXISTS").WithArgs("NOPE").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	body := `{"symbol":"nope","rule_type":"price_target","comparison":"above","condition_value":"200"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/alerts", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "unknown symbol NOPE")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateAlertRule_RejectsInvalidValues(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"malformed json", `{"symbol":`},
+		{"missing symbol", `{"rule_type":"PRICE_TARGET","comparison":"ABOVE"}`},
+		{"unknown rule_type", `{"symbol":"AAPL","rule_type":"MOON","comparison":"ABOVE"}`},
+		{"history-only rule_type", `{"symbol":"AAPL","rule_type":"TARGET_HIT","comparison":"ABOVE"}`},
+		{"unevaluated rule_type", `{"symbol":"AAPL","rule_type":"SUPPORT_BOUNCE","comparison":"ABOVE"}`},
+		{"unknown comparison", `{"symbol":"AAPL","rule_type":"PRICE_TARGET","comparison":"NEAR"}`},
+		{"unknown priority", `{"symbol":"AAPL","rule_type":"PRICE_TARGET","comparison":"ABOVE","priority":"urgent"}`},
+		{"unknown channel", `{"symbol":"AAPL","rule_type":"PRICE_TARGET","comparison":"ABOVE","notification_channel":"fax"}`},
+		{"negative cooldown", `{"symbol":"AAPL","rule_type":"PRICE_TARGET","comparison":"ABOVE","cooldown_minutes":-5}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mock := newMockRouter(t, "")
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/alerts", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetAlertRule(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		router, mock := newMockRouter(t, "")
+		mock.ExpectQuery("FROM alert_rules").WithArgs(5).WillReturnRows(
+			alertRuleRow(sqlmock.NewRows(alertRuleColumns), 5, "AAPL", "PRICE_TARGET", "ABOVE", true))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/alerts/5", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var rule models.AlertRule
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rule))
+		assert.Equal(t, 5, rule.ID)
+		assert.Equal(t, "200", rule.ConditionValue.String())
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown id", func(t *testing.T) {
+		router, mock := newMockRouter(t, "")
+		mock.ExpectQuery("FROM alert_rules").WithArgs(99).WillReturnError(sql.ErrNoRows)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/alerts/99", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		router, mock := newMockRouter(t, "")
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/alerts/abc", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetAlertRules(t *testing.T) {
+	t.Run("by symbol includes disabled rules", func(t *testing.T) {
+		router, mock := newMockRouter(t, "")
+		rows := sqlmock.NewRows(alertRuleColumns)
+		alertRuleRow(rows, 1, "AAPL", "PRICE_TARGET", "ABOVE", true)
+		alertRuleRow(rows, 2, "AAPL", "RSI_OVERSOLD", "BELOW", false)
+		mock.ExpectQuery("WHERE symbol = \\$1\\s+ORDER BY priority").WithArgs("AAPL").WillReturnRows(rows)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/alerts?symbol=aapl", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var rules []models.AlertRule
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rules))
+		require.Len(t, rules, 2)
+		assert.False(t, rules[1].Enabled)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unfiltered returns enabled rules", func(t *testing.T) {
+		router, mock := newMockRouter(t, "")
+		mock.ExpectQuery("WHERE enabled = true").WillReturnRows(sqlmock.NewRows(alertRuleColumns))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/alerts", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, "[]", rec.Body.String())
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestUpdateAlertRule(t *testing.T) {
+	t.Run("merges fields onto the stored rule", func(t *testing.T) {
+		router, mock := newMockRouter(t, "")
+		mock.ExpectQuery("FROM alert_rules").WithArgs(5).WillReturnRows(
+			alertRuleRow(sqlmock.NewRows(alertRuleColumns), 5, "AAPL", "PRICE_TARGET", "ABOVE", true))
+		mock.ExpectExec("UPDATE alert_rules").
+			WithArgs(5, "PRICE_TARGET", "180", "BELOW", false, 60, "telegram", "", "high", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		body := `{"symbol":"MSFT","comparison":"below","condition_value":"180","enabled":false,"priority":"high"}`
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/alerts/5", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var rule models.AlertRule
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rule))
+		assert.Equal(t, "AAPL", rule.Symbol, "symbol is immutable")
+		assert.Equal(t, 2, rule.TriggeredCount)
+		assert.False(t, rule.Enabled)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("rejects unknown comparison", func(t *testing.T) {
+		router, mock := newMockRouter(t, "")
+		mock.ExpectQuery("FROM alert_rules").WithArgs(5).WillReturnRows(
+			alertRuleRow(sqlmock.NewRows(alertRuleColumns), 5, "AAPL", "PRICE_TARGET", "ABOVE", true))
+
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/alerts/5", strings.NewReader(`{"comparison":"SIDEWAYS"}`))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown id", func(t *testing.T) {
+		router, mock := newMockRouter(t, "")
+		mock.ExpectQuery("FROM alert_rules").WithArgs(99).WillReturnError(sql.ErrNoRows)
+
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/alerts/99", strings.NewReader(`{}`))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeleteAlertRule(t *testing.T) {
+	tests := []struct {
+		name     string
+		affected int64
+		expected int
+	}{
+		{"deleted", 1, http.StatusNoContent},
+		{"unknown id", 0, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mock := newMockRouter(t, "")
+			mock.ExpectExec("DELETE FROM alert_rules").WithArgs(5).WillReturnResult(sqlmock.NewResult(0, tt.affected))
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/v1/alerts/5", nil)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expected, rec.Code)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
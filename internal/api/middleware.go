@@ -0,0 +1,28 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// apiKeyHeader carries the API key on guarded requests
+const apiKeyHeader = "X-API-Key"
+
+// RequireAPIKey rejects requests whose X-API-Key header doesn't match key.
+// An empty key disables the guarded routes entirely rather than leaving them open.
+func RequireAPIKey(key string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key == "" {
+				http.Error(w, "endpoint disabled: API key not configured", http.StatusForbidden)
+				return
+			}
+			provided := r.Header.Get(apiKeyHeader)
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(key)) != 1 {
+				http.Error(w, "invalid API key", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
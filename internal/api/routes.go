@@ -1,11 +1,14 @@
 package api
 
 import (
+	"expvar"
+
 	"github.com/gorilla/mux"
 )
 
-// SetupRoutes configures all API routes
-func SetupRoutes(handler *Handler) *mux.Router {
+// SetupRoutes configures all API routes. apiKey guards the diagnostic
+// endpoints; when empty they are disabled.
+func SetupRoutes(handler *Handler, apiKey string) *mux.Router {
 	r := mux.NewRouter()
 
 	// Health check
@@ -18,5 +21,30 @@ func SetupRoutes(handler *Handler) *mux.Router {
 	api.HandleFunc("/stocks/{symbol}", handler.GetStock).Methods("GET")
 	api.HandleFunc("/stocks/{symbol}", handler.RemoveStock).Methods("DELETE")
 
+	// Position routes
+	api.HandleFunc("/positions", handler.GetAllPositions).Methods("GET")
+	api.HandleFunc("/positions/{symbol}", handler.GetPosition).Methods("GET")
+
+	// Trade history routes
+	api.HandleFunc("/trades", handler.GetTrades).Methods("GET")
+	api.HandleFunc("/trades/stats", handler.GetTradeStats).Methods("GET")
+	api.HandleFunc("/trades/{id}", handler.GetTrade).Methods("GET")
+	api.HandleFunc("/trades/{id}/executions", handler.GetTradeExecutions).Methods("GET")
+
+	// Alert rule routes
+	api.HandleFunc("/alerts", handler.GetAlertRules).Methods("GET")
+	api.HandleFunc("/alerts", handler.CreateAlertRule).Methods("POST")
+	api.HandleFunc("/alerts/evaluate", handler.EvaluateAlerts).Methods("POST")
+	api.HandleFunc("/alerts/{id}", handler.GetAlertRule).Methods("GET")
+	api.HandleFunc("/alerts/{id}", handler.UpdateAlertRule).Methods("PUT")
+	api.HandleFunc("/alerts/{id}", handler.DeleteAlertRule).Methods("DELETE")
+
+	// Diagnostics, guarded by API key
+	debug := api.PathPrefix("/debug").Subrouter()
+	debug.Use(RequireAPIKey(apiKey))
+	debug.HandleFunc("/dbstats", handler.GetDBStats).Methods("GET")
+	// Runtime metrics (consumer lag, etc.) published via expvar
+	debug.Handle("/vars", expvar.Handler()).Methods("GET")
+
 	return r
 }
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"log"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Config holds all application configuration
@@ -11,12 +14,24 @@ type Config struct {
 	Database DatabaseConfig
 	Kafka    KafkaConfig
 	Redis    RedisConfig
+	Alerts   AlertsConfig
+	Telegram TelegramConfig
+	Pushover PushoverConfig
+	SMTP     SMTPConfig
+
+	// SymbolAliases maps alternate tickers to the canonical symbol, e.g. BRK.B -> BRK-B
+	SymbolAliases map[string]string
 }
 
 // ServerConfig holds HTTP server configuration
 type ServerConfig struct {
 	Port string
 	Host string
+	// APIKey guards diagnostic endpoints; empty disables them
+	APIKey string
+	// Decimal places for quantities and prices in API responses (negative = full precision)
+	QuantityPrecision int
+	PricePrecision    int
 }
 
 // DatabaseConfig holds PostgreSQL configuration
@@ -37,6 +52,67 @@ type KafkaConfig struct {
 	PositionsTopic string
 	WatchlistTopic string
 	ConsumerGroup  string
+	// Combined reads the trades and positions topics under one consumer instead
+	// of a consumer per topic. Trade batching is disabled in combined mode.
+	Combined bool
+
+	// MaxBytes caps a single fetch from the trades topic
+	MaxBytes int
+	// DeadLetterTopic receives oversized, truncated or unparseable trade messages ("" disables)
+	DeadLetterTopic string
+
+	// BatchSize processes trade messages in batches committed together (1 disables);
+	// BatchWait is how long a partial batch waits to fill
+	BatchSize int
+	BatchWait time.Duration
+
+	// Circuit breaker: pause the trades consumer for FailureCooldown after
+	// FailureThreshold consecutive processing failures (0 disables)
+	FailureThreshold int
+	FailureCooldown  time.Duration
+
+	// Retry database calls failing with a transient error up to RetryAttempts
+	// times in total, backing off exponentially from RetryBaseDelay (1 disables)
+	RetryAttempts  int
+	RetryBaseDelay time.Duration
+
+	// ReprocessWindow updates an already stored trade in place when it's
+	// redelivered within this long of executing, instead of skipping it (0 disables)
+	ReprocessWindow time.Duration
+
+	// NotionalTolerance is the relative deviation allowed between a trade's
+	// total_notional and quantity*price before the computed value is used (0 disables)
+	NotionalTolerance float64
+
+	// PositionReopenWindow merges a position that reappears this soon after
+	// closing back into the closed one (0 disables)
+	PositionReopenWindow time.Duration
+
+	// CostBasis is "fifo" to record closes by matching sells to buy lots, or
+	// empty to record them when positions drop out of snapshots
+	CostBasis string
+	// EntryFromTrades rebuilds position entry prices from raw trades when they
+	// cover the whole position, adding buy fees to cost when EntryIncludeFees is set
+	EntryFromTrades  bool
+	EntryIncludeFees bool
+	// EntryRSISource is "at-entry" to record a new position's entry RSI from the
+	// reading on or before its entry date, "latest" for the latest reading, or
+	// empty to leave it unset
+	EntryRSISource string
+	// DefaultEntryReason is recorded on new positions whose monitored stock has no
+	// reason (empty leaves it unset)
+	DefaultEntryReason string
+	// PnlFees is "all" to deduct buy and sell fees from realized P&L, or "sell"
+	// to deduct only sell fees and treat buy fees as cost basis
+	PnlFees string
+
+	// Regular market session used to flag extended-hours trades, as an IANA
+	// timezone and HH:MM open/close times in that zone
+	MarketTimezone string
+	MarketOpen     string
+	MarketClose    string
+	// RejectExtendedHours drops trades executed outside the session instead of flagging them
+	RejectExtendedHours bool
 }
 
 // RedisConfig holds Redis configuration
@@ -47,12 +123,92 @@ type RedisConfig struct {
 	DB       int
 }
 
+// AlertsConfig holds alert evaluation defaults
+type AlertsConfig struct {
+	// RSIOversoldDefault applies to monitored stocks without their own threshold
+	// (0 = only stocks with their own threshold get RSI oversold alerts)
+	RSIOversoldDefault float64
+	// RSIMinDataPoints skips RSI rules when fewer daily candles backed the reading (0 = no minimum)
+	RSIMinDataPoints int
+	// MaxRulesPerSymbol caps alert rules per symbol (0 = unlimited)
+	MaxRulesPerSymbol int
+	// DefaultCooldownMinutes applies to rules created without a cooldown (0 = none)
+	DefaultCooldownMinutes int
+	// Escalate a repeatedly firing rule to high/critical after this many triggers (0 = never)
+	EscalateHighAfter     int
+	EscalateCriticalAfter int
+	// NotifyPositionOpen records an informational alert when a new position appears
+	NotifyPositionOpen bool
+	// NotifyPositionClose records and sends a realized P&L summary on
+	// PositionCloseChannel when a position fully closes
+	NotifyPositionClose  bool
+	PositionCloseChannel string
+	// EvaluationInterval is how often enabled alert rules are checked (0 disables)
+	EvaluationInterval time.Duration
+	// Alert once a day when the portfolio moves this many dollars or percent
+	// either way since the previous close (0 ignores that threshold)
+	BigDayChange float64
+	BigDayPct    float64
+	// MaxLeverage alerts when a positions snapshot shows negative cash or
+	// positions worth more than this multiple of buying power (0 disables)
+	MaxLeverage float64
+	// MaxQuoteAge skips price rules whose stock quote is older than this (0 = no limit)
+	MaxQuoteAge time.Duration
+}
+
+// TelegramConfig holds the bot used to send alert notifications
+type TelegramConfig struct {
+	// APIURL is the Bot API endpoint, overridable for a proxy
+	APIURL   string
+	BotToken string
+	ChatID   string
+}
+
+// Enabled reports whether a bot token and chat are configured
+func (c TelegramConfig) Enabled() bool {
+	return c.BotToken != "" && c.ChatID != ""
+}
+
+// PushoverConfig holds the application and user that pushover alerts are sent with
+type PushoverConfig struct {
+	// APIURL is the message endpoint, overridable for a proxy
+	APIURL   string
+	AppToken string
+	UserKey  string
+}
+
+// Enabled reports whether an app token and user key are configured
+func (c PushoverConfig) Enabled() bool {
+	return c.AppToken != "" && c.UserKey != ""
+}
+
+// SMTPConfig holds the mail server and addresses email alerts are sent with
+type SMTPConfig struct {
+	Host string
+	Port int
+	// Username and Password enable PLAIN auth when Username is set
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// Enabled reports whether a server, sender and at least one recipient are configured
+func (c SMTPConfig) Enabled() bool {
+	return c.Host != "" && c.From != "" && len(c.To) > 0
+}
+
 // Load reads configuration from environment variables
 func Load() *Config {
 	return &Config{
 		Server: ServerConfig{
 			Port: getEnv("SERVER_PORT", "8081"),
 			Host: getEnv("SERVER_HOST", "0.0.0.0"),
+
+			APIKey: getEnv("API_KEY", ""),
+
+			QuantityPrecision: getEnvInt("DISPLAY_QUANTITY_PRECISION", 4),
+			PricePrecision:    getEnvInt("DISPLAY_PRICE_PRECISION", 2),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "postgres"),
@@ -63,12 +219,42 @@ func Load() *Config {
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
 		},
 		Kafka: KafkaConfig{
-			Brokers:        parseBrokers(getEnv("KAFKA_BROKERS", "localhost:19092")),
+			Brokers:        parseList(getEnv("KAFKA_BROKERS", "localhost:19092")),
 			Topic:          getEnv("KAFKA_TOPIC", "stock-events"),
 			TradesTopic:    getEnv("KAFKA_TRADES_TOPIC", "trading.orders"),
 			PositionsTopic: getEnv("KAFKA_POSITIONS_TOPIC", "trading.positions"),
 			WatchlistTopic: getEnv("KAFKA_WATCHLIST_TOPIC", "trading.watchlist"),
 			ConsumerGroup:  getEnv("KAFKA_CONSUMER_GROUP", "stock-service"),
+			Combined:       getEnvBool("KAFKA_COMBINED_CONSUMER", false),
+
+			MaxBytes:        getEnvInt("KAFKA_MAX_BYTES", 10e6),
+			DeadLetterTopic: getEnv("KAFKA_DLQ_TOPIC", ""),
+
+			BatchSize: getEnvInt("KAFKA_BATCH_SIZE", 1),
+			BatchWait: getEnvDuration("KAFKA_BATCH_WAIT", 500*time.Millisecond),
+
+			FailureThreshold: getEnvInt("KAFKA_FAILURE_THRESHOLD", 5),
+			FailureCooldown:  getEnvDuration("KAFKA_FAILURE_COOLDOWN", 30*time.Second),
+
+			RetryAttempts:  getEnvInt("KAFKA_DB_RETRY_ATTEMPTS", 3),
+			RetryBaseDelay: getEnvDuration("KAFKA_DB_RETRY_BASE_DELAY", 200*time.Millisecond),
+
+			ReprocessWindow: getEnvDuration("KAFKA_REPROCESS_WINDOW", 0),
+
+			NotionalTolerance: getEnvFloat("KAFKA_NOTIONAL_TOLERANCE", 0.01),
+
+			PositionReopenWindow: getEnvDuration("POSITION_REOPEN_WINDOW", 0),
+			CostBasis:            strings.ToLower(getEnv("KAFKA_COST_BASIS", "")),
+			PnlFees:              strings.ToLower(getEnv("KAFKA_PNL_FEES", "all")),
+			EntryFromTrades:      getEnvBool("POSITION_ENTRY_FROM_TRADES", true),
+			EntryIncludeFees:     getEnvBool("POSITION_ENTRY_INCLUDE_FEES", true),
+			EntryRSISource:       strings.ToLower(getEnv("POSITION_ENTRY_RSI_SOURCE", "at-entry")),
+			DefaultEntryReason:   getEnv("POSITION_DEFAULT_ENTRY_REASON", ""),
+
+			MarketTimezone:      getEnv("MARKET_TIMEZONE", "America/New_York"),
+			MarketOpen:          getEnv("MARKET_OPEN", "09:30"),
+			MarketClose:         getEnv("MARKET_CLOSE", "16:00"),
+			RejectExtendedHours: getEnvBool("KAFKA_REJECT_EXTENDED_HOURS", false),
 		},
 		Redis: RedisConfig{
 			Host:     getEnv("REDIS_HOST", "localhost"),
@@ -76,6 +262,48 @@ func Load() *Config {
 			Password: getEnv("REDIS_PASSWORD", ""),
 			DB:       0,
 		},
+		Alerts: AlertsConfig{
+			RSIOversoldDefault: getEnvFloat("ALERT_RSI_OVERSOLD_DEFAULT", 30),
+			RSIMinDataPoints:   getEnvInt("ALERT_RSI_MIN_DATA_POINTS", 15),
+			MaxRulesPerSymbol:  getEnvInt("ALERT_MAX_RULES_PER_SYMBOL", 20),
+
+			DefaultCooldownMinutes: getEnvInt("ALERT_DEFAULT_COOLDOWN_MINUTES", 60),
+
+			EscalateHighAfter:     getEnvInt("ALERT_ESCALATE_HIGH_AFTER", 3),
+			EscalateCriticalAfter: getEnvInt("ALERT_ESCALATE_CRITICAL_AFTER", 6),
+
+			NotifyPositionOpen: getEnvBool("ALERT_ON_POSITION_OPEN", false),
+
+			NotifyPositionClose:  getEnvBool("ALERT_ON_POSITION_CLOSE", false),
+			PositionCloseChannel: getEnv("ALERT_POSITION_CLOSE_CHANNEL", "telegram"),
+
+			EvaluationInterval: getEnvDuration("ALERT_EVAL_INTERVAL", time.Minute),
+
+			BigDayChange: getEnvFloat("ALERT_BIG_DAY_CHANGE", 0),
+			BigDayPct:    getEnvFloat("ALERT_BIG_DAY_PCT", 3),
+			MaxLeverage:  getEnvFloat("ALERT_MAX_LEVERAGE", 0),
+
+			MaxQuoteAge: getEnvDuration("ALERT_MAX_QUOTE_AGE", 0),
+		},
+		Telegram: TelegramConfig{
+			APIURL:   getEnv("TELEGRAM_API_URL", "https://api.telegram.org"),
+			BotToken: getEnv("TELEGRAM_BOT_TOKEN", ""),
+			ChatID:   getEnv("TELEGRAM_CHAT_ID", ""),
+		},
+		Pushover: PushoverConfig{
+			APIURL:   getEnv("PUSHOVER_API_URL", "https://api.pushover.net/1/messages.json"),
+			AppToken: getEnv("PUSHOVER_APP_TOKEN", ""),
+			UserKey:  getEnv("PUSHOVER_USER_KEY", ""),
+		},
+		SMTP: SMTPConfig{
+			Host:     getEnv("SMTP_HOST", ""),
+			Port:     getEnvInt("SMTP_PORT", 587),
+			Username: getEnv("SMTP_USERNAME", ""),
+			Password: getEnv("SMTP_PASSWORD", ""),
+			From:     getEnv("SMTP_FROM", ""),
+			To:       parseList(getEnv("SMTP_TO", "")),
+		},
+		SymbolAliases: parseSymbolAliases(getEnv("SYMBOL_ALIASES", "")),
 	}
 }
 
@@ -91,8 +319,60 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-// parseBrokers splits a comma-separated broker list
-func parseBrokers(brokers string) []string {
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid integer for %s=%q, using default %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Invalid number for %s=%q, using default %g", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid boolean for %s=%q, using default %t", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid duration for %s=%q, using default %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// parseList splits a comma-separated list such as brokers, dropping blanks
+func parseList(brokers string) []string {
 	parts := strings.Split(brokers, ",")
 	result := make([]string, 0, len(parts))
 	for _, p := range parts {
@@ -103,6 +383,26 @@ func parseBrokers(brokers string) []string {
 	return result
 }
 
+// parseSymbolAliases parses a comma-separated list of alias=canonical pairs.
+// Aliases are uppercased so lookups ignore case; malformed pairs are skipped.
+func parseSymbolAliases(aliases string) map[string]string {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(aliases, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		alias, canonical, ok := strings.Cut(pair, "=")
+		alias = strings.ToUpper(strings.TrimSpace(alias))
+		canonical = strings.TrimSpace(canonical)
+		if !ok || alias == "" || canonical == "" {
+			log.Printf("Invalid symbol alias %q, expected ALIAS=CANONICAL", pair)
+			continue
+		}
+		result[alias] = canonical
+	}
+	return result
+}
+
 // Address returns the Redis address in host:port format
 func (r *RedisConfig) Address() string {
 	return r.Host + ":" + r.Port
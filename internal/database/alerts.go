@@ -2,6 +2,7 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
@@ -9,8 +10,47 @@ import (
 	"github.com/trogers1052/stock-alert-system/internal/models"
 )
 
-// CreateAlertRule inserts a new alert rule
+// ErrAlertRuleNotFound is returned when no alert rule has the requested ID
+var ErrAlertRuleNotFound = errors.New("alert rule not found")
+
+// ErrAlertRuleLimitExceeded is returned when a symbol already has the maximum number of alert rules
+var ErrAlertRuleLimitExceeded = errors.New("alert rule limit exceeded")
+
+// CreateAlertRule validates and inserts a new alert rule, enforcing the per-symbol
+// limit if one is set. A rule without a cooldown gets the configured default.
+// The limit is checked and the rule inserted in one transaction holding a lock on
+// the symbol, so concurrent creates can't both slip under it.
 func (db *DB) CreateAlertRule(a *models.AlertRule) error {
+	if err := a.Validate(); err != nil {
+		return err
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if db.maxAlertRulesPerSymbol > 0 {
+		// Released when the transaction ends
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, "alert_rules:"+a.Symbol); err != nil {
+			return fmt.Errorf("failed to lock alert rules for %s: %w", a.Symbol, err)
+		}
+		var count int
+		err := tx.QueryRow(`SELECT COUNT(*) FROM alert_rules WHERE symbol = $1`, a.Symbol).Scan(&count)
+		if err != nil {
+			return fmt.Errorf("failed to count alert rules: %w", err)
+		}
+		if count >= db.maxAlertRulesPerSymbol {
+			return fmt.Errorf("%w: %s already has %d rules (max %d)",
+				ErrAlertRuleLimitExceeded, a.Symbol, count, db.maxAlertRulesPerSymbol)
+		}
+	}
+
+	if a.CooldownMinutes == 0 && db.defaultAlertCooldown > 0 {
+		a.CooldownMinutes = db.defaultAlertCooldown
+	}
+
 	query := `
 		INSERT INTO alert_rules (
 			symbol, rule_type, condition_value, comparison, enabled,
@@ -20,7 +60,7 @@ func (db *DB) CreateAlertRule(a *models.AlertRule) error {
 		RETURNING id
 	`
 	now := time.Now()
-	err := db.conn.QueryRow(query,
+	err = tx.QueryRow(query,
 		a.Symbol, a.RuleType, a.ConditionValue, a.Comparison, a.Enabled,
 		a.CooldownMinutes, a.NotificationChannel, a.MessageTemplate, a.Priority,
 		now, now,
@@ -29,6 +69,9 @@ func (db *DB) CreateAlertRule(a *models.AlertRule) error {
 	if err != nil {
 		return fmt.Errorf("failed to create alert rule: %w", err)
 	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 	a.CreatedAt = now
 	a.UpdatedAt = now
 	return nil
@@ -55,7 +98,7 @@ func (db *DB) GetAlertRuleByID(id int) (*models.AlertRule, error) {
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("alert rule not found: %d", id)
+		return nil, fmt.Errorf("%w: %d", ErrAlertRuleNotFound, id)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get alert rule: %w", err)
@@ -113,6 +156,27 @@ func (db *DB) GetEnabledAlertRulesBySymbol(symbol string) ([]*models.AlertRule,
 	return db.scanAlertRules(db.conn.Query(query, symbol))
 }
 
+// GetMostTriggeredRules retrieves the rules that have fired most often, to
+// find noisy rules worth tuning. Rules that never fired are left out; a limit
+// of 0 or less returns every rule that fired.
+func (db *DB) GetMostTriggeredRules(limit int) ([]*models.AlertRule, error) {
+	var limitArg interface{}
+	if limit > 0 {
+		limitArg = limit
+	}
+
+	query := `
+		SELECT id, symbol, rule_type, condition_value, comparison, enabled,
+		       triggered_count, last_triggered_at, cooldown_minutes,
+		       notification_channel, message_template, priority, created_at, updated_at
+		FROM alert_rules
+		WHERE triggered_count > 0
+		ORDER BY triggered_count DESC, id ASC
+		LIMIT $1
+	`
+	return db.scanAlertRules(db.conn.Query(query, limitArg))
+}
+
 func (db *DB) scanAlertRules(rows *sql.Rows, err error) ([]*models.AlertRule, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to query alert rules: %w", err)
@@ -151,8 +215,12 @@ func (db *DB) scanAlertRules(rows *sql.Rows, err error) ([]*models.AlertRule, er
 	return rules, nil
 }
 
-// UpdateAlertRule updates an existing alert rule
+// UpdateAlertRule validates and updates an existing alert rule
 func (db *DB) UpdateAlertRule(a *models.AlertRule) error {
+	if err := a.Validate(); err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE alert_rules SET
 			rule_type = $2, condition_value = $3, comparison = $4, enabled = $5,
@@ -172,7 +240,7 @@ func (db *DB) UpdateAlertRule(a *models.AlertRule) error {
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		return fmt.Errorf("alert rule not found: %d", a.ID)
+		return fmt.Errorf("%w: %d", ErrAlertRuleNotFound, a.ID)
 	}
 	return nil
 }
@@ -204,7 +272,7 @@ func (db *DB) DeleteAlertRule(id int) error {
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		return fmt.Errorf("alert rule not found: %d", id)
+		return fmt.Errorf("%w: %d", ErrAlertRuleNotFound, id)
 	}
 	return nil
 }
@@ -221,7 +289,7 @@ func (db *DB) DeleteAlertRulesBySymbol(symbol string) error {
 
 // --- Alert History ---
 
-// CreateAlertHistory records a triggered alert
+// CreateAlertHistory records a triggered alert at h.TriggeredAt, or now when it's unset
 func (db *DB) CreateAlertHistory(h *models.AlertHistory) error {
 	query := `
 		INSERT INTO alert_history (
@@ -234,15 +302,20 @@ func (db *DB) CreateAlertHistory(h *models.AlertHistory) error {
 	if h.AlertRuleID > 0 {
 		alertRuleID = h.AlertRuleID
 	}
+	triggeredAt := h.TriggeredAt
+	if triggeredAt.IsZero() {
+		triggeredAt = time.Now()
+	}
 
 	err := db.conn.QueryRow(query,
 		alertRuleID, h.Symbol, h.RuleType, h.TriggeredValue,
-		h.Message, h.NotificationSent, h.NotificationChannel, time.Now(),
+		h.Message, h.NotificationSent, h.NotificationChannel, triggeredAt,
 	).Scan(&h.ID)
 
 	if err != nil {
 		return fmt.Errorf("failed to create alert history: %w", err)
 	}
+	h.TriggeredAt = triggeredAt
 	return nil
 }
 
@@ -377,6 +450,62 @@ func (db *DB) DeleteAlertHistory(id int) error {
 	return nil
 }
 
+// ChannelStats counts alerts and delivered notifications for one channel
+type ChannelStats struct {
+	Total    int `json:"total"`
+	Notified int `json:"notified"`
+	Unsent   int `json:"unsent"`
+}
+
+// AlertHistoryStats summarizes notification delivery for alerts triggered since a point in time
+type AlertHistoryStats struct {
+	Since     time.Time               `json:"since"`
+	Total     int                     `json:"total"`
+	Notified  int                     `json:"notified"`
+	Unsent    int                     `json:"unsent"`
+	ByChannel map[string]ChannelStats `json:"by_channel"`
+}
+
+// GetAlertHistoryStats returns delivery counts for alerts triggered at or after since.
+// Alerts without a channel are grouped under the empty string.
+func (db *DB) GetAlertHistoryStats(since time.Time) (*AlertHistoryStats, error) {
+	query := `
+		SELECT COALESCE(notification_channel, ''),
+		       COUNT(*),
+		       COUNT(*) FILTER (WHERE notification_sent)
+		FROM alert_history
+		WHERE triggered_at >= $1
+		GROUP BY COALESCE(notification_channel, '')
+	`
+	rows, err := db.conn.Query(query, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alert history stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := &AlertHistoryStats{
+		Since:     since,
+		ByChannel: make(map[string]ChannelStats),
+	}
+	for rows.Next() {
+		var channel string
+		var cs ChannelStats
+		if err := rows.Scan(&channel, &cs.Total, &cs.Notified); err != nil {
+			return nil, fmt.Errorf("failed to scan alert history stats: %w", err)
+		}
+		cs.Unsent = cs.Total - cs.Notified
+		stats.ByChannel[channel] = cs
+		stats.Total += cs.Total
+		stats.Notified += cs.Notified
+		stats.Unsent += cs.Unsent
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate alert history stats: %w", err)
+	}
+
+	return stats, nil
+}
+
 // DeleteAlertHistoryOlderThan removes alert history older than a specified date
 func (db *DB) DeleteAlertHistoryOlderThan(date time.Time) (int64, error) {
 	query := `DELETE FROM alert_history WHERE triggered_at < $1`
@@ -382,4 +382,208 @@ func TestAlertsRepository(t *testing.T) {
 		require.NoError(t, err)
 		assert.Len(t, remaining, 0)
 	})
+
+	t.Run("CreateAlertRule enforces per-symbol limit", func(t *testing.T) {
+		testDB.TruncateAll(t)
+		createTestStock(t, "AAPL")
+		createTestStock(t, "MSFT")
+
+		testDB.SetMaxAlertRulesPerSymbol(3)
+		defer testDB.SetMaxAlertRulesPerSymbol(0)
+
+		newRule := func(symbol string) *models.AlertRule {
+			return &models.AlertRule{
+				Symbol:              symbol,
+				RuleType:            models.RuleTypePriceTarget,
+				ConditionValue:      decimal.NewFromFloat(200.00),
+				Comparison:          models.ComparisonAbove,
+				Enabled:             true,
+				NotificationChannel: models.ChannelTelegram,
+				Priority:            models.PriorityNormal,
+			}
+		}
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, testDB.CreateAlertRule(newRule("AAPL")))
+		}
+
+		err := testDB.CreateAlertRule(newRule("AAPL"))
+		require.Error(t, err)
+		assert.ErrorIs(t, err, ErrAlertRuleLimitExceeded)
+		assert.Contains(t, err.Error(), "AAPL already has 3 rules (max 3)")
+
+		// Other symbols are counted separately
+		require.NoError(t, testDB.CreateAlertRule(newRule("MSFT")))
+
+		rules, err := testDB.GetAlertRulesBySymbol("AAPL")
+		require.NoError(t, err)
+		assert.Len(t, rules, 3)
+	})
+
+	t.Run("GetAlertHistoryStats reports delivery by channel", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		for _, h := range []struct {
+			channel string
+			sent    bool
+		}{
+			{models.ChannelTelegram, true},
+			{models.ChannelTelegram, true},
+			{models.ChannelTelegram, false},
+			{models.ChannelPushover, false},
+			{"", false},
+		} {
+			err := testDB.CreateAlertHistory(&models.AlertHistory{
+				Symbol:              "STATS",
+				RuleType:            models.RuleTypePriceTarget,
+				TriggeredValue:      decimal.NewFromFloat(100.00),
+				NotificationSent:    h.sent,
+				NotificationChannel: h.channel,
+			})
+			require.NoError(t, err)
+		}
+
+		stats, err := testDB.GetAlertHistoryStats(time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 5, stats.Total)
+		assert.Equal(t, 2, stats.Notified)
+		assert.Equal(t, 3, stats.Unsent)
+		assert.Equal(t, ChannelStats{Total: 3, Notified: 2, Unsent: 1}, stats.ByChannel[models.ChannelTelegram])
+		assert.Equal(t, ChannelStats{Total: 1, Notified: 0, Unsent: 1}, stats.ByChannel[models.ChannelPushover])
+		assert.Equal(t, ChannelStats{Total: 1, Notified: 0, Unsent: 1}, stats.ByChannel[""])
+
+		// Nothing triggered after now
+		stats, err = testDB.GetAlertHistoryStats(time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		assert.Zero(t, stats.Total)
+		assert.Empty(t, stats.ByChannel)
+	})
+
+	t.Run("CreateAlertRule applies default cooldown", func(t *testing.T) {
+		testDB.TruncateAll(t)
+		createTestStock(t, "AAPL")
+
+		testDB.SetDefaultAlertCooldown(45)
+		defer testDB.SetDefaultAlertCooldown(0)
+
+		unset := &models.AlertRule{
+			Symbol:              "AAPL",
+			RuleType:            models.RuleTypePriceTarget,
+			ConditionValue:      decimal.NewFromFloat(200.00),
+			Comparison:          models.ComparisonAbove,
+			Enabled:             true,
+			NotificationChannel: models.ChannelTelegram,
+			Priority:            models.PriorityNormal,
+		}
+		require.NoError(t, testDB.CreateAlertRule(unset))
+
+		explicit := *unset
+		explicit.CooldownMinutes = 5
+		require.NoError(t, testDB.CreateAlertRule(&explicit))
+
+		got, err := testDB.GetAlertRuleByID(unset.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 45, got.CooldownMinutes)
+
+		got, err = testDB.GetAlertRuleByID(explicit.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 5, got.CooldownMinutes)
+	})
+
+	t.Run("CreateAlertRule and UpdateAlertRule reject unknown values", func(t *testing.T) {
+		testDB.TruncateAll(t)
+		createTestStock(t, "AAPL")
+
+		rule := &models.AlertRule{
+			Symbol:              "AAPL",
+			RuleType:            "PRICE_TAGRET",
+			ConditionValue:      decimal.NewFromFloat(200.00),
+			Comparison:          models.ComparisonAbove,
+			Enabled:             true,
+			NotificationChannel: models.ChannelTelegram,
+			Priority:            models.PriorityNormal,
+		}
+		err := testDB.CreateAlertRule(rule)
+		assert.ErrorIs(t, err, models.ErrInvalidAlertRule)
+
+		rules, err := testDB.GetAlertRulesBySymbol("AAPL")
+		require.NoError(t, err)
+		assert.Empty(t, rules, "invalid rule must not be persisted")
+
+		rule.RuleType = models.RuleTypePriceTarget
+		require.NoError(t, testDB.CreateAlertRule(rule))
+
+		rule.Comparison = "GREATER"
+		err = testDB.UpdateAlertRule(rule)
+		assert.ErrorIs(t, err, models.ErrInvalidAlertRule)
+
+		got, err := testDB.GetAlertRuleByID(rule.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.ComparisonAbove, got.Comparison)
+	})
+
+	t.Run("GetMostTriggeredRules ranks rules by trigger count", func(t *testing.T) {
+		testDB.TruncateAll(t)
+		createTestStock(t, "NFLX")
+
+		counts := []int{2, 5, 0, 3}
+		ids := make([]int, len(counts))
+		for i, n := range counts {
+			rule := &models.AlertRule{
+				Symbol:              "NFLX",
+				RuleType:            models.RuleTypePriceTarget,
+				ConditionValue:      decimal.NewFromInt(int64(500 + i)),
+				Comparison:          models.ComparisonAbove,
+				Enabled:             true,
+				NotificationChannel: models.ChannelTelegram,
+				Priority:            models.PriorityNormal,
+			}
+			require.NoError(t, testDB.CreateAlertRule(rule))
+			ids[i] = rule.ID
+			for j := 0; j < n; j++ {
+				require.NoError(t, testDB.MarkAlertTriggered(rule.ID))
+			}
+		}
+
+		rules, err := testDB.GetMostTriggeredRules(10)
+		require.NoError(t, err)
+		require.Len(t, rules, 3)
+		assert.Equal(t, []int{ids[1], ids[3], ids[0]}, []int{rules[0].ID, rules[1].ID, rules[2].ID})
+		assert.Equal(t, 5, rules[0].TriggeredCount)
+
+		top, err := testDB.GetMostTriggeredRules(1)
+		require.NoError(t, err)
+		require.Len(t, top, 1)
+		assert.Equal(t, ids[1], top[0].ID)
+
+		// No limit returns every rule that fired
+		all, err := testDB.GetMostTriggeredRules(0)
+		require.NoError(t, err)
+		assert.Len(t, all, 3)
+	})
+
+	t.Run("CreateAlertHistory keeps a set trigger time", func(t *testing.T) {
+		testDB.TruncateAll(t)
+		createTestStock(t, "AAPL")
+
+		executed := time.Date(2026, 3, 10, 14, 30, 0, 0, time.UTC)
+		history := &models.AlertHistory{
+			Symbol:              "AAPL",
+			RuleType:            models.RuleTypePositionClosed,
+			TriggeredValue:      decimal.NewFromFloat(120),
+			Message:             "Closed AAPL",
+			NotificationChannel: models.ChannelTelegram,
+			TriggeredAt:         executed,
+		}
+		require.NoError(t, testDB.CreateAlertHistory(history))
+
+		retrieved, err := testDB.GetAlertHistoryByID(history.ID)
+		require.NoError(t, err)
+		assert.True(t, executed.Equal(retrieved.TriggeredAt), "got %s", retrieved.TriggeredAt)
+
+		before := time.Now().Add(-time.Second)
+		unset := &models.AlertHistory{Symbol: "AAPL", RuleType: models.RuleTypeTargetHit, Message: "AAPL hit target", NotificationChannel: models.ChannelTelegram}
+		require.NoError(t, testDB.CreateAlertHistory(unset))
+		assert.True(t, unset.TriggeredAt.After(before))
+	})
 }
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+func newLimitedRule() *models.AlertRule {
+	return &models.AlertRule{
+		Symbol:              "AAPL",
+		RuleType:            models.RuleTypePriceTarget,
+		ConditionValue:      decimal.NewFromInt(200),
+		Comparison:          models.ComparisonAbove,
+		Enabled:             true,
+		NotificationChannel: models.ChannelTelegram,
+		Priority:            models.PriorityNormal,
+	}
+}
+
+func TestCreateAlertRule_ChecksLimitUnderSymbolLock(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	db := &DB{conn: sqlDB}
+	db.SetMaxAlertRulesPerSymbol(2)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock\(hashtext\(\$1\)\)`).
+		WithArgs("alert_rules:AAPL").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM alert_rules WHERE symbol = \$1`).
+		WithArgs("AAPL").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("INSERT INTO alert_rules").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectCommit()
+
+	rule := newLimitedRule()
+	require.NoError(t, db.CreateAlertRule(rule))
+	assert.Equal(t, 7, rule.ID)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateAlertRule_RollsBackAtLimit(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	db := &DB{conn: sqlDB}
+	db.SetMaxAlertRulesPerSymbol(2)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM alert_rules`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectRollback()
+
+	err = db.CreateAlertRule(newLimitedRule())
+	assert.ErrorIs(t, err, ErrAlertRuleLimitExceeded)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+func newSellWithClose() (*models.RawTrade, []*models.ClosedLot) {
+	executedAt := time.Date(2026, 1, 18, 15, 0, 0, 0, time.UTC)
+	sell := &models.RawTrade{
+		OrderID:    "sell-1",
+		Source:     "robinhood",
+		Symbol:     "AAPL",
+		Side:       models.TradeTypeSell,
+		Quantity:   decimal.NewFromInt(4),
+		Price:      decimal.NewFromInt(110),
+		TotalCost:  decimal.NewFromInt(440),
+		ExecutedAt: executedAt,
+	}
+	closed := &models.TradeHistory{
+		Symbol:      "AAPL",
+		TradeType:   models.TradeTypeSell,
+		Quantity:    decimal.NewFromInt(4),
+		Price:       decimal.NewFromInt(110),
+		TotalCost:   decimal.NewFromInt(440),
+		RealizedPnl: decimal.NewFromInt(40),
+		ExecutedAt:  executedAt,
+	}
+	return sell, []*models.ClosedLot{{Trade: closed, BuyTradeID: 2}}
+}
+
+func TestCreateRawTradeWithCloses_LinksAndCommitsTogether(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	db := &DB{conn: sqlDB}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO raw_trades").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectQuery("INSERT INTO trades_history").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectExec(`UPDATE raw_trades SET trade_history_id = \$1 WHERE id = ANY\(\$2\)`).
+		WithArgs(9, pq.Array([]int{3, 2})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	sell, closes := newSellWithClose()
+	linked, err := db.CreateRawTradeWithCloses(sell, closes)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), linked)
+	assert.Equal(t, 3, sell.ID)
+	assert.Equal(t, 9, closes[0].Trade.ID)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateRawTradeWithCloses_RollsBackWhenACloseFails(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	db := &DB{conn: sqlDB}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO raw_trades").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectQuery("INSERT INTO trades_history").WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	sell, closes := newSellWithClose()
+	_, err = db.CreateRawTradeWithCloses(sell, closes)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to create trade history")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -10,6 +10,18 @@ import (
 // DB wraps the database connection
 type DB struct {
 	conn *sql.DB
+
+	// maxAlertRulesPerSymbol caps rules created per symbol (0 = unlimited)
+	maxAlertRulesPerSymbol int
+	// defaultAlertCooldown applies to rules created without a cooldown (0 = none)
+	defaultAlertCooldown int
+}
+
+// execer runs statements on either the connection or a transaction, so inserts
+// can be shared by both
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
 }
 
 // New creates a new database connection
@@ -26,6 +38,11 @@ func New(connectionString string) (*DB, error) {
 	return &DB{conn: conn}, nil
 }
 
+// NewWithConn wraps an already-open connection, e.g. a mock in tests
+func NewWithConn(conn *sql.DB) *DB {
+	return &DB{conn: conn}
+}
+
 // Close closes the database connection
 func (db *DB) Close() error {
 	return db.conn.Close()
@@ -40,3 +57,20 @@ func (db *DB) Conn() *sql.DB {
 func (db *DB) Ping() error {
 	return db.conn.Ping()
 }
+
+// Stats returns connection pool statistics
+func (db *DB) Stats() sql.DBStats {
+	return db.conn.Stats()
+}
+
+// SetMaxAlertRulesPerSymbol limits how many alert rules CreateAlertRule allows per symbol.
+// Zero or less removes the limit.
+func (db *DB) SetMaxAlertRulesPerSymbol(n int) {
+	db.maxAlertRulesPerSymbol = n
+}
+
+// SetDefaultAlertCooldown sets the cooldown, in minutes, CreateAlertRule gives rules
+// that don't specify one. Zero or less leaves such rules without a cooldown.
+func (db *DB) SetDefaultAlertCooldown(minutes int) {
+	db.defaultAlertCooldown = minutes
+}
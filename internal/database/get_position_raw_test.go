@@ -0,0 +1,26 @@
+package database
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetPositionRaw_UnknownSymbolIsPositionNotFound(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	db := &DB{conn: sqlDB}
+
+	mock.ExpectQuery("FROM positions").WithArgs("NOPE").WillReturnError(sql.ErrNoRows)
+
+	raw, err := db.GetPositionRaw("NOPE")
+	assert.Nil(t, raw)
+	assert.ErrorIs(t, err, ErrPositionNotFound)
+	assert.Contains(t, err.Error(), "NOPE")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,33 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+func TestGetIndicatorRange_ReportsIterationError(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	db := &DB{conn: sqlDB}
+
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "symbol", "date", "indicator_type", "value", "timeframe", "created_at"}).
+		AddRow(1, "AAPL", day, models.IndicatorRSI14, "45.5", "1d", day).
+		AddRow(2, "AAPL", day.AddDate(0, 0, 1), models.IndicatorRSI14, "47.1", "1d", day).
+		RowError(1, errors.New("connection reset"))
+	mock.ExpectQuery("FROM technical_indicators").WillReturnRows(rows)
+
+	indicators, err := db.GetIndicatorRange("AAPL", models.IndicatorRSI14, day, day.AddDate(0, 0, 7))
+	assert.Nil(t, indicators)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to iterate indicator range")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
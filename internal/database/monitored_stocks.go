@@ -138,6 +138,21 @@ func (db *DB) GetMonitoredStocksByPriority(priority int) ([]*models.MonitoredSto
 	return db.scanMonitoredStocks(db.conn.Query(query, priority))
 }
 
+// GetMonitoredStocksUpdatedSince retrieves monitored stocks added or changed after
+// since, oldest change first, so a sync client can pass the last updated_at it saw.
+// Deleted stocks aren't reported.
+func (db *DB) GetMonitoredStocksUpdatedSince(since time.Time) ([]*models.MonitoredStock, error) {
+	query := `
+		SELECT symbol, enabled, priority, buy_zone_low, buy_zone_high,
+		       target_price, stop_loss_price, alert_on_buy_zone, alert_on_rsi_oversold,
+		       rsi_oversold_threshold, notes, reason, added_at, updated_at
+		FROM monitored_stocks
+		WHERE updated_at > $1
+		ORDER BY updated_at ASC, symbol ASC
+	`
+	return db.scanMonitoredStocks(db.conn.Query(query, since))
+}
+
 // GetMonitoredSymbols returns just the symbols of enabled monitored stocks
 func (db *DB) GetMonitoredSymbols() ([]string, error) {
 	query := `
@@ -340,3 +355,40 @@ func (db *DB) GetStocksInBuyZone() ([]*models.MonitoredStock, error) {
 	`
 	return db.scanMonitoredStocks(db.conn.Query(query))
 }
+
+// GetStocksRSIOversold returns enabled stocks with RSI alerts on whose latest RSI is at or
+// below their oversold threshold. Stocks without their own threshold use defaultThreshold,
+// or are left out when it is zero or less.
+func (db *DB) GetStocksRSIOversold(defaultThreshold float64) ([]*models.MonitoredStock, error) {
+	return db.getStocksRSIOversold(sql.NullFloat64{Float64: defaultThreshold, Valid: defaultThreshold > 0})
+}
+
+// GetStocksBelowRSIThreshold returns enabled stocks with RSI alerts on whose latest
+// RSI is at or below their own oversold threshold. Unlike GetStocksRSIOversold,
+// stocks without a threshold are skipped rather than given a default.
+func (db *DB) GetStocksBelowRSIThreshold() ([]*models.MonitoredStock, error) {
+	return db.getStocksRSIOversold(sql.NullFloat64{})
+}
+
+// getStocksRSIOversold compares each stock's latest RSI with its own threshold, or
+// defaultThreshold when it has none. A NULL default leaves those stocks out.
+func (db *DB) getStocksRSIOversold(defaultThreshold sql.NullFloat64) ([]*models.MonitoredStock, error) {
+	query := `
+		SELECT ms.symbol, ms.enabled, ms.priority, ms.buy_zone_low, ms.buy_zone_high,
+		       ms.target_price, ms.stop_loss_price, ms.alert_on_buy_zone, ms.alert_on_rsi_oversold,
+		       ms.rsi_oversold_threshold, ms.notes, ms.reason, ms.added_at, ms.updated_at
+		FROM monitored_stocks ms
+		JOIN LATERAL (
+			SELECT ti.value
+			FROM technical_indicators ti
+			WHERE ti.symbol = ms.symbol AND ti.indicator_type = $1
+			ORDER BY ti.date DESC
+			LIMIT 1
+		) rsi ON true
+		WHERE ms.enabled = true
+		  AND ms.alert_on_rsi_oversold = true
+		  AND rsi.value <= COALESCE(ms.rsi_oversold_threshold, $2)
+		ORDER BY ms.priority ASC, ms.symbol ASC
+	`
+	return db.scanMonitoredStocks(db.conn.Query(query, models.IndicatorRSI14, defaultThreshold))
+}
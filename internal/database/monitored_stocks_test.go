@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/trogers1052/stock-alert-system/internal/models"
@@ -447,4 +448,157 @@ func TestMonitoredStocksRepository(t *testing.T) {
 		require.NoError(t, err)
 		assert.Len(t, inZone, 0)
 	})
+
+	t.Run("GetStocksRSIOversold applies default threshold when unset", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		ownThreshold := 40.0
+		for _, data := range []struct {
+			symbol    string
+			rsi       float64
+			threshold *float64
+		}{
+			{"DEFLOW", 28, nil},            // Below default 30
+			{"DEFHIGH", 35, nil},           // Above default 30
+			{"OWNLOW", 35, &ownThreshold},  // Below own threshold 40
+			{"OWNHIGH", 45, &ownThreshold}, // Above own threshold 40
+		} {
+			require.NoError(t, testDB.UpsertStockBasic(data.symbol, data.symbol+" Inc."))
+			require.NoError(t, testDB.CreateMonitoredStock(&models.MonitoredStock{
+				Symbol:               data.symbol,
+				Enabled:              true,
+				Priority:             1,
+				AlertOnRSIOversold:   true,
+				RSIOversoldThreshold: data.threshold,
+			}))
+			// Older reading should be ignored in favour of the latest
+			require.NoError(t, testDB.CreateTechnicalIndicator(&models.TechnicalIndicator{
+				Symbol:        data.symbol,
+				Date:          time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC),
+				IndicatorType: models.IndicatorRSI14,
+				Value:         decimal.NewFromFloat(10),
+			}))
+			require.NoError(t, testDB.CreateTechnicalIndicator(&models.TechnicalIndicator{
+				Symbol:        data.symbol,
+				Date:          time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+				IndicatorType: models.IndicatorRSI14,
+				Value:         decimal.NewFromFloat(data.rsi),
+			}))
+		}
+
+		oversold, err := testDB.GetStocksRSIOversold(30)
+		require.NoError(t, err)
+		symbols := make([]string, len(oversold))
+		for i, s := range oversold {
+			symbols[i] = s.Symbol
+		}
+		assert.ElementsMatch(t, []string{"DEFLOW", "OWNLOW"}, symbols)
+
+		// A lower default excludes stocks relying on it
+		oversold, err = testDB.GetStocksRSIOversold(25)
+		require.NoError(t, err)
+		require.Len(t, oversold, 1)
+		assert.Equal(t, "OWNLOW", oversold[0].Symbol)
+		assert.Equal(t, 40.0, oversold[0].EffectiveRSIOversoldThreshold(25))
+
+		// Without a default, stocks with their own threshold still qualify
+		oversold, err = testDB.GetStocksRSIOversold(0)
+		require.NoError(t, err)
+		require.Len(t, oversold, 1)
+		assert.Equal(t, "OWNLOW", oversold[0].Symbol)
+	})
+
+	t.Run("GetMonitoredStocksUpdatedSince returns only changed stocks", func(t *testing.T) {
+		testDB.TruncateAll(t)
+		for _, symbol := range []string{"SYNCA", "SYNCB", "SYNCC"} {
+			createTestStock(t, symbol)
+			require.NoError(t, testDB.CreateMonitoredStock(&models.MonitoredStock{
+				Symbol: symbol, Enabled: true, Priority: 1,
+			}))
+		}
+
+		all, err := testDB.GetMonitoredStocksUpdatedSince(time.Time{})
+		require.NoError(t, err)
+		assert.Len(t, all, 3)
+
+		checkpoint := all[len(all)-1].UpdatedAt
+		time.Sleep(10 * time.Millisecond)
+		require.NoError(t, testDB.SetBuyZone("SYNCB", 95.00, 100.00))
+
+		changed, err := testDB.GetMonitoredStocksUpdatedSince(checkpoint)
+		require.NoError(t, err)
+		require.Len(t, changed, 1)
+		assert.Equal(t, "SYNCB", changed[0].Symbol)
+		assert.Equal(t, 95.00, *changed[0].BuyZoneLow)
+		assert.True(t, changed[0].UpdatedAt.After(checkpoint))
+	})
+
+	t.Run("GetStocksBelowRSIThreshold returns only enabled stocks below their own threshold", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		threshold := 30.0
+		for _, data := range []struct {
+			symbol    string
+			rsi       float64
+			threshold *float64
+			enabled   bool
+			alertOn   bool
+		}{
+			{"BELOW", 25, &threshold, true, true},
+			{"ATTHRESH", 30, &threshold, true, true},
+			{"ABOVE", 45, &threshold, true, true},
+			{"NOTHRESH", 10, nil, true, true},
+			{"DISABLED", 20, &threshold, false, true},
+			{"ALERTOFF", 20, &threshold, true, false},
+		} {
+			require.NoError(t, testDB.UpsertStockBasic(data.symbol, data.symbol+" Inc."))
+			require.NoError(t, testDB.CreateMonitoredStock(&models.MonitoredStock{
+				Symbol:               data.symbol,
+				Enabled:              data.enabled,
+				Priority:             1,
+				AlertOnRSIOversold:   data.alertOn,
+				RSIOversoldThreshold: data.threshold,
+			}))
+			// Only the latest reading counts
+			require.NoError(t, testDB.CreateTechnicalIndicator(&models.TechnicalIndicator{
+				Symbol:        data.symbol,
+				Date:          time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC),
+				IndicatorType: models.IndicatorRSI14,
+				Value:         decimal.NewFromFloat(60),
+			}))
+			require.NoError(t, testDB.CreateTechnicalIndicator(&models.TechnicalIndicator{
+				Symbol:        data.symbol,
+				Date:          time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+				IndicatorType: models.IndicatorRSI14,
+				Value:         decimal.NewFromFloat(data.rsi),
+			}))
+		}
+
+		stocks, err := testDB.GetStocksBelowRSIThreshold()
+		require.NoError(t, err)
+		symbols := make([]string, len(stocks))
+		for i, s := range stocks {
+			symbols[i] = s.Symbol
+		}
+		assert.Equal(t, []string{"ATTHRESH", "BELOW"}, symbols)
+	})
+
+	t.Run("CreateMonitoredStock needs a stock row for a new symbol", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		monitored := &models.MonitoredStock{Symbol: "NEWCO", Enabled: true}
+		err := testDB.CreateMonitoredStock(monitored)
+		require.Error(t, err, "monitored_stocks.symbol references stocks")
+
+		exists, err := testDB.StockExists("NEWCO")
+		require.NoError(t, err)
+		require.False(t, exists)
+
+		require.NoError(t, testDB.UpsertStockBasic("NEWCO", "NEWCO"))
+		require.NoError(t, testDB.CreateMonitoredStock(monitored))
+
+		retrieved, err := testDB.GetMonitoredStockBySymbol("NEWCO")
+		require.NoError(t, err)
+		assert.True(t, retrieved.Enabled)
+	})
 }
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSymbolsWithOpenPositions_ReportsIterationError(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	db := &DB{conn: sqlDB}
+
+	rows := sqlmock.NewRows([]string{"symbol"}).
+		AddRow("AAPL").
+		AddRow("MSFT").
+		RowError(1, errors.New("connection reset"))
+	mock.ExpectQuery("SELECT DISTINCT symbol").WillReturnRows(rows)
+
+	symbols, err := db.GetSymbolsWithOpenPositions()
+	assert.Nil(t, symbols)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to iterate open position symbols")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
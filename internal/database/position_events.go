@@ -0,0 +1,60 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+// CreatePositionEvent appends a position lifecycle event
+func (db *DB) CreatePositionEvent(e *models.PositionEvent) error {
+	query := `
+		INSERT INTO position_events (
+			symbol, event_type, quantity, position_quantity, price, occurred_at, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+	now := time.Now()
+	err := db.conn.QueryRow(query,
+		e.Symbol, e.EventType, e.Quantity, e.PositionQuantity, e.Price, e.OccurredAt, now,
+	).Scan(&e.ID)
+
+	if err != nil {
+		return fmt.Errorf("failed to create position event: %w", err)
+	}
+	e.CreatedAt = now
+	return nil
+}
+
+// GetPositionEvents returns a symbol's lifecycle events in the order they occurred
+func (db *DB) GetPositionEvents(symbol string) ([]*models.PositionEvent, error) {
+	query := `
+		SELECT id, symbol, event_type, quantity, position_quantity, price, occurred_at, created_at
+		FROM position_events
+		WHERE symbol = $1
+		ORDER BY occurred_at ASC, id ASC
+	`
+	rows, err := db.conn.Query(query, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get position events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*models.PositionEvent
+	for rows.Next() {
+		var e models.PositionEvent
+		err := rows.Scan(
+			&e.ID, &e.Symbol, &e.EventType, &e.Quantity, &e.PositionQuantity, &e.Price, &e.OccurredAt, &e.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan position event: %w", err)
+		}
+		events = append(events, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate position events: %w", err)
+	}
+
+	return events, nil
+}
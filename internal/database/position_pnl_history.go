@@ -0,0 +1,65 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+// CreatePositionPnlSnapshot records a position's unrealized P&L at a point in time
+func (db *DB) CreatePositionPnlSnapshot(s *models.PositionPnlSnapshot) error {
+	query := `
+		INSERT INTO position_pnl_history (
+			symbol, quantity, entry_price, current_price, unrealized_pnl,
+			unrealized_pnl_pct, recorded_at, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`
+	now := time.Now()
+	err := db.conn.QueryRow(query,
+		s.Symbol, s.Quantity, s.EntryPrice, s.CurrentPrice, s.UnrealizedPnl,
+		s.UnrealizedPnlPct, s.RecordedAt, now,
+	).Scan(&s.ID)
+
+	if err != nil {
+		return fmt.Errorf("failed to create position P&L snapshot: %w", err)
+	}
+	s.CreatedAt = now
+	return nil
+}
+
+// GetPositionPnlHistory returns a symbol's P&L snapshots recorded between start
+// and end inclusive, oldest first
+func (db *DB) GetPositionPnlHistory(symbol string, start, end time.Time) ([]*models.PositionPnlSnapshot, error) {
+	query := `
+		SELECT id, symbol, quantity, entry_price, current_price, unrealized_pnl,
+		       unrealized_pnl_pct, recorded_at, created_at
+		FROM position_pnl_history
+		WHERE symbol = $1 AND recorded_at BETWEEN $2 AND $3
+		ORDER BY recorded_at ASC, id ASC
+	`
+	rows, err := db.conn.Query(query, symbol, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get position P&L history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []*models.PositionPnlSnapshot
+	for rows.Next() {
+		var s models.PositionPnlSnapshot
+		err := rows.Scan(
+			&s.ID, &s.Symbol, &s.Quantity, &s.EntryPrice, &s.CurrentPrice, &s.UnrealizedPnl,
+			&s.UnrealizedPnlPct, &s.RecordedAt, &s.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan position P&L snapshot: %w", err)
+		}
+		history = append(history, &s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate position P&L history: %w", err)
+	}
+
+	return history, nil
+}
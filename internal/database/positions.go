@@ -2,28 +2,35 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/shopspring/decimal"
 	"github.com/trogers1052/stock-alert-system/internal/models"
 )
 
+// ErrPositionNotFound is returned when no position row exists for a symbol
+var ErrPositionNotFound = errors.New("position not found")
+
 // CreatePosition inserts a new position into the database
 func (db *DB) CreatePosition(p *models.Position) error {
 	query := `
 		INSERT INTO positions (
 			symbol, quantity, entry_price, entry_date, current_price,
 			unrealized_pnl_pct, days_held, entry_rsi, entry_reason,
-			sector, industry, position_size_pct, created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+			sector, industry, position_size_pct, realized_pnl, notes, tags, lowest_price, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
 		RETURNING id
 	`
+	p.ObservePrice(p.CurrentPrice)
 	now := time.Now()
 	err := db.conn.QueryRow(query,
 		p.Symbol, p.Quantity, p.EntryPrice, p.EntryDate, p.CurrentPrice,
 		p.UnrealizedPnlPct, p.DaysHeld, p.EntryRSI, p.EntryReason,
-		p.Sector, p.Industry, p.PositionSizePct, now, now,
+		p.Sector, p.Industry, p.PositionSizePct, p.RealizedPnl,
+		sql.NullString{String: p.Notes, Valid: p.Notes != ""}, pq.Array(p.Tags), nullablePositive(p.LowestPrice), now, now,
 	).Scan(&p.ID)
 
 	if err != nil {
@@ -39,19 +46,19 @@ func (db *DB) GetPositionByID(id int) (*models.Position, error) {
 	query := `
 		SELECT id, symbol, quantity, entry_price, entry_date, current_price,
 		       unrealized_pnl_pct, days_held, entry_rsi, entry_reason,
-		       sector, industry, position_size_pct, created_at, updated_at
+		       sector, industry, position_size_pct, realized_pnl, notes, tags, lowest_price, created_at, updated_at
 		FROM positions
 		WHERE id = $1
 	`
 	var p models.Position
-	var currentPrice, unrealizedPnlPct, entryRSI, positionSizePct sql.NullString
+	var currentPrice, unrealizedPnlPct, entryRSI, positionSizePct, realizedPnl, lowestPrice sql.NullString
 	var daysHeld sql.NullInt64
-	var entryReason, sector, industry sql.NullString
+	var entryReason, sector, industry, notes sql.NullString
 
 	err := db.conn.QueryRow(query, id).Scan(
 		&p.ID, &p.Symbol, &p.Quantity, &p.EntryPrice, &p.EntryDate, &currentPrice,
 		&unrealizedPnlPct, &daysHeld, &entryRSI, &entryReason,
-		&sector, &industry, &positionSizePct, &p.CreatedAt, &p.UpdatedAt,
+		&sector, &industry, &positionSizePct, &realizedPnl, &notes, pq.Array(&p.Tags), &lowestPrice, &p.CreatedAt, &p.UpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -85,6 +92,13 @@ func (db *DB) GetPositionByID(id int) (*models.Position, error) {
 	if positionSizePct.Valid {
 		p.PositionSizePct, _ = decimal.NewFromString(positionSizePct.String)
 	}
+	if realizedPnl.Valid {
+		p.RealizedPnl, _ = decimal.NewFromString(realizedPnl.String)
+	}
+	if lowestPrice.Valid {
+		p.LowestPrice, _ = decimal.NewFromString(lowestPrice.String)
+	}
+	p.Notes = notes.String
 
 	return &p, nil
 }
@@ -94,23 +108,23 @@ func (db *DB) GetPositionBySymbol(symbol string) (*models.Position, error) {
 	query := `
 		SELECT id, symbol, quantity, entry_price, entry_date, current_price,
 		       unrealized_pnl_pct, days_held, entry_rsi, entry_reason,
-		       sector, industry, position_size_pct, created_at, updated_at
+		       sector, industry, position_size_pct, realized_pnl, notes, tags, lowest_price, created_at, updated_at
 		FROM positions
 		WHERE symbol = $1
 	`
 	var p models.Position
-	var currentPrice, unrealizedPnlPct, entryRSI, positionSizePct sql.NullString
+	var currentPrice, unrealizedPnlPct, entryRSI, positionSizePct, realizedPnl, lowestPrice sql.NullString
 	var daysHeld sql.NullInt64
-	var entryReason, sector, industry sql.NullString
+	var entryReason, sector, industry, notes sql.NullString
 
 	err := db.conn.QueryRow(query, symbol).Scan(
 		&p.ID, &p.Symbol, &p.Quantity, &p.EntryPrice, &p.EntryDate, &currentPrice,
 		&unrealizedPnlPct, &daysHeld, &entryRSI, &entryReason,
-		&sector, &industry, &positionSizePct, &p.CreatedAt, &p.UpdatedAt,
+		&sector, &industry, &positionSizePct, &realizedPnl, &notes, pq.Array(&p.Tags), &lowestPrice, &p.CreatedAt, &p.UpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("position not found for symbol: %s", symbol)
+		return nil, fmt.Errorf("%w for symbol: %s", ErrPositionNotFound, symbol)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get position: %w", err)
@@ -140,20 +154,171 @@ func (db *DB) GetPositionBySymbol(symbol string) (*models.Position, error) {
 	if positionSizePct.Valid {
 		p.PositionSizePct, _ = decimal.NewFromString(positionSizePct.String)
 	}
+	if realizedPnl.Valid {
+		p.RealizedPnl, _ = decimal.NewFromString(realizedPnl.String)
+	}
+	if lowestPrice.Valid {
+		p.LowestPrice, _ = decimal.NewFromString(lowestPrice.String)
+	}
+	p.Notes = notes.String
 
 	return &p, nil
 }
 
+// GetPositionBySymbolOrNil retrieves a position by symbol, returning nil without
+// an error when the symbol isn't held
+func (db *DB) GetPositionBySymbolOrNil(symbol string) (*models.Position, error) {
+	p, err := db.GetPositionBySymbol(symbol)
+	if errors.Is(err, ErrPositionNotFound) {
+		return nil, nil
+	}
+	return p, err
+}
+
+// PositionRaw holds a position's numeric columns exactly as PostgreSQL renders them,
+// at the full scale of each DECIMAL column. Nullable columns are empty when NULL.
+type PositionRaw struct {
+	Symbol           string `json:"symbol"`
+	Quantity         string `json:"quantity"`
+	EntryPrice       string `json:"entry_price"`
+	CurrentPrice     string `json:"current_price,omitempty"`
+	UnrealizedPnlPct string `json:"unrealized_pnl_pct,omitempty"`
+	EntryRSI         string `json:"entry_rsi,omitempty"`
+	PositionSizePct  string `json:"position_size_pct,omitempty"`
+	RealizedPnl      string `json:"realized_pnl,omitempty"`
+}
+
+// GetPositionRaw retrieves a position's numeric columns as text, for auditing precision
+func (db *DB) GetPositionRaw(symbol string) (*PositionRaw, error) {
+	query := `
+		SELECT symbol, quantity::text, entry_price::text, current_price::text,
+		       unrealized_pnl_pct::text, entry_rsi::text, position_size_pct::text,
+		       realized_pnl::text
+		FROM positions
+		WHERE symbol = $1
+	`
+	var raw PositionRaw
+	var currentPrice, unrealizedPnlPct, entryRSI, positionSizePct, realizedPnl sql.NullString
+
+	err := db.conn.QueryRow(query, symbol).Scan(
+		&raw.Symbol, &raw.Quantity, &raw.EntryPrice, &currentPrice,
+		&unrealizedPnlPct, &entryRSI, &positionSizePct, &realizedPnl,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w for symbol: %s", ErrPositionNotFound, symbol)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get raw position: %w", err)
+	}
+
+	raw.CurrentPrice = currentPrice.String
+	raw.UnrealizedPnlPct = unrealizedPnlPct.String
+	raw.EntryRSI = entryRSI.String
+	raw.PositionSizePct = positionSizePct.String
+	raw.RealizedPnl = realizedPnl.String
+
+	return &raw, nil
+}
+
 // GetAllPositions retrieves all positions
 func (db *DB) GetAllPositions() ([]*models.Position, error) {
 	query := `
 		SELECT id, symbol, quantity, entry_price, entry_date, current_price,
 		       unrealized_pnl_pct, days_held, entry_rsi, entry_reason,
-		       sector, industry, position_size_pct, created_at, updated_at
+		       sector, industry, position_size_pct, realized_pnl, notes, tags, lowest_price, created_at, updated_at
 		FROM positions
 		ORDER BY entry_date DESC
 	`
+	return db.scanPositions(db.conn.Query(query))
+}
+
+// GetPositionsSortedByPnl retrieves open positions ordered by unrealized P&L percent,
+// biggest gainers first when desc is true. A limit of 0 or less returns all positions.
+func (db *DB) GetPositionsSortedByPnl(desc bool, limit int) ([]*models.Position, error) {
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+	var limitArg interface{}
+	if limit > 0 {
+		limitArg = limit
+	}
+
+	query := `
+		SELECT id, symbol, quantity, entry_price, entry_date, current_price,
+		       unrealized_pnl_pct, days_held, entry_rsi, entry_reason,
+		       sector, industry, position_size_pct, realized_pnl, notes, tags, lowest_price, created_at, updated_at
+		FROM positions
+		WHERE quantity > 0
+		ORDER BY unrealized_pnl_pct ` + direction + ` NULLS LAST, symbol ASC
+		LIMIT $1
+	`
+	return db.scanPositions(db.conn.Query(query, limitArg))
+}
+
+// GetPositionsByIndustry retrieves open positions in an industry, ordered by symbol
+func (db *DB) GetPositionsByIndustry(industry string) ([]*models.Position, error) {
+	query := `
+		SELECT id, symbol, quantity, entry_price, entry_date, current_price,
+		       unrealized_pnl_pct, days_held, entry_rsi, entry_reason,
+		       sector, industry, position_size_pct, realized_pnl, notes, tags, lowest_price, created_at, updated_at
+		FROM positions
+		WHERE industry = $1 AND quantity > 0
+		ORDER BY symbol ASC
+	`
+	return db.scanPositions(db.conn.Query(query, industry))
+}
+
+// IndustryExposure is the market value held in one industry and its share of the portfolio
+type IndustryExposure struct {
+	Industry    string          `json:"industry"`
+	Positions   int             `json:"positions"`
+	MarketValue decimal.Decimal `json:"market_value"`
+	Pct         decimal.Decimal `json:"pct"`
+}
+
+// GetIndustryExposure groups open positions by industry, valued at current price
+// (entry price when no current price is known), largest exposure first. Positions
+// without an industry are grouped as "Unknown".
+func (db *DB) GetIndustryExposure() ([]*IndustryExposure, error) {
+	query := `
+		SELECT COALESCE(NULLIF(industry, ''), 'Unknown') AS industry,
+		       COUNT(*),
+		       SUM(quantity * COALESCE(NULLIF(current_price, 0), entry_price)) AS market_value
+		FROM positions
+		WHERE quantity > 0
+		GROUP BY 1
+		ORDER BY market_value DESC, industry ASC
+	`
 	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get industry exposure: %w", err)
+	}
+	defer rows.Close()
+
+	var exposures []*IndustryExposure
+	total := decimal.Zero
+	for rows.Next() {
+		var e IndustryExposure
+		if err := rows.Scan(&e.Industry, &e.Positions, &e.MarketValue); err != nil {
+			return nil, fmt.Errorf("failed to scan industry exposure: %w", err)
+		}
+		total = total.Add(e.MarketValue)
+		exposures = append(exposures, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate industry exposure: %w", err)
+	}
+
+	if total.IsPositive() {
+		for _, e := range exposures {
+			e.Pct = e.MarketValue.Div(total).Mul(decimal.NewFromInt(100)).Round(2)
+		}
+	}
+	return exposures, nil
+}
+
+func (db *DB) scanPositions(rows *sql.Rows, err error) ([]*models.Position, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get positions: %w", err)
 	}
@@ -162,14 +327,14 @@ func (db *DB) GetAllPositions() ([]*models.Position, error) {
 	var positions []*models.Position
 	for rows.Next() {
 		var p models.Position
-		var currentPrice, unrealizedPnlPct, entryRSI, positionSizePct sql.NullString
+		var currentPrice, unrealizedPnlPct, entryRSI, positionSizePct, realizedPnl, lowestPrice sql.NullString
 		var daysHeld sql.NullInt64
-		var entryReason, sector, industry sql.NullString
+		var entryReason, sector, industry, notes sql.NullString
 
 		err := rows.Scan(
 			&p.ID, &p.Symbol, &p.Quantity, &p.EntryPrice, &p.EntryDate, &currentPrice,
 			&unrealizedPnlPct, &daysHeld, &entryRSI, &entryReason,
-			&sector, &industry, &positionSizePct, &p.CreatedAt, &p.UpdatedAt,
+			&sector, &industry, &positionSizePct, &realizedPnl, &notes, pq.Array(&p.Tags), &lowestPrice, &p.CreatedAt, &p.UpdatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan position: %w", err)
@@ -199,6 +364,13 @@ func (db *DB) GetAllPositions() ([]*models.Position, error) {
 		if positionSizePct.Valid {
 			p.PositionSizePct, _ = decimal.NewFromString(positionSizePct.String)
 		}
+		if realizedPnl.Valid {
+			p.RealizedPnl, _ = decimal.NewFromString(realizedPnl.String)
+		}
+		if lowestPrice.Valid {
+			p.LowestPrice, _ = decimal.NewFromString(lowestPrice.String)
+		}
+		p.Notes = notes.String
 
 		positions = append(positions, &p)
 	}
@@ -206,6 +378,35 @@ func (db *DB) GetAllPositions() ([]*models.Position, error) {
 	return positions, nil
 }
 
+// GetSymbolsWithOpenPositions returns the distinct symbols currently held
+func (db *DB) GetSymbolsWithOpenPositions() ([]string, error) {
+	query := `
+		SELECT DISTINCT symbol
+		FROM positions
+		WHERE quantity > 0
+		ORDER BY symbol ASC
+	`
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get open position symbols: %w", err)
+	}
+	defer rows.Close()
+
+	var symbols []string
+	for rows.Next() {
+		var symbol string
+		if err := rows.Scan(&symbol); err != nil {
+			return nil, fmt.Errorf("failed to scan symbol: %w", err)
+		}
+		symbols = append(symbols, symbol)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate open position symbols: %w", err)
+	}
+
+	return symbols, nil
+}
+
 // UpdatePosition updates an existing position
 func (db *DB) UpdatePosition(p *models.Position) error {
 	query := `
@@ -232,6 +433,54 @@ func (db *DB) UpdatePosition(p *models.Position) error {
 	return nil
 }
 
+// UpsertPosition inserts a position or, if the symbol is already held, updates its
+// quantity and prices. The stored entry date, realized P&L, notes and tags are kept,
+// as are the entry RSI and entry reason once set, sector and industry are only
+// replaced when p has them, and the lowest price only moves down.
+func (db *DB) UpsertPosition(p *models.Position) error {
+	query := `
+		INSERT INTO positions (
+			symbol, quantity, entry_price, entry_date, current_price,
+			unrealized_pnl_pct, days_held, sector, industry,
+			realized_pnl, notes, tags, lowest_price, entry_rsi, entry_reason, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+		ON CONFLICT (symbol) DO UPDATE SET
+			quantity = EXCLUDED.quantity,
+			entry_price = EXCLUDED.entry_price,
+			current_price = EXCLUDED.current_price,
+			unrealized_pnl_pct = EXCLUDED.unrealized_pnl_pct,
+			sector = COALESCE(NULLIF(EXCLUDED.sector, ''), positions.sector),
+			industry = COALESCE(NULLIF(EXCLUDED.industry, ''), positions.industry),
+			lowest_price = LEAST(positions.lowest_price, EXCLUDED.lowest_price),
+			entry_rsi = COALESCE(positions.entry_rsi, EXCLUDED.entry_rsi),
+			entry_reason = COALESCE(NULLIF(positions.entry_reason, ''), EXCLUDED.entry_reason),
+			updated_at = EXCLUDED.updated_at
+		RETURNING id, entry_date, lowest_price, entry_rsi, entry_reason, created_at
+	`
+	p.ObservePrice(p.CurrentPrice)
+	now := time.Now()
+	var lowestPrice, entryRSI, entryReason sql.NullString
+	err := db.conn.QueryRow(query,
+		p.Symbol, p.Quantity, p.EntryPrice, p.EntryDate, p.CurrentPrice,
+		p.UnrealizedPnlPct, p.DaysHeld, p.Sector, p.Industry, p.RealizedPnl,
+		sql.NullString{String: p.Notes, Valid: p.Notes != ""}, pq.Array(p.Tags), nullablePositive(p.LowestPrice),
+		nullablePositive(p.EntryRSI), sql.NullString{String: p.EntryReason, Valid: p.EntryReason != ""}, now, now,
+	).Scan(&p.ID, &p.EntryDate, &lowestPrice, &entryRSI, &entryReason, &p.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to upsert position %s: %w", p.Symbol, err)
+	}
+	if lowestPrice.Valid {
+		p.LowestPrice, _ = decimal.NewFromString(lowestPrice.String)
+	}
+	if entryRSI.Valid {
+		p.EntryRSI, _ = decimal.NewFromString(entryRSI.String)
+	}
+	p.EntryReason = entryReason.String
+	p.UpdatedAt = now
+	return nil
+}
+
 // DeletePosition removes a position by ID
 func (db *DB) DeletePosition(id int) error {
 	query := `DELETE FROM positions WHERE id = $1`
@@ -271,6 +520,14 @@ func (db *DB) ReplaceAllPositions(positions []*models.Position) error {
 	}
 	defer tx.Rollback()
 
+	// Entry dates, sector and industry, realized P&L, notes, tags, the lowest
+	// price seen, entry RSI and entry reason aren't reliably part of the snapshot,
+	// so carry them over for symbols that are still held
+	carried, err := carryOverPositionFields(tx)
+	if err != nil {
+		return err
+	}
+
 	// Delete all existing positions
 	_, err = tx.Exec(`DELETE FROM positions`)
 	if err != nil {
@@ -281,16 +538,42 @@ func (db *DB) ReplaceAllPositions(positions []*models.Position) error {
 	insertQuery := `
 		INSERT INTO positions (
 			symbol, quantity, entry_price, entry_date, current_price,
-			unrealized_pnl_pct, days_held, created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			unrealized_pnl_pct, days_held, sector, industry, position_size_pct,
+			realized_pnl, notes, tags, lowest_price, entry_rsi, entry_reason, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
 		RETURNING id
 	`
 
 	now := time.Now()
 	for _, p := range positions {
+		if c, ok := carried[p.Symbol]; ok {
+			// Keep the earliest known entry so days held survives a re-insert
+			if !c.entryDate.IsZero() && (p.EntryDate.IsZero() || c.entryDate.Before(p.EntryDate)) {
+				p.EntryDate = c.entryDate
+			}
+			if p.Sector == "" {
+				p.Sector = c.sector
+			}
+			if p.Industry == "" {
+				p.Industry = c.industry
+			}
+			p.RealizedPnl = c.realizedPnl
+			p.Notes = c.notes
+			p.Tags = c.tags
+			p.ObservePrice(c.lowestPrice)
+			if !c.entryRSI.IsZero() {
+				p.EntryRSI = c.entryRSI
+			}
+			if c.entryReason != "" {
+				p.EntryReason = c.entryReason
+			}
+		}
+		p.ObservePrice(p.CurrentPrice)
 		err := tx.QueryRow(insertQuery,
 			p.Symbol, p.Quantity, p.EntryPrice, p.EntryDate, p.CurrentPrice,
-			p.UnrealizedPnlPct, p.DaysHeld, now, now,
+			p.UnrealizedPnlPct, p.DaysHeld, p.Sector, p.Industry, nullablePositive(p.PositionSizePct), p.RealizedPnl,
+			sql.NullString{String: p.Notes, Valid: p.Notes != ""}, pq.Array(p.Tags), nullablePositive(p.LowestPrice),
+			nullablePositive(p.EntryRSI), sql.NullString{String: p.EntryReason, Valid: p.EntryReason != ""}, now, now,
 		).Scan(&p.ID)
 		if err != nil {
 			return fmt.Errorf("failed to insert position %s: %w", p.Symbol, err)
@@ -306,6 +589,255 @@ func (db *DB) ReplaceAllPositions(positions []*models.Position) error {
 	return nil
 }
 
+// carriedPosition holds the position fields a snapshot doesn't include
+type carriedPosition struct {
+	entryDate   time.Time
+	sector      string
+	industry    string
+	realizedPnl decimal.Decimal
+	notes       string
+	tags        []string
+	lowestPrice decimal.Decimal
+	entryRSI    decimal.Decimal
+	entryReason string
+}
+
+// carryOverPositionFields reads entry date, sector, industry, realized P&L, notes,
+// tags, lowest price, entry RSI and entry reason by symbol within tx
+func carryOverPositionFields(tx *sql.Tx) (map[string]carriedPosition, error) {
+	rows, err := tx.Query(`SELECT symbol, entry_date, sector, industry, realized_pnl, notes, tags, lowest_price, entry_rsi, entry_reason FROM positions`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read carried position fields: %w", err)
+	}
+	defer rows.Close()
+
+	carried := make(map[string]carriedPosition)
+	for rows.Next() {
+		var symbol string
+		var entryDate sql.NullTime
+		var pnl sql.NullString
+		var sector, industry, notes, lowestPrice, entryRSI, entryReason sql.NullString
+		var c carriedPosition
+		if err := rows.Scan(&symbol, &entryDate, &sector, &industry, &pnl, &notes, pq.Array(&c.tags), &lowestPrice, &entryRSI, &entryReason); err != nil {
+			return nil, fmt.Errorf("failed to scan carried position fields: %w", err)
+		}
+		c.entryDate = entryDate.Time
+		c.sector = sector.String
+		c.industry = industry.String
+		if pnl.Valid {
+			c.realizedPnl, _ = decimal.NewFromString(pnl.String)
+		}
+		c.notes = notes.String
+		if lowestPrice.Valid {
+			c.lowestPrice, _ = decimal.NewFromString(lowestPrice.String)
+		}
+		if entryRSI.Valid {
+			c.entryRSI, _ = decimal.NewFromString(entryRSI.String)
+		}
+		c.entryReason = entryReason.String
+		carried[symbol] = c
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate carried position fields: %w", err)
+	}
+	return carried, nil
+}
+
+// UpdatePositionNotes replaces a position's notes and tags. Empty notes are stored as NULL.
+func (db *DB) UpdatePositionNotes(symbol, notes string, tags []string) error {
+	if tags == nil {
+		tags = []string{}
+	}
+	query := `
+		UPDATE positions
+		SET notes = $2, tags = $3, updated_at = $4
+		WHERE symbol = $1
+	`
+	result, err := db.conn.Exec(query, symbol, sql.NullString{String: notes, Valid: notes != ""}, pq.Array(tags), time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to update position notes: %w", err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w for symbol: %s", ErrPositionNotFound, symbol)
+	}
+	return nil
+}
+
+// AddRealizedPnl adds a partial close's realized P&L to the position's running total
+func (db *DB) AddRealizedPnl(symbol string, amount decimal.Decimal) error {
+	query := `
+		UPDATE positions
+		SET realized_pnl = COALESCE(realized_pnl, 0) + $2, updated_at = $3
+		WHERE symbol = $1
+	`
+	result, err := db.conn.Exec(query, symbol, amount, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to add realized pnl: %w", err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w for symbol: %s", ErrPositionNotFound, symbol)
+	}
+	return nil
+}
+
+// UpdatePositionMarkToMarket sets a position's current price and recomputes its
+// unrealized P&L percentage, (current - entry) / entry * 100, and whole days held
+// since entry. The lowest price is lowered when the new price is below it.
+func (db *DB) UpdatePositionMarkToMarket(symbol string, currentPrice decimal.Decimal) error {
+	query := `
+		UPDATE positions
+		SET current_price = $2,
+		    unrealized_pnl_pct = CASE WHEN entry_price > 0
+		        THEN ROUND(($2 - entry_price) / entry_price * 100, 4) ELSE 0 END,
+		    lowest_price = LEAST(lowest_price, $2),
+		    days_held = GREATEST(FLOOR(EXTRACT(EPOCH FROM ($3 - entry_date)) / 86400), 0)::INTEGER,
+		    updated_at = $3
+		WHERE symbol = $1
+	`
+	result, err := db.conn.Exec(query, symbol, currentPrice, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to update position mark to market: %w", err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w for symbol: %s", ErrPositionNotFound, symbol)
+	}
+	return nil
+}
+
+// RefreshAllPositionsMarkToMarket marks every position to its stock's current
+// price as UpdatePositionMarkToMarket does and returns how many were updated.
+// Positions without a stock row or a positive price are left alone.
+func (db *DB) RefreshAllPositionsMarkToMarket() (int64, error) {
+	query := `
+		UPDATE positions p
+		SET current_price = s.current_price,
+		    unrealized_pnl_pct = CASE WHEN p.entry_price > 0
+		        THEN ROUND((s.current_price - p.entry_price) / p.entry_price * 100, 4) ELSE 0 END,
+		    lowest_price = LEAST(p.lowest_price, s.current_price),
+		    days_held = GREATEST(FLOOR(EXTRACT(EPOCH FROM ($1 - p.entry_date)) / 86400), 0)::INTEGER,
+		    updated_at = $1
+		FROM stocks s
+		WHERE s.symbol = p.symbol AND s.current_price > 0
+	`
+	result, err := db.conn.Exec(query, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to refresh positions mark to market: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count refreshed positions: %w", err)
+	}
+	return updated, nil
+}
+
+// GetPortfolioDailyChange values open positions at their stocks' current price and
+// previous close. Positions without both prices are left out. Shares bought today
+// count from the previous close, so a same-day entry's move is included in full.
+func (db *DB) GetPortfolioDailyChange() (*models.PortfolioDailyChange, error) {
+	query := `
+		SELECT COALESCE(SUM(p.quantity * s.current_price), 0),
+		       COALESCE(SUM(p.quantity * s.previous_close), 0)
+		FROM positions p
+		JOIN stocks s ON s.symbol = p.symbol
+		WHERE p.quantity > 0 AND s.current_price > 0 AND s.previous_close > 0
+	`
+	var c models.PortfolioDailyChange
+	if err := db.conn.QueryRow(query).Scan(&c.Value, &c.PreviousValue); err != nil {
+		return nil, fmt.Errorf("failed to get portfolio daily change: %w", err)
+	}
+	c.Change = c.Value.Sub(c.PreviousValue)
+	if c.PreviousValue.IsPositive() {
+		c.ChangePct = c.Change.Div(c.PreviousValue).Mul(decimal.NewFromInt(100)).Round(4)
+	}
+	return &c, nil
+}
+
+// GetPortfolioSummary totals open positions in one query. Market value uses the
+// stock's current price, falling back to the position's own current price and
+// then its entry price when no quote is known.
+func (db *DB) GetPortfolioSummary() (*models.PortfolioSummary, error) {
+	query := `
+		SELECT COUNT(*),
+		       COALESCE(SUM(p.quantity * p.entry_price), 0),
+		       COALESCE(SUM(p.quantity * COALESCE(NULLIF(s.current_price, 0), NULLIF(p.current_price, 0), p.entry_price)), 0)
+		FROM positions p
+		LEFT JOIN stocks s ON s.symbol = p.symbol
+		WHERE p.quantity > 0
+	`
+	var summary models.PortfolioSummary
+	err := db.conn.QueryRow(query).Scan(&summary.PositionCount, &summary.TotalCostBasis, &summary.TotalMarketValue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get portfolio summary: %w", err)
+	}
+	summary.TotalUnrealizedPnl = summary.TotalMarketValue.Sub(summary.TotalCostBasis)
+	if summary.TotalCostBasis.IsPositive() {
+		summary.TotalUnrealizedPnlPct = summary.TotalUnrealizedPnl.Div(summary.TotalCostBasis).Mul(decimal.NewFromInt(100)).Round(4)
+	}
+	return &summary, nil
+}
+
+// GetPortfolioRSI averages each open position's latest RSI_14 weighted by its
+// market value, priced as in GetPortfolioSummary. Positions without RSI are
+// skipped; RSI is zero when none has a reading.
+func (db *DB) GetPortfolioRSI() (*models.PortfolioRSI, error) {
+	query := `
+		SELECT p.quantity * COALESCE(NULLIF(s.current_price, 0), NULLIF(p.current_price, 0), p.entry_price),
+		       r.value
+		FROM positions p
+		LEFT JOIN stocks s ON s.symbol = p.symbol
+		LEFT JOIN LATERAL (
+			SELECT ti.value
+			FROM technical_indicators ti
+			WHERE ti.symbol = p.symbol AND ti.indicator_type = 'RSI_14'
+			ORDER BY ti.date DESC
+			LIMIT 1
+		) r ON true
+		WHERE p.quantity > 0
+	`
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get portfolio RSI: %w", err)
+	}
+	defer rows.Close()
+
+	var result models.PortfolioRSI
+	weighted := decimal.Zero
+	for rows.Next() {
+		var value decimal.Decimal
+		var rsi sql.NullString
+		if err := rows.Scan(&value, &rsi); err != nil {
+			return nil, fmt.Errorf("failed to scan portfolio RSI: %w", err)
+		}
+		if !rsi.Valid {
+			result.Skipped++
+			continue
+		}
+		r, err := decimal.NewFromString(rsi.String)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RSI %q: %w", rsi.String, err)
+		}
+		weighted = weighted.Add(r.Mul(value))
+		result.WeightedValue = result.WeightedValue.Add(value)
+		result.Positions++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate portfolio RSI: %w", err)
+	}
+
+	if result.WeightedValue.IsPositive() {
+		result.RSI = weighted.Div(result.WeightedValue).Round(2)
+	}
+	return &result, nil
+}
+
+// nullablePositive stores a zero or negative value, such as an unknown price, as NULL
+func nullablePositive(d decimal.Decimal) sql.NullString {
+	return sql.NullString{String: d.String(), Valid: d.IsPositive()}
+}
+
 // DeleteAllPositions removes all positions from the database
 func (db *DB) DeleteAllPositions() error {
 	_, err := db.conn.Exec(`DELETE FROM positions`)
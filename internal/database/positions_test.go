@@ -88,6 +88,31 @@ func TestPositionsRepository(t *testing.T) {
 		assert.Equal(t, position.ID, retrieved.ID)
 	})
 
+	t.Run("GetPositionBySymbolOrNil returns a held position", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		position := &models.Position{
+			Symbol:     "MSFT",
+			Quantity:   decimal.NewFromFloat(25),
+			EntryPrice: decimal.NewFromFloat(370.00),
+			EntryDate:  time.Now(),
+		}
+		require.NoError(t, testDB.CreatePosition(position))
+
+		retrieved, err := testDB.GetPositionBySymbolOrNil("MSFT")
+		require.NoError(t, err)
+		require.NotNil(t, retrieved)
+		assert.Equal(t, position.ID, retrieved.ID)
+	})
+
+	t.Run("GetPositionBySymbolOrNil returns nil for an absent position", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		retrieved, err := testDB.GetPositionBySymbolOrNil("NOPE")
+		require.NoError(t, err)
+		assert.Nil(t, retrieved)
+	})
+
 	t.Run("GetAllPositions retrieves all positions ordered by entry date", func(t *testing.T) {
 		testDB.TruncateAll(t)
 
@@ -217,4 +242,607 @@ func TestPositionsRepository(t *testing.T) {
 		err = testDB.CreatePosition(position2)
 		require.Error(t, err) // Should fail due to unique constraint
 	})
+
+	t.Run("GetPositionsSortedByPnl orders by unrealized P&L and applies limit", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		now := time.Now()
+		for _, data := range []struct {
+			symbol string
+			pnl    float64
+		}{
+			{"AAPL", 5.5},
+			{"GOOGL", -12.0},
+			{"MSFT", 20.25},
+			{"TSLA", 0},
+			{"NVDA", -3.1},
+		} {
+			err := testDB.CreatePosition(&models.Position{
+				Symbol:           data.symbol,
+				Quantity:         decimal.NewFromFloat(10),
+				EntryPrice:       decimal.NewFromFloat(100),
+				EntryDate:        now,
+				UnrealizedPnlPct: decimal.NewFromFloat(data.pnl),
+			})
+			require.NoError(t, err)
+		}
+
+		gainers, err := testDB.GetPositionsSortedByPnl(true, 3)
+		require.NoError(t, err)
+		require.Len(t, gainers, 3)
+		assert.Equal(t, "MSFT", gainers[0].Symbol)
+		assert.Equal(t, "AAPL", gainers[1].Symbol)
+		assert.Equal(t, "TSLA", gainers[2].Symbol)
+
+		losers, err := testDB.GetPositionsSortedByPnl(false, 2)
+		require.NoError(t, err)
+		require.Len(t, losers, 2)
+		assert.Equal(t, "GOOGL", losers[0].Symbol)
+		assert.Equal(t, "NVDA", losers[1].Symbol)
+
+		all, err := testDB.GetPositionsSortedByPnl(true, 0)
+		require.NoError(t, err)
+		assert.Len(t, all, 5)
+	})
+
+	t.Run("GetPositionRaw round-trips 8-decimal quantity exactly", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		quantity, err := decimal.NewFromString("0.00012345")
+		require.NoError(t, err)
+		position := &models.Position{
+			Symbol:       "BTC",
+			Quantity:     quantity,
+			EntryPrice:   decimal.NewFromFloat(65000.1234),
+			EntryDate:    time.Now(),
+			CurrentPrice: decimal.NewFromFloat(66000.5),
+		}
+		require.NoError(t, testDB.CreatePosition(position))
+
+		raw, err := testDB.GetPositionRaw("BTC")
+		require.NoError(t, err)
+		assert.Equal(t, "0.00012345", raw.Quantity)
+		assert.Equal(t, "65000.1234", raw.EntryPrice)
+		assert.Equal(t, "66000.5000", raw.CurrentPrice)
+
+		retrieved, err := testDB.GetPositionBySymbol("BTC")
+		require.NoError(t, err)
+		assert.True(t, quantity.Equal(retrieved.Quantity), "quantity: %s", retrieved.Quantity)
+	})
+
+	t.Run("GetPositionRaw returns error for unknown symbol", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		_, err := testDB.GetPositionRaw("NOPE")
+		assert.ErrorIs(t, err, ErrPositionNotFound)
+	})
+
+	t.Run("GetSymbolsWithOpenPositions returns held symbols", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		for _, data := range []struct {
+			symbol   string
+			quantity float64
+		}{
+			{"MSFT", 25},
+			{"AAPL", 100},
+			{"FLAT", 0}, // Fully sold, no longer open
+		} {
+			err := testDB.CreatePosition(&models.Position{
+				Symbol:     data.symbol,
+				Quantity:   decimal.NewFromFloat(data.quantity),
+				EntryPrice: decimal.NewFromFloat(100),
+				EntryDate:  time.Now(),
+			})
+			require.NoError(t, err)
+		}
+
+		symbols, err := testDB.GetSymbolsWithOpenPositions()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"AAPL", "MSFT"}, symbols)
+	})
+
+	t.Run("AddRealizedPnl accumulates across partial closes and snapshots", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		err := testDB.CreatePosition(&models.Position{
+			Symbol:     "AAPL",
+			Quantity:   decimal.NewFromFloat(100),
+			EntryPrice: decimal.NewFromFloat(150),
+			EntryDate:  time.Now(),
+		})
+		require.NoError(t, err)
+
+		// Two scale-outs: sell 25 @ 160, then 25 @ 170
+		require.NoError(t, testDB.AddRealizedPnl("AAPL", decimal.NewFromFloat(250)))
+		require.NoError(t, testDB.AddRealizedPnl("AAPL", decimal.NewFromFloat(500)))
+
+		p, err := testDB.GetPositionBySymbol("AAPL")
+		require.NoError(t, err)
+		assert.True(t, p.RealizedPnl.Equal(decimal.NewFromFloat(750)), "got %s", p.RealizedPnl)
+
+		// A fresh snapshot for the remaining shares keeps the running total
+		err = testDB.ReplaceAllPositions([]*models.Position{{
+			Symbol:     "AAPL",
+			Quantity:   decimal.NewFromFloat(50),
+			EntryPrice: decimal.NewFromFloat(150),
+			EntryDate:  time.Now(),
+		}})
+		require.NoError(t, err)
+
+		p, err = testDB.GetPositionBySymbol("AAPL")
+		require.NoError(t, err)
+		assert.True(t, p.RealizedPnl.Equal(decimal.NewFromFloat(750)), "got %s", p.RealizedPnl)
+
+		err = testDB.AddRealizedPnl("NOPE", decimal.NewFromFloat(1))
+		assert.ErrorIs(t, err, ErrPositionNotFound)
+	})
+
+	t.Run("GetPositionsByIndustry and GetIndustryExposure group by industry", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		for _, data := range []struct {
+			symbol   string
+			industry string
+			quantity float64
+			price    float64
+		}{
+			{"NVDA", "Semiconductors", 10, 300}, // 3000
+			{"AMD", "Semiconductors", 10, 150},  // 1500
+			{"JPM", "Banks", 10, 150},           // 1500
+			{"WFC", "Banks", 0, 50},             // closed, excluded
+		} {
+			err := testDB.CreatePosition(&models.Position{
+				Symbol:       data.symbol,
+				Quantity:     decimal.NewFromFloat(data.quantity),
+				EntryPrice:   decimal.NewFromFloat(data.price),
+				CurrentPrice: decimal.NewFromFloat(data.price),
+				EntryDate:    time.Now(),
+				Industry:     data.industry,
+			})
+			require.NoError(t, err)
+		}
+
+		semis, err := testDB.GetPositionsByIndustry("Semiconductors")
+		require.NoError(t, err)
+		require.Len(t, semis, 2)
+		assert.Equal(t, "AMD", semis[0].Symbol)
+		assert.Equal(t, "NVDA", semis[1].Symbol)
+
+		banks, err := testDB.GetPositionsByIndustry("Banks")
+		require.NoError(t, err)
+		require.Len(t, banks, 1)
+		assert.Equal(t, "JPM", banks[0].Symbol)
+
+		exposure, err := testDB.GetIndustryExposure()
+		require.NoError(t, err)
+		require.Len(t, exposure, 2)
+		assert.Equal(t, "Semiconductors", exposure[0].Industry)
+		assert.Equal(t, 2, exposure[0].Positions)
+		assert.True(t, decimal.NewFromInt(4500).Equal(exposure[0].MarketValue))
+		assert.True(t, decimal.NewFromInt(75).Equal(exposure[0].Pct))
+		assert.Equal(t, "Banks", exposure[1].Industry)
+		assert.True(t, decimal.NewFromInt(25).Equal(exposure[1].Pct))
+	})
+
+	t.Run("UpdatePositionNotes sets notes and tags that survive snapshots", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		err := testDB.CreatePosition(&models.Position{
+			Symbol:     "NVDA",
+			Quantity:   decimal.NewFromFloat(10),
+			EntryPrice: decimal.NewFromFloat(120),
+			EntryDate:  time.Now(),
+		})
+		require.NoError(t, err)
+
+		p, err := testDB.GetPositionBySymbol("NVDA")
+		require.NoError(t, err)
+		assert.Empty(t, p.Notes)
+		assert.Empty(t, p.Tags)
+
+		err = testDB.UpdatePositionNotes("NVDA", "Breakout above 118, stop at 110", []string{"swing", "ai"})
+		require.NoError(t, err)
+
+		p, err = testDB.GetPositionBySymbol("NVDA")
+		require.NoError(t, err)
+		assert.Equal(t, "Breakout above 118, stop at 110", p.Notes)
+		assert.Equal(t, []string{"swing", "ai"}, p.Tags)
+
+		// A fresh snapshot keeps them
+		err = testDB.ReplaceAllPositions([]*models.Position{{
+			Symbol:     "NVDA",
+			Quantity:   decimal.NewFromFloat(12),
+			EntryPrice: decimal.NewFromFloat(121),
+			EntryDate:  time.Now(),
+		}})
+		require.NoError(t, err)
+
+		all, err := testDB.GetAllPositions()
+		require.NoError(t, err)
+		require.Len(t, all, 1)
+		assert.Equal(t, "Breakout above 118, stop at 110", all[0].Notes)
+		assert.Equal(t, []string{"swing", "ai"}, all[0].Tags)
+
+		// Clearing stores no notes and no tags
+		require.NoError(t, testDB.UpdatePositionNotes("NVDA", "", nil))
+		p, err = testDB.GetPositionBySymbol("NVDA")
+		require.NoError(t, err)
+		assert.Empty(t, p.Notes)
+		assert.Empty(t, p.Tags)
+
+		err = testDB.UpdatePositionNotes("NOPE", "x", nil)
+		assert.ErrorIs(t, err, ErrPositionNotFound)
+	})
+
+	t.Run("GetPositionEvents replays a symbol's events in order", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		day := time.Date(2026, 5, 4, 15, 0, 0, 0, time.UTC)
+		events := []*models.PositionEvent{
+			{Symbol: "AAPL", EventType: models.PositionEventOpen, Quantity: decimal.NewFromInt(10), PositionQuantity: decimal.NewFromInt(10), Price: decimal.NewFromInt(150), OccurredAt: day},
+			{Symbol: "MSFT", EventType: models.PositionEventOpen, Quantity: decimal.NewFromInt(3), PositionQuantity: decimal.NewFromInt(3), Price: decimal.NewFromInt(400), OccurredAt: day},
+			{Symbol: "AAPL", EventType: models.PositionEventClose, Quantity: decimal.NewFromInt(10), PositionQuantity: decimal.Zero, Price: decimal.NewFromInt(170), OccurredAt: day.Add(48 * time.Hour)},
+			{Symbol: "AAPL", EventType: models.PositionEventAdd, Quantity: decimal.NewFromInt(5), PositionQuantity: decimal.NewFromInt(15), Price: decimal.NewFromInt(162), OccurredAt: day.Add(24 * time.Hour)},
+		}
+		for _, e := range events {
+			require.NoError(t, testDB.CreatePositionEvent(e))
+			assert.NotZero(t, e.ID)
+		}
+
+		got, err := testDB.GetPositionEvents("AAPL")
+		require.NoError(t, err)
+		require.Len(t, got, 3)
+		assert.Equal(t, models.PositionEventOpen, got[0].EventType)
+		assert.Equal(t, models.PositionEventAdd, got[1].EventType)
+		assert.Equal(t, "162", got[1].Price.String())
+		assert.Equal(t, "15", got[1].PositionQuantity.String())
+		assert.Equal(t, models.PositionEventClose, got[2].EventType)
+		assert.True(t, got[2].PositionQuantity.IsZero())
+
+		got, err = testDB.GetPositionEvents("TSLA")
+		require.NoError(t, err)
+		assert.Empty(t, got)
+	})
+
+	t.Run("UpsertPosition inserts or updates one symbol and keeps entry details", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		entry := time.Date(2026, 2, 2, 15, 0, 0, 0, time.UTC)
+		require.NoError(t, testDB.ReplaceAllPositions([]*models.Position{
+			{Symbol: "AAPL", Quantity: decimal.NewFromInt(10), EntryPrice: decimal.NewFromInt(150), EntryDate: entry},
+			{Symbol: "MSFT", Quantity: decimal.NewFromInt(5), EntryPrice: decimal.NewFromInt(400), EntryDate: entry},
+		}))
+		require.NoError(t, testDB.UpdatePositionNotes("AAPL", "core holding", []string{"long"}))
+
+		update := &models.Position{
+			Symbol:       "AAPL",
+			Quantity:     decimal.NewFromInt(15),
+			EntryPrice:   decimal.NewFromInt(154),
+			EntryDate:    time.Now(),
+			CurrentPrice: decimal.NewFromInt(162),
+		}
+		require.NoError(t, testDB.UpsertPosition(update))
+		assert.True(t, entry.Equal(update.EntryDate.UTC()))
+
+		require.NoError(t, testDB.UpsertPosition(&models.Position{
+			Symbol:     "TSLA",
+			Quantity:   decimal.NewFromInt(2),
+			EntryPrice: decimal.NewFromInt(250),
+			EntryDate:  entry,
+		}))
+
+		all, err := testDB.GetAllPositions()
+		require.NoError(t, err)
+		require.Len(t, all, 3)
+
+		p, err := testDB.GetPositionBySymbol("AAPL")
+		require.NoError(t, err)
+		assert.Equal(t, "15", p.Quantity.String())
+		assert.Equal(t, "154", p.EntryPrice.String())
+		assert.Equal(t, "core holding", p.Notes)
+		assert.Equal(t, []string{"long"}, p.Tags)
+
+		require.NoError(t, testDB.DeletePositionBySymbol("TSLA"))
+		all, err = testDB.GetAllPositions()
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+	})
+
+	t.Run("GetPositionPnlHistory returns a symbol's snapshots within the range", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		day := time.Date(2026, 5, 4, 15, 0, 0, 0, time.UTC)
+		snapshot := func(symbol string, price, pnl int64, at time.Time) *models.PositionPnlSnapshot {
+			return &models.PositionPnlSnapshot{
+				Symbol:           symbol,
+				Quantity:         decimal.NewFromInt(10),
+				EntryPrice:       decimal.NewFromInt(100),
+				CurrentPrice:     decimal.NewFromInt(price),
+				UnrealizedPnl:    decimal.NewFromInt(pnl),
+				UnrealizedPnlPct: decimal.NewFromInt(pnl / 10),
+				RecordedAt:       at,
+			}
+		}
+		for _, s := range []*models.PositionPnlSnapshot{
+			snapshot("AAPL", 110, 100, day.Add(2*time.Hour)),
+			snapshot("AAPL", 105, 50, day.Add(time.Hour)),
+			snapshot("MSFT", 120, 200, day.Add(time.Hour)),
+			snapshot("AAPL", 95, -50, day.Add(3*time.Hour)),
+			snapshot("AAPL", 130, 300, day.Add(48*time.Hour)),
+		} {
+			require.NoError(t, testDB.CreatePositionPnlSnapshot(s))
+			assert.NotZero(t, s.ID)
+		}
+
+		history, err := testDB.GetPositionPnlHistory("AAPL", day, day.Add(24*time.Hour))
+		require.NoError(t, err)
+		require.Len(t, history, 3)
+		assert.Equal(t, "50", history[0].UnrealizedPnl.String())
+		assert.Equal(t, "100", history[1].UnrealizedPnl.String())
+		assert.Equal(t, "-50", history[2].UnrealizedPnl.String())
+		assert.Equal(t, "95", history[2].CurrentPrice.String())
+		assert.True(t, day.Add(3*time.Hour).Equal(history[2].RecordedAt))
+
+		history, err = testDB.GetPositionPnlHistory("TSLA", day, day.Add(24*time.Hour))
+		require.NoError(t, err)
+		assert.Empty(t, history)
+	})
+
+	t.Run("UpdatePositionMarkToMarket recomputes P&L percent and days held", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		require.NoError(t, testDB.CreatePosition(&models.Position{
+			Symbol:     "AAPL",
+			Quantity:   decimal.NewFromInt(10),
+			EntryPrice: decimal.NewFromInt(160),
+			EntryDate:  time.Now().Add(-(10*24 + 5) * time.Hour),
+		}))
+
+		require.NoError(t, testDB.UpdatePositionMarkToMarket("AAPL", decimal.NewFromInt(172)))
+
+		p, err := testDB.GetPositionBySymbol("AAPL")
+		require.NoError(t, err)
+		assert.Equal(t, "172", p.CurrentPrice.String())
+		// (172 - 160) / 160 * 100
+		assert.Equal(t, "7.5", p.UnrealizedPnlPct.String())
+		assert.Equal(t, 10, p.DaysHeld)
+
+		require.NoError(t, testDB.UpdatePositionMarkToMarket("AAPL", decimal.NewFromInt(150)))
+		p, err = testDB.GetPositionBySymbol("AAPL")
+		require.NoError(t, err)
+		assert.Equal(t, "-6.25", p.UnrealizedPnlPct.String())
+
+		err = testDB.UpdatePositionMarkToMarket("TSLA", decimal.NewFromInt(1))
+		assert.ErrorIs(t, err, ErrPositionNotFound)
+	})
+
+	t.Run("RefreshAllPositionsMarkToMarket uses stock prices", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		for symbol, price := range map[string]float64{"AAPL": 180, "MSFT": 0} {
+			require.NoError(t, testDB.SaveStock(&models.Stock{
+				Symbol: symbol, Name: symbol + " Inc.", CurrentPrice: price, LastUpdated: time.Now(),
+			}))
+		}
+		entry := time.Now().Add(-3*24*time.Hour - time.Hour)
+		for _, symbol := range []string{"AAPL", "MSFT", "NVDA"} {
+			require.NoError(t, testDB.CreatePosition(&models.Position{
+				Symbol:     symbol,
+				Quantity:   decimal.NewFromInt(1),
+				EntryPrice: decimal.NewFromInt(200),
+				EntryDate:  entry,
+			}))
+		}
+
+		updated, err := testDB.RefreshAllPositionsMarkToMarket()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), updated)
+
+		p, err := testDB.GetPositionBySymbol("AAPL")
+		require.NoError(t, err)
+		assert.Equal(t, "180", p.CurrentPrice.String())
+		assert.Equal(t, "-10", p.UnrealizedPnlPct.String())
+		assert.Equal(t, 3, p.DaysHeld)
+
+		// No usable price: untouched
+		p, err = testDB.GetPositionBySymbol("MSFT")
+		require.NoError(t, err)
+		assert.True(t, p.CurrentPrice.IsZero())
+	})
+
+	t.Run("GetPortfolioDailyChange values positions against the previous close", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		stocks := []*models.Stock{
+			{Symbol: "AAPL", Name: "Apple", CurrentPrice: 110, PreviousClose: 100, LastUpdated: time.Now()},
+			{Symbol: "MSFT", Name: "Microsoft", CurrentPrice: 380, PreviousClose: 400, LastUpdated: time.Now()},
+			// No previous close yet: left out
+			{Symbol: "NEWCO", Name: "NewCo", CurrentPrice: 10, LastUpdated: time.Now()},
+		}
+		for _, s := range stocks {
+			require.NoError(t, testDB.SaveStock(s))
+		}
+		for symbol, qty := range map[string]int64{"AAPL": 10, "MSFT": 5, "NEWCO": 100} {
+			require.NoError(t, testDB.CreatePosition(&models.Position{
+				Symbol:     symbol,
+				Quantity:   decimal.NewFromInt(qty),
+				EntryPrice: decimal.NewFromInt(50),
+				EntryDate:  time.Now(),
+			}))
+		}
+
+		change, err := testDB.GetPortfolioDailyChange()
+		require.NoError(t, err)
+		// 10*110 + 5*380 = 3000 against 10*100 + 5*400 = 3000
+		assert.Equal(t, "3000", change.Value.String())
+		assert.Equal(t, "3000", change.PreviousValue.String())
+		assert.True(t, change.Change.IsZero())
+
+		require.NoError(t, testDB.SaveStock(&models.Stock{
+			Symbol: "MSFT", Name: "Microsoft", CurrentPrice: 340, PreviousClose: 400, LastUpdated: time.Now(),
+		}))
+		change, err = testDB.GetPortfolioDailyChange()
+		require.NoError(t, err)
+		assert.Equal(t, "-200", change.Change.String())
+		assert.Equal(t, "-6.6667", change.ChangePct.String())
+	})
+
+	t.Run("lowest price only moves down and survives snapshots", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		require.NoError(t, testDB.CreatePosition(&models.Position{
+			Symbol:       "AAPL",
+			Quantity:     decimal.NewFromInt(10),
+			EntryPrice:   decimal.NewFromInt(150),
+			EntryDate:    time.Now().Add(-48 * time.Hour),
+			CurrentPrice: decimal.NewFromInt(152),
+		}))
+
+		require.NoError(t, testDB.UpdatePositionMarkToMarket("AAPL", decimal.NewFromInt(141)))
+		require.NoError(t, testDB.UpdatePositionMarkToMarket("AAPL", decimal.NewFromInt(158)))
+
+		p, err := testDB.GetPositionBySymbol("AAPL")
+		require.NoError(t, err)
+		assert.Equal(t, "141", p.LowestPrice.String())
+		// (150 - 141) / 150 * 100
+		assert.Equal(t, "6", p.MaxDrawdownPct().String())
+
+		require.NoError(t, testDB.ReplaceAllPositions([]*models.Position{{
+			Symbol: "AAPL", Quantity: decimal.NewFromInt(10), EntryPrice: decimal.NewFromInt(150),
+			EntryDate: time.Now(), CurrentPrice: decimal.NewFromInt(160),
+		}}))
+		require.NoError(t, testDB.UpsertPosition(&models.Position{
+			Symbol: "AAPL", Quantity: decimal.NewFromInt(10), EntryPrice: decimal.NewFromInt(150),
+			EntryDate: time.Now(), CurrentPrice: decimal.NewFromInt(145),
+		}))
+
+		p, err = testDB.GetPositionBySymbol("AAPL")
+		require.NoError(t, err)
+		assert.Equal(t, "141", p.LowestPrice.String())
+	})
+
+	t.Run("GetPortfolioSummary totals open positions at stock prices", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		summary, err := testDB.GetPortfolioSummary()
+		require.NoError(t, err)
+		assert.Equal(t, 0, summary.PositionCount)
+		assert.True(t, summary.TotalMarketValue.IsZero())
+		assert.True(t, summary.TotalUnrealizedPnlPct.IsZero())
+
+		for symbol, price := range map[string]float64{"AAPL": 120, "MSFT": 360} {
+			require.NoError(t, testDB.SaveStock(&models.Stock{
+				Symbol: symbol, Name: symbol + " Inc.", CurrentPrice: price, LastUpdated: time.Now(),
+			}))
+		}
+		positions := []*models.Position{
+			{Symbol: "AAPL", Quantity: decimal.NewFromInt(10), EntryPrice: decimal.NewFromInt(100)},
+			{Symbol: "MSFT", Quantity: decimal.NewFromInt(5), EntryPrice: decimal.NewFromInt(400)},
+			// No stock row: valued at the position's own current price
+			{Symbol: "NEWCO", Quantity: decimal.NewFromInt(20), EntryPrice: decimal.NewFromInt(10),
+				CurrentPrice: decimal.NewFromInt(15)},
+		}
+		for _, p := range positions {
+			p.EntryDate = time.Now()
+			require.NoError(t, testDB.CreatePosition(p))
+		}
+
+		summary, err = testDB.GetPortfolioSummary()
+		require.NoError(t, err)
+		assert.Equal(t, 3, summary.PositionCount)
+		// 10*100 + 5*400 + 20*10
+		assert.Equal(t, "3200", summary.TotalCostBasis.String())
+		// 10*120 + 5*360 + 20*15
+		assert.Equal(t, "3300", summary.TotalMarketValue.String())
+		assert.Equal(t, "100", summary.TotalUnrealizedPnl.String())
+		assert.Equal(t, "3.125", summary.TotalUnrealizedPnlPct.String())
+	})
+
+	t.Run("sector and industry survive snapshots and updates", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		require.NoError(t, testDB.ReplaceAllPositions([]*models.Position{{
+			Symbol: "AAPL", Quantity: decimal.NewFromInt(10), EntryPrice: decimal.NewFromInt(150),
+			EntryDate: time.Now(), Sector: "Technology", Industry: "Consumer Electronics",
+		}}))
+		require.NoError(t, testDB.ReplaceAllPositions([]*models.Position{{
+			Symbol: "AAPL", Quantity: decimal.NewFromInt(12), EntryPrice: decimal.NewFromInt(151), EntryDate: time.Now(),
+		}}))
+		require.NoError(t, testDB.UpsertPosition(&models.Position{
+			Symbol: "AAPL", Quantity: decimal.NewFromInt(8), EntryPrice: decimal.NewFromInt(151), EntryDate: time.Now(),
+		}))
+
+		p, err := testDB.GetPositionBySymbol("AAPL")
+		require.NoError(t, err)
+		assert.Equal(t, "Technology", p.Sector)
+		assert.Equal(t, "Consumer Electronics", p.Industry)
+	})
+
+	t.Run("GetPortfolioRSI weights RSI by position value", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		rsi, err := testDB.GetPortfolioRSI()
+		require.NoError(t, err)
+		assert.True(t, rsi.RSI.IsZero())
+		assert.Equal(t, 0, rsi.Positions)
+
+		positions := []*models.Position{
+			// Worth 3000 at RSI 70
+			{Symbol: "AAPL", Quantity: decimal.NewFromInt(20), EntryPrice: decimal.NewFromInt(140), CurrentPrice: decimal.NewFromInt(150)},
+			// Worth 1000 at RSI 30
+			{Symbol: "MSFT", Quantity: decimal.NewFromInt(4), EntryPrice: decimal.NewFromInt(260), CurrentPrice: decimal.NewFromInt(250)},
+			// No RSI: left out of the weights
+			{Symbol: "NEWCO", Quantity: decimal.NewFromInt(100), EntryPrice: decimal.NewFromInt(10), CurrentPrice: decimal.NewFromInt(10)},
+		}
+		for _, p := range positions {
+			p.EntryDate = time.Now()
+			require.NoError(t, testDB.CreatePosition(p))
+		}
+		for symbol, values := range map[string][2]float64{"AAPL": {40, 70}, "MSFT": {55, 30}} {
+			for i, v := range values {
+				require.NoError(t, testDB.CreateTechnicalIndicator(&models.TechnicalIndicator{
+					Symbol:        symbol,
+					Date:          time.Date(2026, 3, 2+i, 0, 0, 0, 0, time.UTC),
+					IndicatorType: models.IndicatorRSI14,
+					Value:         decimal.NewFromFloat(v),
+				}))
+			}
+		}
+
+		rsi, err = testDB.GetPortfolioRSI()
+		require.NoError(t, err)
+		// (70*3000 + 30*1000) / 4000 using only the latest readings
+		assert.Equal(t, "60", rsi.RSI.String())
+		assert.Equal(t, "4000", rsi.WeightedValue.String())
+		assert.Equal(t, 2, rsi.Positions)
+		assert.Equal(t, 1, rsi.Skipped)
+	})
+
+	t.Run("entry reason is stored on insert and kept once set", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		entry := time.Date(2026, 2, 2, 15, 0, 0, 0, time.UTC)
+		require.NoError(t, testDB.ReplaceAllPositions([]*models.Position{
+			{Symbol: "AAPL", Quantity: decimal.NewFromInt(10), EntryPrice: decimal.NewFromInt(150), EntryDate: entry, EntryReason: "Breakout"},
+		}))
+		// A later snapshot without a reason keeps the stored one
+		require.NoError(t, testDB.ReplaceAllPositions([]*models.Position{
+			{Symbol: "AAPL", Quantity: decimal.NewFromInt(10), EntryPrice: decimal.NewFromInt(150), EntryDate: entry},
+		}))
+		p, err := testDB.GetPositionBySymbol("AAPL")
+		require.NoError(t, err)
+		assert.Equal(t, "Breakout", p.EntryReason)
+
+		update := &models.Position{Symbol: "AAPL", Quantity: decimal.NewFromInt(12), EntryPrice: decimal.NewFromInt(151), EntryDate: entry, EntryReason: "auto-imported"}
+		require.NoError(t, testDB.UpsertPosition(update))
+		assert.Equal(t, "Breakout", update.EntryReason)
+
+		require.NoError(t, testDB.UpsertPosition(&models.Position{
+			Symbol: "TSLA", Quantity: decimal.NewFromInt(2), EntryPrice: decimal.NewFromInt(250), EntryDate: entry, EntryReason: "auto-imported",
+		}))
+		p, err = testDB.GetPositionBySymbol("TSLA")
+		require.NoError(t, err)
+		assert.Equal(t, "auto-imported", p.EntryReason)
+	})
 }
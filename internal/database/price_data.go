@@ -33,7 +33,21 @@ func (db *DB) CreatePriceData(p *models.PriceDataDaily) error {
 	return nil
 }
 
-// CreatePriceDataBatch inserts multiple price data records efficiently
+// upsertPriceDataQuery inserts a daily bar, overwriting any existing bar for the same day
+const upsertPriceDataQuery = `
+	INSERT INTO price_data_daily (symbol, date, open, high, low, close, volume, vwap, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	ON CONFLICT (symbol, date) DO UPDATE SET
+		open = EXCLUDED.open,
+		high = EXCLUDED.high,
+		low = EXCLUDED.low,
+		close = EXCLUDED.close,
+		volume = EXCLUDED.volume,
+		vwap = EXCLUDED.vwap
+`
+
+// CreatePriceDataBatch inserts multiple price data records efficiently.
+// It is all-or-nothing: the first bad row rolls back the whole batch.
 func (db *DB) CreatePriceDataBatch(prices []*models.PriceDataDaily) error {
 	tx, err := db.conn.Begin()
 	if err != nil {
@@ -41,17 +55,7 @@ func (db *DB) CreatePriceDataBatch(prices []*models.PriceDataDaily) error {
 	}
 	defer tx.Rollback()
 
-	stmt, err := tx.Prepare(`
-		INSERT INTO price_data_daily (symbol, date, open, high, low, close, volume, vwap, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		ON CONFLICT (symbol, date) DO UPDATE SET
-			open = EXCLUDED.open,
-			high = EXCLUDED.high,
-			low = EXCLUDED.low,
-			close = EXCLUDED.close,
-			volume = EXCLUDED.volume,
-			vwap = EXCLUDED.vwap
-	`)
+	stmt, err := tx.Prepare(upsertPriceDataQuery)
 	if err != nil {
 		return fmt.Errorf("failed to prepare statement: %w", err)
 	}
@@ -71,6 +75,70 @@ func (db *DB) CreatePriceDataBatch(prices []*models.PriceDataDaily) error {
 	return nil
 }
 
+// PriceDataRowError describes a row skipped by CreatePriceDataBatchBestEffort
+type PriceDataRowError struct {
+	Index  int
+	Symbol string
+	Date   time.Time
+	Err    error
+}
+
+func (e PriceDataRowError) Error() string {
+	return fmt.Sprintf("row %d (%s %s): %v", e.Index, e.Symbol, e.Date.Format("2006-01-02"), e.Err)
+}
+
+// PriceDataBatchResult summarizes a best-effort batch insert
+type PriceDataBatchResult struct {
+	Inserted int
+	Skipped  []PriceDataRowError
+}
+
+// CreatePriceDataBatchBestEffort inserts price data records, skipping rows the database
+// rejects instead of aborting the batch. Each row runs under its own savepoint so a
+// failure doesn't poison the transaction; the good rows are committed together.
+func (db *DB) CreatePriceDataBatchBestEffort(prices []*models.PriceDataDaily) (*PriceDataBatchResult, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(upsertPriceDataQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	result := &PriceDataBatchResult{}
+	now := time.Now()
+	for i, p := range prices {
+		if _, err := tx.Exec("SAVEPOINT price_data_row"); err != nil {
+			return nil, fmt.Errorf("failed to create savepoint: %w", err)
+		}
+
+		_, err := stmt.Exec(p.Symbol, p.Date, p.Open, p.High, p.Low, p.Close, p.Volume, p.VWAP, now)
+		if err != nil {
+			if _, rbErr := tx.Exec("ROLLBACK TO SAVEPOINT price_data_row"); rbErr != nil {
+				return nil, fmt.Errorf("failed to roll back to savepoint: %w", rbErr)
+			}
+			result.Skipped = append(result.Skipped, PriceDataRowError{
+				Index: i, Symbol: p.Symbol, Date: p.Date, Err: err,
+			})
+			continue
+		}
+
+		if _, err := tx.Exec("RELEASE SAVEPOINT price_data_row"); err != nil {
+			return nil, fmt.Errorf("failed to release savepoint: %w", err)
+		}
+		result.Inserted++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return result, nil
+}
+
 // GetPriceDataByID retrieves price data by ID
 func (db *DB) GetPriceDataByID(id int) (*models.PriceDataDaily, error) {
 	query := `
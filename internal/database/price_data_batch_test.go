@@ -0,0 +1,55 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+func TestCreatePriceDataBatchBestEffort_SkipsFailedRow(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	db := &DB{conn: sqlDB}
+
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	prices := []*models.PriceDataDaily{
+		{Symbol: "AAPL", Date: date, Open: decimal.NewFromFloat(1), High: decimal.NewFromFloat(1), Low: decimal.NewFromFloat(1), Close: decimal.NewFromFloat(1), Volume: 1},
+		{Symbol: "BAD", Date: date, Open: decimal.NewFromFloat(1), High: decimal.NewFromFloat(1), Low: decimal.NewFromFloat(1), Close: decimal.NewFromFloat(1), Volume: 1},
+		{Symbol: "MSFT", Date: date, Open: decimal.NewFromFloat(1), High: decimal.NewFromFloat(1), Low: decimal.NewFromFloat(1), Close: decimal.NewFromFloat(1), Volume: 1},
+	}
+
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare("INSERT INTO price_data_daily")
+
+	mock.ExpectExec("SAVEPOINT price_data_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	prep.ExpectExec().WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("RELEASE SAVEPOINT price_data_row").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	mock.ExpectExec("SAVEPOINT price_data_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	prep.ExpectExec().WillReturnError(errors.New("value too long"))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT price_data_row").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	mock.ExpectExec("SAVEPOINT price_data_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	prep.ExpectExec().WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("RELEASE SAVEPOINT price_data_row").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	mock.ExpectCommit()
+
+	result, err := db.CreatePriceDataBatchBestEffort(prices)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Inserted)
+	require.Len(t, result.Skipped, 1)
+	assert.Equal(t, 1, result.Skipped[0].Index)
+	assert.Equal(t, "BAD", result.Skipped[0].Symbol)
+	assert.Contains(t, result.Skipped[0].Error(), "value too long")
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -278,4 +278,42 @@ func TestPriceDataRepository(t *testing.T) {
 		require.NoError(t, err)
 		assert.Len(t, remaining, 5) // Jan 15, 16, 17, 18, 19
 	})
+
+	t.Run("CreatePriceDataBatchBestEffort skips invalid rows and keeps the rest", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		prices := []*models.PriceDataDaily{
+			{Symbol: "AAPL", Date: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), Open: decimal.NewFromFloat(175.00), High: decimal.NewFromFloat(178.00), Low: decimal.NewFromFloat(174.00), Close: decimal.NewFromFloat(177.00), Volume: 50000000},
+			// Symbol exceeds VARCHAR(10)
+			{Symbol: "WAYTOOLONGSYMBOL", Date: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), Open: decimal.NewFromFloat(1), High: decimal.NewFromFloat(1), Low: decimal.NewFromFloat(1), Close: decimal.NewFromFloat(1), Volume: 1},
+			{Symbol: "AAPL", Date: time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC), Open: decimal.NewFromFloat(177.00), High: decimal.NewFromFloat(180.00), Low: decimal.NewFromFloat(176.00), Close: decimal.NewFromFloat(179.00), Volume: 55000000},
+		}
+
+		result, err := testDB.CreatePriceDataBatchBestEffort(prices)
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.Inserted)
+		require.Len(t, result.Skipped, 1)
+		assert.Equal(t, 1, result.Skipped[0].Index)
+		assert.Equal(t, "WAYTOOLONGSYMBOL", result.Skipped[0].Symbol)
+
+		retrieved, err := testDB.GetPriceDataBySymbol("AAPL", 10)
+		require.NoError(t, err)
+		assert.Len(t, retrieved, 2)
+	})
+
+	t.Run("CreatePriceDataBatch stays strict on invalid rows", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		prices := []*models.PriceDataDaily{
+			{Symbol: "AAPL", Date: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), Open: decimal.NewFromFloat(175.00), High: decimal.NewFromFloat(178.00), Low: decimal.NewFromFloat(174.00), Close: decimal.NewFromFloat(177.00), Volume: 50000000},
+			{Symbol: "WAYTOOLONGSYMBOL", Date: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), Open: decimal.NewFromFloat(1), High: decimal.NewFromFloat(1), Low: decimal.NewFromFloat(1), Close: decimal.NewFromFloat(1), Volume: 1},
+		}
+
+		err := testDB.CreatePriceDataBatch(prices)
+		require.Error(t, err)
+
+		retrieved, err := testDB.GetPriceDataBySymbol("AAPL", 10)
+		require.NoError(t, err)
+		assert.Len(t, retrieved, 0)
+	})
 }
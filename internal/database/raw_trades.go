@@ -5,26 +5,68 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/shopspring/decimal"
 	"github.com/trogers1052/stock-alert-system/internal/models"
 )
 
 // CreateRawTrade inserts a new raw trade record
 func (db *DB) CreateRawTrade(t *models.RawTrade) error {
+	return createRawTrade(db.conn, t)
+}
+
+// CreateRawTradeWithCloses inserts a raw trade and the trade histories it closed
+// in one transaction, so a failure stores neither and the trade can be retried.
+// The trade and each close's buy are linked to the close; a trade spanning
+// several closes stays linked to the last. It returns how many links were made,
+// so callers can verify it against the raw trades they expect.
+func (db *DB) CreateRawTradeWithCloses(t *models.RawTrade, closes []*models.ClosedLot) (int64, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := createRawTrade(tx, t); err != nil {
+		return 0, err
+	}
+	var linked int64
+	for _, closed := range closes {
+		if err := createTradeHistory(tx, closed.Trade); err != nil {
+			return 0, err
+		}
+		ids := []int{t.ID}
+		if closed.BuyTradeID != 0 {
+			ids = append(ids, closed.BuyTradeID)
+		}
+		n, err := linkRawTrades(tx, closed.Trade.ID, ids)
+		if err != nil {
+			return 0, err
+		}
+		linked += n
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit raw trade and closes: %w", err)
+	}
+	return linked, nil
+}
+
+func createRawTrade(q execer, t *models.RawTrade) error {
 	query := `
 		INSERT INTO raw_trades (
 			order_id, source, symbol, side, quantity, price, total_cost, fees,
-			executed_at, position_id, trade_history_id, created_at
+			executed_at, position_id, trade_history_id, extended_hours, created_at
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
 		)
 		RETURNING id
 	`
 	now := time.Now()
 
-	err := db.conn.QueryRow(query,
+	err := q.QueryRow(query,
 		t.OrderID, t.Source, t.Symbol, t.Side, t.Quantity, t.Price, t.TotalCost, t.Fees,
-		t.ExecutedAt, t.PositionID, t.TradeHistoryID, now,
+		t.ExecutedAt, t.PositionID, t.TradeHistoryID, t.ExtendedHours, now,
 	).Scan(&t.ID)
 
 	if err != nil {
@@ -45,50 +87,126 @@ func (db *DB) RawTradeExistsByOrderID(orderID, source string) (bool, error) {
 	return exists, nil
 }
 
+// UpdateRawTradeByOrderID rewrites the stored raw trade with t's order_id and source
+// in place, keeping its ID, position and trade history links, and sets t.ID and
+// t.CreatedAt from the stored row
+func (db *DB) UpdateRawTradeByOrderID(t *models.RawTrade) error {
+	query := `
+		UPDATE raw_trades
+		SET symbol = $3, side = $4, quantity = $5, price = $6, total_cost = $7, fees = $8,
+		    executed_at = $9, extended_hours = $10
+		WHERE order_id = $1 AND source = $2
+		RETURNING id, created_at
+	`
+	err := db.conn.QueryRow(query,
+		t.OrderID, t.Source, t.Symbol, t.Side, t.Quantity, t.Price, t.TotalCost, t.Fees,
+		t.ExecutedAt, t.ExtendedHours,
+	).Scan(&t.ID, &t.CreatedAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("raw trade not found for order: %s", t.OrderID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update raw trade: %w", err)
+	}
+	return nil
+}
+
 // GetRawTradeByID retrieves a raw trade by ID
 func (db *DB) GetRawTradeByID(id int) (*models.RawTrade, error) {
 	query := `
 		SELECT id, order_id, source, symbol, side, quantity, price, total_cost, fees,
-		       executed_at, position_id, trade_history_id, created_at
+		       executed_at, position_id, trade_history_id, extended_hours, created_at
 		FROM raw_trades
 		WHERE id = $1
 	`
 	return db.scanSingleRawTrade(db.conn.QueryRow(query, id))
 }
 
-// GetRawTradesBySymbol retrieves all raw trades for a symbol
+// Raw trade queries break executed_at ties on id (arrival order) so a buy and sell
+// filled in the same second are always replayed in the order they were received.
+
+// GetRawTradesBySymbol retrieves all raw trades for a symbol, newest first
 func (db *DB) GetRawTradesBySymbol(symbol string, limit int) ([]*models.RawTrade, error) {
 	query := `
 		SELECT id, order_id, source, symbol, side, quantity, price, total_cost, fees,
-		       executed_at, position_id, trade_history_id, created_at
+		       executed_at, position_id, trade_history_id, extended_hours, created_at
 		FROM raw_trades
 		WHERE symbol = $1
-		ORDER BY executed_at DESC
+		ORDER BY executed_at DESC, id DESC
 		LIMIT $2
 	`
 	return db.scanRawTrades(db.conn.Query(query, symbol, limit))
 }
 
+// GetRawTradeLedger retrieves every raw trade for a symbol in execution order
+func (db *DB) GetRawTradeLedger(symbol string) ([]*models.RawTrade, error) {
+	query := `
+		SELECT id, order_id, source, symbol, side, quantity, price, total_cost, fees,
+		       executed_at, position_id, trade_history_id, extended_hours, created_at
+		FROM raw_trades
+		WHERE symbol = $1
+		ORDER BY executed_at ASC, id ASC
+	`
+	return db.scanRawTrades(db.conn.Query(query, symbol))
+}
+
+// GetRawTradeLedgers returns the raw trades for each of symbols in execution
+// order, keyed by symbol. Symbols without trades are absent.
+func (db *DB) GetRawTradeLedgers(symbols []string) (map[string][]*models.RawTrade, error) {
+	ledgers := make(map[string][]*models.RawTrade, len(symbols))
+	if len(symbols) == 0 {
+		return ledgers, nil
+	}
+
+	query := `
+		SELECT id, order_id, source, symbol, side, quantity, price, total_cost, fees,
+		       executed_at, position_id, trade_history_id, extended_hours, created_at
+		FROM raw_trades
+		WHERE symbol = ANY($1)
+		ORDER BY executed_at ASC, id ASC
+	`
+	trades, err := db.scanRawTrades(db.conn.Query(query, pq.Array(symbols)))
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range trades {
+		ledgers[t.Symbol] = append(ledgers[t.Symbol], t)
+	}
+	return ledgers, nil
+}
+
 // GetRawTradesByPositionID retrieves all raw trades linked to a position
 func (db *DB) GetRawTradesByPositionID(positionID int) ([]*models.RawTrade, error) {
 	query := `
 		SELECT id, order_id, source, symbol, side, quantity, price, total_cost, fees,
-		       executed_at, position_id, trade_history_id, created_at
+		       executed_at, position_id, trade_history_id, extended_hours, created_at
 		FROM raw_trades
 		WHERE position_id = $1
-		ORDER BY executed_at ASC
+		ORDER BY executed_at ASC, id ASC
 	`
 	return db.scanRawTrades(db.conn.Query(query, positionID))
 }
 
+// GetRawTradesByTradeHistoryID retrieves the executions linked to a closed trade
+func (db *DB) GetRawTradesByTradeHistoryID(historyID int) ([]*models.RawTrade, error) {
+	query := `
+		SELECT id, order_id, source, symbol, side, quantity, price, total_cost, fees,
+		       executed_at, position_id, trade_history_id, extended_hours, created_at
+		FROM raw_trades
+		WHERE trade_history_id = $1
+		ORDER BY executed_at ASC, id ASC
+	`
+	return db.scanRawTrades(db.conn.Query(query, historyID))
+}
+
 // GetUnlinkedRawTradesBySymbol retrieves raw trades not yet linked to a position
 func (db *DB) GetUnlinkedRawTradesBySymbol(symbol string) ([]*models.RawTrade, error) {
 	query := `
 		SELECT id, order_id, source, symbol, side, quantity, price, total_cost, fees,
-		       executed_at, position_id, trade_history_id, created_at
+		       executed_at, position_id, trade_history_id, extended_hours, created_at
 		FROM raw_trades
 		WHERE symbol = $1 AND position_id IS NULL
-		ORDER BY executed_at ASC
+		ORDER BY executed_at ASC, id ASC
 	`
 	return db.scanRawTrades(db.conn.Query(query, symbol))
 }
@@ -122,13 +240,144 @@ func (db *DB) UpdateRawTradeHistoryID(tradeID int, historyID int) error {
 }
 
 // LinkRawTradesToTradeHistory links all raw trades for a position to a trade history record
-func (db *DB) LinkRawTradesToTradeHistory(positionID, historyID int) error {
+// and returns how many were linked, so callers can verify it against the trades they expect.
+func (db *DB) LinkRawTradesToTradeHistory(positionID, historyID int) (int64, error) {
 	query := `UPDATE raw_trades SET trade_history_id = $2 WHERE position_id = $1`
-	_, err := db.conn.Exec(query, positionID, historyID)
+	result, err := db.conn.Exec(query, positionID, historyID)
 	if err != nil {
-		return fmt.Errorf("failed to link raw trades to trade history: %w", err)
+		return 0, fmt.Errorf("failed to link raw trades to trade history: %w", err)
 	}
-	return nil
+	linked, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count linked raw trades: %w", err)
+	}
+	return linked, nil
+}
+
+// linkRawTrades links the raw trades with ids to a trade history and returns how
+// many were linked
+func linkRawTrades(q execer, historyID int, ids []int) (int64, error) {
+	query := `UPDATE raw_trades SET trade_history_id = $1 WHERE id = ANY($2)`
+	result, err := q.Exec(query, historyID, pq.Array(ids))
+	if err != nil {
+		return 0, fmt.Errorf("failed to link raw trades to trade history: %w", err)
+	}
+	linked, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count linked raw trades: %w", err)
+	}
+	return linked, nil
+}
+
+// GetTotalFees sums fees paid on raw trades executed within [start, end], broken down by symbol
+func (db *DB) GetTotalFees(start, end time.Time) (*models.FeesReport, error) {
+	query := `
+		SELECT symbol, COALESCE(SUM(fees), 0)
+		FROM raw_trades
+		WHERE executed_at >= $1 AND executed_at <= $2
+		GROUP BY symbol
+		ORDER BY symbol ASC
+	`
+	rows, err := db.conn.Query(query, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get total fees: %w", err)
+	}
+	defer rows.Close()
+
+	report := &models.FeesReport{
+		Start:    start,
+		End:      end,
+		Total:    decimal.Zero,
+		BySymbol: make(map[string]decimal.Decimal),
+	}
+	for rows.Next() {
+		var symbol, sum string
+		if err := rows.Scan(&symbol, &sum); err != nil {
+			return nil, fmt.Errorf("failed to scan fees: %w", err)
+		}
+		fees, err := decimal.NewFromString(sum)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse fees for %s: %w", symbol, err)
+		}
+		report.BySymbol[symbol] = fees
+		report.Total = report.Total.Add(fees)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate fees: %w", err)
+	}
+
+	return report, nil
+}
+
+// GetRawTradesDetailed returns raw trades executed within [start, end] in execution
+// order, each joined to its position and trade history for an audit export
+func (db *DB) GetRawTradesDetailed(start, end time.Time) ([]*models.RawTradeDetail, error) {
+	query := `
+		SELECT rt.id, rt.order_id, rt.source, rt.symbol, rt.side, rt.quantity, rt.price, rt.total_cost, rt.fees,
+		       rt.executed_at, rt.position_id, rt.trade_history_id, rt.extended_hours, rt.created_at,
+		       p.symbol, th.entry_date, th.exit_date, th.realized_pnl::text, th.realized_pnl_pct::text
+		FROM raw_trades rt
+		LEFT JOIN positions p ON p.id = rt.position_id
+		LEFT JOIN trades_history th ON th.id = rt.trade_history_id
+		WHERE rt.executed_at >= $1 AND rt.executed_at <= $2
+		ORDER BY rt.executed_at ASC, rt.id ASC
+	`
+	rows, err := db.conn.Query(query, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get detailed raw trades: %w", err)
+	}
+	defer rows.Close()
+
+	var trades []*models.RawTradeDetail
+	for rows.Next() {
+		var d models.RawTradeDetail
+		var positionID, tradeHistoryID sql.NullInt64
+		var fees, positionSymbol, realizedPnl, realizedPnlPct sql.NullString
+		var entryDate, exitDate sql.NullTime
+
+		err := rows.Scan(
+			&d.ID, &d.OrderID, &d.Source, &d.Symbol, &d.Side, &d.Quantity, &d.Price, &d.TotalCost, &fees,
+			&d.ExecutedAt, &positionID, &tradeHistoryID, &d.ExtendedHours, &d.CreatedAt,
+			&positionSymbol, &entryDate, &exitDate, &realizedPnl, &realizedPnlPct,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan detailed raw trade: %w", err)
+		}
+
+		if fees.Valid {
+			d.Fees, _ = decimal.NewFromString(fees.String)
+		}
+		if positionID.Valid {
+			id := int(positionID.Int64)
+			d.PositionID = &id
+		}
+		if tradeHistoryID.Valid {
+			id := int(tradeHistoryID.Int64)
+			d.TradeHistoryID = &id
+		}
+		d.PositionSymbol = positionSymbol.String
+		if entryDate.Valid {
+			d.CycleEntryDate = &entryDate.Time
+		}
+		if exitDate.Valid {
+			d.CycleExitDate = &exitDate.Time
+		}
+		if realizedPnl.Valid {
+			pnl, _ := decimal.NewFromString(realizedPnl.String)
+			d.RealizedPnl = &pnl
+		}
+		if realizedPnlPct.Valid {
+			pct, _ := decimal.NewFromString(realizedPnlPct.String)
+			d.RealizedPnlPct = &pct
+		}
+
+		trades = append(trades, &d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate detailed raw trades: %w", err)
+	}
+
+	return trades, nil
 }
 
 func (db *DB) scanSingleRawTrade(row *sql.Row) (*models.RawTrade, error) {
@@ -138,7 +387,7 @@ func (db *DB) scanSingleRawTrade(row *sql.Row) (*models.RawTrade, error) {
 
 	err := row.Scan(
 		&t.ID, &t.OrderID, &t.Source, &t.Symbol, &t.Side, &t.Quantity, &t.Price, &t.TotalCost, &fees,
-		&t.ExecutedAt, &positionID, &tradeHistoryID, &t.CreatedAt,
+		&t.ExecutedAt, &positionID, &tradeHistoryID, &t.ExtendedHours, &t.CreatedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -177,7 +426,7 @@ func (db *DB) scanRawTrades(rows *sql.Rows, err error) ([]*models.RawTrade, erro
 
 		err := rows.Scan(
 			&t.ID, &t.OrderID, &t.Source, &t.Symbol, &t.Side, &t.Quantity, &t.Price, &t.TotalCost, &fees,
-			&t.ExecutedAt, &positionID, &tradeHistoryID, &t.CreatedAt,
+			&t.ExecutedAt, &positionID, &tradeHistoryID, &t.ExtendedHours, &t.CreatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan raw trade: %w", err)
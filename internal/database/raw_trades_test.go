@@ -0,0 +1,363 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+func TestRawTradesRepository(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup(t)
+
+	createRawTrade := func(t *testing.T, orderID, symbol, side string, fees float64, executedAt time.Time) *models.RawTrade {
+		t.Helper()
+		trade := &models.RawTrade{
+			OrderID:    orderID,
+			Source:     "robinhood",
+			Symbol:     symbol,
+			Side:       side,
+			Quantity:   decimal.NewFromFloat(10),
+			Price:      decimal.NewFromFloat(100),
+			TotalCost:  decimal.NewFromFloat(1000),
+			Fees:       decimal.NewFromFloat(fees),
+			ExecutedAt: executedAt,
+		}
+		require.NoError(t, testDB.CreateRawTrade(trade))
+		return trade
+	}
+
+	t.Run("GetTotalFees sums fees in window by symbol", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		now := time.Now().UTC().Truncate(time.Second)
+		createRawTrade(t, "fee-1", "AAPL", models.TradeTypeBuy, 1.25, now.Add(-48*time.Hour))
+		createRawTrade(t, "fee-2", "AAPL", models.TradeTypeSell, 0.75, now.Add(-24*time.Hour))
+		createRawTrade(t, "fee-3", "MSFT", models.TradeTypeBuy, 2.50, now.Add(-12*time.Hour))
+		createRawTrade(t, "fee-4", "TSLA", models.TradeTypeBuy, 0, now.Add(-6*time.Hour))
+		// Outside the window
+		createRawTrade(t, "fee-5", "AAPL", models.TradeTypeBuy, 10.00, now.Add(-30*24*time.Hour))
+
+		report, err := testDB.GetTotalFees(now.Add(-7*24*time.Hour), now)
+		require.NoError(t, err)
+
+		assert.True(t, decimal.NewFromFloat(4.50).Equal(report.Total), "total: %s", report.Total)
+		require.Len(t, report.BySymbol, 3)
+		assert.True(t, decimal.NewFromFloat(2.00).Equal(report.BySymbol["AAPL"]))
+		assert.True(t, decimal.NewFromFloat(2.50).Equal(report.BySymbol["MSFT"]))
+		assert.True(t, report.BySymbol["TSLA"].IsZero())
+	})
+
+	t.Run("GetTotalFees returns zero for empty window", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		now := time.Now()
+		report, err := testDB.GetTotalFees(now.Add(-time.Hour), now)
+		require.NoError(t, err)
+		assert.True(t, report.Total.IsZero())
+		assert.Empty(t, report.BySymbol)
+	})
+
+	t.Run("LinkRawTradesToTradeHistory returns linked count", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		position := &models.Position{
+			Symbol:     "AAPL",
+			Quantity:   decimal.NewFromFloat(20),
+			EntryPrice: decimal.NewFromFloat(100),
+			EntryDate:  time.Now(),
+		}
+		require.NoError(t, testDB.CreatePosition(position))
+
+		now := time.Now()
+		buy1 := createRawTrade(t, "link-1", "AAPL", models.TradeTypeBuy, 0, now.Add(-48*time.Hour))
+		buy2 := createRawTrade(t, "link-2", "AAPL", models.TradeTypeBuy, 0, now.Add(-24*time.Hour))
+		createRawTrade(t, "link-3", "AAPL", models.TradeTypeBuy, 0, now) // Not part of the position
+		require.NoError(t, testDB.UpdateRawTradePositionID(buy1.ID, position.ID))
+		require.NoError(t, testDB.UpdateRawTradePositionID(buy2.ID, position.ID))
+
+		history := &models.TradeHistory{
+			Symbol:     "AAPL",
+			TradeType:  models.TradeTypeSell,
+			Quantity:   decimal.NewFromFloat(20),
+			Price:      decimal.NewFromFloat(110),
+			TotalCost:  decimal.NewFromFloat(2200),
+			TradeGrade: models.TradeGradeB,
+		}
+		require.NoError(t, testDB.CreateTradeHistory(history))
+
+		linked, err := testDB.LinkRawTradesToTradeHistory(position.ID, history.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), linked)
+
+		trades, err := testDB.GetRawTradesByPositionID(position.ID)
+		require.NoError(t, err)
+		require.Len(t, trades, 2)
+		for _, tr := range trades {
+			require.NotNil(t, tr.TradeHistoryID)
+			assert.Equal(t, history.ID, *tr.TradeHistoryID)
+		}
+
+		// Unknown position links nothing
+		linked, err = testDB.LinkRawTradesToTradeHistory(position.ID+1000, history.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), linked)
+	})
+
+	t.Run("Same-timestamp trades replay in arrival order", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		position := &models.Position{
+			Symbol:     "AAPL",
+			Quantity:   decimal.NewFromFloat(10),
+			EntryPrice: decimal.NewFromFloat(100),
+			EntryDate:  time.Now(),
+		}
+		require.NoError(t, testDB.CreatePosition(position))
+
+		// A round trip filled within the same second
+		executedAt := time.Now().UTC().Truncate(time.Second)
+		buy := createRawTrade(t, "same-ts-buy", "AAPL", models.TradeTypeBuy, 0, executedAt)
+		sell := createRawTrade(t, "same-ts-sell", "AAPL", models.TradeTypeSell, 0, executedAt)
+
+		// Link the sell first so the buy's row is rewritten last and no longer
+		// comes first in physical order
+		require.NoError(t, testDB.UpdateRawTradePositionID(sell.ID, position.ID))
+		require.NoError(t, testDB.UpdateRawTradePositionID(buy.ID, position.ID))
+
+		for i := 0; i < 5; i++ {
+			trades, err := testDB.GetRawTradesByPositionID(position.ID)
+			require.NoError(t, err)
+			require.Len(t, trades, 2)
+			assert.Equal(t, []string{"same-ts-buy", "same-ts-sell"}, []string{trades[0].OrderID, trades[1].OrderID})
+
+			// Replaying in order never takes the position short
+			held := decimal.Zero
+			for _, tr := range trades {
+				if tr.Side == models.TradeTypeBuy {
+					held = held.Add(tr.Quantity)
+				} else {
+					held = held.Sub(tr.Quantity)
+				}
+				assert.False(t, held.IsNegative(), "position went short replaying %s", tr.OrderID)
+			}
+			assert.True(t, held.IsZero())
+		}
+
+		newestFirst, err := testDB.GetRawTradesBySymbol("AAPL", 10)
+		require.NoError(t, err)
+		require.Len(t, newestFirst, 2)
+		assert.Equal(t, "same-ts-sell", newestFirst[0].OrderID)
+		assert.Equal(t, "same-ts-buy", newestFirst[1].OrderID)
+	})
+
+	t.Run("GetRawTradesByTradeHistoryID returns linked executions", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		now := time.Now()
+		buy := createRawTrade(t, "exec-1", "MSFT", models.TradeTypeBuy, 0, now.Add(-24*time.Hour))
+		sell := createRawTrade(t, "exec-2", "MSFT", models.TradeTypeSell, 0, now)
+		createRawTrade(t, "exec-3", "MSFT", models.TradeTypeBuy, 0, now) // Unlinked
+
+		history := &models.TradeHistory{
+			Symbol:    "MSFT",
+			TradeType: models.TradeTypeSell,
+			Quantity:  decimal.NewFromFloat(5),
+			Price:     decimal.NewFromFloat(410),
+			TotalCost: decimal.NewFromFloat(2050),
+		}
+		require.NoError(t, testDB.CreateTradeHistory(history))
+		require.NoError(t, testDB.UpdateRawTradeHistoryID(sell.ID, history.ID))
+		require.NoError(t, testDB.UpdateRawTradeHistoryID(buy.ID, history.ID))
+
+		executions, err := testDB.GetRawTradesByTradeHistoryID(history.ID)
+		require.NoError(t, err)
+		require.Len(t, executions, 2)
+		assert.Equal(t, "exec-1", executions[0].OrderID)
+		assert.Equal(t, "exec-2", executions[1].OrderID)
+
+		executions, err = testDB.GetRawTradesByTradeHistoryID(history.ID + 1000)
+		require.NoError(t, err)
+		assert.Empty(t, executions)
+	})
+
+	t.Run("GetRawTradeLedgers groups trades by symbol in execution order", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		day := time.Date(2024, 3, 4, 15, 0, 0, 0, time.UTC)
+		createRawTrade(t, "lg-1", "AAPL", models.TradeTypeSell, 0, day.Add(time.Hour))
+		createRawTrade(t, "lg-2", "MSFT", models.TradeTypeBuy, 0, day)
+		createRawTrade(t, "lg-3", "AAPL", models.TradeTypeBuy, 0, day)
+		createRawTrade(t, "lg-4", "NVDA", models.TradeTypeBuy, 0, day)
+
+		ledgers, err := testDB.GetRawTradeLedgers([]string{"AAPL", "MSFT", "TSLA"})
+		require.NoError(t, err)
+		require.Len(t, ledgers, 2)
+		require.Len(t, ledgers["AAPL"], 2)
+		assert.Equal(t, "lg-3", ledgers["AAPL"][0].OrderID)
+		assert.Equal(t, "lg-1", ledgers["AAPL"][1].OrderID)
+		require.Len(t, ledgers["MSFT"], 1)
+
+		ledgers, err = testDB.GetRawTradeLedgers(nil)
+		require.NoError(t, err)
+		assert.Empty(t, ledgers)
+	})
+
+	t.Run("UpdateRawTradeByOrderID rewrites the stored row", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		now := time.Now().UTC().Truncate(time.Second)
+		original := createRawTrade(t, "upd-1", "AAPL", models.TradeTypeBuy, 1, now)
+
+		corrected := &models.RawTrade{
+			OrderID:    "upd-1",
+			Source:     "robinhood",
+			Symbol:     "AAPL",
+			Side:       models.TradeTypeBuy,
+			Quantity:   decimal.NewFromFloat(8),
+			Price:      decimal.NewFromFloat(101),
+			TotalCost:  decimal.NewFromFloat(808),
+			Fees:       decimal.NewFromFloat(0.5),
+			ExecutedAt: now,
+		}
+		require.NoError(t, testDB.UpdateRawTradeByOrderID(corrected))
+		assert.Equal(t, original.ID, corrected.ID)
+
+		stored, err := testDB.GetRawTradeByID(original.ID)
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromFloat(8).Equal(stored.Quantity))
+		assert.True(t, decimal.NewFromFloat(101).Equal(stored.Price))
+
+		ledger, err := testDB.GetRawTradeLedger("AAPL")
+		require.NoError(t, err)
+		assert.Len(t, ledger, 1)
+
+		missing := &models.RawTrade{OrderID: "nope", Source: "robinhood", ExecutedAt: now}
+		assert.Error(t, testDB.UpdateRawTradeByOrderID(missing))
+	})
+
+	t.Run("GetRawTradesDetailed joins each execution to its cycle", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		now := time.Now().UTC().Truncate(time.Second)
+		position := &models.Position{
+			Symbol:     "AAPL",
+			Quantity:   decimal.NewFromFloat(10),
+			EntryPrice: decimal.NewFromFloat(100),
+			EntryDate:  now.Add(-48 * time.Hour),
+		}
+		require.NoError(t, testDB.CreatePosition(position))
+
+		buy := createRawTrade(t, "detail-1", "AAPL", models.TradeTypeBuy, 0, now.Add(-48*time.Hour))
+		sell := createRawTrade(t, "detail-2", "AAPL", models.TradeTypeSell, 0, now.Add(-time.Hour))
+		open := createRawTrade(t, "detail-3", "MSFT", models.TradeTypeBuy, 0, now.Add(-30*time.Minute))
+		require.NoError(t, testDB.UpdateRawTradePositionID(buy.ID, position.ID))
+		require.NoError(t, testDB.UpdateRawTradePositionID(sell.ID, position.ID))
+
+		entryDate, exitDate := now.Add(-48*time.Hour), now.Add(-time.Hour)
+		history := &models.TradeHistory{
+			Symbol:         "AAPL",
+			TradeType:      models.TradeTypeSell,
+			Quantity:       decimal.NewFromFloat(10),
+			Price:          decimal.NewFromFloat(110),
+			TotalCost:      decimal.NewFromFloat(1100),
+			EntryDate:      &entryDate,
+			ExitDate:       &exitDate,
+			RealizedPnl:    decimal.NewFromFloat(100),
+			RealizedPnlPct: decimal.NewFromFloat(10),
+		}
+		require.NoError(t, testDB.CreateTradeHistory(history))
+		_, err := testDB.LinkRawTradesToTradeHistory(position.ID, history.ID)
+		require.NoError(t, err)
+
+		trades, err := testDB.GetRawTradesDetailed(now.Add(-72*time.Hour), now)
+		require.NoError(t, err)
+		require.Len(t, trades, 3)
+
+		for _, d := range trades[:2] {
+			assert.Equal(t, "AAPL", d.PositionSymbol)
+			require.NotNil(t, d.TradeHistoryID)
+			assert.Equal(t, history.ID, *d.TradeHistoryID)
+			require.NotNil(t, d.CycleEntryDate)
+			require.NotNil(t, d.CycleExitDate)
+			assert.True(t, entryDate.Equal(*d.CycleEntryDate))
+			assert.True(t, exitDate.Equal(*d.CycleExitDate))
+			require.NotNil(t, d.RealizedPnl)
+			assert.True(t, decimal.NewFromFloat(100).Equal(*d.RealizedPnl))
+			require.NotNil(t, d.RealizedPnlPct)
+			assert.True(t, decimal.NewFromFloat(10).Equal(*d.RealizedPnlPct))
+		}
+		assert.Equal(t, buy.ID, trades[0].ID)
+		assert.Equal(t, sell.ID, trades[1].ID)
+
+		// An unlinked execution has no outcome yet
+		assert.Equal(t, open.ID, trades[2].ID)
+		assert.Empty(t, trades[2].PositionSymbol)
+		assert.Nil(t, trades[2].TradeHistoryID)
+		assert.Nil(t, trades[2].RealizedPnl)
+	})
+
+	t.Run("CreateRawTradeWithCloses stores and links a sell and its closes together", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		now := time.Now().UTC().Truncate(time.Second)
+		newSell := func(orderID string) *models.RawTrade {
+			return &models.RawTrade{
+				OrderID:    orderID,
+				Source:     "robinhood",
+				Symbol:     "AAPL",
+				Side:       models.TradeTypeSell,
+				Quantity:   decimal.NewFromFloat(10),
+				Price:      decimal.NewFromFloat(110),
+				TotalCost:  decimal.NewFromFloat(1100),
+				ExecutedAt: now,
+			}
+		}
+		newClose := func(grade string) *models.TradeHistory {
+			return &models.TradeHistory{
+				Symbol:      "AAPL",
+				TradeType:   models.TradeTypeSell,
+				Quantity:    decimal.NewFromFloat(10),
+				Price:       decimal.NewFromFloat(110),
+				TotalCost:   decimal.NewFromFloat(1100),
+				RealizedPnl: decimal.NewFromFloat(100),
+				TradeGrade:  grade,
+				ExecutedAt:  now,
+			}
+		}
+
+		buy := createRawTrade(t, "close-buy", "AAPL", models.TradeTypeBuy, 0, now.Add(-time.Hour))
+		sell := newSell("close-1")
+		closed := newClose(models.TradeGradeA)
+		linked, err := testDB.CreateRawTradeWithCloses(sell, []*models.ClosedLot{{Trade: closed, BuyTradeID: buy.ID}})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), linked)
+		assert.NotZero(t, sell.ID)
+		assert.NotZero(t, closed.ID)
+
+		executions, err := testDB.GetRawTradesByTradeHistoryID(closed.ID)
+		require.NoError(t, err)
+		require.Len(t, executions, 2)
+		assert.Equal(t, "close-buy", executions[0].OrderID)
+		assert.Equal(t, "close-1", executions[1].OrderID)
+
+		// A close the schema rejects leaves its sell unstored
+		_, err = testDB.CreateRawTradeWithCloses(newSell("close-2"), []*models.ClosedLot{{Trade: newClose("Z")}})
+		require.Error(t, err)
+		exists, err := testDB.RawTradeExistsByOrderID("close-2", "robinhood")
+		require.NoError(t, err)
+		assert.False(t, exists)
+
+		trades, err := testDB.GetTradeHistoryBySymbol("AAPL", 10)
+		require.NoError(t, err)
+		assert.Len(t, trades, 1)
+	})
+}
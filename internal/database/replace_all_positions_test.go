@@ -42,6 +42,10 @@ func TestReplaceAllPositions_Success(t *testing.T) {
 	}
 
 	mock.ExpectBegin()
+	// Realized P&L, notes, tags and the entry reason are carried over to the new snapshot.
+	mock.ExpectQuery("SELECT symbol, entry_date, sector, industry, realized_pnl, notes, tags, lowest_price, entry_rsi, entry_reason FROM positions").
+		WillReturnRows(sqlmock.NewRows([]string{"symbol", "entry_date", "sector", "industry", "realized_pnl", "notes", "tags", "lowest_price", "entry_rsi", "entry_reason"}).
+			AddRow("AAPL", entryDate, nil, nil, "25.5000", "Earnings play", "{swing,tech}", nil, nil, "Breakout"))
 	mock.ExpectExec("DELETE FROM positions").WillReturnResult(sqlmock.NewResult(0, 2))
 
 	// Two inserts, one for each position.
@@ -57,6 +61,12 @@ func TestReplaceAllPositions_Success(t *testing.T) {
 
 	assert.Equal(t, 101, positions[0].ID)
 	assert.Equal(t, 102, positions[1].ID)
+	assert.True(t, positions[0].RealizedPnl.Equal(decimal.NewFromFloat(25.5)))
+	assert.True(t, positions[1].RealizedPnl.IsZero())
+	assert.Equal(t, "Earnings play", positions[0].Notes)
+	assert.Equal(t, []string{"swing", "tech"}, positions[0].Tags)
+	assert.Empty(t, positions[1].Notes)
+	assert.Equal(t, "Breakout", positions[0].EntryReason)
 	assert.False(t, positions[0].CreatedAt.IsZero())
 	assert.False(t, positions[0].UpdatedAt.IsZero())
 	assert.False(t, positions[1].CreatedAt.IsZero())
@@ -90,6 +100,8 @@ func TestReplaceAllPositions_ReturnsErrorIfDeleteFails(t *testing.T) {
 	db := &DB{conn: sqlDB}
 
 	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT symbol, entry_date, sector, industry, realized_pnl, notes, tags, lowest_price, entry_rsi, entry_reason FROM positions").
+		WillReturnRows(sqlmock.NewRows([]string{"symbol", "entry_date", "sector", "industry", "realized_pnl", "notes", "tags", "lowest_price", "entry_rsi", "entry_reason"}))
 	mock.ExpectExec("DELETE FROM positions").WillReturnError(errors.New("delete failed"))
 	mock.ExpectRollback()
 
@@ -99,3 +111,77 @@ func TestReplaceAllPositions_ReturnsErrorIfDeleteFails(t *testing.T) {
 
 	require.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestReplaceAllPositions_PreservesEarliestEntryDate(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	db := &DB{conn: sqlDB}
+
+	heldSince := time.Date(2026, 1, 5, 15, 0, 0, 0, time.UTC)
+	snapshotAt := time.Date(2026, 2, 20, 15, 0, 0, 0, time.UTC)
+	positions := []*models.Position{
+		{Symbol: "AAPL", Quantity: decimal.NewFromInt(10), EntryPrice: decimal.NewFromInt(150), EntryDate: snapshotAt},
+		{Symbol: "NVDA", Quantity: decimal.NewFromInt(4), EntryPrice: decimal.NewFromInt(900), EntryDate: snapshotAt},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT symbol, entry_date, sector, industry, realized_pnl, notes, tags, lowest_price, entry_rsi, entry_reason FROM positions").
+		WillReturnRows(sqlmock.NewRows([]string{"symbol", "entry_date", "sector", "industry", "realized_pnl", "notes", "tags", "lowest_price", "entry_rsi", "entry_reason"}).
+			AddRow("AAPL", heldSince, nil, nil, "0", nil, "{}", nil, nil, nil).
+			AddRow("TSLA", heldSince, nil, nil, "0", nil, "{}", nil, nil, nil))
+	mock.ExpectExec("DELETE FROM positions").WillReturnResult(sqlmock.NewResult(0, 2))
+
+	// AAPL keeps the stored entry date; NVDA is new and keeps the snapshot's
+	mock.ExpectQuery("INSERT INTO positions").
+		WithArgs("AAPL", sqlmock.AnyArg(), sqlmock.AnyArg(), heldSince, sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("INSERT INTO positions").
+		WithArgs("NVDA", sqlmock.AnyArg(), sqlmock.AnyArg(), snapshotAt, sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectCommit()
+
+	require.NoError(t, db.ReplaceAllPositions(positions))
+	assert.True(t, heldSince.Equal(positions[0].EntryDate))
+	assert.True(t, snapshotAt.Equal(positions[1].EntryDate))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReplaceAllPositions_CarriesLowestPrice(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	db := &DB{conn: sqlDB}
+
+	at := time.Date(2026, 2, 20, 15, 0, 0, 0, time.UTC)
+	positions := []*models.Position{
+		{Symbol: "AAPL", Quantity: decimal.NewFromInt(10), EntryPrice: decimal.NewFromInt(150), EntryDate: at,
+			CurrentPrice: decimal.NewFromInt(160)},
+		{Symbol: "TSLA", Quantity: decimal.NewFromInt(4), EntryPrice: decimal.NewFromInt(250), EntryDate: at,
+			CurrentPrice: decimal.NewFromInt(230)},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT symbol, entry_date, sector, industry, realized_pnl, notes, tags, lowest_price, entry_rsi, entry_reason FROM positions").
+		WillReturnRows(sqlmock.NewRows([]string{"symbol", "entry_date", "sector", "industry", "realized_pnl", "notes", "tags", "lowest_price", "entry_rsi", "entry_reason"}).
+			AddRow("AAPL", at, nil, nil, "0", nil, "{}", "141.5000", nil, nil).
+			AddRow("TSLA", at, nil, nil, "0", nil, "{}", "240.0000", nil, nil))
+	mock.ExpectExec("DELETE FROM positions").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery("INSERT INTO positions").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("INSERT INTO positions").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectCommit()
+
+	require.NoError(t, db.ReplaceAllPositions(positions))
+	// AAPL keeps its stored low; TSLA's snapshot price is below the stored one
+	assert.True(t, decimal.RequireFromString("141.5").Equal(positions[0].LowestPrice), "got %s", positions[0].LowestPrice)
+	assert.True(t, decimal.NewFromInt(230).Equal(positions[1].LowestPrice), "got %s", positions[1].LowestPrice)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
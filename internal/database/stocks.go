@@ -2,11 +2,15 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/trogers1052/stock-alert-system/internal/models"
 )
 
+// ErrStockNotFound is returned when no stocks row exists for a symbol
+var ErrStockNotFound = errors.New("stock not found")
+
 // SaveStock inserts or updates a stock in the database
 func (db *DB) SaveStock(stock *models.Stock) error {
 	query := `
@@ -78,7 +82,7 @@ func (db *DB) GetStock(symbol string) (*models.Stock, error) {
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("stock not found: %s", symbol)
+		return nil, fmt.Errorf("%w: %s", ErrStockNotFound, symbol)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get stock %s: %w", symbol, err)
@@ -251,3 +255,47 @@ func (db *DB) GetStocksBySector(sector string) ([]*models.Stock, error) {
 
 	return stocks, nil
 }
+
+// ScreenByMarketCap retrieves stocks with a market cap between min and max inclusive,
+// largest first. A max of 0 or less leaves the band open-ended.
+func (db *DB) ScreenByMarketCap(min, max int64) ([]*models.Stock, error) {
+	query := `
+		SELECT id, symbol, name, exchange, sector, industry,
+		       current_price, previous_close, change_amount, change_percent,
+		       day_high, day_low, volume, average_volume,
+		       week_52_high, week_52_low, market_cap, shares_outstanding,
+		       last_updated, created_at
+		FROM stocks
+		WHERE market_cap >= $1 AND ($2::BIGINT IS NULL OR market_cap <= $2)
+		ORDER BY market_cap DESC, symbol
+	`
+
+	var upper interface{}
+	if max > 0 {
+		upper = max
+	}
+
+	rows, err := db.conn.Query(query, min, upper)
+	if err != nil {
+		return nil, fmt.Errorf("failed to screen stocks by market cap: %w", err)
+	}
+	defer rows.Close()
+
+	var stocks []*models.Stock
+	for rows.Next() {
+		var stock models.Stock
+		err := rows.Scan(
+			&stock.ID, &stock.Symbol, &stock.Name, &stock.Exchange, &stock.Sector, &stock.Industry,
+			&stock.CurrentPrice, &stock.PreviousClose, &stock.ChangeAmount, &stock.ChangePercent,
+			&stock.DayHigh, &stock.DayLow, &stock.Volume, &stock.AverageVolume,
+			&stock.Week52High, &stock.Week52Low, &stock.MarketCap, &stock.SharesOutstanding,
+			&stock.LastUpdated, &stock.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan stock: %w", err)
+		}
+		stocks = append(stocks, &stock)
+	}
+
+	return stocks, nil
+}
@@ -215,4 +215,33 @@ func TestStocksRepository(t *testing.T) {
 		_, err = testDB.GetStockByID(stock.ID)
 		require.Error(t, err)
 	})
+
+	t.Run("ScreenByMarketCap filters by market cap band", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		stocks := []*models.Stock{
+			{Symbol: "AAPL", Name: "Apple Inc.", MarketCap: 2_800_000_000_000, LastUpdated: time.Now()},
+			{Symbol: "CROX", Name: "Crocs Inc.", MarketCap: 6_500_000_000, LastUpdated: time.Now()},
+			{Symbol: "SMCO", Name: "Small Co", MarketCap: 900_000_000, LastUpdated: time.Now()},
+			{Symbol: "MIDC", Name: "Mid Co", MarketCap: 10_000_000_000, LastUpdated: time.Now()},
+		}
+		for _, s := range stocks {
+			require.NoError(t, testDB.SaveStock(s))
+		}
+
+		midCaps, err := testDB.ScreenByMarketCap(2_000_000_000, 10_000_000_000)
+		require.NoError(t, err)
+		require.Len(t, midCaps, 2)
+		assert.Equal(t, "MIDC", midCaps[0].Symbol)
+		assert.Equal(t, "CROX", midCaps[1].Symbol)
+
+		largeCaps, err := testDB.ScreenByMarketCap(200_000_000_000, 0)
+		require.NoError(t, err)
+		require.Len(t, largeCaps, 1)
+		assert.Equal(t, "AAPL", largeCaps[0].Symbol)
+
+		none, err := testDB.ScreenByMarketCap(1, 500_000_000)
+		require.NoError(t, err)
+		assert.Empty(t, none)
+	})
 }
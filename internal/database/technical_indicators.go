@@ -3,8 +3,10 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/shopspring/decimal"
 	"github.com/trogers1052/stock-alert-system/internal/models"
 )
@@ -31,34 +33,46 @@ func (db *DB) CreateTechnicalIndicator(t *models.TechnicalIndicator) error {
 	return nil
 }
 
-// CreateTechnicalIndicatorBatch inserts multiple technical indicator records efficiently
+// indicatorBatchRows caps the rows per INSERT in CreateTechnicalIndicatorBatch.
+// At six parameters a row this stays well under Postgres's 65535 parameter limit.
+const indicatorBatchRows = 500
+
+// CreateTechnicalIndicatorBatch upserts indicator records with multi-row INSERTs
+// of up to indicatorBatchRows rows, all in one transaction, so large backfills
+// take a few statements rather than one per row. When the batch repeats a
+// symbol, date, type and timeframe, the last value wins.
 func (db *DB) CreateTechnicalIndicatorBatch(indicators []*models.TechnicalIndicator) error {
+	rows := dedupeIndicators(indicators)
+	if len(rows) == 0 {
+		return nil
+	}
+
 	tx, err := db.conn.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	stmt, err := tx.Prepare(`
-		INSERT INTO technical_indicators (symbol, date, indicator_type, value, timeframe, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		ON CONFLICT (symbol, date, indicator_type, timeframe) DO UPDATE SET
-			value = EXCLUDED.value
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to prepare statement: %w", err)
-	}
-	defer stmt.Close()
-
 	now := time.Now()
-	for _, t := range indicators {
-		timeframe := t.Timeframe
-		if timeframe == "" {
-			timeframe = "daily"
+	for start := 0; start < len(rows); start += indicatorBatchRows {
+		end := min(start+indicatorBatchRows, len(rows))
+		chunk := rows[start:end]
+
+		var b strings.Builder
+		b.WriteString("INSERT INTO technical_indicators (symbol, date, indicator_type, value, timeframe, created_at) VALUES ")
+		args := make([]interface{}, 0, len(chunk)*6)
+		for i, t := range chunk {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			n := len(args)
+			fmt.Fprintf(&b, "($%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6)
+			args = append(args, t.Symbol, t.Date, t.IndicatorType, t.Value, t.Timeframe, now)
 		}
-		_, err := stmt.Exec(t.Symbol, t.Date, t.IndicatorType, t.Value, timeframe, now)
-		if err != nil {
-			return fmt.Errorf("failed to insert indicator for %s: %w", t.Symbol, err)
+		b.WriteString(" ON CONFLICT (symbol, date, indicator_type, timeframe) DO UPDATE SET value = EXCLUDED.value")
+
+		if _, err := tx.Exec(b.String(), args...); err != nil {
+			return fmt.Errorf("failed to insert indicators %d-%d: %w", start, end-1, err)
 		}
 	}
 
@@ -68,6 +82,32 @@ func (db *DB) CreateTechnicalIndicatorBatch(indicators []*models.TechnicalIndica
 	return nil
 }
 
+// dedupeIndicators copies indicators with the timeframe defaulted to daily,
+// keeping only the last of any rows sharing a conflict key. A single INSERT ...
+// ON CONFLICT can't update the same row twice.
+func dedupeIndicators(indicators []*models.TechnicalIndicator) []models.TechnicalIndicator {
+	type key struct {
+		symbol, indicatorType, timeframe string
+		date                             time.Time
+	}
+	index := make(map[key]int, len(indicators))
+	rows := make([]models.TechnicalIndicator, 0, len(indicators))
+	for _, t := range indicators {
+		row := *t
+		if row.Timeframe == "" {
+			row.Timeframe = "daily"
+		}
+		k := key{row.Symbol, row.IndicatorType, row.Timeframe, row.Date.UTC()}
+		if i, ok := index[k]; ok {
+			rows[i] = row
+			continue
+		}
+		index[k] = len(rows)
+		rows = append(rows, row)
+	}
+	return rows
+}
+
 // GetTechnicalIndicatorByID retrieves a technical indicator by ID
 func (db *DB) GetTechnicalIndicatorByID(id int) (*models.TechnicalIndicator, error) {
 	query := `
@@ -113,6 +153,30 @@ func (db *DB) GetIndicator(symbol string, date time.Time, indicatorType string,
 	return &t, nil
 }
 
+// GetIndicatorAtOrBefore retrieves a symbol's daily indicator from the latest
+// date on or before date, such as the RSI in effect when a position was opened
+func (db *DB) GetIndicatorAtOrBefore(symbol, indicatorType string, date time.Time) (*models.TechnicalIndicator, error) {
+	query := `
+		SELECT id, symbol, date, indicator_type, value, timeframe, created_at
+		FROM technical_indicators
+		WHERE symbol = $1 AND indicator_type = $2 AND timeframe = 'daily' AND date <= $3
+		ORDER BY date DESC
+		LIMIT 1
+	`
+	var t models.TechnicalIndicator
+	err := db.conn.QueryRow(query, symbol, indicatorType, date).Scan(
+		&t.ID, &t.Symbol, &t.Date, &t.IndicatorType, &t.Value, &t.Timeframe, &t.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("indicator not found: %s %s on or before %s", symbol, indicatorType, date.Format("2006-01-02"))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get indicator: %w", err)
+	}
+	return &t, nil
+}
+
 // GetIndicatorsBySymbol retrieves all indicators for a symbol on a specific date
 func (db *DB) GetIndicatorsBySymbol(symbol string, date time.Time) ([]*models.TechnicalIndicator, error) {
 	query := `
@@ -172,6 +236,38 @@ func (db *DB) GetIndicatorHistory(symbol string, indicatorType string, limit int
 	return indicators, nil
 }
 
+// GetIndicatorRange retrieves an indicator's values for a symbol within a date window, oldest first
+func (db *DB) GetIndicatorRange(symbol, indicatorType string, start, end time.Time) ([]*models.TechnicalIndicator, error) {
+	query := `
+		SELECT id, symbol, date, indicator_type, value, timeframe, created_at
+		FROM technical_indicators
+		WHERE symbol = $1 AND indicator_type = $2 AND date >= $3 AND date <= $4
+		ORDER BY date ASC
+	`
+	rows, err := db.conn.Query(query, symbol, indicatorType, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get indicator range: %w", err)
+	}
+	defer rows.Close()
+
+	var indicators []*models.TechnicalIndicator
+	for rows.Next() {
+		var t models.TechnicalIndicator
+		err := rows.Scan(
+			&t.ID, &t.Symbol, &t.Date, &t.IndicatorType, &t.Value, &t.Timeframe, &t.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan indicator: %w", err)
+		}
+		indicators = append(indicators, &t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate indicator range: %w", err)
+	}
+
+	return indicators, nil
+}
+
 // GetLatestIndicators retrieves the most recent indicators for a symbol
 func (db *DB) GetLatestIndicators(symbol string) ([]*models.TechnicalIndicator, error) {
 	query := `
@@ -202,6 +298,39 @@ func (db *DB) GetLatestIndicators(symbol string) ([]*models.TechnicalIndicator,
 	return indicators, nil
 }
 
+// GetSymbolsWithStaleIndicators returns the symbols whose latest indicator of
+// indicatorType is dated more than olderThan ago, sorted by symbol. Symbols that
+// have never had the indicator computed aren't included.
+func (db *DB) GetSymbolsWithStaleIndicators(indicatorType string, olderThan time.Duration) ([]string, error) {
+	query := `
+		SELECT symbol
+		FROM technical_indicators
+		WHERE indicator_type = $1
+		GROUP BY symbol
+		HAVING MAX(date) < $2
+		ORDER BY symbol
+	`
+	rows, err := db.conn.Query(query, indicatorType, time.Now().Add(-olderThan))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get symbols with stale indicators: %w", err)
+	}
+	defer rows.Close()
+
+	var symbols []string
+	for rows.Next() {
+		var symbol string
+		if err := rows.Scan(&symbol); err != nil {
+			return nil, fmt.Errorf("failed to scan symbol: %w", err)
+		}
+		symbols = append(symbols, symbol)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate stale indicator symbols: %w", err)
+	}
+
+	return symbols, nil
+}
+
 // GetLatestRSI is a convenience method to get the most recent RSI value
 func (db *DB) GetLatestRSI(symbol string) (decimal.Decimal, error) {
 	query := `
@@ -223,6 +352,65 @@ func (db *DB) GetLatestRSI(symbol string) (decimal.Decimal, error) {
 	return value, nil
 }
 
+// GetLatestRSIForSymbols returns the most recent RSI for each of symbols in one
+// query. Symbols without an RSI reading are absent from the map.
+func (db *DB) GetLatestRSIForSymbols(symbols []string) (map[string]decimal.Decimal, error) {
+	latest := make(map[string]decimal.Decimal, len(symbols))
+	if len(symbols) == 0 {
+		return latest, nil
+	}
+
+	query := `
+		SELECT DISTINCT ON (symbol) symbol, value
+		FROM technical_indicators
+		WHERE indicator_type = 'RSI_14' AND symbol = ANY($1)
+		ORDER BY symbol, date DESC
+	`
+	rows, err := db.conn.Query(query, pq.Array(symbols))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest RSI: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var symbol string
+		var value decimal.Decimal
+		if err := rows.Scan(&symbol, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan RSI: %w", err)
+		}
+		latest[symbol] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate latest RSI: %w", err)
+	}
+
+	return latest, nil
+}
+
+// GetLatestRSIReading returns the most recent RSI along with the number of daily
+// candles on or before its date, so callers can discard readings built on too little data
+func (db *DB) GetLatestRSIReading(symbol string) (*models.RSIReading, error) {
+	query := `
+		SELECT ti.symbol, ti.date, ti.value,
+		       (SELECT COUNT(*) FROM price_data_daily pd
+		        WHERE pd.symbol = ti.symbol AND pd.date <= ti.date)
+		FROM technical_indicators ti
+		WHERE ti.symbol = $1 AND ti.indicator_type = 'RSI_14'
+		ORDER BY ti.date DESC
+		LIMIT 1
+	`
+	var r models.RSIReading
+	err := db.conn.QueryRow(query, symbol).Scan(&r.Symbol, &r.Date, &r.Value, &r.DataPoints)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no RSI data found for %s", symbol)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get RSI reading: %w", err)
+	}
+	return &r, nil
+}
+
 // DeleteTechnicalIndicator removes an indicator by ID
 func (db *DB) DeleteTechnicalIndicator(id int) error {
 	query := `DELETE FROM technical_indicators WHERE id = $1`
@@ -257,3 +445,17 @@ func (db *DB) DeleteIndicatorsOlderThan(date time.Time) (int64, error) {
 	}
 	return result.RowsAffected()
 }
+
+// DeleteIndicatorsRange removes one indicator's values for a symbol between start
+// and end inclusive, so a corrupted window can be recomputed
+func (db *DB) DeleteIndicatorsRange(symbol, indicatorType string, start, end time.Time) (int64, error) {
+	query := `
+		DELETE FROM technical_indicators
+		WHERE symbol = $1 AND indicator_type = $2 AND date >= $3 AND date <= $4
+	`
+	result, err := db.conn.Exec(query, symbol, indicatorType, start, end)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete indicator range for %s: %w", symbol, err)
+	}
+	return result.RowsAffected()
+}
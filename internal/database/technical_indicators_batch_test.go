@@ -0,0 +1,79 @@
+package database
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+func TestCreateTechnicalIndicatorBatch_ChunksRows(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	db := &DB{conn: sqlDB}
+
+	// 10 symbols x 100 days
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var indicators []*models.TechnicalIndicator
+	for s := 0; s < 10; s++ {
+		for d := 0; d < 100; d++ {
+			indicators = append(indicators, &models.TechnicalIndicator{
+				Symbol:        string(rune('A' + s)),
+				Date:          start.AddDate(0, 0, d),
+				IndicatorType: models.IndicatorRSI14,
+				Value:         decimal.NewFromInt(int64(s*100 + d)),
+			})
+		}
+	}
+	require.Len(t, indicators, 1000)
+
+	mock.ExpectBegin()
+	for chunk := 0; chunk < 2; chunk++ {
+		var args []driver.Value
+		for _, ind := range indicators[chunk*indicatorBatchRows : (chunk+1)*indicatorBatchRows] {
+			args = append(args, ind.Symbol, ind.Date, ind.IndicatorType, ind.Value.String(), "daily", sqlmock.AnyArg())
+		}
+		mock.ExpectExec("INSERT INTO technical_indicators .+ ON CONFLICT").
+			WithArgs(args...).
+			WillReturnResult(sqlmock.NewResult(0, int64(indicatorBatchRows)))
+	}
+	mock.ExpectCommit()
+
+	require.NoError(t, db.CreateTechnicalIndicatorBatch(indicators))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateTechnicalIndicatorBatch_LastDuplicateWins(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	db := &DB{conn: sqlDB}
+
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	indicators := []*models.TechnicalIndicator{
+		{Symbol: "AAPL", Date: date, IndicatorType: models.IndicatorRSI14, Value: decimal.NewFromInt(40)},
+		{Symbol: "AAPL", Date: date, IndicatorType: models.IndicatorMACD, Value: decimal.NewFromInt(2)},
+		{Symbol: "AAPL", Date: date, IndicatorType: models.IndicatorRSI14, Value: decimal.NewFromInt(45), Timeframe: "daily"},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO technical_indicators").
+		WithArgs(
+			"AAPL", date, models.IndicatorRSI14, "45", "daily", sqlmock.AnyArg(),
+			"AAPL", date, models.IndicatorMACD, "2", "daily", sqlmock.AnyArg(),
+		).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	require.NoError(t, db.CreateTechnicalIndicatorBatch(indicators))
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Empty(t, indicators[0].Timeframe, "the caller's records are left unchanged")
+}
@@ -307,4 +307,241 @@ func TestTechnicalIndicatorsRepository(t *testing.T) {
 		require.NoError(t, err)
 		assert.Len(t, history, 5)
 	})
+
+	t.Run("GetIndicatorRange returns windowed values ascending", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		// A month of daily RSI readings
+		start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		for i := 0; i < 31; i++ {
+			err := testDB.CreateTechnicalIndicator(&models.TechnicalIndicator{
+				Symbol:        "AAPL",
+				Date:          start.AddDate(0, 0, i),
+				IndicatorType: models.IndicatorRSI14,
+				Value:         decimal.NewFromInt(int64(30 + i)),
+			})
+			require.NoError(t, err)
+		}
+		// Different indicator in the same window is excluded
+		err := testDB.CreateTechnicalIndicator(&models.TechnicalIndicator{
+			Symbol:        "AAPL",
+			Date:          time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC),
+			IndicatorType: models.IndicatorMACD,
+			Value:         decimal.NewFromFloat(1.5),
+		})
+		require.NoError(t, err)
+
+		windowStart := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+		windowEnd := time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC)
+		values, err := testDB.GetIndicatorRange("AAPL", models.IndicatorRSI14, windowStart, windowEnd)
+		require.NoError(t, err)
+		require.Len(t, values, 7)
+
+		assert.True(t, windowStart.Equal(values[0].Date.UTC()))
+		assert.True(t, windowEnd.Equal(values[6].Date.UTC()))
+		assert.True(t, decimal.NewFromInt(39).Equal(values[0].Value))
+		assert.True(t, decimal.NewFromInt(45).Equal(values[6].Value))
+		for i := 1; i < len(values); i++ {
+			assert.True(t, values[i].Date.After(values[i-1].Date))
+		}
+	})
+
+	t.Run("GetLatestRSIReading counts candles behind the reading", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		for i := 0; i < 20; i++ {
+			err := testDB.CreatePriceData(&models.PriceDataDaily{
+				Symbol: "AAPL",
+				Date:   start.AddDate(0, 0, i),
+				Open:   decimal.NewFromInt(100),
+				High:   decimal.NewFromInt(101),
+				Low:    decimal.NewFromInt(99),
+				Close:  decimal.NewFromInt(100),
+				Volume: 1000,
+			})
+			require.NoError(t, err)
+		}
+		// Latest RSI was computed on day 10, so only 10 candles back it
+		for _, day := range []int{5, 9} {
+			err := testDB.CreateTechnicalIndicator(&models.TechnicalIndicator{
+				Symbol:        "AAPL",
+				Date:          start.AddDate(0, 0, day),
+				IndicatorType: models.IndicatorRSI14,
+				Value:         decimal.NewFromInt(int64(20 + day)),
+			})
+			require.NoError(t, err)
+		}
+
+		reading, err := testDB.GetLatestRSIReading("AAPL")
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(29).Equal(reading.Value))
+		assert.Equal(t, 10, reading.DataPoints)
+		assert.False(t, reading.IsReliable(15))
+
+		_, err = testDB.GetLatestRSIReading("NONE")
+		assert.Error(t, err)
+	})
+
+	t.Run("DeleteIndicatorsRange removes only the window", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		for i := 0; i < 31; i++ {
+			for _, symbol := range []string{"AAPL", "MSFT"} {
+				err := testDB.CreateTechnicalIndicator(&models.TechnicalIndicator{
+					Symbol:        symbol,
+					Date:          start.AddDate(0, 0, i),
+					IndicatorType: models.IndicatorRSI14,
+					Value:         decimal.NewFromInt(int64(30 + i)),
+				})
+				require.NoError(t, err)
+			}
+		}
+		// Another indicator inside the window is kept
+		err := testDB.CreateTechnicalIndicator(&models.TechnicalIndicator{
+			Symbol:        "AAPL",
+			Date:          time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC),
+			IndicatorType: models.IndicatorMACD,
+			Value:         decimal.NewFromFloat(1.5),
+		})
+		require.NoError(t, err)
+
+		weekStart := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+		weekEnd := time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC)
+		deleted, err := testDB.DeleteIndicatorsRange("AAPL", models.IndicatorRSI14, weekStart, weekEnd)
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), deleted)
+
+		monthEnd := start.AddDate(0, 0, 30)
+		remaining, err := testDB.GetIndicatorRange("AAPL", models.IndicatorRSI14, start, monthEnd)
+		require.NoError(t, err)
+		assert.Len(t, remaining, 24)
+		for _, r := range remaining {
+			d := r.Date.UTC()
+			assert.True(t, d.Before(weekStart) || d.After(weekEnd), "%s should have been deleted", d)
+		}
+
+		other, err := testDB.GetIndicatorRange("MSFT", models.IndicatorRSI14, start, monthEnd)
+		require.NoError(t, err)
+		assert.Len(t, other, 31)
+
+		macd, err := testDB.GetIndicatorRange("AAPL", models.IndicatorMACD, start, monthEnd)
+		require.NoError(t, err)
+		assert.Len(t, macd, 1)
+	})
+
+	t.Run("GetSymbolsWithStaleIndicators returns symbols past the threshold", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		seed := func(symbol, indicatorType string, daysAgo int) {
+			err := testDB.CreateTechnicalIndicator(&models.TechnicalIndicator{
+				Symbol:        symbol,
+				Date:          today.AddDate(0, 0, -daysAgo),
+				IndicatorType: indicatorType,
+				Value:         decimal.NewFromFloat(50.0),
+			})
+			require.NoError(t, err)
+		}
+
+		seed("FRESH", models.IndicatorRSI14, 1)
+		seed("STALE", models.IndicatorRSI14, 10)
+		seed("OLDER", models.IndicatorRSI14, 30)
+		// An old reading doesn't make a symbol stale once a newer one exists
+		seed("REFRESHED", models.IndicatorRSI14, 20)
+		seed("REFRESHED", models.IndicatorRSI14, 0)
+		// Only the requested indicator type counts
+		seed("OTHER", "SMA_20", 30)
+
+		symbols, err := testDB.GetSymbolsWithStaleIndicators(models.IndicatorRSI14, 3*24*time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"OLDER", "STALE"}, symbols)
+
+		symbols, err = testDB.GetSymbolsWithStaleIndicators(models.IndicatorRSI14, 60*24*time.Hour)
+		require.NoError(t, err)
+		assert.Empty(t, symbols)
+	})
+
+	t.Run("CreateTechnicalIndicatorBatch upserts large backfills", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		var indicators []*models.TechnicalIndicator
+		for _, symbol := range []string{"AAPL", "MSFT", "NVDA", "GOOGL"} {
+			for d := 0; d < 250; d++ {
+				indicators = append(indicators, &models.TechnicalIndicator{
+					Symbol:        symbol,
+					Date:          start.AddDate(0, 0, d),
+					IndicatorType: models.IndicatorSMA20,
+					Value:         decimal.NewFromInt(int64(d)),
+				})
+			}
+		}
+		require.NoError(t, testDB.CreateTechnicalIndicatorBatch(indicators))
+
+		// Rerunning updates in place
+		for _, ind := range indicators {
+			ind.Value = ind.Value.Add(decimal.NewFromInt(1000))
+		}
+		require.NoError(t, testDB.CreateTechnicalIndicatorBatch(indicators))
+
+		var count int
+		require.NoError(t, testDB.GetRawConn().QueryRow(`SELECT COUNT(*) FROM technical_indicators`).Scan(&count))
+		assert.Equal(t, 1000, count)
+
+		latest, err := testDB.GetIndicator("NVDA", start.AddDate(0, 0, 249), models.IndicatorSMA20, "daily")
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(1249).Equal(latest.Value))
+	})
+
+	t.Run("GetIndicatorAtOrBefore returns the latest value on or before a date", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+		indicators := []*models.TechnicalIndicator{
+			{Symbol: "AAPL", Date: day(10), IndicatorType: models.IndicatorRSI14, Value: decimal.NewFromFloat(28.0)},
+			{Symbol: "AAPL", Date: day(12), IndicatorType: models.IndicatorRSI14, Value: decimal.NewFromFloat(35.0)},
+			{Symbol: "AAPL", Date: day(20), IndicatorType: models.IndicatorRSI14, Value: decimal.NewFromFloat(61.0)},
+		}
+		require.NoError(t, testDB.CreateTechnicalIndicatorBatch(indicators))
+
+		onDate, err := testDB.GetIndicatorAtOrBefore("AAPL", models.IndicatorRSI14, day(12))
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromFloat(35.0).Equal(onDate.Value))
+
+		between, err := testDB.GetIndicatorAtOrBefore("AAPL", models.IndicatorRSI14, day(15).Add(14*time.Hour))
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromFloat(35.0).Equal(between.Value))
+
+		_, err = testDB.GetIndicatorAtOrBefore("AAPL", models.IndicatorRSI14, day(9))
+		assert.Error(t, err)
+	})
+
+	t.Run("GetLatestRSIForSymbols returns each symbol's latest RSI", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		older := time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)
+		latest := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+		indicators := []*models.TechnicalIndicator{
+			{Symbol: "AAPL", Date: older, IndicatorType: models.IndicatorRSI14, Value: decimal.NewFromFloat(40.0)},
+			{Symbol: "AAPL", Date: latest, IndicatorType: models.IndicatorRSI14, Value: decimal.NewFromFloat(42.5)},
+			{Symbol: "MSFT", Date: older, IndicatorType: models.IndicatorRSI14, Value: decimal.NewFromFloat(71.0)},
+			// Other indicators and unrequested symbols are ignored
+			{Symbol: "MSFT", Date: latest, IndicatorType: models.IndicatorMACD, Value: decimal.NewFromFloat(1.5)},
+			{Symbol: "NVDA", Date: latest, IndicatorType: models.IndicatorRSI14, Value: decimal.NewFromFloat(25.0)},
+		}
+		require.NoError(t, testDB.CreateTechnicalIndicatorBatch(indicators))
+
+		rsi, err := testDB.GetLatestRSIForSymbols([]string{"AAPL", "MSFT", "TSLA"})
+		require.NoError(t, err)
+		require.Len(t, rsi, 2)
+		assert.True(t, decimal.NewFromFloat(42.5).Equal(rsi["AAPL"]))
+		assert.True(t, decimal.NewFromFloat(71.0).Equal(rsi["MSFT"]))
+		assert.NotContains(t, rsi, "TSLA")
+
+		empty, err := testDB.GetLatestRSIForSymbols(nil)
+		require.NoError(t, err)
+		assert.Empty(t, empty)
+	})
 }
@@ -122,7 +122,10 @@ func (tdb *TestDB) TruncateAll(t *testing.T) {
 
 	tables := []string{
 		"alert_history",
+		"position_events",
+		"position_pnl_history",
 		"alert_rules",
+		"raw_trades",
 		"trades_history",
 		"technical_indicators",
 		"price_data_daily",
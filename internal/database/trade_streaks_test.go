@@ -0,0 +1,39 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTradeStats_applyStreaks(t *testing.T) {
+	tests := []struct {
+		name                             string
+		pnls                             []float64
+		curWin, curLoss, maxWin, maxLoss int
+	}{
+		{"no trades", nil, 0, 0, 0, 0},
+		{"alternating", []float64{10, -5, 10, -5}, 0, 1, 1, 1},
+		{"streaky", []float64{1, 2, 3, -1, -2, 4, 5, -3}, 0, 1, 3, 2},
+		{"ends on win streak", []float64{-1, -2, -3, 1, 2}, 2, 0, 2, 3},
+		{"breakeven ends streak", []float64{1, 2, 0, 3}, 1, 0, 2, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pnls := make([]decimal.Decimal, len(tt.pnls))
+			for i, p := range tt.pnls {
+				pnls[i] = decimal.NewFromFloat(p)
+			}
+
+			var stats TradeStats
+			stats.applyStreaks(pnls)
+
+			assert.Equal(t, tt.curWin, stats.CurrentWinStreak, "current win")
+			assert.Equal(t, tt.curLoss, stats.CurrentLossStreak, "current loss")
+			assert.Equal(t, tt.maxWin, stats.MaxWinStreak, "max win")
+			assert.Equal(t, tt.maxLoss, stats.MaxLossStreak, "max loss")
+		})
+	}
+}
@@ -2,15 +2,24 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/shopspring/decimal"
 	"github.com/trogers1052/stock-alert-system/internal/models"
 )
 
+// ErrTradeNotFound is returned when no trades_history row exists for an ID
+var ErrTradeNotFound = errors.New("trade not found")
+
 // CreateTradeHistory inserts a new trade record
 func (db *DB) CreateTradeHistory(t *models.TradeHistory) error {
+	return createTradeHistory(db.conn, t)
+}
+
+func createTradeHistory(q execer, t *models.TradeHistory) error {
 	query := `
 		INSERT INTO trades_history (
 			symbol, trade_type, quantity, price, total_cost, fee,
@@ -30,14 +39,16 @@ func (db *DB) CreateTradeHistory(t *models.TradeHistory) error {
 	if executedAt.IsZero() {
 		executedAt = now
 	}
+	// trade_grade is constrained to A-F, so an ungraded trade is stored as NULL
+	tradeGrade := sql.NullString{String: t.TradeGrade, Valid: t.TradeGrade != ""}
 
-	err := db.conn.QueryRow(query,
+	err := q.QueryRow(query,
 		t.Symbol, t.TradeType, t.Quantity, t.Price, t.TotalCost, t.Fee,
 		t.EntryDate, t.ExitDate, t.HoldingPeriodHours,
 		t.EntryRSI, t.ExitRSI, t.RealizedPnl, t.RealizedPnlPct, t.MaxDrawdownPct,
 		t.EntryReason, t.ExitReason, t.EmotionalState, t.ConvictionLevel,
 		t.MarketConditions, t.WhatWentRight, t.WhatWentWrong,
-		t.TradeGrade, t.StrategyTag, t.Notes, executedAt, now,
+		tradeGrade, t.StrategyTag, t.Notes, executedAt, now,
 	).Scan(&t.ID)
 
 	if err != nil {
@@ -82,7 +93,7 @@ func (db *DB) scanSingleTrade(row *sql.Row) (*models.TradeHistory, error) {
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("trade not found")
+		return nil, ErrTradeNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get trade: %w", err)
@@ -185,8 +196,14 @@ func (db *DB) GetAllTradeHistory(limit int) ([]*models.TradeHistory, error) {
 	return db.scanTrades(db.conn.Query(query, limit))
 }
 
-// GetTradeHistoryByDateRange retrieves trades within a date range
-func (db *DB) GetTradeHistoryByDateRange(startDate, endDate time.Time) ([]*models.TradeHistory, error) {
+// GetTradeHistoryByDateRange retrieves trades within a date range, newest first.
+// A limit of 0 or less returns every trade in the range.
+func (db *DB) GetTradeHistoryByDateRange(startDate, endDate time.Time, limit int) ([]*models.TradeHistory, error) {
+	var limitArg interface{}
+	if limit > 0 {
+		limitArg = limit
+	}
+
 	query := `
 		SELECT id, symbol, trade_type, quantity, price, total_cost, fee,
 		       entry_date, exit_date, holding_period_hours,
@@ -197,8 +214,9 @@ func (db *DB) GetTradeHistoryByDateRange(startDate, endDate time.Time) ([]*model
 		FROM trades_history
 		WHERE executed_at >= $1 AND executed_at <= $2
 		ORDER BY executed_at DESC
+		LIMIT $3
 	`
-	return db.scanTrades(db.conn.Query(query, startDate, endDate))
+	return db.scanTrades(db.conn.Query(query, startDate, endDate, limitArg))
 }
 
 // GetTradeHistoryByStrategy retrieves trades with a specific strategy tag
@@ -218,6 +236,75 @@ func (db *DB) GetTradeHistoryByStrategy(strategyTag string, limit int) ([]*model
 	return db.scanTrades(db.conn.Query(query, strategyTag, limit))
 }
 
+// SearchTradeNotes finds trades whose journal text (notes, what went right/wrong,
+// entry and exit reasons) contains query, ignoring case. Newest first; a limit of
+// 0 or less returns every match.
+func (db *DB) SearchTradeNotes(query string, limit int) ([]*models.TradeHistory, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("search query is required")
+	}
+	var limitArg interface{}
+	if limit > 0 {
+		limitArg = limit
+	}
+
+	// Escape LIKE wildcards so the search text matches literally
+	pattern := "%" + likeEscaper.Replace(query) + "%"
+
+	sqlQuery := `
+		SELECT id, symbol, trade_type, quantity, price, total_cost, fee,
+		       entry_date, exit_date, holding_period_hours,
+		       entry_rsi, exit_rsi, realized_pnl, realized_pnl_pct, max_drawdown_pct,
+		       entry_reason, exit_reason, emotional_state, conviction_level,
+		       market_conditions, what_went_right, what_went_wrong,
+		       trade_grade, strategy_tag, notes, executed_at, created_at
+		FROM trades_history
+		WHERE notes ILIKE $1
+		   OR what_went_right ILIKE $1
+		   OR what_went_wrong ILIKE $1
+		   OR entry_reason ILIKE $1
+		   OR exit_reason ILIKE $1
+		ORDER BY executed_at DESC, id DESC
+		LIMIT $2
+	`
+	return db.scanTrades(db.conn.Query(sqlQuery, pattern, limitArg))
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// GetClosedTradesForSymbolBetween retrieves closed (SELL) trades for a symbol whose
+// holding period overlaps the given window, ordered by exit date. Used to overlay
+// entry/exit markers on a price chart, so each trade's EntryPrice is filled in
+// from its exit price, realized P&L and fees.
+func (db *DB) GetClosedTradesForSymbolBetween(symbol string, start, end time.Time) ([]*models.TradeHistory, error) {
+	query := `
+		SELECT id, symbol, trade_type, quantity, price, total_cost, fee,
+		       entry_date, exit_date, holding_period_hours,
+		       entry_rsi, exit_rsi, realized_pnl, realized_pnl_pct, max_drawdown_pct,
+		       entry_reason, exit_reason, emotional_state, conviction_level,
+		       market_conditions, what_went_right, what_went_wrong,
+		       trade_grade, strategy_tag, notes, executed_at, created_at
+		FROM trades_history
+		WHERE symbol = $1
+		  AND trade_type = 'SELL'
+		  AND COALESCE(exit_date, executed_at) >= $2
+		  AND COALESCE(entry_date, exit_date, executed_at) <= $3
+		ORDER BY COALESCE(exit_date, executed_at) ASC
+	`
+	trades, err := db.scanTrades(db.conn.Query(query, symbol, start, end))
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range trades {
+		if entry, ok := t.ImpliedEntryPrice(); ok {
+			entry = entry.Round(4)
+			t.EntryPrice = &entry
+		}
+	}
+	return trades, nil
+}
+
 func (db *DB) scanTrades(rows *sql.Rows, err error) ([]*models.TradeHistory, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to query trades: %w", err)
@@ -325,13 +412,14 @@ func (db *DB) UpdateTradeHistory(t *models.TradeHistory) error {
 			trade_grade = $23, strategy_tag = $24, notes = $25, executed_at = $26
 		WHERE id = $1
 	`
+	tradeGrade := sql.NullString{String: t.TradeGrade, Valid: t.TradeGrade != ""}
 	result, err := db.conn.Exec(query,
 		t.ID, t.Symbol, t.TradeType, t.Quantity, t.Price, t.TotalCost, t.Fee,
 		t.EntryDate, t.ExitDate, t.HoldingPeriodHours,
 		t.EntryRSI, t.ExitRSI, t.RealizedPnl, t.RealizedPnlPct, t.MaxDrawdownPct,
 		t.EntryReason, t.ExitReason, t.EmotionalState, t.ConvictionLevel,
 		t.MarketConditions, t.WhatWentRight, t.WhatWentWrong,
-		t.TradeGrade, t.StrategyTag, t.Notes, t.ExecutedAt,
+		tradeGrade, t.StrategyTag, t.Notes, t.ExecutedAt,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to update trade: %w", err)
@@ -369,9 +457,30 @@ type TradeStats struct {
 	AvgPnlPct     decimal.Decimal `json:"avg_pnl_pct"`
 	AvgWin        decimal.Decimal `json:"avg_win"`
 	AvgLoss       decimal.Decimal `json:"avg_loss"`
+
+	// Consecutive wins/losses by exit date; a breakeven trade ends either streak
+	CurrentWinStreak  int `json:"current_win_streak"`
+	CurrentLossStreak int `json:"current_loss_streak"`
+	MaxWinStreak      int `json:"max_win_streak"`
+	MaxLossStreak     int `json:"max_loss_streak"`
 }
 
 func (db *DB) GetTradeStats() (*TradeStats, error) {
+	return db.tradeStats("")
+}
+
+// GetTradeStatsBySymbol returns aggregated trade statistics for one symbol
+func (db *DB) GetTradeStatsBySymbol(symbol string) (*TradeStats, error) {
+	return db.tradeStats("AND symbol = $1", symbol)
+}
+
+// GetTradeStatsByStrategy returns aggregated trade statistics for one strategy tag
+func (db *DB) GetTradeStatsByStrategy(strategyTag string) (*TradeStats, error) {
+	return db.tradeStats("AND strategy_tag = $1", strategyTag)
+}
+
+// tradeStats aggregates closed trades, narrowed by an optional AND clause over args
+func (db *DB) tradeStats(filter string, args ...interface{}) (*TradeStats, error) {
 	query := `
 		SELECT
 			COUNT(*) as total_trades,
@@ -382,10 +491,9 @@ func (db *DB) GetTradeStats() (*TradeStats, error) {
 			COALESCE(AVG(realized_pnl) FILTER (WHERE realized_pnl > 0), 0) as avg_win,
 			COALESCE(AVG(realized_pnl) FILTER (WHERE realized_pnl < 0), 0) as avg_loss
 		FROM trades_history
-		WHERE trade_type = 'SELL' AND realized_pnl IS NOT NULL
-	`
+		WHERE trade_type = 'SELL' AND realized_pnl IS NOT NULL ` + filter
 	var stats TradeStats
-	err := db.conn.QueryRow(query).Scan(
+	err := db.conn.QueryRow(query, args...).Scan(
 		&stats.TotalTrades, &stats.WinningTrades, &stats.LosingTrades,
 		&stats.TotalPnl, &stats.AvgPnlPct, &stats.AvgWin, &stats.AvgLoss,
 	)
@@ -399,5 +507,176 @@ func (db *DB) GetTradeStats() (*TradeStats, error) {
 			Mul(decimal.NewFromInt(100))
 	}
 
+	pnls, err := db.closedTradePnlsInExitOrder(filter, args...)
+	if err != nil {
+		return nil, err
+	}
+	stats.applyStreaks(pnls)
+
 	return &stats, nil
 }
+
+// closedTradePnlsInExitOrder returns realized P&L for closed trades, oldest exit first
+func (db *DB) closedTradePnlsInExitOrder(filter string, args ...interface{}) ([]decimal.Decimal, error) {
+	query := `
+		SELECT realized_pnl
+		FROM trades_history
+		WHERE trade_type = 'SELL' AND realized_pnl IS NOT NULL ` + filter + `
+		ORDER BY COALESCE(exit_date, executed_at) ASC, id ASC
+	`
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get closed trade pnl: %w", err)
+	}
+	defer rows.Close()
+
+	var pnls []decimal.Decimal
+	for rows.Next() {
+		var pnl decimal.Decimal
+		if err := rows.Scan(&pnl); err != nil {
+			return nil, fmt.Errorf("failed to scan closed trade pnl: %w", err)
+		}
+		pnls = append(pnls, pnl)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate closed trade pnl: %w", err)
+	}
+	return pnls, nil
+}
+
+// applyStreaks sets the streak counts from P&L values in exit order
+func (s *TradeStats) applyStreaks(pnls []decimal.Decimal) {
+	s.CurrentWinStreak, s.CurrentLossStreak = 0, 0
+	s.MaxWinStreak, s.MaxLossStreak = 0, 0
+
+	for _, pnl := range pnls {
+		switch {
+		case pnl.IsPositive():
+			s.CurrentWinStreak++
+			s.CurrentLossStreak = 0
+		case pnl.IsNegative():
+			s.CurrentLossStreak++
+			s.CurrentWinStreak = 0
+		default:
+			s.CurrentWinStreak, s.CurrentLossStreak = 0, 0
+		}
+		if s.CurrentWinStreak > s.MaxWinStreak {
+			s.MaxWinStreak = s.CurrentWinStreak
+		}
+		if s.CurrentLossStreak > s.MaxLossStreak {
+			s.MaxLossStreak = s.CurrentLossStreak
+		}
+	}
+}
+
+// GradeStats aggregates realized P&L for closed trades sharing a self-assigned grade
+type GradeStats struct {
+	Grade     string          `json:"grade"`
+	Count     int             `json:"count"`
+	TotalPnl  decimal.Decimal `json:"total_pnl"`
+	AvgPnl    decimal.Decimal `json:"avg_pnl"`
+	AvgPnlPct decimal.Decimal `json:"avg_pnl_pct"`
+}
+
+// GetRealizedPnlByGrade groups closed trades by trade_grade (A-F). Ungraded trades are excluded.
+func (db *DB) GetRealizedPnlByGrade() ([]*GradeStats, error) {
+	query := `
+		SELECT
+			trade_grade,
+			COUNT(*) as count,
+			COALESCE(SUM(realized_pnl), 0) as total_pnl,
+			COALESCE(AVG(realized_pnl), 0) as avg_pnl,
+			COALESCE(AVG(realized_pnl_pct), 0) as avg_pnl_pct
+		FROM trades_history
+		WHERE trade_type = 'SELL' AND realized_pnl IS NOT NULL AND trade_grade IS NOT NULL
+		GROUP BY trade_grade
+		ORDER BY trade_grade ASC
+	`
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get realized pnl by grade: %w", err)
+	}
+	defer rows.Close()
+
+	var grades []*GradeStats
+	for rows.Next() {
+		var g GradeStats
+		if err := rows.Scan(&g.Grade, &g.Count, &g.TotalPnl, &g.AvgPnl, &g.AvgPnlPct); err != nil {
+			return nil, fmt.Errorf("failed to scan grade stats: %w", err)
+		}
+		g.Grade = strings.TrimSpace(g.Grade)
+		grades = append(grades, &g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate grade stats: %w", err)
+	}
+
+	return grades, nil
+}
+
+// GetDailyPnlCalendar returns realized P&L summed per exit day (YYYY-MM-DD) for the
+// given year, for a calendar heatmap. Days without closed trades are absent.
+func (db *DB) GetDailyPnlCalendar(year int) (map[string]decimal.Decimal, error) {
+	query := `
+		SELECT TO_CHAR(DATE(COALESCE(exit_date, executed_at)), 'YYYY-MM-DD') AS day,
+		       SUM(realized_pnl)
+		FROM trades_history
+		WHERE trade_type = 'SELL'
+		  AND realized_pnl IS NOT NULL
+		  AND COALESCE(exit_date, executed_at) >= $1
+		  AND COALESCE(exit_date, executed_at) < $2
+		GROUP BY day
+	`
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(1, 0, 0)
+
+	rows, err := db.conn.Query(query, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daily pnl calendar: %w", err)
+	}
+	defer rows.Close()
+
+	calendar := make(map[string]decimal.Decimal)
+	for rows.Next() {
+		var day string
+		var pnl decimal.Decimal
+		if err := rows.Scan(&day, &pnl); err != nil {
+			return nil, fmt.Errorf("failed to scan daily pnl: %w", err)
+		}
+		calendar[day] = pnl
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate daily pnl: %w", err)
+	}
+	return calendar, nil
+}
+
+// GetDailyRealizedPnl returns realized P&L summed per exit day across all history,
+// oldest first. Days without closed trades are absent.
+func (db *DB) GetDailyRealizedPnl() ([]*models.DailyPnl, error) {
+	query := `
+		SELECT DATE(COALESCE(exit_date, executed_at)) AS day, SUM(realized_pnl)
+		FROM trades_history
+		WHERE trade_type = 'SELL' AND realized_pnl IS NOT NULL
+		GROUP BY day
+		ORDER BY day ASC
+	`
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daily realized pnl: %w", err)
+	}
+	defer rows.Close()
+
+	var days []*models.DailyPnl
+	for rows.Next() {
+		var d models.DailyPnl
+		if err := rows.Scan(&d.Date, &d.Pnl); err != nil {
+			return nil, fmt.Errorf("failed to scan daily pnl: %w", err)
+		}
+		days = append(days, &d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate daily pnl: %w", err)
+	}
+	return days, nil
+}
@@ -159,7 +159,7 @@ func TestTradesRepository(t *testing.T) {
 		startDate := now.Add(-5 * 24 * time.Hour)
 		endDate := now.Add(24 * time.Hour)
 
-		retrieved, err := testDB.GetTradeHistoryByDateRange(startDate, endDate)
+		retrieved, err := testDB.GetTradeHistoryByDateRange(startDate, endDate, 0)
 		require.NoError(t, err)
 		assert.Len(t, retrieved, 6) // Today + 5 days back
 	})
@@ -320,4 +320,334 @@ func TestTradesRepository(t *testing.T) {
 		assert.Equal(t, 5, *retrieved.EmotionalState)
 		assert.Equal(t, 8, *retrieved.ConvictionLevel)
 	})
+
+	t.Run("GetClosedTradesForSymbolBetween returns closed trades overlapping window", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		base := time.Date(2026, 3, 1, 15, 0, 0, 0, time.UTC)
+		day := 24 * time.Hour
+		at := func(d int) *time.Time {
+			ts := base.Add(time.Duration(d) * day)
+			return &ts
+		}
+
+		trades := []*models.TradeHistory{
+			// Entirely inside the window
+			{Symbol: "CHART", TradeType: models.TradeTypeSell, Quantity: decimal.NewFromFloat(10), Price: decimal.NewFromFloat(110.00), TotalCost: decimal.NewFromFloat(1100.00), Fee: decimal.NewFromFloat(10.00), EntryDate: at(2), ExitDate: at(5), ExecutedAt: *at(5), RealizedPnl: decimal.NewFromFloat(100.00), TradeGrade: models.TradeGradeA},
+			// Entered before the window, exited inside it
+			{Symbol: "CHART", TradeType: models.TradeTypeSell, Quantity: decimal.NewFromFloat(5), Price: decimal.NewFromFloat(95.00), TotalCost: decimal.NewFromFloat(475.00), EntryDate: at(-5), ExitDate: at(1), ExecutedAt: *at(1), RealizedPnl: decimal.NewFromFloat(-25.00), TradeGrade: models.TradeGradeC},
+			// Closed before the window
+			{Symbol: "CHART", TradeType: models.TradeTypeSell, Quantity: decimal.NewFromFloat(5), Price: decimal.NewFromFloat(90.00), TotalCost: decimal.NewFromFloat(450.00), EntryDate: at(-10), ExitDate: at(-3), ExecutedAt: *at(-3), TradeGrade: models.TradeGradeD},
+			// Opened after the window
+			{Symbol: "CHART", TradeType: models.TradeTypeSell, Quantity: decimal.NewFromFloat(5), Price: decimal.NewFromFloat(120.00), TotalCost: decimal.NewFromFloat(600.00), EntryDate: at(15), ExitDate: at(20), ExecutedAt: *at(20), TradeGrade: models.TradeGradeB},
+			// Buy record and other symbol are never returned
+			{Symbol: "CHART", TradeType: models.TradeTypeBuy, Quantity: decimal.NewFromFloat(10), Price: decimal.NewFromFloat(100.00), TotalCost: decimal.NewFromFloat(1000.00), ExecutedAt: *at(2), TradeGrade: models.TradeGradeB},
+			{Symbol: "OTHER", TradeType: models.TradeTypeSell, Quantity: decimal.NewFromFloat(1), Price: decimal.NewFromFloat(10.00), TotalCost: decimal.NewFromFloat(10.00), EntryDate: at(2), ExitDate: at(4), ExecutedAt: *at(4), TradeGrade: models.TradeGradeB},
+		}
+		for _, tr := range trades {
+			require.NoError(t, testDB.CreateTradeHistory(tr))
+		}
+
+		retrieved, err := testDB.GetClosedTradesForSymbolBetween("CHART", *at(0), *at(10))
+		require.NoError(t, err)
+		require.Len(t, retrieved, 2)
+
+		// Ordered by exit date ascending
+		assert.True(t, retrieved[0].ExitDate.Equal(*at(1)))
+		assert.True(t, retrieved[0].EntryDate.Equal(*at(-5)))
+		assert.True(t, decimal.NewFromFloat(95.00).Equal(retrieved[0].Price))
+		require.NotNil(t, retrieved[0].EntryPrice)
+		assert.Equal(t, "100", retrieved[0].EntryPrice.String())
+
+		assert.True(t, retrieved[1].ExitDate.Equal(*at(5)))
+		assert.True(t, retrieved[1].EntryDate.Equal(*at(2)))
+		assert.True(t, decimal.NewFromFloat(110.00).Equal(retrieved[1].Price))
+		assert.True(t, decimal.NewFromFloat(100.00).Equal(retrieved[1].RealizedPnl))
+		// (110 - 99) * 10 less the $10 fee is the $100 realized
+		require.NotNil(t, retrieved[1].EntryPrice)
+		assert.Equal(t, "99", retrieved[1].EntryPrice.String())
+	})
+
+	t.Run("GetRealizedPnlByGrade aggregates closed trades per grade", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		trades := []*models.TradeHistory{
+			{Symbol: "A1", TradeType: models.TradeTypeSell, Quantity: decimal.NewFromFloat(10), Price: decimal.NewFromFloat(110), TotalCost: decimal.NewFromFloat(1100), RealizedPnl: decimal.NewFromFloat(100), RealizedPnlPct: decimal.NewFromFloat(10), TradeGrade: models.TradeGradeA},
+			{Symbol: "A2", TradeType: models.TradeTypeSell, Quantity: decimal.NewFromFloat(10), Price: decimal.NewFromFloat(130), TotalCost: decimal.NewFromFloat(1300), RealizedPnl: decimal.NewFromFloat(300), RealizedPnlPct: decimal.NewFromFloat(30), TradeGrade: models.TradeGradeA},
+			{Symbol: "C1", TradeType: models.TradeTypeSell, Quantity: decimal.NewFromFloat(10), Price: decimal.NewFromFloat(95), TotalCost: decimal.NewFromFloat(950), RealizedPnl: decimal.NewFromFloat(-50), RealizedPnlPct: decimal.NewFromFloat(-5), TradeGrade: models.TradeGradeC},
+			{Symbol: "F1", TradeType: models.TradeTypeSell, Quantity: decimal.NewFromFloat(10), Price: decimal.NewFromFloat(80), TotalCost: decimal.NewFromFloat(800), RealizedPnl: decimal.NewFromFloat(-200), RealizedPnlPct: decimal.NewFromFloat(-20), TradeGrade: models.TradeGradeF},
+			{Symbol: "F2", TradeType: models.TradeTypeSell, Quantity: decimal.NewFromFloat(10), Price: decimal.NewFromFloat(110), TotalCost: decimal.NewFromFloat(1100), RealizedPnl: decimal.NewFromFloat(100), RealizedPnlPct: decimal.NewFromFloat(10), TradeGrade: models.TradeGradeF},
+			// Buys are not closed trades and must be ignored
+			{Symbol: "B1", TradeType: models.TradeTypeBuy, Quantity: decimal.NewFromFloat(10), Price: decimal.NewFromFloat(100), TotalCost: decimal.NewFromFloat(1000), TradeGrade: models.TradeGradeA},
+		}
+		for _, tr := range trades {
+			require.NoError(t, testDB.CreateTradeHistory(tr))
+		}
+
+		grades, err := testDB.GetRealizedPnlByGrade()
+		require.NoError(t, err)
+		require.Len(t, grades, 3)
+
+		assert.Equal(t, "A", grades[0].Grade)
+		assert.Equal(t, 2, grades[0].Count)
+		assert.True(t, decimal.NewFromFloat(400).Equal(grades[0].TotalPnl))
+		assert.True(t, decimal.NewFromFloat(200).Equal(grades[0].AvgPnl))
+		assert.True(t, decimal.NewFromFloat(20).Equal(grades[0].AvgPnlPct))
+
+		assert.Equal(t, "C", grades[1].Grade)
+		assert.Equal(t, 1, grades[1].Count)
+		assert.True(t, decimal.NewFromFloat(-50).Equal(grades[1].TotalPnl))
+
+		assert.Equal(t, "F", grades[2].Grade)
+		assert.Equal(t, 2, grades[2].Count)
+		assert.True(t, decimal.NewFromFloat(-100).Equal(grades[2].TotalPnl))
+		assert.True(t, decimal.NewFromFloat(-50).Equal(grades[2].AvgPnl))
+	})
+
+	t.Run("CreateTradeHistory accepts an ungraded trade", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		trade := &models.TradeHistory{
+			Symbol:    "NOGRADE",
+			TradeType: models.TradeTypeSell,
+			Quantity:  decimal.NewFromInt(5),
+			Price:     decimal.NewFromInt(100),
+			TotalCost: decimal.NewFromInt(500),
+		}
+		require.NoError(t, testDB.CreateTradeHistory(trade))
+
+		got, err := testDB.GetTradeHistoryByID(trade.ID)
+		require.NoError(t, err)
+		assert.Empty(t, got.TradeGrade)
+	})
+
+	t.Run("SearchTradeNotes matches journal text case-insensitively", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		base := time.Now().Add(-72 * time.Hour)
+		for i, tr := range []*models.TradeHistory{
+			{Symbol: "AAPL", Notes: "Chased the gap, should have waited for a pullback"},
+			{Symbol: "MSFT", WhatWentWrong: "Ignored my STOP LOSS rule"},
+			{Symbol: "NVDA", EntryReason: "RSI oversold bounce", ExitReason: "hit target"},
+			{Symbol: "TSLA", WhatWentRight: "Sized at 100% of plan"},
+			{Symbol: "AMD", Notes: "Clean breakout"},
+		} {
+			tr.TradeType = models.TradeTypeSell
+			tr.Quantity = decimal.NewFromInt(1)
+			tr.Price = decimal.NewFromInt(100)
+			tr.TotalCost = decimal.NewFromInt(100)
+			tr.ExecutedAt = base.Add(time.Duration(i) * time.Hour)
+			require.NoError(t, testDB.CreateTradeHistory(tr))
+		}
+
+		symbols := func(trades []*models.TradeHistory) []string {
+			out := make([]string, len(trades))
+			for i, tr := range trades {
+				out[i] = tr.Symbol
+			}
+			return out
+		}
+
+		matches, err := testDB.SearchTradeNotes("stop loss", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"MSFT"}, symbols(matches))
+
+		matches, err = testDB.SearchTradeNotes("PULLBACK", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"AAPL"}, symbols(matches))
+
+		// Entry and exit reasons are searched too
+		matches, err = testDB.SearchTradeNotes("oversold", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"NVDA"}, symbols(matches))
+
+		// Wildcards are literal
+		matches, err = testDB.SearchTradeNotes("100%", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"TSLA"}, symbols(matches))
+		matches, err = testDB.SearchTradeNotes("%", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"TSLA"}, symbols(matches))
+
+		// Newest first, limited
+		matches, err = testDB.SearchTradeNotes("a", 2)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"AMD", "TSLA"}, symbols(matches))
+
+		_, err = testDB.SearchTradeNotes("  ", 10)
+		assert.Error(t, err)
+	})
+
+	t.Run("GetDailyPnlCalendar sums realized P&L per exit day", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		closeOn := func(day time.Time, pnl float64) {
+			exit := day
+			trade := &models.TradeHistory{
+				Symbol:      "CAL",
+				TradeType:   models.TradeTypeSell,
+				Quantity:    decimal.NewFromInt(1),
+				Price:       decimal.NewFromInt(100),
+				TotalCost:   decimal.NewFromInt(100),
+				ExitDate:    &exit,
+				RealizedPnl: decimal.NewFromFloat(pnl),
+				ExecutedAt:  exit,
+			}
+			require.NoError(t, testDB.CreateTradeHistory(trade))
+		}
+
+		noon := func(month time.Month, day int) time.Time {
+			return time.Date(2025, month, day, 12, 0, 0, 0, time.UTC)
+		}
+		closeOn(noon(time.March, 3), 120)
+		closeOn(noon(time.March, 3), -20) // Same day nets out
+		closeOn(noon(time.March, 4), -55.5)
+		closeOn(noon(time.December, 31), 10)
+		closeOn(time.Date(2024, time.December, 31, 12, 0, 0, 0, time.UTC), 999) // Previous year
+
+		calendar, err := testDB.GetDailyPnlCalendar(2025)
+		require.NoError(t, err)
+		require.Len(t, calendar, 3)
+		assert.True(t, decimal.NewFromInt(100).Equal(calendar["2025-03-03"]))
+		assert.True(t, decimal.NewFromFloat(-55.5).Equal(calendar["2025-03-04"]))
+		assert.True(t, decimal.NewFromInt(10).Equal(calendar["2025-12-31"]))
+
+		// Days without closes are absent
+		_, ok := calendar["2025-03-05"]
+		assert.False(t, ok)
+
+		empty, err := testDB.GetDailyPnlCalendar(2023)
+		require.NoError(t, err)
+		assert.Empty(t, empty)
+	})
+
+	t.Run("GetTradeStats reports win and loss streaks in exit order", func(t *testing.T) {
+		closeSequence := func(pnls ...float64) {
+			testDB.TruncateAll(t)
+			base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+			for i, pnl := range pnls {
+				exit := base.AddDate(0, 0, i)
+				require.NoError(t, testDB.CreateTradeHistory(&models.TradeHistory{
+					Symbol:      "STRK",
+					TradeType:   models.TradeTypeSell,
+					Quantity:    decimal.NewFromInt(1),
+					Price:       decimal.NewFromInt(100),
+					TotalCost:   decimal.NewFromInt(100),
+					ExitDate:    &exit,
+					RealizedPnl: decimal.NewFromFloat(pnl),
+					// Insert order differs from exit order to prove sorting
+					ExecutedAt: base.AddDate(0, 0, len(pnls)-i),
+				}))
+			}
+		}
+
+		// Alternating: no streak longer than one
+		closeSequence(10, -5, 10, -5, 10)
+		stats, err := testDB.GetTradeStats()
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.MaxWinStreak)
+		assert.Equal(t, 1, stats.MaxLossStreak)
+		assert.Equal(t, 1, stats.CurrentWinStreak)
+		assert.Equal(t, 0, stats.CurrentLossStreak)
+
+		// Streaky: W W W L L W W W W L L L
+		closeSequence(1, 2, 3, -1, -2, 4, 5, 6, 7, -3, -4, -5)
+		stats, err = testDB.GetTradeStats()
+		require.NoError(t, err)
+		assert.Equal(t, 4, stats.MaxWinStreak)
+		assert.Equal(t, 3, stats.MaxLossStreak)
+		assert.Equal(t, 0, stats.CurrentWinStreak)
+		assert.Equal(t, 3, stats.CurrentLossStreak)
+	})
+
+	t.Run("GetTradeStatsBySymbol and ByStrategy filter the aggregation", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		newTrade := func(symbol, strategy string, pnl float64) *models.TradeHistory {
+			return &models.TradeHistory{
+				Symbol:         symbol,
+				TradeType:      models.TradeTypeSell,
+				Quantity:       decimal.NewFromFloat(10),
+				Price:          decimal.NewFromFloat(100),
+				TotalCost:      decimal.NewFromFloat(1000),
+				RealizedPnl:    decimal.NewFromFloat(pnl),
+				RealizedPnlPct: decimal.NewFromFloat(pnl / 10),
+				StrategyTag:    strategy,
+			}
+		}
+		for _, tr := range []*models.TradeHistory{
+			newTrade("AAPL", "breakout", 100),
+			newTrade("AAPL", "breakout", -50),
+			newTrade("AAPL", "mean_reversion", 30),
+			newTrade("MSFT", "breakout", 80),
+		} {
+			require.NoError(t, testDB.CreateTradeHistory(tr))
+		}
+
+		all, err := testDB.GetTradeStats()
+		require.NoError(t, err)
+		assert.Equal(t, 4, all.TotalTrades)
+		assert.True(t, decimal.NewFromInt(75).Equal(all.WinRate), "got %s", all.WinRate)
+
+		aapl, err := testDB.GetTradeStatsBySymbol("AAPL")
+		require.NoError(t, err)
+		assert.Equal(t, 3, aapl.TotalTrades)
+		assert.Equal(t, 2, aapl.WinningTrades)
+		assert.True(t, decimal.NewFromInt(80).Equal(aapl.TotalPnl), "got %s", aapl.TotalPnl)
+		assert.Equal(t, 1, aapl.CurrentWinStreak)
+
+		breakout, err := testDB.GetTradeStatsByStrategy("breakout")
+		require.NoError(t, err)
+		assert.Equal(t, 3, breakout.TotalTrades)
+		assert.Equal(t, 1, breakout.LosingTrades)
+		assert.True(t, decimal.NewFromInt(130).Equal(breakout.TotalPnl), "got %s", breakout.TotalPnl)
+
+		// With every trade matching the filter, the filtered path agrees with the unfiltered one
+		testDB.TruncateAll(t)
+		for _, tr := range []*models.TradeHistory{
+			newTrade("AAPL", "breakout", 100),
+			newTrade("AAPL", "breakout", -50),
+			newTrade("AAPL", "mean_reversion", 30),
+		} {
+			require.NoError(t, testDB.CreateTradeHistory(tr))
+		}
+		all, err = testDB.GetTradeStats()
+		require.NoError(t, err)
+		aapl, err = testDB.GetTradeStatsBySymbol("AAPL")
+		require.NoError(t, err)
+		assert.Equal(t, all, aapl)
+
+		none, err := testDB.GetTradeStatsByStrategy("unknown")
+		require.NoError(t, err)
+		assert.Equal(t, 0, none.TotalTrades)
+		assert.True(t, none.WinRate.IsZero())
+	})
+
+	t.Run("GetDailyRealizedPnl returns per-day totals oldest first", func(t *testing.T) {
+		testDB.TruncateAll(t)
+
+		closeOn := func(exit time.Time, pnl float64) {
+			require.NoError(t, testDB.CreateTradeHistory(&models.TradeHistory{
+				Symbol:      "DD",
+				TradeType:   models.TradeTypeSell,
+				Quantity:    decimal.NewFromInt(1),
+				Price:       decimal.NewFromInt(100),
+				TotalCost:   decimal.NewFromInt(100),
+				ExitDate:    &exit,
+				RealizedPnl: decimal.NewFromFloat(pnl),
+				ExecutedAt:  exit,
+			}))
+		}
+		closeOn(time.Date(2025, 6, 2, 15, 0, 0, 0, time.UTC), -40)
+		closeOn(time.Date(2024, 11, 5, 15, 0, 0, 0, time.UTC), 75)
+		closeOn(time.Date(2025, 6, 2, 18, 0, 0, 0, time.UTC), 10)
+
+		days, err := testDB.GetDailyRealizedPnl()
+		require.NoError(t, err)
+		require.Len(t, days, 2)
+		assert.Equal(t, "2024-11-05", days[0].Date.Format("2006-01-02"))
+		assert.True(t, decimal.NewFromInt(75).Equal(days[0].Pnl))
+		assert.Equal(t, "2025-06-02", days[1].Date.Format("2006-01-02"))
+		assert.True(t, decimal.NewFromInt(-30).Equal(days[1].Pnl))
+	})
 }
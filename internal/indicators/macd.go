@@ -0,0 +1,90 @@
+package indicators
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+// Standard MACD periods
+const (
+	MACDFast   = 12
+	MACDSlow   = 26
+	MACDSignal = 9
+)
+
+// macdLookback is how many daily candles StoreMACD reads, enough for the EMAs
+// to settle well past their seeds
+const macdLookback = 250
+
+// MACD computes the moving average convergence divergence of closes, oldest
+// first: the fast EMA less the slow EMA, a signal line that's the EMA of that
+// over signal periods, and the histogram of MACD less signal. The MACD line has
+// one value per close from closes[slow-1] on; the signal and histogram start
+// signal-1 values later. All three end at the latest close and are rounded to 4
+// places.
+func MACD(closes []decimal.Decimal, fast, slow, signal int) (macd, sig, hist []decimal.Decimal, err error) {
+	if fast < 1 || signal < 1 || slow <= fast {
+		return nil, nil, nil, fmt.Errorf("%w: fast %d, slow %d, signal %d", ErrInvalidPeriod, fast, slow, signal)
+	}
+	if err := checkWindow(len(closes), slow, slow+signal-1); err != nil {
+		return nil, nil, nil, err
+	}
+
+	fastEMA := ema(closes, fast)[slow-fast:]
+	slowEMA := ema(closes, slow)
+	line := make([]decimal.Decimal, len(slowEMA))
+	for i := range slowEMA {
+		line[i] = fastEMA[i].Sub(slowEMA[i])
+	}
+	signalLine := ema(line, signal)
+
+	offset := len(line) - len(signalLine)
+	macd = make([]decimal.Decimal, len(line))
+	for i, v := range line {
+		macd[i] = v.Round(4)
+	}
+	sig = make([]decimal.Decimal, len(signalLine))
+	hist = make([]decimal.Decimal, len(signalLine))
+	for i, v := range signalLine {
+		sig[i] = v.Round(4)
+		hist[i] = line[offset+i].Sub(v).Round(4)
+	}
+	return macd, sig, hist, nil
+}
+
+// StoreMACD computes the standard 12/26/9 MACD for symbol's latest daily candle
+// and upserts its MACD, signal and histogram values. With too little price
+// history it stores nothing and returns no indicators and no error.
+func StoreMACD(repo Repository, symbol string) ([]*models.TechnicalIndicator, error) {
+	prices, err := repo.GetPriceDataBySymbol(symbol, macdLookback)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prices for %s: %w", symbol, err)
+	}
+
+	// Prices come newest first
+	closes := make([]decimal.Decimal, len(prices))
+	for i, p := range prices {
+		closes[len(prices)-1-i] = p.Close
+	}
+	macd, sig, hist, err := MACD(closes, MACDFast, MACDSlow, MACDSignal)
+	if errors.Is(err, ErrInsufficientData) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute MACD for %s: %w", symbol, err)
+	}
+
+	date := prices[0].Date
+	stored := []*models.TechnicalIndicator{
+		{Symbol: symbol, Date: date, IndicatorType: models.IndicatorMACD, Value: macd[len(macd)-1], Timeframe: "daily"},
+		{Symbol: symbol, Date: date, IndicatorType: models.IndicatorMACDSignal, Value: sig[len(sig)-1], Timeframe: "daily"},
+		{Symbol: symbol, Date: date, IndicatorType: models.IndicatorMACDHist, Value: hist[len(hist)-1], Timeframe: "daily"},
+	}
+	if err := repo.CreateTechnicalIndicatorBatch(stored); err != nil {
+		return nil, fmt.Errorf("failed to store MACD for %s: %w", symbol, err)
+	}
+	return stored, nil
+}
@@ -0,0 +1,106 @@
+package indicators
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+// referenceEMA is a plain float64 EMA seeded with the first period's mean,
+// returning a value for every index with NaN before the seed
+func referenceEMA(values []float64, period int) []float64 {
+	out := make([]float64, len(values))
+	k := 2 / float64(period+1)
+	sum := 0.0
+	for i, v := range values {
+		switch {
+		case i < period-1:
+			sum += v
+			out[i] = math.NaN()
+		case i == period-1:
+			out[i] = (sum + v) / float64(period)
+		default:
+			out[i] = out[i-1] + k*(v-out[i-1])
+		}
+	}
+	return out
+}
+
+// wave is a rising, oscillating series of n closes
+func wave(n int) []float64 {
+	closes := make([]float64, n)
+	for i := range closes {
+		closes[i] = math.Round((100+0.3*float64(i)+4*math.Sin(float64(i)/3))*100) / 100
+	}
+	return closes
+}
+
+func TestMACD(t *testing.T) {
+	t.Run("matches a reference implementation", func(t *testing.T) {
+		closes := wave(60)
+		macd, sig, hist, err := MACD(decimals(closes...), 12, 26, 9)
+		require.NoError(t, err)
+
+		fast, slow := referenceEMA(closes, 12), referenceEMA(closes, 26)
+		line := make([]float64, 0, len(closes))
+		for i := 25; i < len(closes); i++ {
+			line = append(line, fast[i]-slow[i])
+		}
+		signal := referenceEMA(line, 9)
+
+		require.Len(t, macd, 35)
+		require.Len(t, sig, 27)
+		require.Len(t, hist, 27)
+		for i, v := range line {
+			assert.InDelta(t, v, macd[i].InexactFloat64(), 0.0001, "macd %d", i)
+		}
+		for i := range sig {
+			assert.InDelta(t, signal[i+8], sig[i].InexactFloat64(), 0.0001, "signal %d", i)
+			assert.InDelta(t, line[i+8]-signal[i+8], hist[i].InexactFloat64(), 0.0001, "hist %d", i)
+		}
+	})
+
+	t.Run("too few closes", func(t *testing.T) {
+		_, _, _, err := MACD(decimals(wave(33)...), 12, 26, 9)
+		assert.True(t, errors.Is(err, ErrInsufficientData))
+	})
+
+	t.Run("slow must exceed fast", func(t *testing.T) {
+		_, _, _, err := MACD(decimals(wave(60)...), 26, 12, 9)
+		assert.True(t, errors.Is(err, ErrInvalidPeriod))
+	})
+}
+
+func TestStoreMACD(t *testing.T) {
+	t.Run("stores all three lines for the latest candle", func(t *testing.T) {
+		start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		repo := newMockRepo("AAPL", start, wave(60))
+
+		stored, err := StoreMACD(repo, "AAPL")
+		require.NoError(t, err)
+		require.Len(t, repo.stored, 3)
+		assert.Equal(t, stored, repo.stored)
+
+		types := []string{models.IndicatorMACD, models.IndicatorMACDSignal, models.IndicatorMACDHist}
+		for i, ind := range repo.stored {
+			assert.Equal(t, types[i], ind.IndicatorType)
+			assert.True(t, start.AddDate(0, 0, 59).Equal(ind.Date))
+		}
+		macd, signal, hist := repo.stored[0].Value, repo.stored[1].Value, repo.stored[2].Value
+		assert.InDelta(t, macd.Sub(signal).InexactFloat64(), hist.InexactFloat64(), 0.0001)
+	})
+
+	t.Run("too little history stores nothing", func(t *testing.T) {
+		repo := newMockRepo("NEW", time.Now(), wave(30))
+
+		stored, err := StoreMACD(repo, "NEW")
+		require.NoError(t, err)
+		assert.Empty(t, stored)
+		assert.Empty(t, repo.stored)
+	})
+}
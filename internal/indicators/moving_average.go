@@ -0,0 +1,145 @@
+package indicators
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+// MovingAverage is a moving average stored as an indicator
+type MovingAverage struct {
+	IndicatorType string
+	Period        int
+	Exponential   bool
+}
+
+// MovingAverages are the averages ComputeAndStoreMovingAverages stores
+var MovingAverages = []MovingAverage{
+	{IndicatorType: models.IndicatorSMA20, Period: 20},
+	{IndicatorType: models.IndicatorSMA50, Period: 50},
+	{IndicatorType: models.IndicatorSMA200, Period: 200},
+	{IndicatorType: models.IndicatorEMA12, Period: 12, Exponential: true},
+	{IndicatorType: models.IndicatorEMA26, Period: 26, Exponential: true},
+}
+
+// movingAverageLookback is how many daily candles ComputeAndStoreMovingAverages
+// reads: enough for SMA_200, and for the EMAs to settle well past their seed
+const movingAverageLookback = 400
+
+// SMA computes the simple moving average of values, oldest first. It returns one
+// value per element from values[period-1] on, rounded to 4 places.
+func SMA(values []decimal.Decimal, period int) ([]decimal.Decimal, error) {
+	if err := checkWindow(len(values), period, period); err != nil {
+		return nil, err
+	}
+
+	n := decimal.NewFromInt(int64(period))
+	var sum decimal.Decimal
+	for _, v := range values[:period] {
+		sum = sum.Add(v)
+	}
+	out := make([]decimal.Decimal, 0, len(values)-period+1)
+	out = append(out, sum.Div(n).Round(4))
+	for i := period; i < len(values); i++ {
+		sum = sum.Add(values[i]).Sub(values[i-period])
+		out = append(out, sum.Div(n).Round(4))
+	}
+	return out, nil
+}
+
+// EMA computes the exponential moving average of values, oldest first, with a
+// smoothing factor of 2/(period+1). It's seeded with the simple average of the
+// first period values and returns one value per element from values[period-1]
+// on, rounded to 4 places.
+func EMA(values []decimal.Decimal, period int) ([]decimal.Decimal, error) {
+	if err := checkWindow(len(values), period, period); err != nil {
+		return nil, err
+	}
+	out := ema(values, period)
+	for i, v := range out {
+		out[i] = v.Round(4)
+	}
+	return out, nil
+}
+
+// ema is EMA without the validation or rounding, for indicators built on it
+func ema(values []decimal.Decimal, period int) []decimal.Decimal {
+	k := decimal.NewFromInt(2).Div(decimal.NewFromInt(int64(period + 1)))
+	var avg decimal.Decimal
+	for _, v := range values[:period] {
+		avg = avg.Add(v)
+	}
+	avg = avg.Div(decimal.NewFromInt(int64(period)))
+
+	out := make([]decimal.Decimal, 0, len(values)-period+1)
+	out = append(out, avg)
+	for i := period; i < len(values); i++ {
+		avg = values[i].Sub(avg).Mul(k).Add(avg)
+		out = append(out, avg)
+	}
+	return out
+}
+
+// checkWindow validates period and that there are at least need values
+func checkWindow(have, period, need int) error {
+	if period < 1 {
+		return fmt.Errorf("%w: got %d", ErrInvalidPeriod, period)
+	}
+	if have < need {
+		return fmt.Errorf("%w: need at least %d values, got %d", ErrInsufficientData, need, have)
+	}
+	return nil
+}
+
+// ComputeAndStoreMovingAverages computes each of MovingAverages for symbol's
+// latest daily candle and upserts them. Averages with too little price history
+// are skipped rather than failing the rest, so a newly listed symbol stores what
+// it can. It returns the indicators stored, which is empty if none had enough
+// history.
+func ComputeAndStoreMovingAverages(repo Repository, symbol string) ([]*models.TechnicalIndicator, error) {
+	prices, err := repo.GetPriceDataBySymbol(symbol, movingAverageLookback)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prices for %s: %w", symbol, err)
+	}
+	if len(prices) == 0 {
+		return nil, nil
+	}
+
+	// Prices come newest first
+	closes := make([]decimal.Decimal, len(prices))
+	for i, p := range prices {
+		closes[len(prices)-1-i] = p.Close
+	}
+
+	var stored []*models.TechnicalIndicator
+	for _, ma := range MovingAverages {
+		compute := SMA
+		if ma.Exponential {
+			compute = EMA
+		}
+		values, err := compute(closes, ma.Period)
+		if errors.Is(err, ErrInsufficientData) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute %s for %s: %w", ma.IndicatorType, symbol, err)
+		}
+		stored = append(stored, &models.TechnicalIndicator{
+			Symbol:        symbol,
+			Date:          prices[0].Date,
+			IndicatorType: ma.IndicatorType,
+			Value:         values[len(values)-1],
+			Timeframe:     "daily",
+		})
+	}
+	if len(stored) == 0 {
+		return nil, nil
+	}
+
+	if err := repo.CreateTechnicalIndicatorBatch(stored); err != nil {
+		return nil, fmt.Errorf("failed to store moving averages for %s: %w", symbol, err)
+	}
+	return stored, nil
+}
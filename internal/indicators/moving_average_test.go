@@ -0,0 +1,79 @@
+package indicators
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+func asStrings(values []decimal.Decimal) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = v.String()
+	}
+	return out
+}
+
+func TestSMA(t *testing.T) {
+	values, err := SMA(decimals(2, 4, 6, 8, 12), 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"4", "6", "8.6667"}, asStrings(values))
+
+	_, err = SMA(decimals(2, 4), 3)
+	assert.True(t, errors.Is(err, ErrInsufficientData))
+
+	_, err = SMA(decimals(2, 4), 0)
+	assert.True(t, errors.Is(err, ErrInvalidPeriod))
+}
+
+func TestEMA(t *testing.T) {
+	// Seeded with the SMA of 2, 4, 6; then k = 0.5: 4 + (8-4)/2 = 6, 6 + (12-6)/2 = 9
+	values, err := EMA(decimals(2, 4, 6, 8, 12), 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"4", "6", "9"}, asStrings(values))
+
+	_, err = EMA(decimals(2, 4), 3)
+	assert.True(t, errors.Is(err, ErrInsufficientData))
+}
+
+func TestComputeAndStoreMovingAverages(t *testing.T) {
+	t.Run("stores the averages there's history for", func(t *testing.T) {
+		// Closes 1..30. An EMA seeded on a steadily rising series lags it by
+		// (period-1)/2 throughout, so EMA_12 ends at 24.5 and EMA_26 at 17.5.
+		closes := make([]float64, 30)
+		for i := range closes {
+			closes[i] = float64(i + 1)
+		}
+		start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		repo := newMockRepo("AAPL", start, closes)
+
+		stored, err := ComputeAndStoreMovingAverages(repo, "AAPL")
+		require.NoError(t, err)
+		assert.Equal(t, stored, repo.stored)
+
+		got := map[string]string{}
+		for _, ind := range repo.stored {
+			assert.True(t, start.AddDate(0, 0, 29).Equal(ind.Date), "stored for the latest candle")
+			got[ind.IndicatorType] = ind.Value.String()
+		}
+		assert.Equal(t, map[string]string{
+			models.IndicatorSMA20: "20.5",
+			models.IndicatorEMA12: "24.5",
+			models.IndicatorEMA26: "17.5",
+		}, got)
+	})
+
+	t.Run("too little history stores nothing", func(t *testing.T) {
+		repo := newMockRepo("NEW", time.Now(), []float64{1, 2, 3})
+
+		stored, err := ComputeAndStoreMovingAverages(repo, "NEW")
+		require.NoError(t, err)
+		assert.Empty(t, stored)
+		assert.Empty(t, repo.stored)
+	})
+}
@@ -0,0 +1,121 @@
+// Package indicators computes technical indicators from stored daily prices.
+package indicators
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+// RSIPeriod is the lookback of the RSI_14 indicator
+const RSIPeriod = 14
+
+// ErrInvalidPeriod is returned for a lookback period below 1
+var ErrInvalidPeriod = errors.New("period must be at least 1")
+
+// ErrInsufficientData is returned when there are too few closes for the period
+var ErrInsufficientData = errors.New("insufficient data points")
+
+var hundred = decimal.NewFromInt(100)
+
+// RSI computes the relative strength index of closes, oldest first, using
+// Wilder's smoothing. The first value averages the gains and losses of the first
+// period changes; each later one carries the averages forward as
+// (previous*(period-1) + current) / period. It returns one value per close from
+// closes[period] on, rounded to 4 places. A series with no losses has an RSI of
+// 100, and a flat one 50.
+func RSI(closes []decimal.Decimal, period int) ([]decimal.Decimal, error) {
+	if period < 1 {
+		return nil, fmt.Errorf("%w: got %d", ErrInvalidPeriod, period)
+	}
+	if len(closes) <= period {
+		return nil, fmt.Errorf("%w: need at least %d closes, got %d", ErrInsufficientData, period+1, len(closes))
+	}
+
+	n := decimal.NewFromInt(int64(period))
+	var avgGain, avgLoss decimal.Decimal
+	for i := 1; i <= period; i++ {
+		gain, loss := change(closes[i-1], closes[i])
+		avgGain = avgGain.Add(gain)
+		avgLoss = avgLoss.Add(loss)
+	}
+	avgGain = avgGain.Div(n)
+	avgLoss = avgLoss.Div(n)
+
+	values := make([]decimal.Decimal, 0, len(closes)-period)
+	values = append(values, rsiValue(avgGain, avgLoss))
+	for i := period + 1; i < len(closes); i++ {
+		gain, loss := change(closes[i-1], closes[i])
+		avgGain = avgGain.Mul(n.Sub(decimal.NewFromInt(1))).Add(gain).Div(n)
+		avgLoss = avgLoss.Mul(n.Sub(decimal.NewFromInt(1))).Add(loss).Div(n)
+		values = append(values, rsiValue(avgGain, avgLoss))
+	}
+	return values, nil
+}
+
+// change splits the move from prev to cur into a gain and a loss, one of them zero
+func change(prev, cur decimal.Decimal) (decimal.Decimal, decimal.Decimal) {
+	diff := cur.Sub(prev)
+	if diff.IsPositive() {
+		return diff, decimal.Zero
+	}
+	return decimal.Zero, diff.Neg()
+}
+
+func rsiValue(avgGain, avgLoss decimal.Decimal) decimal.Decimal {
+	if avgLoss.IsZero() {
+		if avgGain.IsZero() {
+			return decimal.NewFromInt(50)
+		}
+		return hundred
+	}
+	rs := avgGain.Div(avgLoss)
+	return hundred.Sub(hundred.Div(decimal.NewFromInt(1).Add(rs))).Round(4)
+}
+
+// Repository provides the stored prices and indicators RSI is computed from and
+// written to. *database.DB satisfies it.
+type Repository interface {
+	GetPriceDataBySymbol(symbol string, limit int) ([]*models.PriceDataDaily, error)
+	CreateTechnicalIndicatorBatch(indicators []*models.TechnicalIndicator) error
+}
+
+// StoreRSI14 computes RSI_14 over symbol's latest lookback daily candles and
+// upserts a daily reading for every candle from the fifteenth on. It returns how
+// many readings were stored. Earlier readings are less settled, since Wilder's
+// smoothing depends on every close before them, so a longer lookback gives more
+// accurate recent values.
+func StoreRSI14(repo Repository, symbol string, lookback int) (int, error) {
+	prices, err := repo.GetPriceDataBySymbol(symbol, lookback)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load prices for %s: %w", symbol, err)
+	}
+
+	// Prices come newest first
+	closes := make([]decimal.Decimal, len(prices))
+	for i, p := range prices {
+		closes[len(prices)-1-i] = p.Close
+	}
+	values, err := RSI(closes, RSIPeriod)
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute RSI for %s: %w", symbol, err)
+	}
+
+	readings := make([]*models.TechnicalIndicator, len(values))
+	for i, v := range values {
+		candle := prices[len(values)-1-i]
+		readings[i] = &models.TechnicalIndicator{
+			Symbol:        symbol,
+			Date:          candle.Date,
+			IndicatorType: models.IndicatorRSI14,
+			Value:         v,
+			Timeframe:     "daily",
+		}
+	}
+	if err := repo.CreateTechnicalIndicatorBatch(readings); err != nil {
+		return 0, fmt.Errorf("failed to store RSI for %s: %w", symbol, err)
+	}
+	return len(readings), nil
+}
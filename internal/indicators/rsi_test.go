@@ -0,0 +1,123 @@
+package indicators
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+// zigzag returns n+1 closes starting at 10 that alternately rise 1 and fall 0.5
+func zigzag(n int) []float64 {
+	closes := []float64{10}
+	for i := 0; i < n; i++ {
+		step := 1.0
+		if i%2 == 1 {
+			step = -0.5
+		}
+		closes = append(closes, closes[i]+step)
+	}
+	return closes
+}
+
+func decimals(values ...float64) []decimal.Decimal {
+	out := make([]decimal.Decimal, len(values))
+	for i, v := range values {
+		out[i] = decimal.NewFromFloat(v)
+	}
+	return out
+}
+
+func TestRSI(t *testing.T) {
+	t.Run("Wilder smoothing", func(t *testing.T) {
+		// Changes +1, -0.5, +1, -0.5, +1. The first averages are 2/3 and 1/6 (RS 4);
+		// then 4/9 and 5/18 (RS 1.6); then 17/27 and 5/27 (RS 3.4).
+		values, err := RSI(decimals(10, 11, 10.5, 11.5, 11, 12), 3)
+		require.NoError(t, err)
+		require.Len(t, values, 3)
+		assert.Equal(t, "80", values[0].String())
+		assert.Equal(t, "61.5385", values[1].String())
+		assert.Equal(t, "77.2727", values[2].String())
+	})
+
+	t.Run("only gains is 100", func(t *testing.T) {
+		values, err := RSI(decimals(1, 2, 3, 4), 3)
+		require.NoError(t, err)
+		assert.Equal(t, "100", values[0].String())
+	})
+
+	t.Run("flat series is 50", func(t *testing.T) {
+		values, err := RSI(decimals(5, 5, 5, 5), 3)
+		require.NoError(t, err)
+		assert.Equal(t, "50", values[0].String())
+	})
+
+	t.Run("too few closes", func(t *testing.T) {
+		_, err := RSI(decimals(1, 2, 3), 3)
+		assert.True(t, errors.Is(err, ErrInsufficientData))
+	})
+
+	t.Run("invalid period", func(t *testing.T) {
+		_, err := RSI(decimals(1, 2, 3), 0)
+		assert.True(t, errors.Is(err, ErrInvalidPeriod))
+	})
+}
+
+// mockRepo serves stored prices and collects the indicators written
+type mockRepo struct {
+	prices []*models.PriceDataDaily // newest first, as the database returns them
+	stored []*models.TechnicalIndicator
+}
+
+// newMockRepo stores one daily candle per close, oldest first, from start on
+func newMockRepo(symbol string, start time.Time, closes []float64) *mockRepo {
+	repo := &mockRepo{}
+	for i := len(closes) - 1; i >= 0; i-- {
+		repo.prices = append(repo.prices, &models.PriceDataDaily{
+			Symbol: symbol,
+			Date:   start.AddDate(0, 0, i),
+			Close:  decimal.NewFromFloat(closes[i]),
+		})
+	}
+	return repo
+}
+
+func (m *mockRepo) GetPriceDataBySymbol(symbol string, limit int) ([]*models.PriceDataDaily, error) {
+	if len(m.prices) > limit {
+		return m.prices[:limit], nil
+	}
+	return m.prices, nil
+}
+
+func (m *mockRepo) CreateTechnicalIndicatorBatch(indicators []*models.TechnicalIndicator) error {
+	m.stored = append(m.stored, indicators...)
+	return nil
+}
+
+func TestStoreRSI14(t *testing.T) {
+	// 16 candles: 14 changes averaging 0.5 up and 0.25 down (RS 2), then a rise of
+	// 1 taking the averages to 7.5/14 and 3.25/14 (RS 30/13)
+	closes := zigzag(15)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo := newMockRepo("AAPL", start, closes)
+
+	stored, err := StoreRSI14(repo, "AAPL", 100)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stored)
+	require.Len(t, repo.stored, 2)
+
+	first, last := repo.stored[0], repo.stored[1]
+	assert.Equal(t, "AAPL", first.Symbol)
+	assert.Equal(t, models.IndicatorRSI14, first.IndicatorType)
+	assert.True(t, start.AddDate(0, 0, 14).Equal(first.Date), "first reading is on the fifteenth candle")
+	assert.Equal(t, "66.6667", first.Value.String())
+	assert.True(t, start.AddDate(0, 0, 15).Equal(last.Date))
+	assert.Equal(t, "69.7674", last.Value.String())
+
+	_, err = StoreRSI14(repo, "AAPL", 10)
+	assert.True(t, errors.Is(err, ErrInsufficientData))
+}
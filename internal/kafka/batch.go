@@ -0,0 +1,223 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+// defaultBatchWait bounds how long a partial batch waits for more messages
+const defaultBatchWait = 500 * time.Millisecond
+
+// batchReader fetches messages without committing them so a whole batch can be
+// committed once it has been processed. *kafka.Reader satisfies it.
+type batchReader interface {
+	messageReader
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// SetBatchSize processes trade messages in batches of up to size, committing
+// offsets once per batch instead of per message. When a message fails and isn't
+// dead-lettered, it and the rest of its partition's batch are left out of the
+// commit and retried before more messages are fetched. A partial batch is processed
+// once wait passes without it filling (zero or less uses 500ms). A size of 1 or
+// less disables batching.
+func (c *Consumer) SetBatchSize(size int, wait time.Duration) {
+	if wait <= 0 {
+		wait = defaultBatchWait
+	}
+	c.batchSize = size
+	c.batchWait = wait
+}
+
+// startBatches is the Start loop for batch mode
+func (c *Consumer) startBatches(ctx context.Context, r batchReader) error {
+	log.Printf("Starting Kafka consumer for topic: %s (batches of up to %d)", r.Config().Topic, c.batchSize)
+
+	var retry []kafka.Message
+	handled := make(map[offsetKey]bool)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Kafka consumer shutting down...")
+			return r.Close()
+		default:
+			c.breaker.wait(ctx)
+			if ctx.Err() != nil {
+				continue
+			}
+
+			batch := retry
+			if len(batch) > 0 {
+				pause(ctx, redeliverDelay)
+				if ctx.Err() != nil {
+					continue
+				}
+			} else {
+				batch = c.fetchBatch(ctx, r)
+			}
+			if len(batch) == 0 {
+				continue
+			}
+
+			var done []kafka.Message
+			done, retry = c.processBatch(ctx, batch, handled)
+			c.commit(ctx, done...)
+		}
+	}
+}
+
+// fetchBatch blocks for the first message, then collects more until the batch is
+// full or the batch wait passes
+func (c *Consumer) fetchBatch(ctx context.Context, r batchReader) []kafka.Message {
+	first, err := r.FetchMessage(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			c.fetchFailed(ctx, r, err)
+		}
+		return nil
+	}
+	batch := []kafka.Message{first}
+
+	waitCtx, cancel := context.WithTimeout(ctx, c.batchWait)
+	defer cancel()
+	for len(batch) < c.batchSize {
+		msg, err := r.FetchMessage(waitCtx)
+		if err != nil {
+			if waitCtx.Err() != nil || !c.fetchFailed(ctx, r, err) {
+				break // Batch wait elapsed, shutting down, or the reader failed
+			}
+			continue
+		}
+		batch = append(batch, msg)
+	}
+	return batch
+}
+
+// fetchFailed handles a fetch error, reporting whether fetching can continue.
+// Oversized messages are routed to the dead letter topic as in Start.
+func (c *Consumer) fetchFailed(ctx context.Context, r batchReader, err error) bool {
+	if isOversizedReadError(err) {
+		routeToDeadLetter(ctx, c.dlq, kafka.Message{Topic: r.Config().Topic}, err)
+		return true
+	}
+	log.Printf("Error reading message: %v", err)
+	return false
+}
+
+// offsetKey identifies a message by its position in a topic partition
+type offsetKey struct {
+	topic     string
+	partition int
+	offset    int64
+}
+
+func keyOf(msg kafka.Message) offsetKey {
+	return offsetKey{msg.Topic, msg.Partition, msg.Offset}
+}
+
+// processBatch processes each message in batchOrder, routing oversized and
+// malformed ones to the dead letter topic and feeding the circuit breaker as
+// Start does. It returns the messages that can be committed and those to retry.
+// Commits are cumulative per partition, so only the run of a partition's
+// messages before its first failure can be committed, and from that failure on
+// they're all returned for retry. Messages in handled, which processBatch adds
+// to, were already stored or dead-lettered on an earlier attempt and aren't
+// processed again.
+func (c *Consumer) processBatch(ctx context.Context, batch []kafka.Message, handled map[offsetKey]bool) (done, retry []kafka.Message) {
+	type partition struct {
+		topic string
+		id    int
+	}
+	firstFailed := make(map[partition]int64)
+	for _, msg := range c.batchOrder(batch) {
+		if handled[keyOf(msg)] {
+			continue
+		}
+		if c.maxBytes > 0 && len(msg.Value) > c.maxBytes {
+			routeToDeadLetter(ctx, c.dlq, msg, fmt.Errorf("%w: %d > %d", errMessageTooLarge, len(msg.Value), c.maxBytes))
+			handled[keyOf(msg)] = true
+			continue
+		}
+
+		if err := c.processMessage(ctx, msg); err != nil {
+			if c.processFailed(ctx, msg, err) {
+				handled[keyOf(msg)] = true
+				continue
+			}
+			p := partition{msg.Topic, msg.Partition}
+			if first, ok := firstFailed[p]; !ok || msg.Offset < first {
+				firstFailed[p] = msg.Offset
+			}
+			continue
+		}
+		handled[keyOf(msg)] = true
+		c.breaker.recordSuccess()
+	}
+
+	for _, msg := range batch {
+		if first, ok := firstFailed[partition{msg.Topic, msg.Partition}]; ok && msg.Offset >= first {
+			retry = append(retry, msg)
+			continue
+		}
+		done = append(done, msg)
+		delete(handled, keyOf(msg))
+	}
+	return done, retry
+}
+
+// batchOrder groups a batch by symbol, in order of each symbol's first message,
+// and sorts each symbol's trades by execution time. Fills for one symbol that
+// arrived out of order across partitions are then applied oldest first, which
+// FIFO lot matching depends on. Messages without a parseable execution time keep
+// their arrival order after the timed ones.
+func (c *Consumer) batchOrder(batch []kafka.Message) []kafka.Message {
+	type entry struct {
+		msg        kafka.Message
+		executedAt time.Time
+	}
+
+	var symbols []string
+	groups := make(map[string][]entry)
+	for _, msg := range batch {
+		var event models.TradeEvent
+		var e entry
+		e.msg = msg
+
+		symbol := ""
+		if err := json.Unmarshal(msg.Value, &event); err == nil {
+			symbol = c.aliases.Canonical(event.Data.Symbol)
+			if event.Data.ExecutedAt != nil {
+				e.executedAt, _ = time.Parse(time.RFC3339, *event.Data.ExecutedAt)
+			}
+		}
+
+		if _, ok := groups[symbol]; !ok {
+			symbols = append(symbols, symbol)
+		}
+		groups[symbol] = append(groups[symbol], e)
+	}
+
+	ordered := make([]kafka.Message, 0, len(batch))
+	for _, symbol := range symbols {
+		entries := groups[symbol]
+		sort.SliceStable(entries, func(i, j int) bool {
+			a, b := entries[i].executedAt, entries[j].executedAt
+			if a.IsZero() || b.IsZero() {
+				return !a.IsZero() && b.IsZero()
+			}
+			return a.Before(b)
+		})
+		for _, e := range entries {
+			ordered = append(ordered, e.msg)
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,145 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockBatchReader serves queued messages via FetchMessage and records commits
+type mockBatchReader struct {
+	*mockPositionsReader
+
+	mu      sync.Mutex
+	commits [][]kafka.Message
+}
+
+func newMockBatchReader(msgs ...kafka.Message) *mockBatchReader {
+	r := &mockBatchReader{mockPositionsReader: newMockPositionsReader("trades-topic", len(msgs))}
+	for _, m := range msgs {
+		r.msgs <- m
+	}
+	return r
+}
+
+func (r *mockBatchReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	return r.ReadMessage(ctx)
+}
+
+func (r *mockBatchReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commits = append(r.commits, msgs)
+	return nil
+}
+
+func (r *mockBatchReader) Commits() [][]kafka.Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.commits
+}
+
+func batchTrade(partition int, offset int64, orderID, symbol, side, qty, price, executedAt string) kafka.Message {
+	return kafka.Message{
+		Partition: partition,
+		Offset:    offset,
+		Value: []byte(fmt.Sprintf(`{"event_type":"TRADE_DETECTED","source":"robinhood","data":{
+			"order_id":%q,"symbol":%q,"side":%q,"quantity":%q,"average_price":%q,
+			"fees":"0","state":"filled","executed_at":%q}}`, orderID, symbol, side, qty, price, executedAt)),
+	}
+}
+
+// TestConsumer_BatchModeOrdersBySymbolAndCommitsOnce verifies a batch spanning
+// symbols and partitions is applied in per-symbol execution order and committed once
+func TestConsumer_BatchModeOrdersBySymbolAndCommitsOnce(t *testing.T) {
+	// AAPL's sell (partition 1) arrives before its buy (partition 0)
+	reader := newMockBatchReader(
+		batchTrade(1, 40, "aapl-sell", "AAPL", "sell", "10", "120", "2026-03-02T16:00:00Z"),
+		batchTrade(0, 70, "msft-buy", "MSFT", "buy", "4", "400", "2026-03-02T14:45:00Z"),
+		batchTrade(0, 71, "aapl-buy", "AAPL", "buy", "10", "100", "2026-03-02T14:40:00Z"),
+		batchTrade(0, 72, "msft-sell", "MSFT", "sell", "4", "390", "2026-03-02T17:00:00Z"),
+	)
+	repo := NewMockRawTradeRepository()
+	history := &mockTradeHistoryRepo{raw: repo}
+	consumer := &Consumer{reader: reader, repo: repo}
+	consumer.SetCostBasisMode(CostBasisFIFO, history)
+	consumer.SetBatchSize(10, 50*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- consumer.Start(ctx) }()
+
+	require.Eventually(t, func() bool { return len(reader.Commits()) == 1 }, 2*time.Second, 10*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	commits := reader.Commits()
+	require.Len(t, commits, 1)
+	assert.Len(t, commits[0], 4, "the whole batch is committed together")
+
+	assert.Len(t, repo.rawTrades, 4)
+	require.Len(t, history.closed, 2)
+	assert.Equal(t, "AAPL", history.closed[0].Symbol)
+	assert.Equal(t, "200", history.closed[0].RealizedPnl.String())
+	assert.Equal(t, "MSFT", history.closed[1].Symbol)
+	assert.Equal(t, "-40", history.closed[1].RealizedPnl.String())
+}
+
+// TestConsumer_BatchModeStopsCommitsAtFailure verifies a failed message holds
+// back its partition's commit until a retry succeeds, while other partitions
+// commit as usual
+func TestConsumer_BatchModeStopsCommitsAtFailure(t *testing.T) {
+	reader := newMockBatchReader(
+		batchTrade(0, 10, "a-ok", "AAPL", "buy", "1", "100", "2026-03-02T14:00:00Z"),
+		batchTrade(0, 11, "m-fail", "MSFT", "buy", "1", "400", "2026-03-02T14:01:00Z"),
+		batchTrade(0, 12, "n-ok", "NVDA", "buy", "1", "900", "2026-03-02T14:02:00Z"),
+		batchTrade(1, 20, "t-ok", "TSLA", "buy", "1", "200", "2026-03-02T14:03:00Z"),
+	)
+	repo := &recoveringRawTradeRepo{repo: NewMockRawTradeRepository(), failures: map[string]int{"m-fail": 1}}
+	consumer := &Consumer{reader: reader, repo: repo}
+	consumer.SetBatchSize(10, 50*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- consumer.Start(ctx) }()
+
+	require.Eventually(t, func() bool { return len(reader.Commits()) == 2 }, 2*time.Second, 10*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	offsets := func(msgs []kafka.Message) []int64 {
+		var out []int64
+		for _, m := range msgs {
+			out = append(out, m.Offset)
+		}
+		return out
+	}
+	commits := reader.Commits()
+	assert.Equal(t, []int64{10, 20}, offsets(commits[0]), "nothing at or past the failed offset is committed")
+	assert.Equal(t, []int64{11, 12}, offsets(commits[1]), "the retry commits the rest of the partition")
+	assert.Equal(t, 4, repo.Count())
+}
+
+func TestConsumer_BatchOrder(t *testing.T) {
+	consumer := &Consumer{}
+	batch := []kafka.Message{
+		batchTrade(0, 1, "a2", "AAPL", "sell", "1", "1", "2026-03-02T16:00:00Z"),
+		batchTrade(0, 2, "m1", "MSFT", "buy", "1", "1", "2026-03-02T15:00:00Z"),
+		{Partition: 0, Offset: 3, Value: []byte(`not json`)},
+		batchTrade(1, 4, "a-untimed", "AAPL", "buy", "1", "1", ""),
+		batchTrade(1, 5, "a1", "AAPL", "buy", "1", "1", "2026-03-02T14:00:00Z"),
+	}
+
+	var offsets []int64
+	for _, m := range consumer.batchOrder(batch) {
+		offsets = append(offsets, m.Offset)
+	}
+	// AAPL first (oldest fill first, untimed last), then MSFT, then the unparseable message
+	assert.Equal(t, []int64{5, 1, 4, 2, 3}, offsets)
+}
@@ -0,0 +1,85 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// circuitBreaker pauses consumption after a run of consecutive processing
+// failures, so a downstream outage (e.g. the database being down) doesn't turn
+// into reading and discarding every message while flooding the logs.
+// A nil *circuitBreaker is valid and never trips.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// newCircuitBreaker returns nil (disabled) when threshold is not positive
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// recordFailure counts a failure and reports whether it tripped the breaker
+func (b *circuitBreaker) recordFailure() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.failures < b.threshold {
+		return false
+	}
+	b.failures = 0
+	b.openUntil = time.Now().Add(b.cooldown)
+	return true
+}
+
+// recordSuccess resets the consecutive failure count
+func (b *circuitBreaker) recordSuccess() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.failures = 0
+	b.mu.Unlock()
+}
+
+// isOpen reports whether consumption is currently paused
+func (b *circuitBreaker) isOpen() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return time.Now().Before(b.openUntil)
+}
+
+// wait blocks until the breaker closes or the context is cancelled
+func (b *circuitBreaker) wait(ctx context.Context) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	remaining := time.Until(b.openUntil)
+	b.mu.Unlock()
+	if remaining <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,209 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+// CombinedConsumer reads the trades and positions topics under one Start and
+// hands each message to the trades or positions consumer according to its event
+// type, so both feeds can run in a single process. The positions topic keeps its
+// own reader, since positions should only be read from the latest offset while
+// trades are read from the start of the topic.
+type CombinedConsumer struct {
+	reader          messageReader // trades topic
+	positionsReader messageReader // positions topic
+	trades          *Consumer
+	positions       *PositionsConsumer
+}
+
+// NewCombinedConsumer creates a consumer reading tradesTopic in groupID and
+// positionsTopic in the positions consumer's group. trades and positions keep
+// their settings but only handle messages from now on: their own readers are
+// closed, and they shouldn't be started separately. Offsets are committed once
+// a message is handled. Trade batching isn't supported; messages are handled one
+// at a time.
+func NewCombinedConsumer(brokers []string, tradesTopic, positionsTopic, groupID string, trades *Consumer, positions *PositionsConsumer) *CombinedConsumer {
+	maxBytes := trades.maxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxBytes
+	}
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     brokers,
+		Topic:       tradesTopic,
+		GroupID:     groupID,
+		MinBytes:    10e3, // 10KB
+		MaxBytes:    maxBytes,
+		MaxWait:     1 * time.Second,
+		StartOffset: kafka.FirstOffset,
+	})
+	positionsReader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     brokers,
+		Topic:       positionsTopic,
+		GroupID:     groupID + "-positions", // Same group as the standalone positions consumer
+		MinBytes:    10e3,                   // 10KB
+		MaxBytes:    10e6,                   // 10MB
+		MaxWait:     1 * time.Second,
+		StartOffset: kafka.LastOffset, // Only read new messages (not historical)
+	})
+
+	if trades.batchSize > 1 {
+		log.Printf("Warning: trade batching (batch size %d) is disabled in combined mode", trades.batchSize)
+	}
+
+	for _, r := range []messageReader{trades.reader, positions.reader} {
+		if r != nil {
+			if err := r.Close(); err != nil {
+				log.Printf("Error closing replaced Kafka reader: %v", err)
+			}
+		}
+	}
+
+	return &CombinedConsumer{
+		reader:          reader,
+		positionsReader: positionsReader,
+		trades:          trades,
+		positions:       positions,
+	}
+}
+
+// Start begins consuming messages from both topics and returns once ctx is
+// cancelled and both readers have stopped
+func (c *CombinedConsumer) Start(ctx context.Context) error {
+	var wg sync.WaitGroup
+	if c.positionsReader != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.run(ctx, c.positionsReader); err != nil {
+				log.Printf("Error closing positions reader: %v", err)
+			}
+		}()
+	}
+	err := c.run(ctx, c.reader)
+	wg.Wait()
+	return err
+}
+
+// run consumes r until ctx is cancelled
+func (c *CombinedConsumer) run(ctx context.Context, r messageReader) error {
+	log.Printf("Starting combined Kafka consumer for topic: %s", r.Config().Topic)
+
+	var failed *kafka.Message
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Combined consumer shutting down...")
+			return r.Close()
+		default:
+			// Trade failures still pause consumption through the trades breaker
+			c.trades.breaker.wait(ctx)
+			if ctx.Err() != nil {
+				continue
+			}
+
+			// A failed trade is retried before reading on, since committing a
+			// later offset would skip it
+			var msg kafka.Message
+			if failed != nil {
+				msg, failed = *failed, nil
+				pause(ctx, redeliverDelay)
+				if ctx.Err() != nil {
+					continue
+				}
+			} else {
+				var err error
+				msg, err = fetchFrom(ctx, r)
+				if err != nil {
+					if ctx.Err() != nil {
+						return nil // Context cancelled, normal shutdown
+					}
+					log.Printf("Error reading message: %v", err)
+					continue
+				}
+			}
+
+			if c.handle(ctx, msg) {
+				commitTo(ctx, r, msg)
+			} else {
+				failed = &msg
+			}
+		}
+	}
+}
+
+// handle dispatches msg by event type and reports whether it can be committed.
+// Positions events are committed even when they fail, as the positions consumer
+// does; trade messages follow the trades consumer's rules.
+func (c *CombinedConsumer) handle(ctx context.Context, msg kafka.Message) bool {
+	if isPositionsEvent(msg.Value) {
+		if err := c.positions.processMessage(msg); err != nil {
+			log.Printf("Error processing positions message: %v", err)
+		}
+		return true
+	}
+
+	if c.trades.maxBytes > 0 && len(msg.Value) > c.trades.maxBytes {
+		routeToDeadLetter(ctx, c.trades.dlq, msg, fmt.Errorf("%w: %d > %d", errMessageTooLarge, len(msg.Value), c.trades.maxBytes))
+		return true
+	}
+	if err := c.trades.processMessage(ctx, msg); err != nil {
+		return c.trades.processFailed(ctx, msg, err)
+	}
+	c.trades.breaker.recordSuccess()
+	return true
+}
+
+// isPositionsEvent reports whether value is a positions topic event. Anything
+// else, including unparseable payloads, is treated as a trade event.
+func isPositionsEvent(value []byte) bool {
+	var envelope struct {
+		EventType string `json:"event_type"`
+	}
+	if err := json.Unmarshal(value, &envelope); err != nil {
+		return false
+	}
+	switch envelope.EventType {
+	case models.PositionsEventSnapshot, models.PositionsEventUpdated, models.PositionsEventClosed:
+		return true
+	}
+	return false
+}
+
+// fetchFrom reads the next message from r without committing it when r supports
+// manual commits, falling back to ReadMessage otherwise
+func fetchFrom(ctx context.Context, r messageReader) (kafka.Message, error) {
+	if br, ok := r.(batchReader); ok {
+		return br.FetchMessage(ctx)
+	}
+	return r.ReadMessage(ctx)
+}
+
+// commitTo commits msg's offset when r supports manual commits
+func commitTo(ctx context.Context, r messageReader, msg kafka.Message) {
+	br, ok := r.(batchReader)
+	if !ok {
+		return
+	}
+	if err := br.CommitMessages(ctx, msg); err != nil {
+		log.Printf("Error committing message: %v", err)
+	}
+}
+
+// Close closes both readers
+func (c *CombinedConsumer) Close() error {
+	err := c.reader.Close()
+	if c.positionsReader != nil {
+		if perr := c.positionsReader.Close(); err == nil {
+			err = perr
+		}
+	}
+	return err
+}
@@ -0,0 +1,43 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+func TestCombinedConsumer_DispatchesByEventType(t *testing.T) {
+	trade := tradeMessage(t, "order-1")
+	trade.Topic, trade.Offset = "trading.orders", 1
+	snapshot := positionsSnapshot(t,
+		models.PositionData{Symbol: "MSFT", Quantity: "3", AverageBuyPrice: "400", Equity: "1260"},
+	)
+	snapshot.Topic, snapshot.Offset = "trading.positions", 1
+
+	reader := newMockBatchReader(trade)
+	positionsReader := newMockBatchReader(snapshot)
+	tradeRepo := &lockedRawTradeRepo{repo: NewMockRawTradeRepository()}
+	positionsRepo := &mockPositionsRepo{}
+	consumer := &CombinedConsumer{
+		reader:          reader,
+		positionsReader: positionsReader,
+		trades:          &Consumer{repo: tradeRepo},
+		positions:       &PositionsConsumer{repo: positionsRepo},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go consumer.Start(ctx)
+
+	require.Eventually(t, func() bool {
+		return len(reader.Commits()) == 1 && len(positionsReader.Commits()) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, tradeRepo.Count(), "trade event goes to the trades consumer")
+	assert.Equal(t, 1, positionsRepo.Calls(), "snapshot goes to the positions consumer")
+	require.Len(t, positionsRepo.LastPositions(), 1)
+	assert.Equal(t, "MSFT", positionsRepo.LastPositions()[0].Symbol)
+}
@@ -3,14 +3,15 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"strings"
 	"time"
 
 	"github.com/segmentio/kafka-go"
 	"github.com/shopspring/decimal"
 	"github.com/trogers1052/stock-alert-system/internal/models"
+	"github.com/trogers1052/stock-alert-system/internal/notify"
 )
 
 // RawTradeRepository defines the interface for raw trade database operations
@@ -19,69 +20,284 @@ type RawTradeRepository interface {
 	RawTradeExistsByOrderID(orderID, source string) (bool, error)
 }
 
-// Consumer handles consuming trade events from Kafka
-// Note: This consumer only stores raw trades for audit purposes.
-// Positions are managed separately via the PositionsConsumer which
-// receives position snapshots directly from Robinhood.
+// rawTradeUpdater is implemented by repositories that can rewrite a stored raw
+// trade, which reprocessing needs
+type rawTradeUpdater interface {
+	UpdateRawTradeByOrderID(t *models.RawTrade) error
+}
+
+// messageReader is a small interface wrapper around kafka.Reader to enable unit testing.
+type messageReader interface {
+	ReadMessage(ctx context.Context) (kafka.Message, error)
+	Close() error
+	Config() kafka.ReaderConfig
+}
+
+// Consumer handles consuming trade events from Kafka. It stores each trade as a
+// raw trade and, in FIFO cost basis mode, matches sells against open lots to
+// record closed trades. Positions are managed separately via the
+// PositionsConsumer which receives position snapshots directly from Robinhood.
 type Consumer struct {
-	reader *kafka.Reader
-	repo   RawTradeRepository
+	reader   messageReader
+	repo     RawTradeRepository
+	breaker  *circuitBreaker
+	retry    *retryPolicy
+	dlq      deadLetterWriter
+	maxBytes int
+
+	// batchSize > 1 fetches that many messages and commits them together;
+	// batchWait bounds how long a partial batch waits to fill
+	batchSize int
+	batchWait time.Duration
+
+	// notionalTolerance is the relative deviation allowed between a trade's
+	// reported total and quantity*price before the computed value is used
+	notionalTolerance decimal.Decimal
+
+	aliases models.SymbolAliases
+
+	// reprocessWindow lets a trade already stored be rewritten in place when it
+	// arrives again within this long of executing (0 skips every duplicate)
+	reprocessWindow time.Duration
+
+	// marketHours tags trades executed outside the regular session; they are
+	// skipped instead when rejectExtendedHours is set
+	marketHours         *models.MarketHours
+	rejectExtendedHours bool
+
+	// costBasis selects whether sells are matched against buy lots to record
+	// trade history; history and lots are only used in FIFO mode
+	costBasis CostBasisMode
+	history   TradeHistoryRepository
+	lots      *lotBook
+
+	// closeAlerts summarizes each sell that closes out a symbol's lots, recorded
+	// in alertRepo (nil disables)
+	alertRepo   AlertHistoryRepository
+	closeAlerts closeNotifier
 }
 
-// NewConsumer creates a new Kafka consumer for trade events
-func NewConsumer(brokers []string, topic, groupID string, repo RawTradeRepository) *Consumer {
+// NewConsumer creates a new Kafka consumer for trade events.
+// maxBytes caps the fetch size; zero or less uses the 10MB default.
+func NewConsumer(brokers []string, topic, groupID string, maxBytes int, repo RawTradeRepository) *Consumer {
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxBytes
+	}
 	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:        brokers,
-		Topic:          topic,
-		GroupID:        groupID,
-		MinBytes:       10e3, // 10KB
-		MaxBytes:       10e6, // 10MB
-		MaxWait:        1 * time.Second,
-		StartOffset:    kafka.FirstOffset,
-		CommitInterval: time.Second,
+		Brokers:     brokers,
+		Topic:       topic,
+		GroupID:     groupID,
+		MinBytes:    10e3, // 10KB
+		MaxBytes:    maxBytes,
+		MaxWait:     1 * time.Second,
+		StartOffset: kafka.FirstOffset,
+		// No CommitInterval: offsets are committed explicitly once a message
+		// has been stored or dead-lettered
 	})
 
 	return &Consumer{
-		reader: reader,
-		repo:   repo,
+		reader:   reader,
+		repo:     repo,
+		maxBytes: maxBytes,
 	}
 }
 
+// SetDeadLetterWriter routes oversized, truncated or unparseable messages to w
+// instead of dropping them
+func (c *Consumer) SetDeadLetterWriter(w deadLetterWriter) {
+	c.dlq = w
+}
+
+// SetNotionalTolerance sets the relative deviation (e.g. 0.01 for 1%) allowed between
+// a trade's total_notional and quantity*price. Zero or less disables the check.
+func (c *Consumer) SetNotionalTolerance(tolerance float64) {
+	c.notionalTolerance = decimal.NewFromFloat(tolerance)
+}
+
+// SetCostBasisMode enables lot tracking. In FIFO mode each sell is matched against
+// the oldest open buys and a trade history is recorded per lot consumed. Lots are
+// rebuilt from stored raw trades the first time a symbol is seen.
+func (c *Consumer) SetCostBasisMode(mode CostBasisMode, history TradeHistoryRepository) {
+	c.costBasis = mode
+	c.history = history
+	c.lots = newLotBook()
+}
+
+// SetFeeMode selects which fees FIFO lot matching deducts from realized P&L.
+// It has no effect unless SetCostBasisMode enabled lot tracking.
+func (c *Consumer) SetFeeMode(mode FeeMode) {
+	if c.lots != nil {
+		c.lots.feeMode = mode
+	}
+}
+
+// SetCloseNotifications records an alert in repo on channel summarizing each
+// sell that closes out a symbol's FIFO lots, and delivers it through d when d is
+// set, marking it sent in marker. An empty channel disables it.
+func (c *Consumer) SetCloseNotifications(channel string, repo AlertHistoryRepository, d *notify.Dispatcher, marker notify.SentMarker) {
+	c.alertRepo = repo
+	c.closeAlerts = closeNotifier{channel: channel, notifiers: d, marker: marker}
+}
+
+// SetMarketHours flags trades executed outside hours as extended-hours trades.
+// When reject is true those trades are logged and not stored.
+func (c *Consumer) SetMarketHours(hours *models.MarketHours, reject bool) {
+	c.marketHours = hours
+	c.rejectExtendedHours = reject
+}
+
+// SetSymbolAliases canonicalizes incoming trade symbols using aliases
+func (c *Consumer) SetSymbolAliases(aliases models.SymbolAliases) {
+	c.aliases = aliases
+}
+
+// SetCircuitBreaker pauses consumption for cooldown after threshold consecutive
+// processing failures. A threshold of zero or less disables the breaker.
+func (c *Consumer) SetCircuitBreaker(threshold int, cooldown time.Duration) {
+	c.breaker = newCircuitBreaker(threshold, cooldown)
+}
+
+// SetRetry retries database calls that fail with a transient error (e.g. a reset
+// connection) up to attempts times in total, waiting baseDelay before the first
+// retry and doubling it each time. Attempts of 1 or less disables retries.
+func (c *Consumer) SetRetry(attempts int, baseDelay time.Duration) {
+	c.retry = newRetryPolicy(attempts, baseDelay)
+}
+
+// SetReprocessWindow lets replays correct recent trades: a trade whose order is
+// already stored and that executed within window is updated in place rather than
+// skipped, so no duplicate raw row is created. Zero or less skips every duplicate.
+func (c *Consumer) SetReprocessWindow(window time.Duration) {
+	c.reprocessWindow = window
+}
+
 // Start begins consuming messages from Kafka
 func (c *Consumer) Start(ctx context.Context) error {
+	if r, ok := c.reader.(batchReader); ok && c.batchSize > 1 {
+		return c.startBatches(ctx, r)
+	}
+
 	log.Printf("Starting Kafka consumer for topic: %s", c.reader.Config().Topic)
 
+	var failed *kafka.Message
 	for {
 		select {
 		case <-ctx.Done():
 			log.Println("Kafka consumer shutting down...")
 			return c.reader.Close()
 		default:
-			msg, err := c.reader.ReadMessage(ctx)
-			if err != nil {
+			// Block while the breaker is open; returns early on shutdown
+			c.breaker.wait(ctx)
+			if ctx.Err() != nil {
+				continue
+			}
+
+			// A message that failed is retried before anything after it is read:
+			// commits are cumulative, so committing a later offset would skip it
+			var msg kafka.Message
+			if failed != nil {
+				msg, failed = *failed, nil
+				pause(ctx, redeliverDelay)
 				if ctx.Err() != nil {
-					return nil // Context cancelled, normal shutdown
+					continue
 				}
-				log.Printf("Error reading message: %v", err)
+			} else {
+				var err error
+				msg, err = c.fetch(ctx)
+				if err != nil {
+					if ctx.Err() != nil {
+						return nil // Context cancelled, normal shutdown
+					}
+					if isOversizedReadError(err) {
+						// The payload is unavailable, so route what we know about it
+						routeToDeadLetter(ctx, c.dlq, kafka.Message{Topic: c.reader.Config().Topic}, err)
+						continue
+					}
+					log.Printf("Error reading message: %v", err)
+					continue
+				}
+			}
+
+			if c.maxBytes > 0 && len(msg.Value) > c.maxBytes {
+				routeToDeadLetter(ctx, c.dlq, msg, fmt.Errorf("%w: %d > %d", errMessageTooLarge, len(msg.Value), c.maxBytes))
+				c.commit(ctx, msg)
 				continue
 			}
 
-			if err := c.processMessage(msg); err != nil {
-				log.Printf("Error processing message: %v", err)
-				// Continue processing other messages
+			if err := c.processMessage(ctx, msg); err != nil {
+				if c.processFailed(ctx, msg, err) {
+					c.commit(ctx, msg)
+				} else {
+					failed = &msg
+				}
+				continue
 			}
+			c.commit(ctx, msg)
+			c.breaker.recordSuccess()
 		}
 	}
 }
 
-// processMessage handles a single Kafka message
-func (c *Consumer) processMessage(msg kafka.Message) error {
-	log.Printf("Received message from partition %d offset %d: key=%s",
-		msg.Partition, msg.Offset, string(msg.Key))
+// fetch reads the next message without committing it when the reader supports
+// manual commits, falling back to ReadMessage otherwise
+func (c *Consumer) fetch(ctx context.Context) (kafka.Message, error) {
+	if r, ok := c.reader.(batchReader); ok {
+		return r.FetchMessage(ctx)
+	}
+	return c.reader.ReadMessage(ctx)
+}
+
+// commit commits msgs' offsets when the reader supports manual commits
+func (c *Consumer) commit(ctx context.Context, msgs ...kafka.Message) {
+	r, ok := c.reader.(batchReader)
+	if !ok || len(msgs) == 0 {
+		return
+	}
+	if err := r.CommitMessages(ctx, msgs...); err != nil {
+		log.Printf("Error committing %d messages: %v", len(msgs), err)
+	}
+}
+
+// redeliverDelay spaces out retries of a message that failed to process
+const redeliverDelay = 250 * time.Millisecond
+
+// pause waits for d or until ctx is cancelled
+func pause(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// processFailed handles a message processMessage rejected and reports whether it
+// was dealt with and can be committed. Malformed messages are dead-lettered and
+// don't count toward the circuit breaker, since pausing won't fix them; other
+// failures do and are left uncommitted for the caller to retry before reading on.
+func (c *Consumer) processFailed(ctx context.Context, msg kafka.Message, err error) bool {
+	if errors.Is(err, errMalformedMessage) {
+		routeMalformed(ctx, c.dlq, msg, err)
+		return true
+	}
+	log.Printf("Error processing message: %v", err)
+	if c.breaker.recordFailure() {
+		log.Printf("Kafka consumer paused for %s after %d consecutive failures",
+			c.breaker.cooldown, c.breaker.threshold)
+	}
+	return false
+}
+
+// processMessage handles a single Kafka message. Database calls are retried on
+// transient errors until ctx is cancelled.
+func (c *Consumer) processMessage(ctx context.Context, msg kafka.Message) error {
+	lag := recordLag(msg)
+	log.Printf("Received message from partition %d offset %d: key=%s (lag: %s)",
+		msg.Partition, msg.Offset, string(msg.Key), lag.Round(time.Millisecond))
 
 	var event models.TradeEvent
 	if err := json.Unmarshal(msg.Value, &event); err != nil {
-		return fmt.Errorf("failed to unmarshal trade event: %w", err)
+		return fmt.Errorf("%w: failed to unmarshal: %w", errMalformedMessage, err)
 	}
 
 	// Only process TRADE_DETECTED events
@@ -91,11 +307,15 @@ func (c *Consumer) processMessage(msg kafka.Message) error {
 	}
 
 	// Check for duplicate (idempotency)
-	exists, err := c.repo.RawTradeExistsByOrderID(event.Data.OrderID, event.Source)
+	var exists bool
+	err := c.retry.do(ctx, "duplicate check", func() (err error) {
+		exists, err = c.repo.RawTradeExistsByOrderID(event.Data.OrderID, event.Source)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to check for duplicate trade: %w", err)
 	}
-	if exists {
+	if exists && c.reprocessWindow <= 0 {
 		log.Printf("Trade %s from %s already exists, skipping", event.Data.OrderID, event.Source)
 		return nil
 	}
@@ -103,20 +323,175 @@ func (c *Consumer) processMessage(msg kafka.Message) error {
 	// Convert event to RawTrade
 	rawTrade, err := c.convertEventToRawTrade(event)
 	if err != nil {
-		return fmt.Errorf("failed to convert event to raw trade: %w", err)
+		return fmt.Errorf("%w: failed to convert to raw trade: %w", errMalformedMessage, err)
 	}
 
-	// Save raw trade to database (audit trail only - positions come from Robinhood snapshots)
-	if err := c.repo.CreateRawTrade(rawTrade); err != nil {
+	if c.marketHours != nil && !c.marketHours.IsRegularHours(rawTrade.ExecutedAt) {
+		if c.rejectExtendedHours {
+			log.Printf("Rejecting extended-hours trade %s: %s %s at %s",
+				rawTrade.OrderID, rawTrade.Side, rawTrade.Symbol, rawTrade.ExecutedAt.Format(time.RFC3339))
+			return nil
+		}
+		rawTrade.ExtendedHours = true
+	}
+
+	if exists {
+		return c.reprocess(ctx, rawTrade)
+	}
+
+	// Rebuild lots from earlier trades before this one is stored
+	if c.costBasis == CostBasisFIFO {
+		err := c.retry.do(ctx, "lot rebuild", func() error {
+			return c.lots.seed(rawTrade.Symbol, c.history)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Sells are stored together with the lots they close
+	if c.costBasis == CostBasisFIFO && rawTrade.Side == models.TradeTypeSell {
+		return c.recordClosedLots(ctx, rawTrade)
+	}
+
+	// Save raw trade to database (positions come from Robinhood snapshots)
+	err = c.retry.do(ctx, "raw trade insert", func() error {
+		return c.repo.CreateRawTrade(rawTrade)
+	})
+	if err != nil {
 		return fmt.Errorf("failed to save raw trade: %w", err)
 	}
 
 	log.Printf("Saved raw trade: %s %s %s @ %s (order_id: %s)",
 		rawTrade.Side, rawTrade.Quantity, rawTrade.Symbol, rawTrade.Price, rawTrade.OrderID)
 
+	if c.costBasis == CostBasisFIFO {
+		c.lots.apply(rawTrade)
+	}
+
+	return nil
+}
+
+// reprocess rewrites an already stored trade in place when it executed within the
+// reprocessing window, and skips it otherwise. In FIFO mode the symbol's lots are
+// dropped so they're rebuilt from the corrected ledger on its next trade; closes
+// already recorded are left as they are.
+func (c *Consumer) reprocess(ctx context.Context, t *models.RawTrade) error {
+	if time.Since(t.ExecutedAt) > c.reprocessWindow {
+		log.Printf("Trade %s from %s already exists, skipping", t.OrderID, t.Source)
+		return nil
+	}
+	updater, ok := c.repo.(rawTradeUpdater)
+	if !ok {
+		log.Printf("Trade %s from %s already exists and can't be updated, skipping", t.OrderID, t.Source)
+		return nil
+	}
+
+	err := c.retry.do(ctx, "raw trade update", func() error {
+		return updater.UpdateRawTradeByOrderID(t)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reprocess raw trade: %w", err)
+	}
+	log.Printf("Reprocessed raw trade: %s %s %s @ %s (order_id: %s)",
+		t.Side, t.Quantity, t.Symbol, t.Price, t.OrderID)
+
+	if c.costBasis == CostBasisFIFO {
+		c.lots.reset(t.Symbol)
+	}
+	return nil
+}
+
+// recordClosedLots matches a sell against the lot book and stores it together
+// with a trade history for each lot it closed, linked to the sell and the lot's
+// buy, in one transaction: if that fails
+// nothing is stored, so the redelivered sell is matched again rather than skipped
+// as a duplicate. The symbol's lots are dropped on failure, so they're rebuilt
+// from the ledger, and the error is returned. When shares are still held
+// afterwards, the sell's P&L is added to the position's realized total;
+// otherwise the close is summarized when close notifications are enabled.
+func (c *Consumer) recordClosedLots(ctx context.Context, t *models.RawTrade) error {
+	closes := c.lots.apply(t)
+	var linked int64
+	err := c.retry.do(ctx, "raw trade insert", func() (err error) {
+		linked, err = c.history.CreateRawTradeWithCloses(t, closes)
+		return err
+	})
+	if err != nil {
+		c.lots.reset(t.Symbol)
+		return fmt.Errorf("failed to save sell and closed lots for %s: %w", t.Symbol, err)
+	}
+
+	log.Printf("Saved raw trade: %s %s %s @ %s (order_id: %s)",
+		t.Side, t.Quantity, t.Symbol, t.Price, t.OrderID)
+
+	if expected := expectedLinks(closes); linked != expected {
+		log.Printf("Warning: linked %d raw trades to the closes of sell %s, expected %d",
+			linked, t.OrderID, expected)
+	}
+
+	var realized decimal.Decimal
+	for _, closed := range closes {
+		trade := closed.Trade
+		realized = realized.Add(trade.RealizedPnl)
+		log.Printf("Closed lot: %s %s shares held %dh (P&L: $%s)",
+			trade.Symbol, trade.Quantity, *trade.HoldingPeriodHours, trade.RealizedPnl.StringFixed(2))
+	}
+
+	if len(closes) == 0 {
+		return nil
+	}
+	if c.lots.held(t.Symbol) {
+		if err := c.history.AddRealizedPnl(t.Symbol, realized); err != nil {
+			log.Printf("Warning: failed to add realized P&L for %s: %v", t.Symbol, err)
+		}
+	} else if c.alertRepo != nil {
+		c.closeAlerts.notify(c.alertRepo, fullClose(t, closes))
+	}
 	return nil
 }
 
+// fullClose sums the lots sell t closed into one trade for the close summary,
+// held since the oldest lot's entry
+func fullClose(t *models.RawTrade, closes []*models.ClosedLot) *models.TradeHistory {
+	var quantity, pnl, cost decimal.Decimal
+	for _, closed := range closes {
+		quantity = quantity.Add(closed.Trade.Quantity)
+		pnl = pnl.Add(closed.Trade.RealizedPnl)
+		// Entry cost is what's left of the proceeds after P&L and fees
+		cost = cost.Add(closed.Trade.TotalCost.Sub(closed.Trade.RealizedPnl).Sub(closed.Trade.Fee))
+	}
+
+	var pnlPct decimal.Decimal
+	if !cost.IsZero() {
+		pnlPct = pnl.Div(cost).Mul(decimal.NewFromInt(100)).Round(4)
+	}
+
+	return &models.TradeHistory{
+		Symbol:             t.Symbol,
+		TradeType:          models.TradeTypeSell,
+		Quantity:           quantity,
+		Price:              t.Price,
+		HoldingPeriodHours: closes[0].Trade.HoldingPeriodHours,
+		RealizedPnl:        pnl,
+		RealizedPnlPct:     pnlPct,
+		ExecutedAt:         t.ExecutedAt,
+	}
+}
+
+// expectedLinks counts the raw trades a sell's closes should be linked to: the
+// sell once per close, plus each close's buy when known
+func expectedLinks(closes []*models.ClosedLot) int64 {
+	var n int64
+	for _, closed := range closes {
+		n++
+		if closed.BuyTradeID != 0 {
+			n++
+		}
+	}
+	return n
+}
+
 // convertEventToRawTrade maps a TradeEvent to a RawTrade model
 func (c *Consumer) convertEventToRawTrade(event models.TradeEvent) (*models.RawTrade, error) {
 	data := event.Data
@@ -134,10 +509,15 @@ func (c *Consumer) convertEventToRawTrade(event models.TradeEvent) (*models.RawT
 	}
 
 	// Parse total cost
+	computed := quantity.Mul(price)
 	totalCost, err := decimal.NewFromString(data.TotalNotional)
 	if err != nil {
 		// Fall back to quantity * price
-		totalCost = quantity.Mul(price)
+		totalCost = computed
+	} else if c.notionalMismatch(totalCost, computed) {
+		log.Printf("Warning: order %s total_notional %s disagrees with quantity*price %s, using computed value",
+			data.OrderID, totalCost, computed)
+		totalCost = computed
 	}
 
 	// Parse fees
@@ -146,9 +526,9 @@ func (c *Consumer) convertEventToRawTrade(event models.TradeEvent) (*models.RawT
 		fees, _ = decimal.NewFromString(data.Fees)
 	}
 
-	// Convert side to uppercase
-	side := strings.ToUpper(data.Side)
-	if side != models.TradeTypeBuy && side != models.TradeTypeSell {
+	// Normalize side so "buy", "Sell" and " BUY " are all accepted
+	side := models.NormalizeTradeSide(data.Side)
+	if !models.IsValidTradeSide(side) {
 		return nil, fmt.Errorf("invalid trade side: %s", data.Side)
 	}
 
@@ -170,7 +550,7 @@ func (c *Consumer) convertEventToRawTrade(event models.TradeEvent) (*models.RawT
 	return &models.RawTrade{
 		OrderID:    data.OrderID,
 		Source:     event.Source,
-		Symbol:     data.Symbol,
+		Symbol:     c.aliases.Canonical(data.Symbol),
 		Side:       side,
 		Quantity:   quantity,
 		Price:      price,
@@ -180,6 +560,15 @@ func (c *Consumer) convertEventToRawTrade(event models.TradeEvent) (*models.RawT
 	}, nil
 }
 
+// notionalMismatch reports whether reported deviates from computed by more than the tolerance
+func (c *Consumer) notionalMismatch(reported, computed decimal.Decimal) bool {
+	if !c.notionalTolerance.IsPositive() || computed.IsZero() {
+		return false
+	}
+	deviation := reported.Sub(computed).Abs().Div(computed.Abs())
+	return deviation.GreaterThan(c.notionalTolerance)
+}
+
 // Close closes the Kafka consumer
 func (c *Consumer) Close() error {
 	return c.reader.Close()
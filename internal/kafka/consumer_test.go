@@ -1,9 +1,15 @@
 package kafka
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/segmentio/kafka-go"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -37,6 +43,17 @@ func (m *MockRawTradeRepository) RawTradeExistsByOrderID(orderID, source string)
 	return exists, nil
 }
 
+func (m *MockRawTradeRepository) UpdateRawTradeByOrderID(t *models.RawTrade) error {
+	key := t.OrderID + ":" + t.Source
+	stored, exists := m.rawTrades[key]
+	if !exists {
+		return fmt.Errorf("raw trade not found for order: %s", t.OrderID)
+	}
+	t.ID = stored.ID
+	m.rawTrades[key] = t
+	return nil
+}
+
 // Helper function to create a RawTrade for testing
 func createTestRawTrade(orderID, symbol, side string, qty, price float64, executedAt time.Time) *models.RawTrade {
 	return &models.RawTrade{
@@ -140,6 +157,45 @@ func TestConvertEventToRawTrade(t *testing.T) {
 	assert.True(t, rawTrade.Price.Equal(decimal.NewFromFloat(150.25)))
 }
 
+// TestConvertEventToRawTrade_MismatchedNotional verifies a total that disagrees with
+// quantity*price beyond the tolerance is replaced by the computed value
+func TestConvertEventToRawTrade_MismatchedNotional(t *testing.T) {
+	consumer := &Consumer{repo: NewMockRawTradeRepository()}
+	consumer.SetNotionalTolerance(0.01)
+
+	tests := []struct {
+		name     string
+		notional string
+		expected string
+	}{
+		{"off by 10x", "15000", "1500"},
+		{"within tolerance", "1505", "1505"},
+		{"missing", "", "1500"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := models.TradeEvent{
+				EventType: "TRADE_DETECTED",
+				Source:    "robinhood",
+				Data: models.TradeEventData{
+					OrderID:       "test-order-123",
+					Symbol:        "AAPL",
+					Side:          "buy",
+					Quantity:      "10",
+					AveragePrice:  "150",
+					TotalNotional: tt.notional,
+				},
+			}
+
+			rawTrade, err := consumer.convertEventToRawTrade(event)
+			require.NoError(t, err)
+			assert.True(t, rawTrade.TotalCost.Equal(decimal.RequireFromString(tt.expected)),
+				"expected %s, got %s", tt.expected, rawTrade.TotalCost)
+		})
+	}
+}
+
 // TestConvertEventToRawTrade_InvalidSide verifies invalid side is rejected
 func TestConvertEventToRawTrade_InvalidSide(t *testing.T) {
 	repo := NewMockRawTradeRepository()
@@ -161,3 +217,449 @@ func TestConvertEventToRawTrade_InvalidSide(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "invalid trade side")
 }
+
+// TestConvertEventToRawTrade_NormalizesSide verifies padded and mixed-case sides are accepted
+func TestConvertEventToRawTrade_NormalizesSide(t *testing.T) {
+	consumer := &Consumer{repo: NewMockRawTradeRepository()}
+
+	tests := []struct {
+		side     string
+		expected string
+	}{
+		{"buy", models.TradeTypeBuy},
+		{"Buy", models.TradeTypeBuy},
+		{" BUY ", models.TradeTypeBuy},
+		{"sell", models.TradeTypeSell},
+		{"\tSeLL\n", models.TradeTypeSell},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.side, func(t *testing.T) {
+			event := models.TradeEvent{
+				EventType: "TRADE_DETECTED",
+				Source:    "robinhood",
+				Data: models.TradeEventData{
+					OrderID:      "test-order-123",
+					Symbol:       "AAPL",
+					Side:         tt.side,
+					Quantity:     "10",
+					AveragePrice: "150",
+				},
+			}
+
+			rawTrade, err := consumer.convertEventToRawTrade(event)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, rawTrade.Side)
+		})
+	}
+}
+
+// TestProcessMessage_SchemaVersions verifies v1 (including unversioned) and v2
+// payloads produce the same raw trade
+func TestProcessMessage_SchemaVersions(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+	}{
+		{"unversioned", `{"event_type":"TRADE_DETECTED","source":"robinhood","data":{
+			"order_id":"schema-order","symbol":"AAPL","side":"buy","quantity":"10","average_price":"150.25",
+			"total_notional":"1502.5","fees":"0.5","state":"filled","executed_at":"2026-01-18T10:30:00Z"}}`},
+		{"v1", `{"event_type":"TRADE_DETECTED","source":"robinhood","schema_version":1,"data":{
+			"order_id":"schema-order","symbol":"AAPL","side":"buy","quantity":"10","average_price":"150.25",
+			"total_notional":"1502.5","fees":"0.5","state":"filled","executed_at":"2026-01-18T10:30:00Z"}}`},
+		{"v2", `{"event_type":"TRADE_DETECTED","source":"robinhood","schema_version":2,"data":{
+			"order_id":"schema-order","symbol":"AAPL","side":"buy","filled_quantity":10,"average_fill_price":150.25,
+			"notional":1502.5,"fees":0.5,"state":"filled","executed_at":"2026-01-18T10:30:00Z"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewMockRawTradeRepository()
+			consumer := &Consumer{repo: repo}
+
+			require.NoError(t, consumer.processMessage(context.Background(), kafka.Message{Value: []byte(tt.payload)}))
+
+			trade := repo.rawTrades["schema-order:robinhood"]
+			require.NotNil(t, trade)
+			assert.Equal(t, "AAPL", trade.Symbol)
+			assert.Equal(t, models.TradeTypeBuy, trade.Side)
+			assert.True(t, decimal.NewFromInt(10).Equal(trade.Quantity))
+			assert.True(t, decimal.RequireFromString("150.25").Equal(trade.Price))
+			assert.True(t, decimal.RequireFromString("1502.5").Equal(trade.TotalCost))
+			assert.True(t, decimal.RequireFromString("0.5").Equal(trade.Fees))
+			assert.True(t, time.Date(2026, 1, 18, 10, 30, 0, 0, time.UTC).Equal(trade.ExecutedAt))
+		})
+	}
+}
+
+// TestProcessMessage_UnsupportedSchemaVersion verifies unknown versions fail rather than misparse
+func TestProcessMessage_UnsupportedSchemaVersion(t *testing.T) {
+	repo := NewMockRawTradeRepository()
+	consumer := &Consumer{repo: repo}
+
+	payload := `{"event_type":"TRADE_DETECTED","source":"robinhood","schema_version":9,"data":{"order_id":"x"}}`
+	err := consumer.processMessage(context.Background(), kafka.Message{Value: []byte(payload)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported trade event schema_version 9")
+	assert.Empty(t, repo.rawTrades)
+}
+
+// TestConvertEventToRawTrade_CanonicalizesAliasedSymbol verifies configured aliases are applied
+func TestConvertEventToRawTrade_CanonicalizesAliasedSymbol(t *testing.T) {
+	consumer := &Consumer{repo: NewMockRawTradeRepository()}
+	consumer.SetSymbolAliases(models.SymbolAliases{"BRK.B": "BRK-B", "BRK/B": "BRK-B"})
+
+	for _, symbol := range []string{"BRK.B", "brk/b", "BRK-B"} {
+		event := models.TradeEvent{
+			EventType: "TRADE_DETECTED",
+			Source:    "robinhood",
+			Data: models.TradeEventData{
+				OrderID:      "alias-order",
+				Symbol:       symbol,
+				Side:         "buy",
+				Quantity:     "1",
+				AveragePrice: "450",
+			},
+		}
+
+		rawTrade, err := consumer.convertEventToRawTrade(event)
+		require.NoError(t, err)
+		assert.Equal(t, "BRK-B", rawTrade.Symbol, "symbol %s", symbol)
+	}
+}
+
+// TestProcessMessage_RecordsConsumerLag verifies the lag gauge reflects the message timestamp
+func TestProcessMessage_RecordsConsumerLag(t *testing.T) {
+	repo := NewMockRawTradeRepository()
+	consumer := &Consumer{repo: repo}
+
+	event := models.TradeEvent{
+		EventType: "TRADE_DETECTED",
+		Source:    "robinhood",
+		Data: models.TradeEventData{
+			OrderID:      "lag-order-1",
+			Symbol:       "AAPL",
+			Side:         "buy",
+			Quantity:     "1",
+			AveragePrice: "100",
+		},
+	}
+	payload, err := json.Marshal(event)
+	require.NoError(t, err)
+
+	msg := kafka.Message{
+		Topic: "lag-test-topic",
+		Value: payload,
+		Time:  time.Now().Add(-5 * time.Minute),
+	}
+	require.NoError(t, consumer.processMessage(context.Background(), msg))
+
+	lag := ConsumerLag("lag-test-topic")
+	assert.GreaterOrEqual(t, lag, 5*time.Minute)
+	assert.Less(t, lag, 6*time.Minute)
+}
+
+// failingRawTradeRepo fails every lookup, simulating a database outage
+type failingRawTradeRepo struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (r *failingRawTradeRepo) CreateRawTrade(t *models.RawTrade) error {
+	return errors.New("database unavailable")
+}
+
+func (r *failingRawTradeRepo) RawTradeExistsByOrderID(orderID, source string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	return false, errors.New("database unavailable")
+}
+
+func (r *failingRawTradeRepo) Calls() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls
+}
+
+func tradeMessage(t *testing.T, orderID string) kafka.Message {
+	t.Helper()
+	payload, err := json.Marshal(models.TradeEvent{
+		EventType: "TRADE_DETECTED",
+		Source:    "robinhood",
+		Data: models.TradeEventData{
+			OrderID:      orderID,
+			Symbol:       "AAPL",
+			Side:         "buy",
+			Quantity:     "1",
+			AveragePrice: "100",
+		},
+	})
+	require.NoError(t, err)
+	return kafka.Message{Value: payload}
+}
+
+// TestConsumer_CircuitBreakerPausesAfterConsecutiveFailures verifies consumption stops once the breaker trips
+func TestConsumer_CircuitBreakerPausesAfterConsecutiveFailures(t *testing.T) {
+	repo := &failingRawTradeRepo{}
+	reader := newMockPositionsReader("trades-topic", 5)
+	consumer := &Consumer{reader: reader, repo: repo}
+	consumer.SetCircuitBreaker(3, time.Hour)
+
+	for i := 0; i < 5; i++ {
+		reader.msgs <- tradeMessage(t, fmt.Sprintf("order-%d", i))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- consumer.Start(ctx)
+	}()
+
+	require.Eventually(t, func() bool { return repo.Calls() == 3 }, 2*time.Second, 10*time.Millisecond)
+
+	// Give the consumer a chance to (incorrectly) keep reading
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 3, repo.Calls())
+	// The failing message is retried rather than skipped, so nothing after it is read
+	assert.Len(t, reader.msgs, 4, "remaining messages should not be consumed while paused")
+	assert.True(t, consumer.breaker.isOpen())
+
+	// Shutdown must not wait for the cooldown
+	cancel()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for consumer to shut down while paused")
+	}
+}
+
+// TestConsumer_CircuitBreakerResumesAfterCooldown verifies consumption continues once the cooldown elapses
+func TestConsumer_CircuitBreakerResumesAfterCooldown(t *testing.T) {
+	repo := &recoveringRawTradeRepo{repo: NewMockRawTradeRepository(), failures: map[string]int{"order-0": 3}}
+	reader := newMockPositionsReader("trades-topic", 5)
+	consumer := &Consumer{reader: reader, repo: repo}
+	consumer.SetCircuitBreaker(3, 50*time.Millisecond)
+
+	for i := 0; i < 5; i++ {
+		reader.msgs <- tradeMessage(t, fmt.Sprintf("order-%d", i))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go consumer.Start(ctx)
+
+	require.Eventually(t, func() bool { return repo.Count() == 5 }, 3*time.Second, 10*time.Millisecond)
+}
+
+// recoveringRawTradeRepo fails the duplicate check for an order a set number of times
+// before it starts succeeding
+type recoveringRawTradeRepo struct {
+	mu       sync.Mutex
+	repo     *MockRawTradeRepository
+	failures map[string]int // remaining failures by order ID
+}
+
+func (r *recoveringRawTradeRepo) CreateRawTrade(t *models.RawTrade) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.repo.CreateRawTrade(t)
+}
+
+func (r *recoveringRawTradeRepo) RawTradeExistsByOrderID(orderID, source string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failures[orderID] > 0 {
+		r.failures[orderID]--
+		return false, errors.New("database unavailable")
+	}
+	return r.repo.RawTradeExistsByOrderID(orderID, source)
+}
+
+func (r *recoveringRawTradeRepo) Count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.repo.rawTrades)
+}
+
+// TestConsumer_CommitsOnlyHandledMessages verifies offsets are committed after a
+// message is stored or dead-lettered, and not when processing failed
+func TestConsumer_CommitsOnlyHandledMessages(t *testing.T) {
+	t.Run("stored", func(t *testing.T) {
+		stored := tradeMessage(t, "order-ok")
+		stored.Offset = 1
+		reader := newMockBatchReader(stored)
+		repo := &lockedRawTradeRepo{repo: NewMockRawTradeRepository()}
+		consumer := &Consumer{reader: reader, repo: repo}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go consumer.Start(ctx)
+
+		require.Eventually(t, func() bool { return len(reader.Commits()) == 1 }, 2*time.Second, 10*time.Millisecond)
+		assert.Equal(t, 1, repo.Count())
+		assert.Equal(t, int64(1), reader.Commits()[0][0].Offset)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		failed := tradeMessage(t, "order-db-down")
+		failed.Offset = 1
+		malformed := kafka.Message{Offset: 2, Value: []byte(`not json`)}
+		reader := newMockBatchReader(failed, malformed)
+		repo := &failingRawTradeRepo{}
+		consumer := &Consumer{reader: reader, repo: repo}
+		consumer.SetDeadLetterWriter(&mockDeadLetterWriter{})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go consumer.Start(ctx)
+
+		// The failed message is retried, and nothing past it is read or committed,
+		// since committing offset 2 would also mark offset 1 as consumed
+		require.Eventually(t, func() bool { return repo.Calls() >= 2 }, 2*time.Second, 10*time.Millisecond)
+		assert.Empty(t, reader.Commits())
+		assert.Len(t, reader.msgs, 1)
+	})
+
+	t.Run("recovered", func(t *testing.T) {
+		failed := tradeMessage(t, "order-db-down")
+		failed.Offset = 1
+		next := tradeMessage(t, "order-next")
+		next.Offset = 2
+		reader := newMockBatchReader(failed, next)
+		repo := &recoveringRawTradeRepo{repo: NewMockRawTradeRepository(), failures: map[string]int{"order-db-down": 1}}
+		consumer := &Consumer{reader: reader, repo: repo}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go consumer.Start(ctx)
+
+		require.Eventually(t, func() bool { return len(reader.Commits()) == 2 }, 2*time.Second, 10*time.Millisecond)
+		assert.Equal(t, 2, repo.Count())
+		commits := reader.Commits()
+		assert.Equal(t, int64(1), commits[0][0].Offset)
+		assert.Equal(t, int64(2), commits[1][0].Offset)
+	})
+}
+
+// TestProcessMessage_FlagsExtendedHoursTrades verifies trades outside the session
+// are tagged, or dropped when rejection is enabled
+func TestProcessMessage_FlagsExtendedHoursTrades(t *testing.T) {
+	hours, err := models.NewMarketHours("America/New_York", "09:30", "16:00")
+	require.NoError(t, err)
+
+	// Tuesday in January (EST, UTC-5): 7am and 10am ET
+	payload := func(orderID, executedAt string) kafka.Message {
+		return kafka.Message{Value: []byte(`{"event_type":"TRADE_DETECTED","source":"robinhood","data":{
+			"order_id":"` + orderID + `","symbol":"AAPL","side":"buy","quantity":"1","average_price":"150",
+			"total_notional":"150","fees":"0","state":"filled","executed_at":"` + executedAt + `"}}`)}
+	}
+	premarket := payload("pre", "2026-01-20T12:00:00Z")
+	regular := payload("reg", "2026-01-20T15:00:00Z")
+
+	t.Run("flag", func(t *testing.T) {
+		repo := NewMockRawTradeRepository()
+		consumer := &Consumer{repo: repo}
+		consumer.SetMarketHours(hours, false)
+
+		require.NoError(t, consumer.processMessage(context.Background(), premarket))
+		require.NoError(t, consumer.processMessage(context.Background(), regular))
+
+		require.Len(t, repo.rawTrades, 2)
+		assert.True(t, repo.rawTrades["pre:robinhood"].ExtendedHours)
+		assert.False(t, repo.rawTrades["reg:robinhood"].ExtendedHours)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		repo := NewMockRawTradeRepository()
+		consumer := &Consumer{repo: repo}
+		consumer.SetMarketHours(hours, true)
+
+		require.NoError(t, consumer.processMessage(context.Background(), premarket))
+		require.NoError(t, consumer.processMessage(context.Background(), regular))
+
+		require.Len(t, repo.rawTrades, 1)
+		assert.Contains(t, repo.rawTrades, "reg:robinhood")
+	})
+}
+
+// buyMessage builds a filled AAPL buy for orderID executed at executedAt
+func buyMessage(t *testing.T, orderID, quantity, price string, executedAt time.Time) kafka.Message {
+	t.Helper()
+	at := executedAt.Format(time.RFC3339)
+	payload, err := json.Marshal(models.TradeEvent{
+		EventType: "TRADE_DETECTED",
+		Source:    "robinhood",
+		Data: models.TradeEventData{
+			OrderID:      orderID,
+			Symbol:       "AAPL",
+			Side:         "buy",
+			Quantity:     quantity,
+			AveragePrice: price,
+			State:        "filled",
+			ExecutedAt:   &at,
+		},
+	})
+	require.NoError(t, err)
+	return kafka.Message{Value: payload}
+}
+
+// TestConsumer_ReprocessWindow verifies a redelivered trade inside the window is
+// updated in place and rebuilds FIFO lots, while older ones are still skipped
+func TestConsumer_ReprocessWindow(t *testing.T) {
+	ctx := context.Background()
+	executedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+
+	t.Run("updates recent trades in place", func(t *testing.T) {
+		repo := NewMockRawTradeRepository()
+		history := &mockTradeHistoryRepo{}
+		consumer := &Consumer{repo: repo}
+		consumer.SetCostBasisMode(CostBasisFIFO, history)
+		consumer.SetReprocessWindow(24 * time.Hour)
+
+		require.NoError(t, consumer.processMessage(ctx, buyMessage(t, "buy-1", "10", "100", executedAt)))
+		history.ledger = []*models.RawTrade{repo.rawTrades["buy-1:robinhood"]}
+		require.NoError(t, consumer.processMessage(ctx, buyMessage(t, "buy-1", "8", "101", executedAt)))
+
+		require.Len(t, repo.rawTrades, 1)
+		stored := repo.rawTrades["buy-1:robinhood"]
+		assert.Equal(t, 1, stored.ID)
+		assert.Equal(t, "8", stored.Quantity.String())
+		assert.Equal(t, "101", stored.Price.String())
+
+		// The next sell is matched against the corrected buy
+		history.ledger = []*models.RawTrade{stored}
+		payload := `{"event_type":"TRADE_DETECTED","source":"robinhood","data":{
+			"order_id":"sell-1","symbol":"AAPL","side":"sell","quantity":"8","average_price":"111",
+			"total_notional":"888","fees":"0","state":"filled","executed_at":"` + time.Now().UTC().Format(time.RFC3339) + `"}}`
+		require.NoError(t, consumer.processMessage(ctx, kafka.Message{Value: []byte(payload)}))
+
+		require.Len(t, history.closed, 1)
+		assert.Equal(t, "8", history.closed[0].Quantity.String())
+		assert.Equal(t, "80", history.closed[0].RealizedPnl.String())
+	})
+
+	t.Run("skips trades outside the window", func(t *testing.T) {
+		repo := NewMockRawTradeRepository()
+		consumer := &Consumer{repo: repo}
+		consumer.SetReprocessWindow(30 * time.Minute)
+
+		require.NoError(t, consumer.processMessage(ctx, buyMessage(t, "buy-1", "10", "100", executedAt)))
+		require.NoError(t, consumer.processMessage(ctx, buyMessage(t, "buy-1", "8", "101", executedAt)))
+
+		require.Len(t, repo.rawTrades, 1)
+		assert.Equal(t, "10", repo.rawTrades["buy-1:robinhood"].Quantity.String())
+	})
+
+	t.Run("skips duplicates when disabled", func(t *testing.T) {
+		repo := NewMockRawTradeRepository()
+		consumer := &Consumer{repo: repo}
+
+		require.NoError(t, consumer.processMessage(ctx, buyMessage(t, "buy-1", "10", "100", executedAt)))
+		require.NoError(t, consumer.processMessage(ctx, buyMessage(t, "buy-1", "8", "101", executedAt)))
+
+		require.Len(t, repo.rawTrades, 1)
+		assert.Equal(t, "10", repo.rawTrades["buy-1:robinhood"].Quantity.String())
+	})
+}
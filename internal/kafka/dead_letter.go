@@ -0,0 +1,127 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// defaultMaxBytes is the reader fetch limit used when none is configured
+const defaultMaxBytes = 10e6 // 10MB
+
+// errMessageTooLarge marks a message whose payload exceeds the configured limit
+var errMessageTooLarge = errors.New("message exceeds max bytes")
+
+// errMalformedMessage marks a trade message that can't be parsed or converted,
+// so retrying it can never succeed
+var errMalformedMessage = errors.New("malformed trade event")
+
+// oversizedMessages counts oversized or truncated messages per topic.
+// It is published through expvar and served at /api/v1/debug/vars.
+var oversizedMessages = expvar.NewMap("kafka_oversized_messages")
+
+// malformedMessages counts unparseable messages per topic, also served at /api/v1/debug/vars
+var malformedMessages = expvar.NewMap("kafka_malformed_messages")
+
+// deadLetterWriter is the subset of kafka.Writer used to route unprocessable messages.
+type deadLetterWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// NewDeadLetterWriter creates a writer for the dead-letter topic
+func NewDeadLetterWriter(brokers []string, topic string) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.LeastBytes{},
+		BatchTimeout: 10 * time.Millisecond,
+	}
+}
+
+// isOversizedReadError reports whether a read error indicates a message that was larger
+// than the fetch limit or arrived truncated.
+func isOversizedReadError(err error) bool {
+	var tooLarge kafka.MessageTooLargeError
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, kafka.MessageSizeTooLarge) ||
+		errors.As(err, &tooLarge) ||
+		errors.Is(err, errMessageTooLarge)
+}
+
+// recordOversized increments the oversized message counter for a topic
+func recordOversized(topic string) {
+	if topic == "" {
+		topic = "unknown"
+	}
+	oversizedMessages.Add(topic, 1)
+}
+
+// OversizedMessages returns how many oversized messages have been seen on a topic
+func OversizedMessages(topic string) int64 {
+	counter, ok := oversizedMessages.Get(topic).(*expvar.Int)
+	if !ok {
+		return 0
+	}
+	return counter.Value()
+}
+
+// routeToDeadLetter records an oversized message and forwards it to the
+// dead-letter topic when a writer is configured
+func routeToDeadLetter(ctx context.Context, dlq deadLetterWriter, msg kafka.Message, cause error) {
+	recordOversized(msg.Topic)
+	log.Printf("Oversized message on topic %s partition %d offset %d (%d bytes): %v",
+		msg.Topic, msg.Partition, msg.Offset, len(msg.Value), cause)
+	sendToDLQ(ctx, dlq, msg, cause)
+}
+
+// MalformedMessages returns how many unparseable messages have been seen on a topic
+func MalformedMessages(topic string) int64 {
+	counter, ok := malformedMessages.Get(topic).(*expvar.Int)
+	if !ok {
+		return 0
+	}
+	return counter.Value()
+}
+
+// routeMalformed records an unparseable message and forwards it to the
+// dead-letter topic when a writer is configured
+func routeMalformed(ctx context.Context, dlq deadLetterWriter, msg kafka.Message, cause error) {
+	topic := msg.Topic
+	if topic == "" {
+		topic = "unknown"
+	}
+	malformedMessages.Add(topic, 1)
+	log.Printf("Malformed message on topic %s partition %d offset %d: %v",
+		msg.Topic, msg.Partition, msg.Offset, cause)
+	sendToDLQ(ctx, dlq, msg, cause)
+}
+
+// sendToDLQ forwards msg to the dead-letter writer, if any, with headers
+// describing where it came from and why it failed
+func sendToDLQ(ctx context.Context, dlq deadLetterWriter, msg kafka.Message, cause error) {
+	if dlq == nil {
+		return
+	}
+
+	headers := append([]kafka.Header{}, msg.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: "x-error", Value: []byte(cause.Error())},
+		kafka.Header{Key: "x-source-topic", Value: []byte(msg.Topic)},
+		kafka.Header{Key: "x-source-partition", Value: []byte(strconv.Itoa(msg.Partition))},
+		kafka.Header{Key: "x-source-offset", Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+	)
+
+	// Topic must be empty when the writer has its own topic configured
+	dead := kafka.Message{Key: msg.Key, Value: msg.Value, Headers: headers}
+	if err := dlq.WriteMessages(ctx, dead); err != nil {
+		log.Printf("Failed to route message to dead-letter topic: %v", fmt.Errorf("%w (original: %v)", err, cause))
+	}
+}
@@ -0,0 +1,192 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+// readResult is a scripted outcome for scriptedReader
+type readResult struct {
+	msg kafka.Message
+	err error
+}
+
+// scriptedReader replays a fixed sequence of reads, then blocks until cancelled
+type scriptedReader struct {
+	cfg     kafka.ReaderConfig
+	results chan readResult
+}
+
+func newScriptedReader(topic string, results ...readResult) *scriptedReader {
+	r := &scriptedReader{
+		cfg:     kafka.ReaderConfig{Topic: topic},
+		results: make(chan readResult, len(results)),
+	}
+	for _, res := range results {
+		r.results <- res
+	}
+	return r
+}
+
+func (r *scriptedReader) ReadMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case res := <-r.results:
+		return res.msg, res.err
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+}
+
+func (r *scriptedReader) Close() error { return nil }
+
+func (r *scriptedReader) Config() kafka.ReaderConfig { return r.cfg }
+
+type mockDeadLetterWriter struct {
+	mu   sync.Mutex
+	msgs []kafka.Message
+}
+
+func (w *mockDeadLetterWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func (w *mockDeadLetterWriter) Close() error { return nil }
+
+func (w *mockDeadLetterWriter) Messages() []kafka.Message {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]kafka.Message(nil), w.msgs...)
+}
+
+// lockedRawTradeRepo guards the mock repository for use from the consumer goroutine
+type lockedRawTradeRepo struct {
+	mu   sync.Mutex
+	repo *MockRawTradeRepository
+}
+
+func (r *lockedRawTradeRepo) CreateRawTrade(t *models.RawTrade) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.repo.CreateRawTrade(t)
+}
+
+func (r *lockedRawTradeRepo) RawTradeExistsByOrderID(orderID, source string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.repo.RawTradeExistsByOrderID(orderID, source)
+}
+
+func (r *lockedRawTradeRepo) Count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.repo.rawTrades)
+}
+
+func header(msg kafka.Message, key string) string {
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func TestConsumer_OversizedMessagesAreRecordedAndDeadLettered(t *testing.T) {
+	topic := fmt.Sprintf("oversized-test-%d", time.Now().UnixNano())
+	oversized := kafka.Message{
+		Topic:     topic,
+		Partition: 2,
+		Offset:    42,
+		Value:     []byte(strings.Repeat("x", 2048)),
+	}
+	valid := tradeMessage(t, "order-ok")
+
+	reader := newScriptedReader(topic,
+		readResult{err: fmt.Errorf("read batch: %w", io.ErrUnexpectedEOF)},
+		readResult{msg: oversized},
+		readResult{msg: valid},
+	)
+	repo := &lockedRawTradeRepo{repo: NewMockRawTradeRepository()}
+	dlq := &mockDeadLetterWriter{}
+	consumer := &Consumer{reader: reader, repo: repo, maxBytes: 1024}
+	consumer.SetDeadLetterWriter(dlq)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go consumer.Start(ctx)
+
+	require.Eventually(t, func() bool { return repo.Count() == 1 }, 2*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, int64(2), OversizedMessages(topic))
+
+	dead := dlq.Messages()
+	require.Len(t, dead, 2)
+
+	// Truncated read: payload unavailable, but the failure is recorded
+	assert.Equal(t, topic, header(dead[0], "x-source-topic"))
+	assert.Contains(t, header(dead[0], "x-error"), "unexpected EOF")
+
+	// Oversized payload: forwarded intact with its origin
+	assert.Equal(t, oversized.Value, dead[1].Value)
+	assert.Empty(t, dead[1].Topic)
+	assert.Equal(t, "2", header(dead[1], "x-source-partition"))
+	assert.Equal(t, "42", header(dead[1], "x-source-offset"))
+	assert.Contains(t, header(dead[1], "x-error"), "exceeds max bytes")
+}
+
+func TestIsOversizedReadError(t *testing.T) {
+	assert.True(t, isOversizedReadError(io.ErrUnexpectedEOF))
+	assert.True(t, isOversizedReadError(fmt.Errorf("fetch: %w", kafka.MessageSizeTooLarge)))
+	assert.False(t, isOversizedReadError(io.EOF))
+	assert.False(t, isOversizedReadError(context.Canceled))
+}
+
+func TestConsumer_MalformedMessagesAreDeadLettered(t *testing.T) {
+	topic := fmt.Sprintf("malformed-test-%d", time.Now().UnixNano())
+	malformed := kafka.Message{Topic: topic, Partition: 1, Offset: 7, Value: []byte(`{"event_type":`)}
+	badQuantity := kafka.Message{Topic: topic, Offset: 8, Value: []byte(`{"event_type":"TRADE_DETECTED","source":"robinhood",
+		"data":{"order_id":"bad-qty","symbol":"AAPL","side":"buy","quantity":"lots","average_price":"150"}}`)}
+	valid := tradeMessage(t, "order-ok")
+
+	reader := newScriptedReader(topic,
+		readResult{msg: malformed},
+		readResult{msg: badQuantity},
+		readResult{msg: valid},
+	)
+	repo := &lockedRawTradeRepo{repo: NewMockRawTradeRepository()}
+	dlq := &mockDeadLetterWriter{}
+	consumer := &Consumer{reader: reader, repo: repo}
+	consumer.SetDeadLetterWriter(dlq)
+	consumer.SetCircuitBreaker(2, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go consumer.Start(ctx)
+
+	// Malformed messages don't trip the breaker, so the valid trade still lands
+	require.Eventually(t, func() bool { return repo.Count() == 1 }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(2), MalformedMessages(topic))
+	assert.Zero(t, OversizedMessages(topic))
+
+	dead := dlq.Messages()
+	require.Len(t, dead, 2)
+	assert.Equal(t, malformed.Value, dead[0].Value)
+	assert.Equal(t, "1", header(dead[0], "x-source-partition"))
+	assert.Equal(t, "7", header(dead[0], "x-source-offset"))
+	assert.Contains(t, header(dead[0], "x-error"), "failed to unmarshal")
+	assert.Equal(t, badQuantity.Value, dead[1].Value)
+	assert.Contains(t, header(dead[1], "x-error"), "invalid quantity")
+}
@@ -0,0 +1,173 @@
+package kafka
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+// CostBasisMode selects how the trades consumer turns sells into trade history
+type CostBasisMode string
+
+const (
+	// CostBasisNone stores raw trades only; closes come from position snapshots
+	CostBasisNone CostBasisMode = ""
+	// CostBasisFIFO matches each sell against the oldest open buy lots
+	CostBasisFIFO CostBasisMode = "fifo"
+)
+
+// FeeMode selects which fees are deducted from a closed lot's realized P&L
+type FeeMode string
+
+const (
+	// FeeModeAll deducts both the buy and sell fees for the shares closed
+	FeeModeAll FeeMode = "all"
+	// FeeModeSellOnly deducts only the sell fees, treating buy fees as part of
+	// cost basis that isn't charged against the trade
+	FeeModeSellOnly FeeMode = "sell"
+)
+
+// TradeHistoryRepository defines the operations needed to track lots and record closes
+type TradeHistoryRepository interface {
+	GetRawTradeLedger(symbol string) ([]*models.RawTrade, error)
+	CreateRawTradeWithCloses(t *models.RawTrade, closes []*models.ClosedLot) (int64, error)
+	AddRealizedPnl(symbol string, amount decimal.Decimal) error
+}
+
+// lot is the unsold remainder of a single buy
+type lot struct {
+	rawTradeID  int
+	quantity    decimal.Decimal
+	price       decimal.Decimal
+	feePerShare decimal.Decimal
+	executedAt  time.Time
+}
+
+// lotBook holds open buy lots per symbol, oldest first
+type lotBook struct {
+	lots    map[string][]*lot
+	seeded  map[string]bool
+	feeMode FeeMode
+}
+
+func newLotBook() *lotBook {
+	return &lotBook{
+		lots:    make(map[string][]*lot),
+		seeded:  make(map[string]bool),
+		feeMode: FeeModeAll,
+	}
+}
+
+// seed replays a symbol's earlier raw trades once so lots survive restarts.
+// Closes from the replay were recorded when those trades first arrived.
+func (b *lotBook) seed(symbol string, repo TradeHistoryRepository) error {
+	if b.seeded[symbol] {
+		return nil
+	}
+	ledger, err := repo.GetRawTradeLedger(symbol)
+	if err != nil {
+		return fmt.Errorf("failed to load raw trades for %s: %w", symbol, err)
+	}
+	for _, t := range ledger {
+		b.apply(t)
+	}
+	b.seeded[symbol] = true
+	return nil
+}
+
+// held reports whether symbol has shares left in open lots
+func (b *lotBook) held(symbol string) bool {
+	return len(b.lots[symbol]) > 0
+}
+
+// reset forgets a symbol's lots so the next seed replays its ledger again
+func (b *lotBook) reset(symbol string) {
+	delete(b.lots, symbol)
+	delete(b.seeded, symbol)
+}
+
+// apply adds a buy as a new lot or matches a sell against open lots FIFO,
+// returning one closed lot per lot the sell consumed
+func (b *lotBook) apply(t *models.RawTrade) []*models.ClosedLot {
+	if !t.Quantity.IsPositive() {
+		return nil
+	}
+	if t.Side == models.TradeTypeBuy {
+		b.lots[t.Symbol] = append(b.lots[t.Symbol], &lot{
+			rawTradeID:  t.ID,
+			quantity:    t.Quantity,
+			price:       t.Price,
+			feePerShare: t.Fees.Div(t.Quantity),
+			executedAt:  t.ExecutedAt,
+		})
+		return nil
+	}
+	return b.sell(t)
+}
+
+func (b *lotBook) sell(t *models.RawTrade) []*models.ClosedLot {
+	remaining := t.Quantity
+	sellFeePerShare := t.Fees.Div(t.Quantity)
+	open := b.lots[t.Symbol]
+
+	var closed []*models.ClosedLot
+	for len(open) > 0 && remaining.IsPositive() {
+		l := open[0]
+		matched := decimal.Min(l.quantity, remaining)
+
+		closed = append(closed, &models.ClosedLot{
+			Trade:      closedLot(t, l, matched, sellFeePerShare, b.feeMode),
+			BuyTradeID: l.rawTradeID,
+		})
+
+		l.quantity = l.quantity.Sub(matched)
+		remaining = remaining.Sub(matched)
+		if l.quantity.IsZero() {
+			open = open[1:]
+		}
+	}
+	b.lots[t.Symbol] = open
+
+	if remaining.IsPositive() {
+		log.Printf("Warning: sell %s of %s %s exceeds open lots by %s; unmatched shares not recorded",
+			t.OrderID, t.Quantity, t.Symbol, remaining)
+	}
+	return closed
+}
+
+// closedLot builds the trade history for matched shares of l sold by t
+func closedLot(t *models.RawTrade, l *lot, matched, sellFeePerShare decimal.Decimal, mode FeeMode) *models.TradeHistory {
+	entryDate := l.executedAt
+	exitDate := t.ExecutedAt
+	holdingHours := int(exitDate.Sub(entryDate).Hours())
+
+	fee := sellFeePerShare.Mul(matched)
+	if mode != FeeModeSellOnly {
+		fee = fee.Add(l.feePerShare.Mul(matched))
+	}
+	cost := l.price.Mul(matched)
+	pnl := t.Price.Sub(l.price).Mul(matched).Sub(fee)
+
+	var pnlPct decimal.Decimal
+	if !cost.IsZero() {
+		pnlPct = pnl.Div(cost).Mul(decimal.NewFromInt(100)).Round(4)
+	}
+
+	return &models.TradeHistory{
+		Symbol:             t.Symbol,
+		TradeType:          models.TradeTypeSell,
+		Quantity:           matched,
+		Price:              t.Price,
+		TotalCost:          t.Price.Mul(matched),
+		Fee:                fee,
+		EntryDate:          &entryDate,
+		ExitDate:           &exitDate,
+		HoldingPeriodHours: &holdingHours,
+		RealizedPnl:        pnl,
+		RealizedPnlPct:     pnlPct,
+		ExecutedAt:         t.ExecutedAt,
+	}
+}
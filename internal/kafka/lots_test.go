@@ -0,0 +1,314 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+	"github.com/trogers1052/stock-alert-system/internal/notify"
+)
+
+// mockTradeHistoryRepo serves a fixed ledger and collects recorded closes
+type mockTradeHistoryRepo struct {
+	ledger   []*models.RawTrade
+	closed   []*models.TradeHistory
+	realized map[string]decimal.Decimal // partial-close P&L added to each position
+	raw      *MockRawTradeRepository    // stores sells' raw trades when set
+	err      error                      // returned by CreateRawTradeWithCloses when set
+}
+
+func (m *mockTradeHistoryRepo) GetRawTradeLedger(symbol string) ([]*models.RawTrade, error) {
+	var trades []*models.RawTrade
+	for _, t := range m.ledger {
+		if t.Symbol == symbol {
+			trades = append(trades, t)
+		}
+	}
+	return trades, nil
+}
+
+func (m *mockTradeHistoryRepo) CreateRawTradeWithCloses(t *models.RawTrade, closes []*models.ClosedLot) (int64, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	if m.raw != nil {
+		if err := m.raw.CreateRawTrade(t); err != nil {
+			return 0, err
+		}
+	}
+	for _, closed := range closes {
+		m.closed = append(m.closed, closed.Trade)
+	}
+	return expectedLinks(closes), nil
+}
+
+func (m *mockTradeHistoryRepo) AddRealizedPnl(symbol string, amount decimal.Decimal) error {
+	if m.realized == nil {
+		m.realized = make(map[string]decimal.Decimal)
+	}
+	m.realized[symbol] = m.realized[symbol].Add(amount)
+	return nil
+}
+
+// TestLotBook_FIFOWithPartialFills verifies sells consume the oldest lots first,
+// splitting a lot when a sell only takes part of it
+func TestLotBook_FIFOWithPartialFills(t *testing.T) {
+	day := time.Date(2026, 3, 2, 14, 30, 0, 0, time.UTC)
+	book := newLotBook()
+
+	b1 := createTestRawTrade("b1", "AAPL", "BUY", 10, 100, day)
+	b1.ID = 1
+	b2 := createTestRawTrade("b2", "AAPL", "BUY", 5, 110, day.Add(24*time.Hour))
+	b2.ID = 2
+	assert.Empty(t, book.apply(b1))
+	assert.Empty(t, book.apply(b2))
+
+	// Sell 12: all of the first lot and 2 of the second
+	closed := book.apply(createTestRawTrade("s1", "AAPL", "SELL", 12, 120, day.Add(48*time.Hour)))
+	require.Len(t, closed, 2)
+	assert.Equal(t, "10", closed[0].Trade.Quantity.String())
+	assert.Equal(t, "200", closed[0].Trade.RealizedPnl.String())
+	assert.Equal(t, "20", closed[0].Trade.RealizedPnlPct.String())
+	assert.Equal(t, 48, *closed[0].Trade.HoldingPeriodHours)
+	assert.Equal(t, 1, closed[0].BuyTradeID)
+	assert.Equal(t, "2", closed[1].Trade.Quantity.String())
+	assert.Equal(t, "20", closed[1].Trade.RealizedPnl.String())
+	assert.Equal(t, 24, *closed[1].Trade.HoldingPeriodHours)
+	assert.Equal(t, 2, closed[1].BuyTradeID)
+
+	// A later buy queues behind the 3 shares left in the second lot
+	book.apply(createTestRawTrade("b3", "AAPL", "BUY", 4, 90, day.Add(72*time.Hour)))
+	closed = book.apply(createTestRawTrade("s2", "AAPL", "SELL", 5, 100, day.Add(96*time.Hour)))
+	require.Len(t, closed, 2)
+	assert.Equal(t, "3", closed[0].Trade.Quantity.String())
+	assert.Equal(t, "-30", closed[0].Trade.RealizedPnl.String())
+	assert.True(t, day.Add(24*time.Hour).Equal(*closed[0].Trade.EntryDate))
+	assert.Equal(t, "2", closed[1].Trade.Quantity.String())
+	assert.Equal(t, "20", closed[1].Trade.RealizedPnl.String())
+
+	require.Len(t, book.lots["AAPL"], 1)
+	assert.Equal(t, "2", book.lots["AAPL"][0].quantity.String())
+}
+
+// TestExpectedLinks verifies each close expects its sell and, when known, its buy
+func TestExpectedLinks(t *testing.T) {
+	closes := []*models.ClosedLot{
+		{Trade: &models.TradeHistory{}, BuyTradeID: 4},
+		{Trade: &models.TradeHistory{}}, // buy stored before IDs were tracked
+	}
+	assert.Equal(t, int64(3), expectedLinks(closes))
+	assert.Equal(t, int64(0), expectedLinks(nil))
+}
+
+// TestLotBook_AllocatesFees verifies buy and sell fees are split per share across lots
+func TestLotBook_AllocatesFees(t *testing.T) {
+	day := time.Date(2026, 3, 2, 14, 30, 0, 0, time.UTC)
+	book := newLotBook()
+
+	buy := createTestRawTrade("b1", "MSFT", "BUY", 4, 50, day)
+	buy.Fees = decimal.NewFromInt(2)
+	book.apply(buy)
+
+	sell := createTestRawTrade("s1", "MSFT", "SELL", 2, 60, day.Add(time.Hour))
+	sell.Fees = decimal.NewFromInt(1)
+	closed := book.apply(sell)
+
+	require.Len(t, closed, 1)
+	assert.Equal(t, "2", closed[0].Trade.Fee.String())
+	assert.Equal(t, "18", closed[0].Trade.RealizedPnl.String())
+}
+
+// TestLotBook_SellBeyondOpenLots verifies unmatched shares are dropped rather than invented
+func TestLotBook_SellBeyondOpenLots(t *testing.T) {
+	day := time.Date(2026, 3, 2, 14, 30, 0, 0, time.UTC)
+	book := newLotBook()
+
+	book.apply(createTestRawTrade("b1", "TSLA", "BUY", 1, 200, day))
+	closed := book.apply(createTestRawTrade("s1", "TSLA", "SELL", 3, 210, day.Add(time.Hour)))
+
+	require.Len(t, closed, 1)
+	assert.Equal(t, "1", closed[0].Trade.Quantity.String())
+	assert.Empty(t, book.lots["TSLA"])
+}
+
+// TestConsumer_FIFORebuildsLotsFromLedger verifies lots from trades stored before a
+// restart are matched without recording their old closes again
+func TestConsumer_FIFORebuildsLotsFromLedger(t *testing.T) {
+	day := time.Date(2026, 1, 12, 15, 0, 0, 0, time.UTC)
+	history := &mockTradeHistoryRepo{ledger: []*models.RawTrade{
+		createTestRawTrade("old-buy", "AAPL", "BUY", 10, 100, day),
+		createTestRawTrade("old-sell", "AAPL", "SELL", 4, 105, day.Add(time.Hour)),
+	}}
+	consumer := &Consumer{repo: NewMockRawTradeRepository()}
+	consumer.SetCostBasisMode(CostBasisFIFO, history)
+
+	payload := `{"event_type":"TRADE_DETECTED","source":"robinhood","data":{
+		"order_id":"new-sell","symbol":"AAPL","side":"sell","quantity":"6","average_price":"110",
+		"total_notional":"660","fees":"0","state":"filled","executed_at":"2026-01-18T10:30:00Z"}}`
+	require.NoError(t, consumer.processMessage(context.Background(), kafka.Message{Value: []byte(payload)}))
+
+	require.Len(t, history.closed, 1)
+	assert.Equal(t, "AAPL", history.closed[0].Symbol)
+	assert.Equal(t, "6", history.closed[0].Quantity.String())
+	assert.Equal(t, "60", history.closed[0].RealizedPnl.String())
+	assert.True(t, day.Equal(*history.closed[0].EntryDate))
+}
+
+// TestConsumer_FIFOAddsPartialSellsToRealizedPnl verifies sells that leave shares
+// held add their P&L to the position's running total, and a final sell doesn't
+func TestConsumer_FIFOAddsPartialSellsToRealizedPnl(t *testing.T) {
+	history := &mockTradeHistoryRepo{}
+	consumer := &Consumer{repo: NewMockRawTradeRepository()}
+	consumer.SetCostBasisMode(CostBasisFIFO, history)
+
+	for i, trade := range []struct{ side, qty, price string }{
+		{"buy", "10", "100"},
+		{"sell", "4", "110"},
+		{"sell", "3", "120"},
+		{"sell", "3", "90"},
+	} {
+		payload := fmt.Sprintf(`{"event_type":"TRADE_DETECTED","source":"robinhood","data":{
+			"order_id":"order-%d","symbol":"AAPL","side":%q,"quantity":%q,"average_price":%q,
+			"fees":"0","state":"filled","executed_at":"2026-01-2%dT15:00:00Z"}}`,
+			i, trade.side, trade.qty, trade.price, i)
+		require.NoError(t, consumer.processMessage(context.Background(), kafka.Message{Value: []byte(payload)}))
+	}
+
+	require.Len(t, history.closed, 3)
+	// (110 - 100) * 4 + (120 - 100) * 3; the closing sell isn't added
+	assert.Equal(t, "100", history.realized["AAPL"].String())
+}
+
+// TestConsumer_FIFOFailedCloseResetsLots verifies a close that can't be stored
+// fails the message and drops the symbol's lots so they're rebuilt from the ledger
+func TestConsumer_FIFOFailedCloseResetsLots(t *testing.T) {
+	day := time.Date(2026, 1, 12, 15, 0, 0, 0, time.UTC)
+	history := &mockTradeHistoryRepo{
+		ledger: []*models.RawTrade{createTestRawTrade("old-buy", "AAPL", "BUY", 10, 100, day)},
+		err:    errors.New("insert failed"),
+	}
+	consumer := &Consumer{repo: NewMockRawTradeRepository()}
+	consumer.SetCostBasisMode(CostBasisFIFO, history)
+
+	payload := `{"event_type":"TRADE_DETECTED","source":"robinhood","data":{
+		"order_id":"new-sell","symbol":"AAPL","side":"sell","quantity":"4","average_price":"110",
+		"fees":"0","state":"filled","executed_at":"2026-01-18T10:30:00Z"}}`
+	err := consumer.processMessage(context.Background(), kafka.Message{Value: []byte(payload)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to save sell and closed lots for AAPL")
+
+	assert.Empty(t, history.closed)
+	assert.Empty(t, history.realized)
+	assert.False(t, consumer.lots.seeded["AAPL"], "lots are rebuilt from the ledger on the next trade")
+	assert.False(t, consumer.lots.held("AAPL"))
+}
+
+// TestConsumer_FIFORedeliveredSellRecordsCloses verifies a sell whose closes
+// couldn't be stored isn't stored either, so its redelivery records the closes
+// once instead of being skipped as a duplicate
+func TestConsumer_FIFORedeliveredSellRecordsCloses(t *testing.T) {
+	day := time.Date(2026, 1, 12, 15, 0, 0, 0, time.UTC)
+	repo := NewMockRawTradeRepository()
+	history := &mockTradeHistoryRepo{
+		ledger: []*models.RawTrade{createTestRawTrade("old-buy", "AAPL", "BUY", 10, 100, day)},
+		raw:    repo,
+		err:    errors.New("insert failed"),
+	}
+	consumer := &Consumer{repo: repo}
+	consumer.SetCostBasisMode(CostBasisFIFO, history)
+
+	msg := kafka.Message{Value: []byte(`{"event_type":"TRADE_DETECTED","source":"robinhood","data":{
+		"order_id":"new-sell","symbol":"AAPL","side":"sell","quantity":"4","average_price":"110",
+		"fees":"0","state":"filled","executed_at":"2026-01-18T10:30:00Z"}}`)}
+	require.Error(t, consumer.processMessage(context.Background(), msg))
+	assert.Empty(t, repo.rawTrades, "the sell isn't stored without its closes")
+
+	// Redelivered once the database recovers, then delivered again
+	history.err = nil
+	require.NoError(t, consumer.processMessage(context.Background(), msg))
+	require.NoError(t, consumer.processMessage(context.Background(), msg))
+
+	assert.Len(t, repo.rawTrades, 1)
+	require.Len(t, history.closed, 1)
+	assert.Equal(t, "4", history.closed[0].Quantity.String())
+	assert.Equal(t, "40", history.closed[0].RealizedPnl.String())
+	assert.Equal(t, "40", history.realized["AAPL"].String())
+}
+
+// TestLotBook_FeeModes verifies sell-only mode leaves buy fees out of realized P&L
+func TestLotBook_FeeModes(t *testing.T) {
+	day := time.Date(2026, 3, 2, 14, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		mode        FeeMode
+		expectedFee string
+		expectedPnl string
+	}{
+		// (60-50)*2 = 20, less $1 of the buy fee and the $1 sell fee
+		{FeeModeAll, "2", "18"},
+		// Only the sell fee is deducted
+		{FeeModeSellOnly, "1", "19"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			book := newLotBook()
+			book.feeMode = tt.mode
+
+			buy := createTestRawTrade("b1", "MSFT", "BUY", 4, 50, day)
+			buy.Fees = decimal.NewFromInt(2)
+			book.apply(buy)
+
+			sell := createTestRawTrade("s1", "MSFT", "SELL", 2, 60, day.Add(time.Hour))
+			sell.Fees = decimal.NewFromInt(1)
+			closed := book.apply(sell)
+
+			require.Len(t, closed, 1)
+			assert.Equal(t, tt.expectedFee, closed[0].Trade.Fee.String())
+			assert.Equal(t, tt.expectedPnl, closed[0].Trade.RealizedPnl.String())
+		})
+	}
+}
+
+// TestConsumer_FIFONotifiesFullCloses verifies the sell that closes out a symbol's
+// lots records and sends a summary of its closes, and partial sells don't
+func TestConsumer_FIFONotifiesFullCloses(t *testing.T) {
+	alertRepo := &mockPositionAlertRepo{}
+	notifier := &recordingNotifier{channel: models.ChannelTelegram}
+	marker := &sentMarker{}
+	consumer := &Consumer{repo: NewMockRawTradeRepository()}
+	consumer.SetCostBasisMode(CostBasisFIFO, &mockTradeHistoryRepo{})
+	consumer.SetCloseNotifications(models.ChannelTelegram, alertRepo, notify.NewDispatcher(notifier), marker)
+
+	for i, trade := range []struct{ side, qty, price, fees, day string }{
+		{"buy", "10", "100", "0", "20"},
+		{"sell", "4", "110", "0", "21"},
+		{"buy", "5", "90", "0", "22"},
+		{"sell", "11", "120", "1.1", "25"},
+	} {
+		payload := fmt.Sprintf(`{"event_type":"TRADE_DETECTED","source":"robinhood","data":{
+			"order_id":"order-%d","symbol":"AAPL","side":%q,"quantity":%q,"average_price":%q,
+			"fees":%q,"state":"filled","executed_at":"2026-01-%sT15:00:00Z"}}`,
+			i, trade.side, trade.qty, trade.price, trade.fees, trade.day)
+		require.NoError(t, consumer.processMessage(context.Background(), kafka.Message{Value: []byte(payload)}))
+	}
+
+	alerts := alertRepo.Alerts()
+	require.Len(t, alerts, 1)
+	alert := alerts[0]
+	assert.Equal(t, "AAPL", alert.Symbol)
+	assert.Equal(t, models.RuleTypePositionClosed, alert.RuleType)
+	// 6 shares from $100 and 5 from $90, less the $1.10 sell fee
+	assert.Equal(t, "268.9", alert.TriggeredValue.String())
+	assert.Equal(t, "Closed AAPL: 11 shares @ $120.00, P&L $268.90 (25.61%) after 5 days", alert.Message)
+
+	require.Len(t, notifier.sent, 1)
+	assert.Equal(t, []int{alert.ID}, marker.ids)
+}
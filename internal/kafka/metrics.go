@@ -0,0 +1,46 @@
+package kafka
+
+import (
+	"expvar"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// consumerLagSeconds is a per-topic gauge of how far behind real time the most
+// recently processed message was, based on the broker-assigned message timestamp.
+// It is published through expvar and served at /api/v1/debug/vars.
+var consumerLagSeconds = expvar.NewMap("kafka_consumer_lag_seconds")
+
+// recordLag updates the lag gauge for the message's topic and returns the lag.
+// Messages without a timestamp are ignored.
+func recordLag(msg kafka.Message) time.Duration {
+	if msg.Time.IsZero() {
+		return 0
+	}
+
+	lag := time.Since(msg.Time)
+	if lag < 0 {
+		lag = 0 // Clock skew between broker and consumer
+	}
+
+	topic := msg.Topic
+	if topic == "" {
+		topic = "unknown"
+	}
+
+	gauge := new(expvar.Float)
+	gauge.Set(lag.Seconds())
+	consumerLagSeconds.Set(topic, gauge)
+
+	return lag
+}
+
+// ConsumerLag returns the last recorded lag for a topic, or zero if none was recorded.
+func ConsumerLag(topic string) time.Duration {
+	gauge, ok := consumerLagSeconds.Get(topic).(*expvar.Float)
+	if !ok {
+		return 0
+	}
+	return time.Duration(gauge.Value() * float64(time.Second))
+}
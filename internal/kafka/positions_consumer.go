@@ -2,32 +2,110 @@ package kafka
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/segmentio/kafka-go"
 	"github.com/shopspring/decimal"
 	"github.com/trogers1052/stock-alert-system/internal/models"
+	"github.com/trogers1052/stock-alert-system/internal/notify"
 )
 
 // PositionsRepository defines the interface for position database operations
 type PositionsRepository interface {
+	GetAllPositions() ([]*models.Position, error)
 	ReplaceAllPositions(positions []*models.Position) error
+	UpsertPosition(p *models.Position) error
+	DeletePositionBySymbol(symbol string) error
+	CreateTradeHistory(t *models.TradeHistory) error
+	DeleteTradeHistory(id int) error
+	AddRealizedPnl(symbol string, amount decimal.Decimal) error
+	GetRawTradeLedgers(symbols []string) (map[string][]*models.RawTrade, error)
+	CreatePositionEvent(e *models.PositionEvent) error
+	CreatePositionPnlSnapshot(s *models.PositionPnlSnapshot) error
+	GetStock(symbol string) (*models.Stock, error)
 }
 
-// positionsReader is a small interface wrapper around kafka.Reader to enable unit testing.
-type positionsReader interface {
-	ReadMessage(ctx context.Context) (kafka.Message, error)
-	Close() error
-	Config() kafka.ReaderConfig
+// PositionAlertRepository defines the lookups needed to raise alerts from position snapshots
+type PositionAlertRepository interface {
+	GetAllMonitoredStocks() ([]*models.MonitoredStock, error)
+	CreateAlertHistory(h *models.AlertHistory) error
 }
 
-// PositionsConsumer handles consuming position snapshot events from Kafka
+// EntryRSISource selects which RSI reading is recorded as a new position's entry RSI
+type EntryRSISource string
+
+const (
+	// EntryRSINone leaves entry RSI unset
+	EntryRSINone EntryRSISource = ""
+	// EntryRSILatest uses the latest stored RSI when the position is first seen
+	EntryRSILatest EntryRSISource = "latest"
+	// EntryRSIAtEntry uses the RSI from on or before the position's entry date
+	EntryRSIAtEntry EntryRSISource = "at-entry"
+)
+
+// EntryRSIRepository defines the RSI lookups used to set entry RSI
+type EntryRSIRepository interface {
+	GetLatestRSI(symbol string) (decimal.Decimal, error)
+	GetIndicatorAtOrBefore(symbol, indicatorType string, date time.Time) (*models.TechnicalIndicator, error)
+}
+
+// PositionsConsumer handles consuming position snapshot and single-position events from Kafka
 type PositionsConsumer struct {
-	reader positionsReader
-	repo   PositionsRepository
+	reader    messageReader
+	repo      PositionsRepository
+	alertRepo PositionAlertRepository
+	alertOpen bool
+
+	// reopenWindow merges a re-entry within this long of a close back into the
+	// closed position instead of recording a separate round trip (0 disables)
+	reopenWindow time.Duration
+
+	// closesFromTrades is set when the trades consumer records closes, so
+	// snapshots shouldn't also write them
+	closesFromTrades bool
+
+	aliases models.SymbolAliases
+
+	// entryFromTrades replaces the snapshot's average buy price with one rebuilt
+	// from raw trades, including buy fees when entryFees is set
+	entryFromTrades bool
+	entryFees       bool
+
+	// entryRSISource picks the RSI recorded on new positions, read from rsiRepo
+	entryRSISource EntryRSISource
+	rsiRepo        EntryRSIRepository
+
+	// defaultEntryReason is recorded on new positions whose monitored stock has
+	// no reason (empty leaves it unset)
+	defaultEntryReason string
+
+	// maxLeverage warns when a snapshot's positions value exceeds this multiple
+	// of buying power, or cash goes negative (0 disables)
+	maxLeverage float64
+
+	// closeAlerts summarizes each full close (disabled without a channel)
+	closeAlerts closeNotifier
+
+	mu             sync.Mutex
+	targetsHit     map[string]bool        // symbols already alerted as at/above target
+	lastSnapshotAt time.Time              // timestamp of the most recently applied event
+	lastSnapshotFP string                 // fingerprint of the most recently applied snapshot
+	recentCloses   map[string]recentClose // closes still inside the reopen window
+	overLeveraged  bool                   // the last snapshot was already flagged as over-leveraged
+}
+
+// recentClose remembers a close recorded from a snapshot so a quick re-entry can undo it
+type recentClose struct {
+	position *models.Position
+	tradeID  int
+	closedAt time.Time
 }
 
 // NewPositionsConsumer creates a new Kafka consumer for position events
@@ -49,6 +127,74 @@ func NewPositionsConsumer(brokers []string, topic, groupID string, repo Position
 	}
 }
 
+// SetAlertRepository enables target-hit alerts for positions in incoming snapshots
+func (c *PositionsConsumer) SetAlertRepository(repo PositionAlertRepository) {
+	c.alertRepo = repo
+}
+
+// SetPositionOpenAlerts records an informational alert whenever a snapshot
+// contains a symbol that wasn't held before. Requires an alert repository.
+func (c *PositionsConsumer) SetPositionOpenAlerts(enabled bool) {
+	c.alertOpen = enabled
+}
+
+// SetReopenWindow treats a position that reappears within window of being closed
+// as never having closed: the close's trade history is removed and the original
+// entry is restored. Zero or less disables merging.
+func (c *PositionsConsumer) SetReopenWindow(window time.Duration) {
+	c.reopenWindow = window
+}
+
+// SetClosesFromTrades stops snapshots from recording closing trades, for when the
+// trades consumer already records them from sells
+func (c *PositionsConsumer) SetClosesFromTrades(enabled bool) {
+	c.closesFromTrades = enabled
+}
+
+// SetSymbolAliases canonicalizes snapshot symbols using aliases
+func (c *PositionsConsumer) SetSymbolAliases(aliases models.SymbolAliases) {
+	c.aliases = aliases
+}
+
+// SetEntryPriceFromTrades uses the weighted-average cost of a symbol's raw trades
+// as its entry price instead of the broker's average buy price, when the trades
+// account for every share held. includeFees adds buy fees to the cost.
+func (c *PositionsConsumer) SetEntryPriceFromTrades(enabled, includeFees bool) {
+	c.entryFromTrades = enabled
+	c.entryFees = includeFees
+}
+
+// SetEntryRSISource records an entry RSI on each newly opened position, read
+// from repo according to source. Positions already held keep theirs, and
+// EntryRSINone disables it.
+func (c *PositionsConsumer) SetEntryRSISource(source EntryRSISource, repo EntryRSIRepository) {
+	c.entryRSISource = source
+	c.rsiRepo = repo
+}
+
+// SetDefaultEntryReason records reason as the entry reason of each newly opened
+// position without one. When an alert repository is set, a monitored stock's
+// own reason is used first. Empty disables it.
+func (c *PositionsConsumer) SetDefaultEntryReason(reason string) {
+	c.defaultEntryReason = reason
+}
+
+// SetMaxLeverage warns, and records an alert when an alert repository is set,
+// once a snapshot shows negative cash or positions worth more than maxLeverage
+// times buying power. It warns again only after leverage has come back down.
+// Zero or less disables the check.
+func (c *PositionsConsumer) SetMaxLeverage(maxLeverage float64) {
+	c.maxLeverage = maxLeverage
+}
+
+// SetCloseNotifications records an alert on channel summarizing the realized P&L
+// and holding period of each position that fully closes, and delivers it through
+// d when d is set, marking it sent in marker. Requires an alert repository; an
+// empty channel disables it.
+func (c *PositionsConsumer) SetCloseNotifications(channel string, d *notify.Dispatcher, marker notify.SentMarker) {
+	c.closeAlerts = closeNotifier{channel: channel, notifiers: d, marker: marker}
+}
+
 // Start begins consuming messages from Kafka
 func (c *PositionsConsumer) Start(ctx context.Context) error {
 	log.Printf("Starting Kafka positions consumer for topic: %s", c.reader.Config().Topic)
@@ -78,27 +224,54 @@ func (c *PositionsConsumer) Start(ctx context.Context) error {
 
 // processMessage handles a single Kafka message
 func (c *PositionsConsumer) processMessage(msg kafka.Message) error {
-	log.Printf("Received positions message from partition %d offset %d",
-		msg.Partition, msg.Offset)
+	lag := recordLag(msg)
+	log.Printf("Received positions message from partition %d offset %d (lag: %s)",
+		msg.Partition, msg.Offset, lag.Round(time.Millisecond))
 
 	var event models.PositionsEvent
 	if err := json.Unmarshal(msg.Value, &event); err != nil {
 		return fmt.Errorf("failed to unmarshal positions event: %w", err)
 	}
 
-	// Only process POSITIONS_SNAPSHOT events
-	if event.EventType != "POSITIONS_SNAPSHOT" {
+	switch event.EventType {
+	case models.PositionsEventSnapshot, models.PositionsEventUpdated, models.PositionsEventClosed:
+	default:
 		log.Printf("Ignoring event type: %s", event.EventType)
 		return nil
 	}
 
+	// Ignore events delivered out of order so stale data can't overwrite newer positions
+	snapshotAt, err := time.Parse(time.RFC3339Nano, event.Timestamp)
+	if err != nil {
+		log.Printf("Warning: positions event has unparseable timestamp %q, applying anyway", event.Timestamp)
+	} else if last := c.lastApplied(); snapshotAt.Before(last) {
+		log.Printf("Ignoring stale %s from %s (last applied: %s)",
+			event.EventType, snapshotAt.Format(time.RFC3339), last.Format(time.RFC3339))
+		return nil
+	}
+
+	now := time.Now()
+	appliedAt := snapshotAt
+	if appliedAt.IsZero() {
+		appliedAt = now
+	}
+
+	if event.EventType != models.PositionsEventSnapshot {
+		return c.applyPositionChange(event, snapshotAt, appliedAt, now)
+	}
+
+	// Skip redelivered snapshots so the table isn't churned for no change
+	fingerprint := snapshotFingerprint(event)
+	if fingerprint == c.LastSnapshotFingerprint() {
+		log.Printf("Ignoring duplicate positions snapshot from %s", event.Timestamp)
+		return nil
+	}
+
 	log.Printf("Processing positions snapshot: %d positions, buying_power=%s",
 		len(event.Data.Positions), event.Data.BuyingPower)
 
 	// Convert event data to Position models
 	positions := make([]*models.Position, 0, len(event.Data.Positions))
-	now := time.Now()
-
 	for _, pd := range event.Data.Positions {
 		position, err := c.convertPositionData(pd, now)
 		if err != nil {
@@ -108,10 +281,28 @@ func (c *PositionsConsumer) processMessage(msg kafka.Message) error {
 		positions = append(positions, position)
 	}
 
+	c.backfillEntryDates(positions)
+	c.reconstructEntryPrices(positions)
+
+	// Capture the stored positions before they're replaced so opens and closes can be detected
+	previous, err := c.currentPositions()
+	if err != nil {
+		log.Printf("Warning: failed to load current positions: %v", err)
+	}
+	if previous != nil && !c.closesFromTrades {
+		c.reopenRecentlyClosed(previous, positions, appliedAt)
+	}
+	c.enrichNewPositions(previous, positions)
+	c.setEntryRSI(previous, positions)
+	c.setEntryReason(previous, positions)
+	c.setPositionSizes(event.Data, positions)
+
 	// Replace all positions in the database
 	if err := c.repo.ReplaceAllPositions(positions); err != nil {
 		return fmt.Errorf("failed to replace positions: %w", err)
 	}
+	c.markApplied(snapshotAt)
+	c.setSnapshotFingerprint(fingerprint)
 
 	log.Printf("Successfully updated %d positions from snapshot", len(positions))
 
@@ -121,6 +312,785 @@ func (c *PositionsConsumer) processMessage(msg kafka.Message) error {
 			p.Symbol, p.Quantity, p.EntryPrice, p.CurrentPrice, p.UnrealizedPnlPct)
 	}
 
+	c.reconcile(previous, positions, appliedAt)
+	c.checkLeverage(event.Data)
+	return nil
+}
+
+// applyPositionChange upserts or deletes the one position named by a
+// POSITION_UPDATED or POSITION_CLOSED event, leaving other positions untouched.
+// An update to zero shares is treated as a close. The rest of the book is taken
+// from the stored positions so closes, events and alerts match the snapshot path.
+func (c *PositionsConsumer) applyPositionChange(event models.PositionsEvent, eventAt, appliedAt, now time.Time) error {
+	pd := event.Data.Position
+	if pd == nil || pd.Symbol == "" {
+		return fmt.Errorf("%s event has no position", event.EventType)
+	}
+	symbol := c.aliases.Canonical(pd.Symbol)
+
+	var updated *models.Position
+	if event.EventType == models.PositionsEventUpdated {
+		p, err := c.convertPositionData(*pd, now)
+		if err != nil {
+			return fmt.Errorf("failed to convert position %s: %w", pd.Symbol, err)
+		}
+		if p.Quantity.IsPositive() {
+			updated = p
+		}
+	}
+
+	previous, err := c.currentPositions()
+	if err != nil {
+		return fmt.Errorf("failed to load current positions: %w", err)
+	}
+
+	positions := make([]*models.Position, 0, len(previous)+1)
+	for s, p := range previous {
+		if s != symbol {
+			positions = append(positions, p)
+		}
+	}
+
+	if updated != nil {
+		c.backfillEntryDates([]*models.Position{updated})
+		c.reconstructEntryPrices([]*models.Position{updated})
+		if !c.closesFromTrades {
+			c.reopenRecentlyClosed(previous, []*models.Position{updated}, appliedAt)
+		}
+		c.enrichNewPositions(previous, []*models.Position{updated})
+		c.setEntryRSI(previous, []*models.Position{updated})
+		c.setEntryReason(previous, []*models.Position{updated})
+		if err := c.repo.UpsertPosition(updated); err != nil {
+			return fmt.Errorf("failed to upsert position: %w", err)
+		}
+		positions = append(positions, updated)
+		log.Printf("Updated position %s: %s shares @ $%s (current: $%s)",
+			updated.Symbol, updated.Quantity, updated.EntryPrice, updated.CurrentPrice)
+	} else {
+		if _, held := previous[symbol]; !held {
+			log.Printf("Ignoring %s for %s: no open position", event.EventType, symbol)
+			c.markApplied(eventAt)
+			return nil
+		}
+		if err := c.repo.DeletePositionBySymbol(symbol); err != nil {
+			return fmt.Errorf("failed to delete position: %w", err)
+		}
+	}
+	c.markApplied(eventAt)
+	// The book no longer matches the last snapshot, so a redelivery of it must apply
+	c.setSnapshotFingerprint("")
+
+	sort.Slice(positions, func(i, j int) bool { return positions[i].Symbol < positions[j].Symbol })
+	c.reconcile(previous, positions, appliedAt)
+	return nil
+}
+
+// reconcile records lifecycle events, closes and alerts for the change from
+// previous to positions, the full set now held. previous is nil when the stored
+// positions couldn't be loaded, in which case only targets are checked.
+func (c *PositionsConsumer) reconcile(previous map[string]*models.Position, positions []*models.Position, at time.Time) {
+	if previous != nil {
+		c.recordPositionEvents(previous, positions, at)
+		if !c.closesFromTrades {
+			c.recordClosedPositions(previous, positions, at)
+		}
+		c.alertOpenedPositions(previous, positions)
+	}
+	c.recordPnlHistory(positions, at)
+
+	if err := c.checkTargets(positions); err != nil {
+		log.Printf("Warning: failed to evaluate position targets: %v", err)
+	}
+}
+
+// markApplied advances the stale-event watermark to at, if set
+func (c *PositionsConsumer) markApplied(at time.Time) {
+	if at.IsZero() {
+		return
+	}
+	c.mu.Lock()
+	c.lastSnapshotAt = at
+	c.mu.Unlock()
+}
+
+// lastApplied returns the timestamp of the most recently applied snapshot
+func (c *PositionsConsumer) lastApplied() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastSnapshotAt
+}
+
+// LastSnapshotFingerprint returns the fingerprint of the most recently applied
+// snapshot, or "" if none has been applied since the last single-position change
+func (c *PositionsConsumer) LastSnapshotFingerprint() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastSnapshotFP
+}
+
+func (c *PositionsConsumer) setSnapshotFingerprint(fp string) {
+	c.mu.Lock()
+	c.lastSnapshotFP = fp
+	c.mu.Unlock()
+}
+
+// snapshotFingerprint hashes a snapshot's timestamp and positions, ordered by
+// symbol, so a redelivered message can be recognized
+func snapshotFingerprint(event models.PositionsEvent) string {
+	positions := make([]models.PositionData, len(event.Data.Positions))
+	copy(positions, event.Data.Positions)
+	sort.Slice(positions, func(i, j int) bool { return positions[i].Symbol < positions[j].Symbol })
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", event.Timestamp)
+	for _, pd := range positions {
+		fmt.Fprintf(h, "%s|%s|%s|%s|%s\n", pd.Symbol, pd.Quantity, pd.AverageBuyPrice, pd.Equity, pd.PercentChange)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// currentPositions returns the stored open positions keyed by symbol
+func (c *PositionsConsumer) currentPositions() (map[string]*models.Position, error) {
+	stored, err := c.repo.GetAllPositions()
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[string]*models.Position, len(stored))
+	for _, p := range stored {
+		if p.Quantity.IsPositive() {
+			current[p.Symbol] = p
+		}
+	}
+	return current, nil
+}
+
+// recordClosedPositions writes a closing trade for each previously held symbol that
+// is missing from the snapshot (sold outside the trade feed), and a partial one for
+// each symbol held with fewer shares. The last known current price is used as the
+// exit price.
+func (c *PositionsConsumer) recordClosedPositions(previous map[string]*models.Position, positions []*models.Position, closedAt time.Time) {
+	held := make(map[string]*models.Position, len(positions))
+	for _, p := range positions {
+		if p.Quantity.IsPositive() {
+			held[p.Symbol] = p
+		}
+	}
+
+	for symbol, p := range previous {
+		if now, ok := held[symbol]; ok {
+			if now.Quantity.LessThan(p.Quantity) {
+				c.recordPartialClose(p, now, closedAt)
+			}
+			continue
+		}
+
+		trade := closingTrade(p, p.Quantity, p.CurrentPrice, closedAt)
+		if err := c.repo.CreateTradeHistory(trade); err != nil {
+			log.Printf("Warning: failed to record close for %s: %v", symbol, err)
+			continue
+		}
+		log.Printf("Position closed: %s %s shares @ $%s (P&L: $%s)",
+			symbol, trade.Quantity, trade.Price.StringFixed(2), trade.RealizedPnl.StringFixed(2))
+		c.notifyClose(trade)
+
+		if c.reopenWindow > 0 {
+			c.mu.Lock()
+			if c.recentCloses == nil {
+				c.recentCloses = make(map[string]recentClose)
+			}
+			c.recentCloses[symbol] = recentClose{position: p, tradeID: trade.ID, closedAt: closedAt}
+			c.mu.Unlock()
+		}
+	}
+}
+
+// recordPartialClose writes the trade history for the shares sold between old and
+// now, two snapshots of the same position, and adds its P&L to the position's
+// realized total. The sold shares are priced against the old average entry,
+// which a sale doesn't change.
+func (c *PositionsConsumer) recordPartialClose(old, now *models.Position, closedAt time.Time) {
+	sold := old.Quantity.Sub(now.Quantity)
+	trade := closingTrade(old, sold, lastPrice(now, old), closedAt)
+	trade.ExitReason = "Position reduced in broker snapshot"
+	if err := c.repo.CreateTradeHistory(trade); err != nil {
+		log.Printf("Warning: failed to record partial close for %s: %v", old.Symbol, err)
+		return
+	}
+	if err := c.repo.AddRealizedPnl(old.Symbol, trade.RealizedPnl); err != nil {
+		log.Printf("Warning: failed to add realized P&L for %s: %v", old.Symbol, err)
+	}
+	log.Printf("Position reduced: %s %s shares @ $%s (P&L: $%s)",
+		old.Symbol, sold, trade.Price.StringFixed(2), trade.RealizedPnl.StringFixed(2))
+}
+
+// reopenRecentlyClosed merges positions that reappear within the reopen window of
+// their close: the close's trade history is deleted, the original entry is carried
+// onto the snapshot position, and the symbol is added to previous so it isn't
+// treated as a new open.
+func (c *PositionsConsumer) reopenRecentlyClosed(previous map[string]*models.Position, positions []*models.Position, at time.Time) {
+	if c.reopenWindow <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for symbol, rc := range c.recentCloses {
+		if at.Sub(rc.closedAt) > c.reopenWindow {
+			delete(c.recentCloses, symbol)
+		}
+	}
+
+	for _, p := range positions {
+		if _, held := previous[p.Symbol]; held || !p.Quantity.IsPositive() {
+			continue
+		}
+		rc, ok := c.recentCloses[p.Symbol]
+		if !ok {
+			continue
+		}
+		delete(c.recentCloses, p.Symbol)
+
+		if err := c.repo.DeleteTradeHistory(rc.tradeID); err != nil {
+			log.Printf("Warning: failed to remove close for reopened %s: %v", p.Symbol, err)
+			continue
+		}
+		p.EntryDate = rc.position.EntryDate
+		p.EntryRSI = rc.position.EntryRSI
+		p.EntryReason = rc.position.EntryReason
+		p.RealizedPnl = rc.position.RealizedPnl
+		p.Notes = rc.position.Notes
+		p.Tags = rc.position.Tags
+		previous[p.Symbol] = rc.position
+
+		log.Printf("Position reopened within %s of close, merged: %s", c.reopenWindow, p.Symbol)
+	}
+}
+
+// recordPositionEvents appends an audit event for each position that opened, grew,
+// shrank or closed since the previous snapshot
+func (c *PositionsConsumer) recordPositionEvents(previous map[string]*models.Position, positions []*models.Position, at time.Time) {
+	for _, e := range positionEvents(previous, positions, at) {
+		if err := c.repo.CreatePositionEvent(e); err != nil {
+			log.Printf("Warning: failed to record %s event for %s: %v", e.EventType, e.Symbol, err)
+		}
+	}
+}
+
+// positionEvents diffs previous against the new snapshot, ordered by symbol. Buys
+// are priced from the change in cost basis and sells at the last known price.
+func positionEvents(previous map[string]*models.Position, positions []*models.Position, at time.Time) []*models.PositionEvent {
+	held := make(map[string]*models.Position, len(positions))
+	for _, p := range positions {
+		if p.Quantity.IsPositive() {
+			held[p.Symbol] = p
+		}
+	}
+
+	var events []*models.PositionEvent
+	add := func(symbol, eventType string, qty, after, price decimal.Decimal) {
+		events = append(events, &models.PositionEvent{
+			Symbol:           symbol,
+			EventType:        eventType,
+			Quantity:         qty,
+			PositionQuantity: after,
+			Price:            price,
+			OccurredAt:       at,
+		})
+	}
+
+	for symbol, p := range held {
+		old, ok := previous[symbol]
+		switch {
+		case !ok:
+			add(symbol, models.PositionEventOpen, p.Quantity, p.Quantity, p.EntryPrice)
+		case p.Quantity.GreaterThan(old.Quantity):
+			added := p.Quantity.Sub(old.Quantity)
+			price := p.Quantity.Mul(p.EntryPrice).Sub(old.Quantity.Mul(old.EntryPrice)).Div(added).Round(4)
+			if !price.IsPositive() {
+				price = p.CurrentPrice
+			}
+			add(symbol, models.PositionEventAdd, added, p.Quantity, price)
+		case p.Quantity.LessThan(old.Quantity):
+			add(symbol, models.PositionEventPartialSell, old.Quantity.Sub(p.Quantity), p.Quantity, lastPrice(p, old))
+		}
+	}
+	for symbol, old := range previous {
+		if _, ok := held[symbol]; !ok {
+			add(symbol, models.PositionEventClose, old.Quantity, decimal.Zero, lastPrice(old))
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool { return events[i].Symbol < events[j].Symbol })
+	return events
+}
+
+// recordPnlHistory stores the unrealized P&L of each held position that has a
+// current price, so a position's P&L can be charted over time
+func (c *PositionsConsumer) recordPnlHistory(positions []*models.Position, at time.Time) {
+	for _, p := range positions {
+		if !p.Quantity.IsPositive() || p.CurrentPrice.IsZero() {
+			continue
+		}
+		s := &models.PositionPnlSnapshot{
+			Symbol:           p.Symbol,
+			Quantity:         p.Quantity,
+			EntryPrice:       p.EntryPrice,
+			CurrentPrice:     p.CurrentPrice.Round(4),
+			UnrealizedPnl:    p.CurrentPrice.Sub(p.EntryPrice).Mul(p.Quantity).Round(4),
+			UnrealizedPnlPct: p.UnrealizedPnlPct,
+			RecordedAt:       at,
+		}
+		if err := c.repo.CreatePositionPnlSnapshot(s); err != nil {
+			log.Printf("Warning: failed to record P&L history for %s: %v", p.Symbol, err)
+		}
+	}
+}
+
+// lastPrice returns the first non-zero current price among positions, falling back
+// to the entry price of the first
+func lastPrice(positions ...*models.Position) decimal.Decimal {
+	for _, p := range positions {
+		if !p.CurrentPrice.IsZero() {
+			return p.CurrentPrice
+		}
+	}
+	return positions[0].EntryPrice
+}
+
+// closingTrade builds the SELL trade history for quantity shares of p sold at
+// exitPrice. Only the shares closed in this event are counted, at the position's
+// average entry price:
+//
+//	realized P&L = (exit price - average entry price) * quantity
+//
+// so partial sells and the final close record non-overlapping P&L.
+func closingTrade(p *models.Position, quantity, exitPrice decimal.Decimal, closedAt time.Time) *models.TradeHistory {
+	if exitPrice.IsZero() {
+		// No price seen since entry; record the close flat rather than as a total loss
+		exitPrice = p.EntryPrice
+	}
+
+	entryDate := p.EntryDate
+	exitDate := closedAt
+	holdingHours := int(exitDate.Sub(entryDate).Hours())
+
+	var pnlPct decimal.Decimal
+	if !p.EntryPrice.IsZero() {
+		pnlPct = exitPrice.Sub(p.EntryPrice).Div(p.EntryPrice).Mul(decimal.NewFromInt(100)).Round(4)
+	}
+
+	// The exit itself may be the lowest price seen
+	held := *p
+	held.ObservePrice(exitPrice)
+
+	return &models.TradeHistory{
+		Symbol:             p.Symbol,
+		TradeType:          models.TradeTypeSell,
+		Quantity:           quantity,
+		Price:              exitPrice,
+		TotalCost:          exitPrice.Mul(quantity),
+		EntryDate:          &entryDate,
+		ExitDate:           &exitDate,
+		HoldingPeriodHours: &holdingHours,
+		EntryRSI:           p.EntryRSI,
+		RealizedPnl:        exitPrice.Sub(p.EntryPrice).Mul(quantity),
+		RealizedPnlPct:     pnlPct,
+		MaxDrawdownPct:     held.MaxDrawdownPct(),
+		EntryReason:        p.EntryReason,
+		ExitReason:         "Position no longer in broker snapshot",
+		ExecutedAt:         closedAt,
+	}
+}
+
+// notifyClose records and sends the close summary for trade when close
+// notifications are enabled
+func (c *PositionsConsumer) notifyClose(trade *models.TradeHistory) {
+	if c.alertRepo == nil {
+		return
+	}
+	c.closeAlerts.notify(c.alertRepo, trade)
+}
+
+// AlertHistoryRepository records triggered alerts. *database.DB satisfies it.
+type AlertHistoryRepository interface {
+	CreateAlertHistory(h *models.AlertHistory) error
+}
+
+// closeNotifier records an alert summarizing a full close on channel, delivered
+// through notifiers when set and marked sent in marker
+type closeNotifier struct {
+	channel   string
+	notifiers *notify.Dispatcher
+	marker    notify.SentMarker
+}
+
+// notify records the close summary for trade in repo and sends it. It does
+// nothing without a channel; failures are logged and don't affect the close.
+func (n closeNotifier) notify(repo AlertHistoryRepository, trade *models.TradeHistory) {
+	if n.channel == "" {
+		return
+	}
+
+	held := "unknown period"
+	if trade.HoldingPeriodHours != nil {
+		held = holdingPeriod(*trade.HoldingPeriodHours)
+	}
+	alert := &models.AlertHistory{
+		Symbol:         trade.Symbol,
+		RuleType:       models.RuleTypePositionClosed,
+		TriggeredValue: trade.RealizedPnl,
+		Message: fmt.Sprintf("Closed %s: %s shares @ $%s, P&L $%s (%s%%) after %s",
+			trade.Symbol, trade.Quantity, trade.Price.StringFixed(2),
+			trade.RealizedPnl.StringFixed(2), trade.RealizedPnlPct.StringFixed(2), held),
+		NotificationChannel: n.channel,
+		TriggeredAt:         trade.ExecutedAt,
+	}
+	if err := repo.CreateAlertHistory(alert); err != nil {
+		log.Printf("Warning: failed to record close alert for %s: %v", trade.Symbol, err)
+		return
+	}
+	if n.notifiers == nil {
+		return
+	}
+	if err := n.notifiers.Deliver(context.Background(), alert, n.marker); err != nil {
+		log.Printf("Warning: failed to send close alert for %s via %s: %v", trade.Symbol, n.channel, err)
+	}
+}
+
+// holdingPeriod renders hours held as days, or as hours under a day
+func holdingPeriod(hours int) string {
+	if hours < 24 {
+		return pluralize(hours, "hour")
+	}
+	return pluralize(hours/24, "day")
+}
+
+func pluralize(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// alertOpenedPositions records an informational alert for each held symbol
+// that wasn't in previous, when position-open alerts are enabled
+func (c *PositionsConsumer) alertOpenedPositions(previous map[string]*models.Position, positions []*models.Position) {
+	if !c.alertOpen || c.alertRepo == nil {
+		return
+	}
+
+	for _, p := range positions {
+		if _, held := previous[p.Symbol]; held || !p.Quantity.IsPositive() {
+			continue
+		}
+		alert := &models.AlertHistory{
+			Symbol:         p.Symbol,
+			RuleType:       models.RuleTypePositionOpened,
+			TriggeredValue: p.EntryPrice,
+			Message: fmt.Sprintf("Opened %s: %s shares @ $%s",
+				p.Symbol, p.Quantity, p.EntryPrice.StringFixed(2)),
+		}
+		if err := c.alertRepo.CreateAlertHistory(alert); err != nil {
+			log.Printf("Warning: failed to record position open alert for %s: %v", p.Symbol, err)
+			continue
+		}
+		log.Printf("Position opened: %s", alert.Message)
+	}
+}
+
+// checkLeverage flags a snapshot that is over the configured leverage. Only the
+// first over-leveraged snapshot in a run is alerted.
+func (c *PositionsConsumer) checkLeverage(data models.PositionsEventData) {
+	if c.maxLeverage <= 0 {
+		return
+	}
+
+	leverage, over := data.CheckLeverage(c.maxLeverage)
+
+	c.mu.Lock()
+	alreadyFlagged := c.overLeveraged
+	c.overLeveraged = over
+	c.mu.Unlock()
+	if !over || alreadyFlagged {
+		return
+	}
+
+	message := fmt.Sprintf("Positions worth $%s are %sx buying power of $%s (max %sx)",
+		leverage.PositionsValue.StringFixed(2), leverage.Ratio.StringFixed(2),
+		leverage.BuyingPower.StringFixed(2), decimal.NewFromFloat(c.maxLeverage).String())
+	if leverage.NegativeCash {
+		message = fmt.Sprintf("Cash is negative ($%s) with positions worth $%s",
+			leverage.Cash.StringFixed(2), leverage.PositionsValue.StringFixed(2))
+	}
+	log.Printf("Warning: over-leveraged: %s", message)
+
+	if c.alertRepo == nil {
+		return
+	}
+	alert := &models.AlertHistory{
+		Symbol:         models.PortfolioSymbol,
+		RuleType:       models.RuleTypeOverLeveraged,
+		TriggeredValue: leverage.Ratio,
+		Message:        message,
+	}
+	if err := c.alertRepo.CreateAlertHistory(alert); err != nil {
+		log.Printf("Warning: failed to record leverage alert: %v", err)
+		// Leave unflagged so the next snapshot retries
+		c.mu.Lock()
+		c.overLeveraged = false
+		c.mu.Unlock()
+	}
+}
+
+// enrichNewPositions copies sector and industry from the stocks table onto
+// positions that weren't in previous; held positions keep what's stored. A symbol
+// without a stock row is left blank.
+func (c *PositionsConsumer) enrichNewPositions(previous map[string]*models.Position, positions []*models.Position) {
+	for _, p := range positions {
+		if _, held := previous[p.Symbol]; held || p.Sector != "" {
+			continue
+		}
+		stock, err := c.repo.GetStock(p.Symbol)
+		if err != nil {
+			log.Printf("No sector/industry for new position %s: %v", p.Symbol, err)
+			continue
+		}
+		p.Sector = stock.Sector
+		p.Industry = stock.Industry
+	}
+}
+
+// setEntryRSI sets the entry RSI of positions that weren't in previous and don't
+// already have one. A symbol without an RSI reading is left unset.
+func (c *PositionsConsumer) setEntryRSI(previous map[string]*models.Position, positions []*models.Position) {
+	if c.entryRSISource == EntryRSINone || c.rsiRepo == nil {
+		return
+	}
+	for _, p := range positions {
+		if _, held := previous[p.Symbol]; held || !p.EntryRSI.IsZero() {
+			continue
+		}
+		switch c.entryRSISource {
+		case EntryRSIAtEntry:
+			reading, err := c.rsiRepo.GetIndicatorAtOrBefore(p.Symbol, models.IndicatorRSI14, p.EntryDate)
+			if err != nil {
+				log.Printf("No entry RSI for new position %s: %v", p.Symbol, err)
+				continue
+			}
+			p.EntryRSI = reading.Value
+		case EntryRSILatest:
+			rsi, err := c.rsiRepo.GetLatestRSI(p.Symbol)
+			if err != nil {
+				log.Printf("No entry RSI for new position %s: %v", p.Symbol, err)
+				continue
+			}
+			p.EntryRSI = rsi
+		}
+	}
+}
+
+// setEntryReason sets the entry reason of positions that weren't in previous and
+// don't already have one, from the monitored stock's reason or else the default
+func (c *PositionsConsumer) setEntryReason(previous map[string]*models.Position, positions []*models.Position) {
+	if c.defaultEntryReason == "" && c.alertRepo == nil {
+		return
+	}
+
+	var reasons map[string]string
+	for _, p := range positions {
+		if _, held := previous[p.Symbol]; held || p.EntryReason != "" {
+			continue
+		}
+		if reasons == nil {
+			reasons = c.monitoredReasons()
+		}
+		if reason := reasons[p.Symbol]; reason != "" {
+			p.EntryReason = reason
+		} else {
+			p.EntryReason = c.defaultEntryReason
+		}
+	}
+}
+
+// monitoredReasons returns each monitored stock's reason by symbol. It's empty
+// without an alert repository or when the stocks can't be loaded.
+func (c *PositionsConsumer) monitoredReasons() map[string]string {
+	reasons := make(map[string]string)
+	if c.alertRepo == nil {
+		return reasons
+	}
+	stocks, err := c.alertRepo.GetAllMonitoredStocks()
+	if err != nil {
+		log.Printf("Warning: failed to load monitored stocks for entry reasons: %v", err)
+		return reasons
+	}
+	for _, s := range stocks {
+		reasons[s.Symbol] = s.Reason
+	}
+	return reasons
+}
+
+// setPositionSizes sizes each position against the account value, the snapshot's
+// buying power plus every position's market value. Sizes are left unset when
+// buying power is missing or doesn't parse.
+func (c *PositionsConsumer) setPositionSizes(data models.PositionsEventData, positions []*models.Position) {
+	buyingPower, err := decimal.NewFromString(data.BuyingPower)
+	if err != nil {
+		if data.BuyingPower != "" {
+			log.Printf("Warning: invalid buying_power %q, position sizes not set", data.BuyingPower)
+		}
+		return
+	}
+	models.SetPositionSizes(positions, models.AccountValue(buyingPower, positions))
+}
+
+// backfillEntryDates replaces the snapshot's placeholder entry date with the
+// first buy of the shares currently held, when raw trades are available. Buys
+// before the ledger last sold out belong to an earlier position and don't count.
+func (c *PositionsConsumer) backfillEntryDates(positions []*models.Position) {
+	if len(positions) == 0 {
+		return
+	}
+
+	symbols := make([]string, len(positions))
+	for i, p := range positions {
+		symbols[i] = p.Symbol
+	}
+
+	ledgers, err := c.repo.GetRawTradeLedgers(symbols)
+	if err != nil {
+		log.Printf("Warning: failed to load raw trades for entry dates: %v", err)
+		return
+	}
+	for _, p := range positions {
+		if _, _, opened := replayLedger(ledgers[p.Symbol], false); !opened.IsZero() {
+			p.EntryDate = opened
+		}
+	}
+}
+
+// reconstructEntryPrices replaces each position's entry price with the average
+// cost rebuilt from its raw trades, when enabled. Positions whose trades don't add
+// up to the quantity held keep the snapshot's price, since trades are missing.
+func (c *PositionsConsumer) reconstructEntryPrices(positions []*models.Position) {
+	if !c.entryFromTrades || len(positions) == 0 {
+		return
+	}
+
+	symbols := make([]string, len(positions))
+	for i, p := range positions {
+		symbols[i] = p.Symbol
+	}
+
+	ledgers, err := c.repo.GetRawTradeLedgers(symbols)
+	if err != nil {
+		log.Printf("Warning: failed to load raw trades for entry prices: %v", err)
+		return
+	}
+	for _, p := range positions {
+		ledger, ok := ledgers[p.Symbol]
+		if !ok {
+			continue
+		}
+		held, price := averageEntry(ledger, c.entryFees)
+		if !held.Equal(p.Quantity) {
+			log.Printf("Keeping snapshot entry price for %s: raw trades hold %s shares, snapshot %s",
+				p.Symbol, held, p.Quantity)
+			continue
+		}
+		p.EntryPrice = price.Round(4)
+	}
+}
+
+// averageEntry replays a ledger with the average cost method and returns the
+// shares still held and their average entry price. Sells leave the average
+// unchanged; selling out resets it for the next buy.
+func averageEntry(ledger []*models.RawTrade, includeFees bool) (decimal.Decimal, decimal.Decimal) {
+	held, cost, _ := replayLedger(ledger, includeFees)
+	if !held.IsPositive() {
+		return decimal.Zero, decimal.Zero
+	}
+	return held, cost.Div(held)
+}
+
+// replayLedger replays a ledger with the average cost method and returns the
+// shares still held, their cost and when the first of them was bought. Selling
+// out resets all three for the next buy.
+func replayLedger(ledger []*models.RawTrade, includeFees bool) (held, cost decimal.Decimal, opened time.Time) {
+	for _, t := range ledger {
+		switch t.Side {
+		case models.TradeTypeBuy:
+			if !held.IsPositive() {
+				opened = t.ExecutedAt
+			}
+			held = held.Add(t.Quantity)
+			cost = cost.Add(t.Quantity.Mul(t.Price))
+			if includeFees {
+				cost = cost.Add(t.Fees)
+			}
+		case models.TradeTypeSell:
+			if t.Quantity.GreaterThanOrEqual(held) {
+				held, cost, opened = decimal.Zero, decimal.Zero, time.Time{}
+				continue
+			}
+			cost = cost.Sub(cost.Mul(t.Quantity).Div(held))
+			held = held.Sub(t.Quantity)
+		}
+	}
+	return held, cost, opened
+}
+
+// checkTargets records an informational alert for each position whose current price
+// has reached its monitored stock's target. A symbol alerts once and re-arms after
+// the price falls back below target or the position disappears from the snapshot.
+func (c *PositionsConsumer) checkTargets(positions []*models.Position) error {
+	if c.alertRepo == nil {
+		return nil
+	}
+
+	stocks, err := c.alertRepo.GetAllMonitoredStocks()
+	if err != nil {
+		return fmt.Errorf("failed to get monitored stocks: %w", err)
+	}
+	targets := make(map[string]decimal.Decimal, len(stocks))
+	for _, s := range stocks {
+		if s.TargetPrice != nil && *s.TargetPrice > 0 {
+			targets[s.Symbol] = decimal.NewFromFloat(*s.TargetPrice)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hit := make(map[string]bool, len(positions))
+	for _, p := range positions {
+		target, ok := targets[p.Symbol]
+		if !ok || p.CurrentPrice.IsZero() || p.CurrentPrice.LessThan(target) {
+			continue
+		}
+		hit[p.Symbol] = true
+		if c.targetsHit[p.Symbol] {
+			continue
+		}
+
+		alert := &models.AlertHistory{
+			Symbol:         p.Symbol,
+			RuleType:       models.RuleTypeTargetHit,
+			TriggeredValue: p.CurrentPrice,
+			Message: fmt.Sprintf("%s reached target $%s (current: $%s)",
+				p.Symbol, target.StringFixed(2), p.CurrentPrice.StringFixed(2)),
+		}
+		if err := c.alertRepo.CreateAlertHistory(alert); err != nil {
+			log.Printf("Warning: failed to record target alert for %s: %v", p.Symbol, err)
+			// Leave unmarked so the next snapshot retries
+			delete(hit, p.Symbol)
+			continue
+		}
+		log.Printf("Target hit: %s", alert.Message)
+	}
+	c.targetsHit = hit
+
 	return nil
 }
 
@@ -153,7 +1123,7 @@ func (c *PositionsConsumer) convertPositionData(pd models.PositionData, now time
 	}
 
 	return &models.Position{
-		Symbol:           pd.Symbol,
+		Symbol:           c.aliases.Canonical(pd.Symbol),
 		Quantity:         quantity,
 		EntryPrice:       entryPrice,
 		EntryDate:        now, // We don't have the actual entry date from Robinhood snapshot
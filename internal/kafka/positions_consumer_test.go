@@ -3,6 +3,7 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"sync"
 	"testing"
 	"time"
@@ -12,13 +13,96 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/trogers1052/stock-alert-system/internal/models"
+	"github.com/trogers1052/stock-alert-system/internal/notify"
 )
 
 type mockPositionsRepo struct {
-	mu     sync.Mutex
-	calls  int
-	last   []*models.Position
-	called chan struct{}
+	mu       sync.Mutex
+	calls    int
+	last     []*models.Position
+	called   chan struct{}
+	ledgers  map[string][]*models.RawTrade
+	stocks   map[string]*models.Stock
+	trades   []*models.TradeHistory
+	events   []*models.PositionEvent
+	pnl      []*models.PositionPnlSnapshot
+	upserts  int
+	realized map[string]decimal.Decimal
+}
+
+func (m *mockPositionsRepo) CreatePositionEvent(e *models.PositionEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, e)
+	return nil
+}
+
+func (m *mockPositionsRepo) Events() []*models.PositionEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.events
+}
+
+func (m *mockPositionsRepo) CreatePositionPnlSnapshot(s *models.PositionPnlSnapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pnl = append(m.pnl, s)
+	return nil
+}
+
+func (m *mockPositionsRepo) PnlHistory() []*models.PositionPnlSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pnl
+}
+
+func (m *mockPositionsRepo) CreateTradeHistory(t *models.TradeHistory) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t.ID = len(m.trades) + 1
+	m.trades = append(m.trades, t)
+	return nil
+}
+
+func (m *mockPositionsRepo) DeleteTradeHistory(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, t := range m.trades {
+		if t.ID == id {
+			m.trades = append(m.trades[:i], m.trades[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("trade not found: %d", id)
+}
+
+func (m *mockPositionsRepo) Trades() []*models.TradeHistory {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.trades
+}
+
+func (m *mockPositionsRepo) GetStock(symbol string) (*models.Stock, error) {
+	if s, ok := m.stocks[symbol]; ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("stock not found: %s", symbol)
+}
+
+func (m *mockPositionsRepo) GetRawTradeLedgers(symbols []string) (map[string][]*models.RawTrade, error) {
+	ledgers := make(map[string][]*models.RawTrade)
+	for _, s := range symbols {
+		if ledger, ok := m.ledgers[s]; ok {
+			ledgers[s] = ledger
+		}
+	}
+	return ledgers, nil
+}
+
+func (m *mockPositionsRepo) GetAllPositions() ([]*models.Position, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last, nil
 }
 
 func (m *mockPositionsRepo) ReplaceAllPositions(positions []*models.Position) error {
@@ -36,6 +120,57 @@ func (m *mockPositionsRepo) ReplaceAllPositions(positions []*models.Position) er
 	return nil
 }
 
+func (m *mockPositionsRepo) UpsertPosition(p *models.Position) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.upserts++
+	for i, existing := range m.last {
+		if existing.Symbol == p.Symbol {
+			p.EntryDate = existing.EntryDate
+			m.last[i] = p
+			return nil
+		}
+	}
+	m.last = append(m.last, p)
+	return nil
+}
+
+// AddRealizedPnl adds to the held position's realized P&L, which the database
+// carries across snapshots
+func (m *mockPositionsRepo) AddRealizedPnl(symbol string, amount decimal.Decimal) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range m.last {
+		if p.Symbol == symbol {
+			if m.realized == nil {
+				m.realized = make(map[string]decimal.Decimal)
+			}
+			m.realized[symbol] = m.realized[symbol].Add(amount)
+			return nil
+		}
+	}
+	return fmt.Errorf("position not found for symbol: %s", symbol)
+}
+
+func (m *mockPositionsRepo) Realized(symbol string) decimal.Decimal {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.realized[symbol]
+}
+
+func (m *mockPositionsRepo) DeletePositionBySymbol(symbol string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, p := range m.last {
+		if p.Symbol == symbol {
+			m.last = append(m.last[:i:i], m.last[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("position not found for symbol: %s", symbol)
+}
+
 func (m *mockPositionsRepo) Calls() int {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -164,3 +299,889 @@ func TestPositionsConsumer_Start_consumesAndProcessesMessages(t *testing.T) {
 	assert.True(t, p.UnrealizedPnlPct.Equal(decimal.RequireFromString("10")))
 	assert.False(t, p.EntryDate.IsZero())
 }
+
+type mockPositionAlertRepo struct {
+	mu     sync.Mutex
+	stocks []*models.MonitoredStock
+	alerts []*models.AlertHistory
+}
+
+func (m *mockPositionAlertRepo) GetAllMonitoredStocks() ([]*models.MonitoredStock, error) {
+	return m.stocks, nil
+}
+
+func (m *mockPositionAlertRepo) CreateAlertHistory(h *models.AlertHistory) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.alerts = append(m.alerts, h)
+	return nil
+}
+
+func (m *mockPositionAlertRepo) Alerts() []*models.AlertHistory {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.alerts
+}
+
+func positionsSnapshot(t *testing.T, positions ...models.PositionData) kafka.Message {
+	t.Helper()
+	payload, err := json.Marshal(models.PositionsEvent{
+		EventType: "POSITIONS_SNAPSHOT",
+		Source:    "robinhood",
+		Timestamp: time.Now().Format(time.RFC3339),
+		Data:      models.PositionsEventData{Positions: positions},
+	})
+	require.NoError(t, err)
+	return kafka.Message{Value: payload}
+}
+
+func TestPositionsConsumer_processMessage_alertsWhenTargetHit(t *testing.T) {
+	target := 200.0
+	alertRepo := &mockPositionAlertRepo{
+		stocks: []*models.MonitoredStock{
+			{Symbol: "AAPL", TargetPrice: &target},
+			{Symbol: "MSFT", TargetPrice: &target},
+		},
+	}
+	consumer := &PositionsConsumer{repo: &mockPositionsRepo{}}
+	consumer.SetAlertRepository(alertRepo)
+
+	// AAPL at 205 (above target), MSFT at 150 (below), TSLA has no target
+	msg := positionsSnapshot(t,
+		models.PositionData{Symbol: "AAPL", Quantity: "10", AverageBuyPrice: "150", Equity: "2050"},
+		models.PositionData{Symbol: "MSFT", Quantity: "10", AverageBuyPrice: "120", Equity: "1500"},
+		models.PositionData{Symbol: "TSLA", Quantity: "10", AverageBuyPrice: "100", Equity: "5000"},
+	)
+	require.NoError(t, consumer.processMessage(msg))
+
+	alerts := alertRepo.Alerts()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, "AAPL", alerts[0].Symbol)
+	assert.Equal(t, models.RuleTypeTargetHit, alerts[0].RuleType)
+	assert.True(t, decimal.NewFromInt(205).Equal(alerts[0].TriggeredValue))
+	assert.Contains(t, alerts[0].Message, "reached target $200.00")
+
+	// A repeated snapshot at target should not alert again
+	require.NoError(t, consumer.processMessage(msg))
+	assert.Len(t, alertRepo.Alerts(), 1)
+
+	// Dropping below target re-arms the alert
+	require.NoError(t, consumer.processMessage(positionsSnapshot(t,
+		models.PositionData{Symbol: "AAPL", Quantity: "10", AverageBuyPrice: "150", Equity: "1900"},
+	)))
+	require.NoError(t, consumer.processMessage(msg))
+	assert.Len(t, alertRepo.Alerts(), 2)
+}
+
+func TestPositionsConsumer_processMessage_backfillsEntryDateFromFirstBuy(t *testing.T) {
+	firstBuy := time.Date(2024, 3, 1, 14, 30, 0, 0, time.UTC)
+	repo := &mockPositionsRepo{ledgers: map[string][]*models.RawTrade{"AAPL": {
+		createTestRawTrade("buy-1", "AAPL", models.TradeTypeBuy, 6, 150, firstBuy),
+		createTestRawTrade("buy-2", "AAPL", models.TradeTypeBuy, 4, 155, firstBuy.AddDate(0, 0, 7)),
+	}}}
+	consumer := &PositionsConsumer{repo: repo}
+
+	before := time.Now()
+	require.NoError(t, consumer.processMessage(positionsSnapshot(t,
+		models.PositionData{Symbol: "AAPL", Quantity: "10", AverageBuyPrice: "150", Equity: "1600"},
+		models.PositionData{Symbol: "MSFT", Quantity: "5", AverageBuyPrice: "300", Equity: "1550"},
+	)))
+
+	positions := repo.LastPositions()
+	require.Len(t, positions, 2)
+	assert.True(t, firstBuy.Equal(positions[0].EntryDate), "AAPL entry date should match first buy")
+	// No raw trades for MSFT, so the snapshot time is kept
+	assert.False(t, positions[1].EntryDate.Before(before))
+}
+
+func TestPositionsConsumer_processMessage_backfillsEntryDateAfterSellOut(t *testing.T) {
+	day := time.Date(2024, 3, 1, 14, 30, 0, 0, time.UTC)
+	rebuy := day.AddDate(0, 2, 0)
+	repo := &mockPositionsRepo{ledgers: map[string][]*models.RawTrade{"AAPL": {
+		createTestRawTrade("buy-1", "AAPL", models.TradeTypeBuy, 10, 150, day),
+		createTestRawTrade("sell-1", "AAPL", models.TradeTypeSell, 10, 170, day.AddDate(0, 1, 0)),
+		createTestRawTrade("buy-2", "AAPL", models.TradeTypeBuy, 5, 160, rebuy),
+	}}}
+	consumer := &PositionsConsumer{repo: repo}
+
+	require.NoError(t, consumer.processMessage(positionsSnapshot(t,
+		models.PositionData{Symbol: "AAPL", Quantity: "5", AverageBuyPrice: "160", Equity: "820"},
+	)))
+
+	positions := repo.LastPositions()
+	require.Len(t, positions, 1)
+	assert.True(t, rebuy.Equal(positions[0].EntryDate), "buys before selling out belong to the earlier position, got %s", positions[0].EntryDate)
+}
+
+func TestPositionsConsumer_processMessage_ignoresOlderSnapshot(t *testing.T) {
+	repo := &mockPositionsRepo{}
+	consumer := &PositionsConsumer{repo: repo}
+
+	snapshotAt := func(ts time.Time, equity string) kafka.Message {
+		payload, err := json.Marshal(models.PositionsEvent{
+			EventType: "POSITIONS_SNAPSHOT",
+			Source:    "robinhood",
+			Timestamp: ts.Format(time.RFC3339),
+			Data: models.PositionsEventData{Positions: []models.PositionData{
+				{Symbol: "AAPL", Quantity: "10", AverageBuyPrice: "100", Equity: equity},
+			}},
+		})
+		require.NoError(t, err)
+		return kafka.Message{Value: payload}
+	}
+
+	newer := time.Now().Truncate(time.Second)
+	older := newer.Add(-time.Minute)
+
+	require.NoError(t, consumer.processMessage(snapshotAt(newer, "1500")))
+	require.NoError(t, consumer.processMessage(snapshotAt(older, "1100")))
+
+	assert.Equal(t, 1, repo.Calls(), "older snapshot should not be applied")
+	positions := repo.LastPositions()
+	require.Len(t, positions, 1)
+	assert.True(t, decimal.NewFromInt(150).Equal(positions[0].CurrentPrice))
+
+	// A later snapshot is still applied
+	require.NoError(t, consumer.processMessage(snapshotAt(newer.Add(time.Minute), "1600")))
+	assert.Equal(t, 2, repo.Calls())
+}
+
+func TestPositionsConsumer_processMessage_alertsOnPositionOpenWhenEnabled(t *testing.T) {
+	held := &models.Position{Symbol: "AAPL", Quantity: decimal.NewFromInt(10), EntryPrice: decimal.NewFromInt(150)}
+	snapshot := positionsSnapshot(t,
+		models.PositionData{Symbol: "AAPL", Quantity: "10", AverageBuyPrice: "150", Equity: "1600"},
+		models.PositionData{Symbol: "MSFT", Quantity: "5", AverageBuyPrice: "300", Equity: "1550"},
+	)
+
+	t.Run("disabled", func(t *testing.T) {
+		alertRepo := &mockPositionAlertRepo{}
+		consumer := &PositionsConsumer{repo: &mockPositionsRepo{last: []*models.Position{held}}}
+		consumer.SetAlertRepository(alertRepo)
+
+		require.NoError(t, consumer.processMessage(snapshot))
+		assert.Empty(t, alertRepo.Alerts())
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		alertRepo := &mockPositionAlertRepo{}
+		consumer := &PositionsConsumer{repo: &mockPositionsRepo{last: []*models.Position{held}}}
+		consumer.SetAlertRepository(alertRepo)
+		consumer.SetPositionOpenAlerts(true)
+
+		require.NoError(t, consumer.processMessage(snapshot))
+
+		// Only MSFT is new; AAPL was already held
+		alerts := alertRepo.Alerts()
+		require.Len(t, alerts, 1)
+		assert.Equal(t, "MSFT", alerts[0].Symbol)
+		assert.Equal(t, models.RuleTypePositionOpened, alerts[0].RuleType)
+		assert.True(t, decimal.NewFromInt(300).Equal(alerts[0].TriggeredValue))
+		assert.Equal(t, "Opened MSFT: 5 shares @ $300.00", alerts[0].Message)
+
+		// The same snapshot again opens nothing new
+		require.NoError(t, consumer.processMessage(snapshot))
+		assert.Len(t, alertRepo.Alerts(), 1)
+	})
+}
+
+func TestPositionsConsumer_processMessage_recordsCloseForDisappearedPosition(t *testing.T) {
+	entryDate := time.Now().Truncate(time.Second).Add(-48 * time.Hour)
+	repo := &mockPositionsRepo{last: []*models.Position{
+		{Symbol: "AAPL", Quantity: decimal.NewFromInt(10), EntryPrice: decimal.NewFromInt(150),
+			CurrentPrice: decimal.NewFromInt(160), EntryDate: entryDate},
+		{Symbol: "TSLA", Quantity: decimal.NewFromInt(4), EntryPrice: decimal.NewFromInt(250),
+			CurrentPrice: decimal.NewFromInt(200), EntryDate: entryDate},
+	}}
+	consumer := &PositionsConsumer{repo: repo}
+
+	// TSLA was sold outside the trade feed, so it's missing from the new snapshot
+	require.NoError(t, consumer.processMessage(positionsSnapshot(t,
+		models.PositionData{Symbol: "AAPL", Quantity: "10", AverageBuyPrice: "150", Equity: "1650"},
+	)))
+
+	trades := repo.Trades()
+	require.Len(t, trades, 1)
+	trade := trades[0]
+	assert.Equal(t, "TSLA", trade.Symbol)
+	assert.Equal(t, models.TradeTypeSell, trade.TradeType)
+	assert.True(t, decimal.NewFromInt(4).Equal(trade.Quantity))
+	assert.True(t, decimal.NewFromInt(200).Equal(trade.Price), "exit should use last known current price")
+	assert.True(t, decimal.NewFromInt(800).Equal(trade.TotalCost))
+	assert.True(t, decimal.NewFromInt(-200).Equal(trade.RealizedPnl))
+	assert.True(t, decimal.NewFromInt(-20).Equal(trade.RealizedPnlPct))
+	require.NotNil(t, trade.EntryDate)
+	assert.True(t, entryDate.Equal(*trade.EntryDate))
+	require.NotNil(t, trade.HoldingPeriodHours)
+	assert.Equal(t, 48, *trade.HoldingPeriodHours)
+
+	// Re-applying the same snapshot doesn't close anything else
+	require.NoError(t, consumer.processMessage(positionsSnapshot(t,
+		models.PositionData{Symbol: "AAPL", Quantity: "10", AverageBuyPrice: "150", Equity: "1650"},
+	)))
+	assert.Len(t, repo.Trades(), 1)
+}
+
+func TestPositionsConsumer_processMessage_partialClosesCountOnlySoldShares(t *testing.T) {
+	entryDate := time.Now().Truncate(time.Second).Add(-72 * time.Hour)
+	repo := &mockPositionsRepo{last: []*models.Position{
+		{Symbol: "AAPL", Quantity: decimal.NewFromInt(10), EntryPrice: decimal.NewFromInt(100),
+			CurrentPrice: decimal.NewFromInt(105), EntryDate: entryDate},
+	}}
+	consumer := &PositionsConsumer{repo: repo}
+
+	// Sell 4 at 110, then the remaining 6 at 120
+	require.NoError(t, consumer.processMessage(positionsSnapshot(t,
+		models.PositionData{Symbol: "AAPL", Quantity: "6", AverageBuyPrice: "100", Equity: "660"},
+	)))
+	require.NoError(t, consumer.processMessage(positionsSnapshot(t,
+		models.PositionData{Symbol: "AAPL", Quantity: "6", AverageBuyPrice: "100", Equity: "720"},
+	)))
+	require.NoError(t, consumer.processMessage(positionsSnapshot(t)))
+
+	trades := repo.Trades()
+	require.Len(t, trades, 2)
+
+	partial := trades[0]
+	assert.True(t, decimal.NewFromInt(4).Equal(partial.Quantity), "got %s", partial.Quantity)
+	assert.True(t, decimal.NewFromInt(110).Equal(partial.Price))
+	// (110 - 100) * 4
+	assert.True(t, decimal.NewFromInt(40).Equal(partial.RealizedPnl), "got %s", partial.RealizedPnl)
+	assert.True(t, decimal.NewFromInt(10).Equal(partial.RealizedPnlPct))
+
+	final := trades[1]
+	assert.True(t, decimal.NewFromInt(6).Equal(final.Quantity), "got %s", final.Quantity)
+	assert.True(t, decimal.NewFromInt(120).Equal(final.Price))
+	// (120 - 100) * 6
+	assert.True(t, decimal.NewFromInt(120).Equal(final.RealizedPnl), "got %s", final.RealizedPnl)
+	assert.True(t, decimal.NewFromInt(20).Equal(final.RealizedPnlPct))
+}
+
+// TestPositionsConsumer_processMessage_accumulatesRealizedPnlOnScaleOuts verifies
+// each partial close adds its P&L to the position's running total
+func TestPositionsConsumer_processMessage_accumulatesRealizedPnlOnScaleOuts(t *testing.T) {
+	repo := &mockPositionsRepo{last: []*models.Position{
+		{Symbol: "AAPL", Quantity: decimal.NewFromInt(10), EntryPrice: decimal.NewFromInt(100),
+			CurrentPrice: decimal.NewFromInt(105), EntryDate: time.Now().Add(-72 * time.Hour)},
+	}}
+	consumer := &PositionsConsumer{repo: repo}
+
+	// Sell 4 at 110, then 3 more at 120
+	require.NoError(t, consumer.processMessage(positionsSnapshot(t,
+		models.PositionData{Symbol: "AAPL", Quantity: "6", AverageBuyPrice: "100", Equity: "660"},
+	)))
+	require.NoError(t, consumer.processMessage(positionsSnapshot(t,
+		models.PositionData{Symbol: "AAPL", Quantity: "3", AverageBuyPrice: "100", Equity: "360"},
+	)))
+
+	require.Len(t, repo.Trades(), 2)
+	// (110 - 100) * 4 + (120 - 100) * 3
+	assert.Equal(t, "100", repo.Realized("AAPL").String())
+}
+
+func TestPositionsConsumer_processMessage_closeRecordsMaxDrawdown(t *testing.T) {
+	entryDate := time.Now().Truncate(time.Second).Add(-72 * time.Hour)
+	repo := &mockPositionsRepo{last: []*models.Position{
+		{Symbol: "AAPL", Quantity: decimal.NewFromInt(10), EntryPrice: decimal.NewFromInt(150),
+			CurrentPrice: decimal.NewFromInt(160), LowestPrice: decimal.NewFromInt(135), EntryDate: entryDate},
+		{Symbol: "TSLA", Quantity: decimal.NewFromInt(4), EntryPrice: decimal.NewFromInt(250),
+			CurrentPrice: decimal.NewFromInt(200), LowestPrice: decimal.NewFromInt(225), EntryDate: entryDate},
+		{Symbol: "NVDA", Quantity: decimal.NewFromInt(2), EntryPrice: decimal.NewFromInt(100),
+			CurrentPrice: decimal.NewFromInt(120), LowestPrice: decimal.NewFromInt(105), EntryDate: entryDate},
+	}}
+	consumer := &PositionsConsumer{repo: repo}
+
+	require.NoError(t, consumer.processMessage(positionsSnapshot(t)))
+
+	drawdowns := make(map[string]decimal.Decimal)
+	for _, trade := range repo.Trades() {
+		drawdowns[trade.Symbol] = trade.MaxDrawdownPct
+	}
+	require.Len(t, drawdowns, 3)
+	assert.True(t, decimal.NewFromInt(10).Equal(drawdowns["AAPL"]), "running low of 135 is 10%% below entry, got %s", drawdowns["AAPL"])
+	assert.True(t, decimal.NewFromInt(20).Equal(drawdowns["TSLA"]), "exit below the running low counts, got %s", drawdowns["TSLA"])
+	assert.True(t, drawdowns["NVDA"].IsZero(), "never below entry, got %s", drawdowns["NVDA"])
+}
+
+func TestPositionsConsumer_processMessage_mergesReopenWithinWindow(t *testing.T) {
+	entryDate := time.Date(2025, 6, 2, 14, 30, 0, 0, time.UTC)
+	held := func() *mockPositionsRepo {
+		return &mockPositionsRepo{last: []*models.Position{{
+			Symbol: "AAPL", Quantity: decimal.NewFromInt(10), EntryPrice: decimal.NewFromInt(150),
+			CurrentPrice: decimal.NewFromInt(155), EntryDate: entryDate, EntryReason: "Breakout",
+		}}}
+	}
+	snapshot := func(ts time.Time, positions ...models.PositionData) kafka.Message {
+		payload, err := json.Marshal(models.PositionsEvent{
+			EventType: "POSITIONS_SNAPSHOT",
+			Timestamp: ts.Format(time.RFC3339),
+			Data:      models.PositionsEventData{Positions: positions},
+		})
+		require.NoError(t, err)
+		return kafka.Message{Value: payload}
+	}
+	aapl := models.PositionData{Symbol: "AAPL", Quantity: "10", AverageBuyPrice: "151", Equity: "1560"}
+	closedAt := time.Now().Truncate(time.Second)
+
+	t.Run("buy back inside window", func(t *testing.T) {
+		repo := held()
+		alertRepo := &mockPositionAlertRepo{}
+		consumer := &PositionsConsumer{repo: repo}
+		consumer.SetAlertRepository(alertRepo)
+		consumer.SetPositionOpenAlerts(true)
+		consumer.SetReopenWindow(5 * time.Minute)
+
+		require.NoError(t, consumer.processMessage(snapshot(closedAt)))
+		require.Len(t, repo.Trades(), 1, "full close records a trade")
+
+		require.NoError(t, consumer.processMessage(snapshot(closedAt.Add(2*time.Minute), aapl)))
+
+		assert.Empty(t, repo.Trades(), "merged re-entry removes the close")
+		positions := repo.LastPositions()
+		require.Len(t, positions, 1)
+		assert.True(t, entryDate.Equal(positions[0].EntryDate), "original entry date is restored")
+		assert.Equal(t, "Breakout", positions[0].EntryReason)
+		assert.True(t, decimal.NewFromInt(151).Equal(positions[0].EntryPrice))
+		assert.Empty(t, alertRepo.Alerts(), "a merged re-entry is not a new open")
+	})
+
+	t.Run("buy back after window", func(t *testing.T) {
+		repo := held()
+		consumer := &PositionsConsumer{repo: repo}
+		consumer.SetReopenWindow(5 * time.Minute)
+
+		require.NoError(t, consumer.processMessage(snapshot(closedAt)))
+		require.NoError(t, consumer.processMessage(snapshot(closedAt.Add(10*time.Minute), aapl)))
+
+		assert.Len(t, repo.Trades(), 1, "close stands")
+		positions := repo.LastPositions()
+		require.Len(t, positions, 1)
+		assert.False(t, entryDate.Equal(positions[0].EntryDate))
+	})
+}
+
+func TestPositionsConsumer_processMessage_canonicalizesAliasedSymbols(t *testing.T) {
+	repo := &mockPositionsRepo{last: []*models.Position{{
+		Symbol: "BRK-B", Quantity: decimal.NewFromInt(5), EntryPrice: decimal.NewFromInt(400),
+		CurrentPrice: decimal.NewFromInt(420), EntryDate: time.Now().Add(-24 * time.Hour),
+	}}}
+	consumer := &PositionsConsumer{repo: repo}
+	consumer.SetSymbolAliases(models.SymbolAliases{"BRK.B": "BRK-B"})
+
+	require.NoError(t, consumer.processMessage(positionsSnapshot(t,
+		models.PositionData{Symbol: "BRK.B", Quantity: "5", AverageBuyPrice: "400", Equity: "2150"},
+	)))
+
+	positions := repo.LastPositions()
+	require.Len(t, positions, 1)
+	assert.Equal(t, "BRK-B", positions[0].Symbol)
+	// Same position under its canonical symbol, so nothing was closed
+	assert.Empty(t, repo.Trades())
+}
+
+func TestPositionsConsumer_processMessage_recordsLifecycleEvents(t *testing.T) {
+	repo := &mockPositionsRepo{}
+	consumer := &PositionsConsumer{repo: repo}
+
+	snapshots := [][]models.PositionData{
+		{{Symbol: "AAPL", Quantity: "10", AverageBuyPrice: "150", Equity: "1500"}},
+		// Buy 5 more @ 162: average becomes (1500 + 810) / 15 = 154
+		{{Symbol: "AAPL", Quantity: "15", AverageBuyPrice: "154", Equity: "2430"}},
+		// Sell 7 @ 170
+		{{Symbol: "AAPL", Quantity: "8", AverageBuyPrice: "154", Equity: "1360"}},
+		{},
+	}
+	for _, s := range snapshots {
+		require.NoError(t, consumer.processMessage(positionsSnapshot(t, s...)))
+	}
+
+	events := repo.Events()
+	require.Len(t, events, 4)
+
+	assert.Equal(t, models.PositionEventOpen, events[0].EventType)
+	assert.Equal(t, "10", events[0].Quantity.String())
+	assert.Equal(t, "150", events[0].Price.String())
+
+	assert.Equal(t, models.PositionEventAdd, events[1].EventType)
+	assert.Equal(t, "5", events[1].Quantity.String())
+	assert.Equal(t, "15", events[1].PositionQuantity.String())
+	assert.Equal(t, "162", events[1].Price.String())
+
+	assert.Equal(t, models.PositionEventPartialSell, events[2].EventType)
+	assert.Equal(t, "7", events[2].Quantity.String())
+	assert.Equal(t, "8", events[2].PositionQuantity.String())
+	assert.Equal(t, "170", events[2].Price.String())
+
+	assert.Equal(t, models.PositionEventClose, events[3].EventType)
+	assert.Equal(t, "8", events[3].Quantity.String())
+	assert.True(t, events[3].PositionQuantity.IsZero())
+	assert.Equal(t, "170", events[3].Price.String())
+
+	// A fresh buy after the close is an open
+	require.NoError(t, consumer.processMessage(positionsSnapshot(t,
+		models.PositionData{Symbol: "AAPL", Quantity: "2", AverageBuyPrice: "171", Equity: "342"},
+	)))
+	events = repo.Events()
+	require.Len(t, events, 5)
+	assert.Equal(t, models.PositionEventOpen, events[4].EventType)
+	assert.Equal(t, "2", events[4].Quantity.String())
+	assert.Equal(t, "171", events[4].Price.String())
+}
+
+func positionChange(t *testing.T, eventType string, position models.PositionData) kafka.Message {
+	t.Helper()
+	payload, err := json.Marshal(models.PositionsEvent{
+		EventType: eventType,
+		Source:    "robinhood",
+		Timestamp: time.Now().Format(time.RFC3339),
+		Data:      models.PositionsEventData{Position: &position},
+	})
+	require.NoError(t, err)
+	return kafka.Message{Value: payload}
+}
+
+func TestPositionsConsumer_processMessage_positionUpdated(t *testing.T) {
+	repo := &mockPositionsRepo{}
+	consumer := &PositionsConsumer{repo: repo}
+
+	require.NoError(t, consumer.processMessage(positionsSnapshot(t,
+		models.PositionData{Symbol: "AAPL", Quantity: "10", AverageBuyPrice: "150", Equity: "1600"},
+		models.PositionData{Symbol: "MSFT", Quantity: "5", AverageBuyPrice: "400", Equity: "2000"},
+	)))
+	entry := repo.LastPositions()[0].EntryDate
+
+	// Adds to AAPL without replacing the book
+	require.NoError(t, consumer.processMessage(positionChange(t, models.PositionsEventUpdated,
+		models.PositionData{Symbol: "AAPL", Quantity: "15", AverageBuyPrice: "154", Equity: "2430"})))
+	// Opens TSLA
+	require.NoError(t, consumer.processMessage(positionChange(t, models.PositionsEventUpdated,
+		models.PositionData{Symbol: "TSLA", Quantity: "2", AverageBuyPrice: "250", Equity: "500"})))
+
+	assert.Equal(t, 1, repo.Calls(), "updates must not replace all positions")
+	assert.Equal(t, 2, repo.upserts)
+
+	held := make(map[string]*models.Position)
+	for _, p := range repo.LastPositions() {
+		held[p.Symbol] = p
+	}
+	require.Len(t, held, 3)
+	assert.Equal(t, "15", held["AAPL"].Quantity.String())
+	assert.Equal(t, "162", held["AAPL"].CurrentPrice.String())
+	assert.True(t, entry.Equal(held["AAPL"].EntryDate), "entry date is kept")
+	assert.Equal(t, "5", held["MSFT"].Quantity.String())
+	assert.Equal(t, "2", held["TSLA"].Quantity.String())
+
+	events := repo.Events()
+	require.Len(t, events, 4)
+	assert.Equal(t, models.PositionEventAdd, events[2].EventType)
+	assert.Equal(t, "AAPL", events[2].Symbol)
+	assert.Equal(t, models.PositionEventOpen, events[3].EventType)
+	assert.Equal(t, "TSLA", events[3].Symbol)
+	assert.Empty(t, repo.Trades())
+}
+
+func TestPositionsConsumer_processMessage_positionClosed(t *testing.T) {
+	repo := &mockPositionsRepo{}
+	consumer := &PositionsConsumer{repo: repo}
+
+	require.NoError(t, consumer.processMessage(positionsSnapshot(t,
+		models.PositionData{Symbol: "AAPL", Quantity: "10", AverageBuyPrice: "150", Equity: "1700"},
+		models.PositionData{Symbol: "MSFT", Quantity: "5", AverageBuyPrice: "400", Equity: "2000"},
+	)))
+
+	require.NoError(t, consumer.processMessage(positionChange(t, models.PositionsEventClosed,
+		models.PositionData{Symbol: "AAPL"})))
+
+	positions := repo.LastPositions()
+	require.Len(t, positions, 1)
+	assert.Equal(t, "MSFT", positions[0].Symbol)
+
+	trades := repo.Trades()
+	require.Len(t, trades, 1)
+	assert.Equal(t, "AAPL", trades[0].Symbol)
+	assert.Equal(t, "200", trades[0].RealizedPnl.String())
+
+	events := repo.Events()
+	assert.Equal(t, models.PositionEventClose, events[len(events)-1].EventType)
+
+	// An update to zero shares closes too; a close for an unheld symbol is ignored
+	require.NoError(t, consumer.processMessage(positionChange(t, models.PositionsEventUpdated,
+		models.PositionData{Symbol: "MSFT", Quantity: "0", AverageBuyPrice: "400", Equity: "0"})))
+	require.NoError(t, consumer.processMessage(positionChange(t, models.PositionsEventClosed,
+		models.PositionData{Symbol: "NFLX"})))
+	assert.Empty(t, repo.LastPositions())
+	assert.Len(t, repo.Trades(), 2)
+
+	assert.Error(t, consumer.processMessage(positionChange(t, models.PositionsEventClosed, models.PositionData{})))
+}
+
+func TestPositionsConsumer_processMessage_skipsDuplicateSnapshot(t *testing.T) {
+	repo := &mockPositionsRepo{}
+	consumer := &PositionsConsumer{repo: repo}
+
+	msg := positionsSnapshot(t, models.PositionData{Symbol: "AAPL", Quantity: "10", AverageBuyPrice: "100", Equity: "1100"})
+	require.NoError(t, consumer.processMessage(msg))
+	fingerprint := consumer.LastSnapshotFingerprint()
+	require.NotEmpty(t, fingerprint)
+
+	// Redelivery of the same message is skipped
+	require.NoError(t, consumer.processMessage(msg))
+	assert.Equal(t, 1, repo.Calls())
+	assert.Equal(t, fingerprint, consumer.LastSnapshotFingerprint())
+
+	// A snapshot with different holdings is still applied
+	require.NoError(t, consumer.processMessage(positionsSnapshot(t,
+		models.PositionData{Symbol: "AAPL", Quantity: "12", AverageBuyPrice: "100", Equity: "1320"})))
+	assert.Equal(t, 2, repo.Calls())
+	assert.NotEqual(t, fingerprint, consumer.LastSnapshotFingerprint())
+}
+
+func TestPositionsConsumer_processMessage_recordsPnlHistory(t *testing.T) {
+	repo := &mockPositionsRepo{}
+	consumer := &PositionsConsumer{repo: repo}
+
+	require.NoError(t, consumer.processMessage(positionsSnapshot(t,
+		models.PositionData{Symbol: "AAPL", Quantity: "10", AverageBuyPrice: "100", Equity: "1100", PercentChange: "10"},
+		models.PositionData{Symbol: "MSFT", Quantity: "2", AverageBuyPrice: "400", Equity: "0"},
+	)))
+	require.NoError(t, consumer.processMessage(positionChange(t, models.PositionsEventUpdated,
+		models.PositionData{Symbol: "AAPL", Quantity: "10", AverageBuyPrice: "100", Equity: "950", PercentChange: "-5"})))
+
+	// MSFT has no current price yet, so only AAPL is recorded
+	history := repo.PnlHistory()
+	require.Len(t, history, 2)
+	assert.Equal(t, "AAPL", history[0].Symbol)
+	assert.Equal(t, "110", history[0].CurrentPrice.String())
+	assert.Equal(t, "100", history[0].UnrealizedPnl.String())
+	assert.Equal(t, "10", history[0].UnrealizedPnlPct.String())
+	assert.Equal(t, "AAPL", history[1].Symbol)
+	assert.Equal(t, "-50", history[1].UnrealizedPnl.String())
+	assert.Equal(t, "-5", history[1].UnrealizedPnlPct.String())
+}
+
+func TestPositionsConsumer_processMessage_rebuildsEntryPriceFromTrades(t *testing.T) {
+	day := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+	buy := func(id string, qty, price, fees float64, at time.Time) *models.RawTrade {
+		trade := createTestRawTrade(id, "AAPL", models.TradeTypeBuy, qty, price, at)
+		trade.Fees = decimal.NewFromFloat(fees)
+		return trade
+	}
+	ledger := map[string][]*models.RawTrade{
+		// 10 @ 100 + $5 fee, then 10 @ 110 + $5 fee; selling 5 keeps the average
+		"AAPL": {
+			buy("b1", 10, 100, 5, day),
+			buy("b2", 10, 110, 5, day.Add(time.Hour)),
+			createTestRawTrade("s1", "AAPL", models.TradeTypeSell, 5, 120, day.Add(2*time.Hour)),
+		},
+		// Trades cover only part of the position, so the snapshot price stands
+		"MSFT": {createTestRawTrade("m1", "MSFT", models.TradeTypeBuy, 1, 390, day)},
+	}
+	snapshot := positionsSnapshot(t,
+		models.PositionData{Symbol: "AAPL", Quantity: "15", AverageBuyPrice: "104.5", Equity: "1800"},
+		models.PositionData{Symbol: "MSFT", Quantity: "3", AverageBuyPrice: "400", Equity: "1230"},
+		models.PositionData{Symbol: "NVDA", Quantity: "2", AverageBuyPrice: "900", Equity: "1900"},
+	)
+
+	tests := []struct {
+		name        string
+		includeFees bool
+		expected    string
+	}{
+		{"with fees", true, "105.5"},
+		{"without fees", false, "105"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mockPositionsRepo{ledgers: ledger}
+			consumer := &PositionsConsumer{repo: repo}
+			consumer.SetEntryPriceFromTrades(true, tt.includeFees)
+
+			require.NoError(t, consumer.processMessage(snapshot))
+
+			entries := make(map[string]string)
+			for _, p := range repo.LastPositions() {
+				entries[p.Symbol] = p.EntryPrice.String()
+			}
+			assert.Equal(t, map[string]string{"AAPL": tt.expected, "MSFT": "400", "NVDA": "900"}, entries)
+		})
+	}
+}
+
+func TestAverageEntry_ResetsAfterSellingOut(t *testing.T) {
+	day := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+	held, price := averageEntry([]*models.RawTrade{
+		createTestRawTrade("b1", "TSLA", models.TradeTypeBuy, 4, 200, day),
+		createTestRawTrade("s1", "TSLA", models.TradeTypeSell, 4, 250, day.Add(time.Hour)),
+		createTestRawTrade("b2", "TSLA", models.TradeTypeBuy, 2, 180, day.Add(2*time.Hour)),
+	}, true)
+	assert.Equal(t, "2", held.String())
+	assert.Equal(t, "180", price.String())
+}
+
+func TestPositionsConsumer_processMessage_warnsWhenOverLeveraged(t *testing.T) {
+	snapshot := func(buyingPower, cash string, positions ...models.PositionData) kafka.Message {
+		payload, err := json.Marshal(models.PositionsEvent{
+			EventType: models.PositionsEventSnapshot,
+			Timestamp: time.Now().Format(time.RFC3339Nano),
+			Data: models.PositionsEventData{
+				Positions:   positions,
+				BuyingPower: buyingPower,
+				Cash:        cash,
+			},
+		})
+		require.NoError(t, err)
+		return kafka.Message{Value: payload}
+	}
+	aapl := models.PositionData{Symbol: "AAPL", Quantity: "10", AverageBuyPrice: "150", Equity: "1600"}
+	msft := models.PositionData{Symbol: "MSFT", Quantity: "2", AverageBuyPrice: "400", Equity: "900"}
+
+	alertRepo := &mockPositionAlertRepo{}
+	consumer := &PositionsConsumer{repo: &mockPositionsRepo{}}
+	consumer.SetAlertRepository(alertRepo)
+	consumer.SetMaxLeverage(2)
+
+	// $2,500 of positions on $1,000 buying power is 2.5x
+	require.NoError(t, consumer.processMessage(snapshot("1000", "1000", aapl, msft)))
+	alerts := alertRepo.Alerts()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, models.RuleTypeOverLeveraged, alerts[0].RuleType)
+	assert.Equal(t, models.PortfolioSymbol, alerts[0].Symbol)
+	assert.True(t, decimal.NewFromFloat(2.5).Equal(alerts[0].TriggeredValue), "got %s", alerts[0].TriggeredValue)
+
+	// Still over: no repeat until leverage comes back down
+	require.NoError(t, consumer.processMessage(snapshot("900", "900", aapl, msft)))
+	assert.Len(t, alertRepo.Alerts(), 1)
+	require.NoError(t, consumer.processMessage(snapshot("2000", "2000", aapl, msft)))
+	assert.Len(t, alertRepo.Alerts(), 1)
+
+	// Negative cash is flagged even within the ratio
+	require.NoError(t, consumer.processMessage(snapshot("2000", "-150", aapl)))
+	alerts = alertRepo.Alerts()
+	require.Len(t, alerts, 2)
+	assert.Contains(t, alerts[1].Message, "Cash is negative")
+}
+
+func TestPositionsConsumer_processMessage_enrichesNewPositionsFromStocks(t *testing.T) {
+	repo := &mockPositionsRepo{
+		last: []*models.Position{
+			{Symbol: "MSFT", Quantity: decimal.NewFromInt(2), EntryPrice: decimal.NewFromInt(400),
+				Sector: "Technology", Industry: "Software"},
+		},
+		stocks: map[string]*models.Stock{
+			"AAPL": {Symbol: "AAPL", Sector: "Technology", Industry: "Consumer Electronics"},
+			"MSFT": {Symbol: "MSFT", Sector: "Other", Industry: "Other"},
+		},
+	}
+	consumer := &PositionsConsumer{repo: repo}
+
+	require.NoError(t, consumer.processMessage(positionsSnapshot(t,
+		models.PositionData{Symbol: "AAPL", Quantity: "10", AverageBuyPrice: "150", Equity: "1600"},
+		models.PositionData{Symbol: "MSFT", Quantity: "2", AverageBuyPrice: "400", Equity: "820"},
+		// No stock row: opened without sector or industry
+		models.PositionData{Symbol: "NEWCO", Quantity: "5", AverageBuyPrice: "10", Equity: "50"},
+	)))
+
+	bySymbol := make(map[string]*models.Position)
+	for _, p := range repo.LastPositions() {
+		bySymbol[p.Symbol] = p
+	}
+	require.Len(t, bySymbol, 3)
+	assert.Equal(t, "Technology", bySymbol["AAPL"].Sector)
+	assert.Equal(t, "Consumer Electronics", bySymbol["AAPL"].Industry)
+	assert.Empty(t, bySymbol["MSFT"].Sector, "held positions aren't looked up again")
+	assert.Empty(t, bySymbol["NEWCO"].Sector)
+	assert.Empty(t, bySymbol["NEWCO"].Industry)
+}
+
+// TestPositionsConsumer_snapshotSetsPositionSizes verifies each position is sized
+// against buying power plus the snapshot's market value
+func TestPositionsConsumer_snapshotSetsPositionSizes(t *testing.T) {
+	repo := &mockPositionsRepo{}
+	consumer := &PositionsConsumer{repo: repo}
+
+	payload, err := json.Marshal(models.PositionsEvent{
+		EventType: models.PositionsEventSnapshot,
+		Source:    "robinhood",
+		Timestamp: time.Now().Format(time.RFC3339),
+		Data: models.PositionsEventData{
+			BuyingPower: "2500",
+			Positions: []models.PositionData{
+				{Symbol: "AAPL", Quantity: "10", AverageBuyPrice: "150", Equity: "1600"},
+				{Symbol: "MSFT", Quantity: "5", AverageBuyPrice: "200", Equity: "900"},
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, consumer.processMessage(kafka.Message{Value: payload}))
+
+	positions := repo.LastPositions()
+	require.Len(t, positions, 2)
+	assert.Equal(t, "30", positions[0].PositionSizePct.String())
+	assert.Equal(t, "20", positions[1].PositionSizePct.String())
+}
+
+// recordingNotifier collects the alerts sent on its channel
+type recordingNotifier struct {
+	channel string
+	sent    []*models.AlertHistory
+}
+
+func (n *recordingNotifier) Channel() string { return n.channel }
+
+func (n *recordingNotifier) Notify(ctx context.Context, h *models.AlertHistory) error {
+	n.sent = append(n.sent, h)
+	return nil
+}
+
+// sentMarker records the alerts marked as sent
+type sentMarker struct {
+	ids []int
+}
+
+func (m *sentMarker) MarkNotificationSent(id int) error {
+	m.ids = append(m.ids, id)
+	return nil
+}
+
+// TestPositionsConsumer_processMessage_notifiesFullCloses verifies a full close
+// records and sends a P&L summary, and a partial close doesn't
+func TestPositionsConsumer_processMessage_notifiesFullCloses(t *testing.T) {
+	entryDate := time.Now().Truncate(time.Second).Add(-72 * time.Hour)
+	repo := &mockPositionsRepo{last: []*models.Position{
+		{Symbol: "AAPL", Quantity: decimal.NewFromInt(10), EntryPrice: decimal.NewFromInt(150),
+			CurrentPrice: decimal.NewFromInt(165), EntryDate: entryDate},
+		{Symbol: "MSFT", Quantity: decimal.NewFromInt(5), EntryPrice: decimal.NewFromInt(400),
+			CurrentPrice: decimal.NewFromInt(410), EntryDate: entryDate},
+	}}
+	alertRepo := &mockPositionAlertRepo{}
+	notifier := &recordingNotifier{channel: models.ChannelTelegram}
+	marker := &sentMarker{}
+	consumer := &PositionsConsumer{repo: repo}
+	consumer.SetAlertRepository(alertRepo)
+	consumer.SetCloseNotifications(models.ChannelTelegram, notify.NewDispatcher(notifier), marker)
+
+	// AAPL is sold out; MSFT is only reduced
+	msg := positionsSnapshot(t, models.PositionData{Symbol: "MSFT", Quantity: "2", AverageBuyPrice: "400", Equity: "820"})
+	require.NoError(t, consumer.processMessage(msg))
+
+	alerts := alertRepo.Alerts()
+	require.Len(t, alerts, 1)
+	alert := alerts[0]
+	assert.Equal(t, "AAPL", alert.Symbol)
+	assert.Equal(t, models.RuleTypePositionClosed, alert.RuleType)
+	assert.Equal(t, models.ChannelTelegram, alert.NotificationChannel)
+	assert.Equal(t, "150", alert.TriggeredValue.String())
+	assert.Equal(t, "Closed AAPL: 10 shares @ $165.00, P&L $150.00 (10.00%) after 3 days", alert.Message)
+
+	require.Len(t, notifier.sent, 1)
+	assert.Same(t, alert, notifier.sent[0])
+	assert.Equal(t, []int{alert.ID}, marker.ids)
+	assert.True(t, alert.NotificationSent)
+}
+
+func TestHoldingPeriod(t *testing.T) {
+	assert.Equal(t, "0 hours", holdingPeriod(0))
+	assert.Equal(t, "1 hour", holdingPeriod(1))
+	assert.Equal(t, "23 hours", holdingPeriod(23))
+	assert.Equal(t, "1 day", holdingPeriod(47))
+	assert.Equal(t, "3 days", holdingPeriod(72))
+}
+
+// mockRSIRepo serves a fixed latest RSI and dated readings for entry RSI lookups
+type mockRSIRepo struct {
+	latest   map[string]decimal.Decimal
+	readings map[string][]*models.TechnicalIndicator // oldest first
+}
+
+func (m *mockRSIRepo) GetLatestRSI(symbol string) (decimal.Decimal, error) {
+	rsi, ok := m.latest[symbol]
+	if !ok {
+		return decimal.Zero, fmt.Errorf("no RSI data found for %s", symbol)
+	}
+	return rsi, nil
+}
+
+func (m *mockRSIRepo) GetIndicatorAtOrBefore(symbol, indicatorType string, date time.Time) (*models.TechnicalIndicator, error) {
+	var found *models.TechnicalIndicator
+	for _, r := range m.readings[symbol] {
+		if r.IndicatorType == indicatorType && !r.Date.After(date) {
+			found = r
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("indicator not found: %s %s", symbol, indicatorType)
+	}
+	return found, nil
+}
+
+func TestPositionsConsumer_processMessage_setsEntryRSI(t *testing.T) {
+	firstBuy := time.Date(2024, 3, 1, 14, 30, 0, 0, time.UTC)
+	rsiRepo := &mockRSIRepo{
+		latest: map[string]decimal.Decimal{"AAPL": decimal.NewFromInt(64), "MSFT": decimal.NewFromInt(55)},
+		readings: map[string][]*models.TechnicalIndicator{
+			"AAPL": {
+				{Symbol: "AAPL", Date: time.Date(2024, 2, 28, 0, 0, 0, 0, time.UTC), IndicatorType: models.IndicatorRSI14, Value: decimal.NewFromInt(31)},
+				{Symbol: "AAPL", Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), IndicatorType: models.IndicatorRSI14, Value: decimal.NewFromInt(29)},
+				{Symbol: "AAPL", Date: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), IndicatorType: models.IndicatorRSI14, Value: decimal.NewFromInt(40)},
+			},
+		},
+	}
+	snapshot := func() kafka.Message {
+		return positionsSnapshot(t,
+			models.PositionData{Symbol: "AAPL", Quantity: "10", AverageBuyPrice: "150", Equity: "1600"},
+			models.PositionData{Symbol: "MSFT", Quantity: "2", AverageBuyPrice: "400", Equity: "820"},
+		)
+	}
+
+	t.Run("at-entry uses the reading from the entry date", func(t *testing.T) {
+		repo := &mockPositionsRepo{
+			ledgers: map[string][]*models.RawTrade{"AAPL": {createTestRawTrade("buy-1", "AAPL", models.TradeTypeBuy, 10, 150, firstBuy)}},
+			last:    []*models.Position{{Symbol: "MSFT", Quantity: decimal.NewFromInt(2), EntryPrice: decimal.NewFromInt(400)}},
+		}
+		consumer := &PositionsConsumer{repo: repo}
+		consumer.SetEntryRSISource(EntryRSIAtEntry, rsiRepo)
+
+		require.NoError(t, consumer.processMessage(snapshot()))
+
+		positions := repo.LastPositions()
+		require.Len(t, positions, 2)
+		assert.Equal(t, "29", positions[0].EntryRSI.String(), "AAPL takes the RSI from its first buy, not the latest")
+		assert.True(t, positions[1].EntryRSI.IsZero(), "held positions aren't looked up again")
+	})
+
+	t.Run("latest uses the latest reading", func(t *testing.T) {
+		repo := &mockPositionsRepo{ledgers: map[string][]*models.RawTrade{"AAPL": {createTestRawTrade("buy-1", "AAPL", models.TradeTypeBuy, 10, 150, firstBuy)}}}
+		consumer := &PositionsConsumer{repo: repo}
+		consumer.SetEntryRSISource(EntryRSILatest, rsiRepo)
+
+		require.NoError(t, consumer.processMessage(snapshot()))
+
+		positions := repo.LastPositions()
+		require.Len(t, positions, 2)
+		assert.Equal(t, "64", positions[0].EntryRSI.String())
+		assert.Equal(t, "55", positions[1].EntryRSI.String())
+	})
+}
+
+func TestPositionsConsumer_processMessage_setsDefaultEntryReason(t *testing.T) {
+	repo := &mockPositionsRepo{
+		last: []*models.Position{{Symbol: "MSFT", Quantity: decimal.NewFromInt(2), EntryPrice: decimal.NewFromInt(400)}},
+	}
+	alertRepo := &mockPositionAlertRepo{stocks: []*models.MonitoredStock{{Symbol: "NVDA", Reason: "Breakout setup"}}}
+	consumer := &PositionsConsumer{repo: repo}
+	consumer.SetAlertRepository(alertRepo)
+	consumer.SetDefaultEntryReason("auto-imported")
+
+	require.NoError(t, consumer.processMessage(positionsSnapshot(t,
+		models.PositionData{Symbol: "AAPL", Quantity: "10", AverageBuyPrice: "150", Equity: "1600"},
+		models.PositionData{Symbol: "MSFT", Quantity: "2", AverageBuyPrice: "400", Equity: "820"},
+		models.PositionData{Symbol: "NVDA", Quantity: "4", AverageBuyPrice: "900", Equity: "3700"},
+	)))
+
+	reasons := make(map[string]string)
+	for _, p := range repo.LastPositions() {
+		reasons[p.Symbol] = p.EntryReason
+	}
+	assert.Equal(t, "auto-imported", reasons["AAPL"], "no monitored stock reason, so the default is used")
+	assert.Empty(t, reasons["MSFT"], "held positions aren't given a reason")
+	assert.Equal(t, "Breakout setup", reasons["NVDA"])
+}
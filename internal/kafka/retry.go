@@ -0,0 +1,92 @@
+package kafka
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// maxRetryDelay caps the backoff between retries
+const maxRetryDelay = 5 * time.Second
+
+// retryPolicy retries operations that failed with a transient database error,
+// doubling the delay after each attempt up to maxRetryDelay.
+// A nil *retryPolicy runs each operation once.
+type retryPolicy struct {
+	attempts  int
+	baseDelay time.Duration
+}
+
+// newRetryPolicy returns nil (no retries) when attempts is 1 or less
+func newRetryPolicy(attempts int, baseDelay time.Duration) *retryPolicy {
+	if attempts <= 1 {
+		return nil
+	}
+	return &retryPolicy{attempts: attempts, baseDelay: baseDelay}
+}
+
+// do runs fn until it succeeds, fails with a non-transient error, runs out of
+// attempts or ctx is cancelled, returning fn's last error
+func (p *retryPolicy) do(ctx context.Context, op string, fn func() error) error {
+	err := fn()
+	if p == nil {
+		return err
+	}
+
+	delay := p.baseDelay
+	for attempt := 1; attempt < p.attempts && err != nil && isTransientDBError(err); attempt++ {
+		log.Printf("Transient error during %s (attempt %d/%d), retrying in %s: %v",
+			op, attempt, p.attempts, delay, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		delay = min(delay*2, maxRetryDelay)
+		err = fn()
+	}
+	return err
+}
+
+// isTransientDBError reports whether err looks like a dropped connection or a
+// transaction conflict that may succeed if tried again
+func isTransientDBError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case "08", // connection exception
+			"40", // transaction rollback: serialization failure, deadlock
+			"53", // insufficient resources
+			"57": // operator intervention, e.g. admin shutdown
+			return true
+		}
+		return false
+	}
+
+	return strings.Contains(err.Error(), "connection reset")
+}
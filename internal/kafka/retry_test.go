@@ -0,0 +1,83 @@
+package kafka
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+// flakyRawTradeRepo fails its first `failures` inserts with err, then succeeds
+type flakyRawTradeRepo struct {
+	*MockRawTradeRepository
+	failures int
+	err      error
+	inserts  int
+}
+
+func (r *flakyRawTradeRepo) CreateRawTrade(t *models.RawTrade) error {
+	r.inserts++
+	if r.inserts <= r.failures {
+		return fmt.Errorf("failed to create raw trade: %w", r.err)
+	}
+	return r.MockRawTradeRepository.CreateRawTrade(t)
+}
+
+// TestProcessMessage_RetriesTransientErrors verifies a trade is stored after the
+// database recovers within the retry budget
+func TestProcessMessage_RetriesTransientErrors(t *testing.T) {
+	repo := &flakyRawTradeRepo{MockRawTradeRepository: NewMockRawTradeRepository(), failures: 2, err: driver.ErrBadConn}
+	consumer := &Consumer{repo: repo}
+	consumer.SetRetry(3, time.Millisecond)
+
+	require.NoError(t, consumer.processMessage(context.Background(), tradeMessage(t, "order-1")))
+	assert.Equal(t, 3, repo.inserts)
+	assert.Len(t, repo.rawTrades, 1)
+}
+
+// TestProcessMessage_GivesUpAfterMaxAttempts verifies retries stop at the limit
+// and that non-transient errors aren't retried at all
+func TestProcessMessage_GivesUpAfterMaxAttempts(t *testing.T) {
+	tests := []struct {
+		name            string
+		err             error
+		expectedInserts int
+	}{
+		{"transient", driver.ErrBadConn, 3},
+		{"unique violation", &pq.Error{Code: "23505"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &flakyRawTradeRepo{MockRawTradeRepository: NewMockRawTradeRepository(), failures: 10, err: tt.err}
+			consumer := &Consumer{repo: repo}
+			consumer.SetRetry(3, time.Millisecond)
+
+			err := consumer.processMessage(context.Background(), tradeMessage(t, "order-1"))
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, tt.err))
+			assert.Equal(t, tt.expectedInserts, repo.inserts)
+			assert.Empty(t, repo.rawTrades)
+		})
+	}
+}
+
+// TestRetryPolicy_StopsOnCancel verifies a pending retry is abandoned on shutdown
+func TestRetryPolicy_StopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	err := newRetryPolicy(5, time.Hour).do(ctx, "test", func() error {
+		calls++
+		return driver.ErrBadConn
+	})
+	assert.ErrorIs(t, err, driver.ErrBadConn)
+	assert.Equal(t, 1, calls)
+}
@@ -101,8 +101,9 @@ func (c *WatchlistConsumer) Start(ctx context.Context) error {
 
 // processMessage handles a single Kafka message
 func (c *WatchlistConsumer) processMessage(msg kafka.Message) error {
-	log.Printf("Received watchlist message from partition %d offset %d: key=%s",
-		msg.Partition, msg.Offset, string(msg.Key))
+	lag := recordLag(msg)
+	log.Printf("Received watchlist message from partition %d offset %d: key=%s (lag: %s)",
+		msg.Partition, msg.Offset, string(msg.Key), lag.Round(time.Millisecond))
 
 	var event WatchlistEvent
 	if err := json.Unmarshal(msg.Value, &event); err != nil {
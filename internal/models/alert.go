@@ -1,6 +1,8 @@
 package models
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/shopspring/decimal"
@@ -14,8 +16,27 @@ const (
 	RuleTypeSupportBounce    = "SUPPORT_BOUNCE"
 	RuleTypeResistanceBreak  = "RESISTANCE_BREAK"
 	RuleTypeVolumeSpike      = "VOLUME_SPIKE"
+	RuleTypeTargetHit        = "TARGET_HIT"
+	RuleTypePositionOpened   = "POSITION_OPENED"
 )
 
+// RuleTypePositionClosed marks the summary alert recorded when a position fully
+// closes, when close notifications are enabled
+const RuleTypePositionClosed = "POSITION_CLOSED"
+
+// RuleTypeBigDay marks a portfolio-wide alert for a large daily move. It's raised
+// by the evaluator from configured thresholds rather than from a stored rule, and
+// is recorded against PortfolioSymbol.
+const (
+	RuleTypeBigDay  = "BIG_DAY"
+	PortfolioSymbol = "PORTFOLIO"
+)
+
+// RuleTypeOverLeveraged marks a portfolio-wide alert raised when a positions
+// snapshot shows negative cash or more leverage than configured. Like
+// RuleTypeBigDay it's recorded against PortfolioSymbol.
+const RuleTypeOverLeveraged = "OVER_LEVERAGED"
+
 // Comparison constants
 const (
 	ComparisonAbove  = "ABOVE"
@@ -39,6 +60,37 @@ const (
 	PriorityCritical = "critical"
 )
 
+// IsValidRuleType reports whether t is a rule type the evaluator can check.
+// Types only recorded in alert history, such as TARGET_HIT and POSITION_OPENED,
+// and types without stored data to evaluate them aren't valid for a rule.
+func IsValidRuleType(t string) bool {
+	switch t {
+	case RuleTypePriceTarget, RuleTypeRSIOversold, RuleTypeRSIOverbought, RuleTypeVolumeSpike:
+		return true
+	}
+	return false
+}
+
+// IsValidComparison reports whether c is ABOVE, BELOW or EQUALS
+func IsValidComparison(c string) bool {
+	return c == ComparisonAbove || c == ComparisonBelow || c == ComparisonEquals
+}
+
+// IsValidChannel reports whether c is one of the notification channel constants
+func IsValidChannel(c string) bool {
+	switch c {
+	case ChannelTelegram, ChannelPushover, ChannelSMS, ChannelEmail:
+		return true
+	}
+	return false
+}
+
+// IsValidPriority reports whether p is one of the priority constants
+func IsValidPriority(p string) bool {
+	_, ok := priorityRank[p]
+	return ok
+}
+
 // AlertRule represents a configurable alert condition
 type AlertRule struct {
 	ID                  int              `json:"id"`
@@ -57,6 +109,27 @@ type AlertRule struct {
 	UpdatedAt           time.Time        `json:"updated_at"`
 }
 
+// ErrInvalidAlertRule is returned by Validate for a rule that could never be evaluated
+var ErrInvalidAlertRule = errors.New("invalid alert rule")
+
+// Validate checks the rule's type, comparison, notification channel and priority
+// against the known constants, so a typo can't persist a rule that never matches
+func (a *AlertRule) Validate() error {
+	switch {
+	case !IsValidRuleType(a.RuleType):
+		return fmt.Errorf("%w: unknown rule_type %q", ErrInvalidAlertRule, a.RuleType)
+	case !IsValidComparison(a.Comparison):
+		return fmt.Errorf("%w: unknown comparison %q", ErrInvalidAlertRule, a.Comparison)
+	case !IsValidChannel(a.NotificationChannel):
+		return fmt.Errorf("%w: unknown notification_channel %q", ErrInvalidAlertRule, a.NotificationChannel)
+	case !IsValidPriority(a.Priority):
+		return fmt.Errorf("%w: unknown priority %q", ErrInvalidAlertRule, a.Priority)
+	case a.CooldownMinutes < 0:
+		return fmt.Errorf("%w: cooldown_minutes must not be negative", ErrInvalidAlertRule)
+	}
+	return nil
+}
+
 // AlertHistory represents a triggered alert record
 type AlertHistory struct {
 	ID                  int             `json:"id"`
@@ -67,5 +140,77 @@ type AlertHistory struct {
 	Message             string          `json:"message,omitempty"`
 	NotificationSent    bool            `json:"notification_sent"`
 	NotificationChannel string          `json:"notification_channel,omitempty"`
+	// Priority is the rule's effective priority when it fired; it isn't stored
+	Priority            string          `json:"priority,omitempty"`
 	TriggeredAt         time.Time       `json:"triggered_at"`
 }
+
+// priorityRank orders priorities from least to most urgent
+var priorityRank = map[string]int{
+	PriorityLow:      0,
+	PriorityNormal:   1,
+	PriorityHigh:     2,
+	PriorityCritical: 3,
+}
+
+// PriorityEscalation sets how many triggers it takes before a rule's dispatched
+// priority is raised. A zero threshold disables that level.
+type PriorityEscalation struct {
+	HighAfter     int
+	CriticalAfter int
+}
+
+// EffectivePriority returns the priority to dispatch with, escalating to high or
+// critical once TriggeredCount reaches the configured thresholds. A rule's own
+// priority is never lowered.
+func (a *AlertRule) EffectivePriority(e PriorityEscalation) string {
+	priority := a.Priority
+	if priority == "" {
+		priority = PriorityNormal
+	}
+
+	escalated := priority
+	switch {
+	case e.CriticalAfter > 0 && a.TriggeredCount >= e.CriticalAfter:
+		escalated = PriorityCritical
+	case e.HighAfter > 0 && a.TriggeredCount >= e.HighAfter:
+		escalated = PriorityHigh
+	}
+
+	if priorityRank[escalated] > priorityRank[priority] {
+		return escalated
+	}
+	return priority
+}
+
+// CanTrigger reports whether the rule is out of its cooldown at now. A rule that
+// has never fired, or has no cooldown, can always trigger.
+func (a *AlertRule) CanTrigger(now time.Time) bool {
+	if a.LastTriggeredAt == nil || a.CooldownMinutes <= 0 {
+		return true
+	}
+	return !now.Before(a.LastTriggeredAt.Add(time.Duration(a.CooldownMinutes) * time.Minute))
+}
+
+// ConditionMet compares value against the rule's ConditionValue. ABOVE and BELOW
+// are inclusive; an unknown comparison never matches.
+func (a *AlertRule) ConditionMet(value decimal.Decimal) bool {
+	switch a.Comparison {
+	case ComparisonAbove:
+		return value.GreaterThanOrEqual(a.ConditionValue)
+	case ComparisonBelow:
+		return value.LessThanOrEqual(a.ConditionValue)
+	case ComparisonEquals:
+		return value.Equal(a.ConditionValue)
+	}
+	return false
+}
+
+// RSIConditionMet reports whether an RSI rule should fire for r. Readings computed
+// from fewer than minDataPoints candles are skipped as unreliable.
+func (a *AlertRule) RSIConditionMet(r *RSIReading, minDataPoints int) bool {
+	if r == nil || !r.IsReliable(minDataPoints) {
+		return false
+	}
+	return a.ConditionMet(r.Value)
+}
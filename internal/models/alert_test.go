@@ -0,0 +1,143 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertRule_EffectivePriority(t *testing.T) {
+	escalation := PriorityEscalation{HighAfter: 3, CriticalAfter: 6}
+
+	tests := []struct {
+		name      string
+		priority  string
+		triggered int
+		expected  string
+	}{
+		{"first fire keeps normal", PriorityNormal, 0, PriorityNormal},
+		{"below high threshold", PriorityNormal, 2, PriorityNormal},
+		{"escalates to high", PriorityNormal, 3, PriorityHigh},
+		{"stays high before critical", PriorityNormal, 5, PriorityHigh},
+		{"escalates to critical", PriorityNormal, 6, PriorityCritical},
+		{"empty priority treated as normal", "", 4, PriorityHigh},
+		{"low escalates too", PriorityLow, 3, PriorityHigh},
+		{"never downgrades critical", PriorityCritical, 0, PriorityCritical},
+		{"high not lowered below threshold", PriorityHigh, 1, PriorityHigh},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &AlertRule{RuleType: RuleTypePriceTarget, Priority: tt.priority, TriggeredCount: tt.triggered}
+			assert.Equal(t, tt.expected, rule.EffectivePriority(escalation))
+		})
+	}
+}
+
+func TestAlertRule_EffectivePriority_RepeatedFires(t *testing.T) {
+	rule := &AlertRule{RuleType: RuleTypePriceTarget, Priority: PriorityNormal}
+	escalation := PriorityEscalation{HighAfter: 2, CriticalAfter: 4}
+
+	var dispatched []string
+	for i := 0; i < 5; i++ {
+		dispatched = append(dispatched, rule.EffectivePriority(escalation))
+		rule.TriggeredCount++ // MarkAlertTriggered increments after each fire
+	}
+
+	assert.Equal(t, []string{
+		PriorityNormal, PriorityNormal, PriorityHigh, PriorityHigh, PriorityCritical,
+	}, dispatched)
+
+	// Disabled escalation leaves priority untouched
+	assert.Equal(t, PriorityNormal, rule.EffectivePriority(PriorityEscalation{}))
+}
+
+func TestAlertRule_RSIConditionMet(t *testing.T) {
+	rule := &AlertRule{
+		RuleType:       RuleTypeRSIOversold,
+		ConditionValue: decimal.NewFromInt(30),
+		Comparison:     ComparisonBelow,
+	}
+
+	tests := []struct {
+		name       string
+		value      int64
+		dataPoints int
+		minPoints  int
+		expected   bool
+	}{
+		{"oversold with enough data", 25, 60, 15, true},
+		{"at threshold", 30, 15, 15, true},
+		{"not oversold", 45, 60, 15, false},
+		{"oversold but too few candles", 10, 5, 15, false},
+		{"no minimum configured", 10, 5, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reading := &RSIReading{Symbol: "AAPL", Value: decimal.NewFromInt(tt.value), DataPoints: tt.dataPoints}
+			assert.Equal(t, tt.expected, rule.RSIConditionMet(reading, tt.minPoints))
+		})
+	}
+
+	assert.False(t, rule.RSIConditionMet(nil, 0))
+}
+
+func TestAlertRule_CanTrigger(t *testing.T) {
+	fired := time.Date(2026, 4, 1, 15, 0, 0, 0, time.UTC)
+
+	rule := &AlertRule{CooldownMinutes: 60}
+	assert.True(t, rule.CanTrigger(fired), "never fired")
+
+	rule.LastTriggeredAt = &fired
+	assert.False(t, rule.CanTrigger(fired.Add(59*time.Minute)))
+	assert.True(t, rule.CanTrigger(fired.Add(60*time.Minute)))
+
+	rule.CooldownMinutes = 0
+	assert.True(t, rule.CanTrigger(fired), "no cooldown")
+}
+
+func TestAlertRule_Validate(t *testing.T) {
+	valid := func() *AlertRule {
+		return &AlertRule{
+			Symbol:              "AAPL",
+			RuleType:            RuleTypePriceTarget,
+			Comparison:          ComparisonAbove,
+			NotificationChannel: ChannelTelegram,
+			Priority:            PriorityNormal,
+		}
+	}
+	require.NoError(t, valid().Validate())
+
+	tests := []struct {
+		name    string
+		mutate  func(a *AlertRule)
+		message string
+	}{
+		{"unknown rule type", func(a *AlertRule) { a.RuleType = "PRICE_TAGRET" }, `unknown rule_type "PRICE_TAGRET"`},
+		{"lowercase rule type", func(a *AlertRule) { a.RuleType = "price_target" }, "unknown rule_type"},
+		{"empty rule type", func(a *AlertRule) { a.RuleType = "" }, "unknown rule_type"},
+		{"unknown comparison", func(a *AlertRule) { a.Comparison = "GREATER" }, `unknown comparison "GREATER"`},
+		{"empty comparison", func(a *AlertRule) { a.Comparison = "" }, "unknown comparison"},
+		{"unknown channel", func(a *AlertRule) { a.NotificationChannel = "slack" }, `unknown notification_channel "slack"`},
+		{"empty channel", func(a *AlertRule) { a.NotificationChannel = "" }, "unknown notification_channel"},
+		{"unknown priority", func(a *AlertRule) { a.Priority = "urgent" }, `unknown priority "urgent"`},
+		{"empty priority", func(a *AlertRule) { a.Priority = "" }, "unknown priority"},
+		{"negative cooldown", func(a *AlertRule) { a.CooldownMinutes = -1 }, "cooldown_minutes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := valid()
+			tt.mutate(a)
+			err := a.Validate()
+			require.Error(t, err)
+			assert.ErrorIs(t, err, ErrInvalidAlertRule)
+			assert.Contains(t, err.Error(), tt.message)
+		})
+	}
+}
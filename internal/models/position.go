@@ -21,10 +21,134 @@ type Position struct {
 	Sector          string          `json:"sector,omitempty"`
 	Industry        string          `json:"industry,omitempty"`
 	PositionSizePct decimal.Decimal `json:"position_size_pct,omitempty"`
+	RealizedPnl     decimal.Decimal `json:"realized_pnl,omitempty"`
+	Notes           string          `json:"notes,omitempty"`
+	Tags            []string        `json:"tags,omitempty"`
+	// LowestPrice is the lowest price seen while the position has been open
+	LowestPrice     decimal.Decimal `json:"lowest_price,omitempty"`
 	CreatedAt       time.Time       `json:"created_at"`
 	UpdatedAt       time.Time       `json:"updated_at"`
 }
 
+// ObservePrice lowers the running low when price is below it. Zero and negative
+// prices are ignored.
+func (p *Position) ObservePrice(price decimal.Decimal) {
+	if !price.IsPositive() {
+		return
+	}
+	if p.LowestPrice.IsZero() || price.LessThan(p.LowestPrice) {
+		p.LowestPrice = price
+	}
+}
+
+// MaxDrawdownPct is the worst decline below entry seen while open, as a positive
+// percentage of the entry price. It's zero when the price never fell below entry.
+func (p *Position) MaxDrawdownPct() decimal.Decimal {
+	if !p.EntryPrice.IsPositive() || !p.LowestPrice.IsPositive() || !p.LowestPrice.LessThan(p.EntryPrice) {
+		return decimal.Zero
+	}
+	return p.EntryPrice.Sub(p.LowestPrice).Div(p.EntryPrice).Mul(decimal.NewFromInt(100)).Round(4)
+}
+
+// maxPositionSizePct is the largest size the position_size_pct column can hold
+var maxPositionSizePct = decimal.NewFromFloat(999.99)
+
+// AccountValue is buying power plus the market value of every position
+func AccountValue(buyingPower decimal.Decimal, positions []*Position) decimal.Decimal {
+	total := buyingPower
+	for _, p := range positions {
+		total = total.Add(p.Quantity.Mul(p.CurrentPrice))
+	}
+	return total
+}
+
+// SetPositionSizes sets each position's PositionSizePct to its cost basis as a
+// percentage of totalAccountValue, rounded to 2 places and capped at 999.99. Sizes
+// are cleared when totalAccountValue isn't positive.
+func SetPositionSizes(positions []*Position, totalAccountValue decimal.Decimal) {
+	for _, p := range positions {
+		if !totalAccountValue.IsPositive() {
+			p.PositionSizePct = decimal.Zero
+			continue
+		}
+		size := p.Quantity.Mul(p.EntryPrice).Div(totalAccountValue).Mul(decimal.NewFromInt(100)).Round(2)
+		if size.GreaterThan(maxPositionSizePct) {
+			size = maxPositionSizePct
+		}
+		p.PositionSizePct = size
+	}
+}
+
+// Position lifecycle event types
+const (
+	PositionEventOpen        = "OPEN"
+	PositionEventAdd         = "ADD"
+	PositionEventPartialSell = "PARTIAL_SELL"
+	PositionEventClose       = "CLOSE"
+)
+
+// PositionEvent records one change to a position between snapshots
+type PositionEvent struct {
+	ID               int             `json:"id"`
+	Symbol           string          `json:"symbol"`
+	EventType        string          `json:"event_type"`
+	Quantity         decimal.Decimal `json:"quantity"`          // shares opened, added or sold
+	PositionQuantity decimal.Decimal `json:"position_quantity"` // shares held afterwards
+	Price            decimal.Decimal `json:"price"`
+	OccurredAt       time.Time       `json:"occurred_at"`
+	CreatedAt        time.Time       `json:"created_at"`
+}
+
+// PositionPnlSnapshot records a position's unrealized P&L at a point in time
+type PositionPnlSnapshot struct {
+	ID               int             `json:"id"`
+	Symbol           string          `json:"symbol"`
+	Quantity         decimal.Decimal `json:"quantity"`
+	EntryPrice       decimal.Decimal `json:"entry_price"`
+	CurrentPrice     decimal.Decimal `json:"current_price"`
+	UnrealizedPnl    decimal.Decimal `json:"unrealized_pnl"`
+	UnrealizedPnlPct decimal.Decimal `json:"unrealized_pnl_pct"`
+	RecordedAt       time.Time       `json:"recorded_at"`
+	CreatedAt        time.Time       `json:"created_at"`
+}
+
+// PortfolioDailyChange is the day's move across open positions, valued at each
+// stock's current price against its previous close
+type PortfolioDailyChange struct {
+	Value         decimal.Decimal `json:"value"`
+	PreviousValue decimal.Decimal `json:"previous_value"`
+	Change        decimal.Decimal `json:"change"`
+	ChangePct     decimal.Decimal `json:"change_pct"`
+}
+
+// PortfolioSummary totals cost basis, market value and unrealized P&L across
+// open positions
+type PortfolioSummary struct {
+	TotalCostBasis        decimal.Decimal `json:"total_cost_basis"`
+	TotalMarketValue      decimal.Decimal `json:"total_market_value"`
+	TotalUnrealizedPnl    decimal.Decimal `json:"total_unrealized_pnl"`
+	TotalUnrealizedPnlPct decimal.Decimal `json:"total_unrealized_pnl_pct"`
+	PositionCount         int             `json:"position_count"`
+}
+
+// PortfolioRSI is the average latest RSI of open positions weighted by market
+// value. Positions without an RSI reading are left out and the remaining
+// weights renormalized.
+type PortfolioRSI struct {
+	RSI           decimal.Decimal `json:"rsi"`
+	WeightedValue decimal.Decimal `json:"weighted_value"` // market value of the positions included
+	Positions     int             `json:"positions"`
+	Skipped       int             `json:"skipped"` // positions without RSI
+}
+
+// Positions topic event types. A snapshot replaces every position; an update or
+// close changes a single symbol and leaves the others alone.
+const (
+	PositionsEventSnapshot = "POSITIONS_SNAPSHOT"
+	PositionsEventUpdated  = "POSITION_UPDATED"
+	PositionsEventClosed   = "POSITION_CLOSED"
+)
+
 // PositionsEvent represents a Kafka message with position snapshot from Robinhood
 type PositionsEvent struct {
 	EventType string             `json:"event_type"`
@@ -36,11 +160,52 @@ type PositionsEvent struct {
 // PositionsEventData contains the positions and account balance
 type PositionsEventData struct {
 	Positions   []PositionData `json:"positions"`
+	// Position is the changed position for POSITION_UPDATED and POSITION_CLOSED
+	// events; a close only needs the symbol
+	Position    *PositionData  `json:"position,omitempty"`
 	BuyingPower string         `json:"buying_power"`
 	Cash        string         `json:"cash"`
 	TotalEquity string         `json:"total_equity"`
 }
 
+// Leverage is a snapshot's positions value measured against its buying power
+type Leverage struct {
+	PositionsValue decimal.Decimal `json:"positions_value"`
+	BuyingPower    decimal.Decimal `json:"buying_power"`
+	Cash           decimal.Decimal `json:"cash"`
+	Ratio          decimal.Decimal `json:"ratio"` // positions value / buying power; zero without buying power
+	NegativeCash   bool            `json:"negative_cash"`
+}
+
+// CheckLeverage sums the snapshot's position equity and reports whether the
+// account is over-leveraged: cash is negative, or the positions value is more than
+// maxLeverage times buying power. The ratio is only checked when buying power is
+// positive and maxLeverage is above zero. Fields that don't parse count as zero.
+func (d PositionsEventData) CheckLeverage(maxLeverage float64) (*Leverage, bool) {
+	parse := func(s string) decimal.Decimal {
+		v, err := decimal.NewFromString(s)
+		if err != nil {
+			return decimal.Zero
+		}
+		return v
+	}
+
+	l := &Leverage{BuyingPower: parse(d.BuyingPower), Cash: parse(d.Cash)}
+	for _, p := range d.Positions {
+		l.PositionsValue = l.PositionsValue.Add(parse(p.Equity))
+	}
+	l.NegativeCash = l.Cash.IsNegative()
+
+	over := l.NegativeCash
+	if l.BuyingPower.IsPositive() {
+		l.Ratio = l.PositionsValue.Div(l.BuyingPower).Round(4)
+		if maxLeverage > 0 && l.Ratio.GreaterThan(decimal.NewFromFloat(maxLeverage)) {
+			over = true
+		}
+	}
+	return l, over
+}
+
 // PositionData represents a single position from Robinhood
 type PositionData struct {
 	Symbol          string `json:"symbol"`
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPosition_ObservePrice(t *testing.T) {
+	p := &Position{EntryPrice: decimal.NewFromInt(100)}
+
+	p.ObservePrice(decimal.NewFromInt(104))
+	assert.True(t, decimal.NewFromInt(104).Equal(p.LowestPrice), "first price starts the running low")
+
+	p.ObservePrice(decimal.NewFromInt(92))
+	p.ObservePrice(decimal.NewFromInt(97))
+	p.ObservePrice(decimal.Zero)
+	assert.True(t, decimal.NewFromInt(92).Equal(p.LowestPrice), "got %s", p.LowestPrice)
+}
+
+func TestPosition_MaxDrawdownPct(t *testing.T) {
+	tests := []struct {
+		name     string
+		entry    string
+		lowest   string
+		expected string
+	}{
+		{"below entry", "150", "135", "10"},
+		{"rounded to four places", "30", "29", "3.3333"},
+		{"never below entry", "100", "104", "0"},
+		{"no price seen", "100", "0", "0"},
+		{"no entry price", "0", "90", "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Position{
+				EntryPrice:  decimal.RequireFromString(tt.entry),
+				LowestPrice: decimal.RequireFromString(tt.lowest),
+			}
+			expected := decimal.RequireFromString(tt.expected)
+			assert.True(t, expected.Equal(p.MaxDrawdownPct()), "expected %s, got %s", expected, p.MaxDrawdownPct())
+		})
+	}
+}
+
+func TestPositionsEventData_CheckLeverage(t *testing.T) {
+	positions := []PositionData{{Symbol: "AAPL", Equity: "1500"}, {Symbol: "MSFT", Equity: "1500"}}
+
+	tests := []struct {
+		name        string
+		buyingPower string
+		cash        string
+		max         float64
+		ratio       string
+		over        bool
+	}{
+		{"within limit", "2000", "2000", 2, "1.5", false},
+		{"over limit", "1000", "1000", 2, "3", true},
+		{"negative cash", "2000", "-10", 2, "1.5", true},
+		{"no buying power", "0", "0", 2, "0", false},
+		{"ratio check disabled", "1000", "1000", 0, "3", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := PositionsEventData{Positions: positions, BuyingPower: tt.buyingPower, Cash: tt.cash}
+			leverage, over := d.CheckLeverage(tt.max)
+			assert.Equal(t, tt.over, over)
+			assert.Equal(t, "3000", leverage.PositionsValue.String())
+			assert.Equal(t, tt.ratio, leverage.Ratio.String())
+		})
+	}
+}
+
+func TestSetPositionSizes(t *testing.T) {
+	// Cost basis 1500 and 1000; market value 1600 + 900 plus 2500 buying power = 5000
+	positions := []*Position{
+		{Symbol: "AAPL", Quantity: decimal.NewFromInt(10), EntryPrice: decimal.NewFromInt(150), CurrentPrice: decimal.NewFromInt(160)},
+		{Symbol: "MSFT", Quantity: decimal.NewFromInt(5), EntryPrice: decimal.NewFromInt(200), CurrentPrice: decimal.NewFromInt(180)},
+	}
+
+	total := AccountValue(decimal.NewFromInt(2500), positions)
+	assert.Equal(t, "5000", total.String())
+
+	SetPositionSizes(positions, total)
+	assert.Equal(t, "30", positions[0].PositionSizePct.String())
+	assert.Equal(t, "20", positions[1].PositionSizePct.String())
+	assert.Equal(t, "50", positions[0].PositionSizePct.Add(positions[1].PositionSizePct).String())
+
+	SetPositionSizes(positions, decimal.Zero)
+	assert.True(t, positions[0].PositionSizePct.IsZero())
+	assert.True(t, positions[1].PositionSizePct.IsZero())
+}
@@ -1,6 +1,21 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
+
+// SymbolAliases maps alternate tickers for an instrument (e.g. "BRK.B") to the
+// canonical symbol stored by the service (e.g. "BRK-B"). Keys are uppercase.
+type SymbolAliases map[string]string
+
+// Canonical returns the canonical symbol for s, or s unchanged when it has no alias
+func (a SymbolAliases) Canonical(s string) string {
+	if canonical, ok := a[strings.ToUpper(strings.TrimSpace(s))]; ok {
+		return canonical
+	}
+	return s
+}
 
 // StockEvent represents a Kafka event for stock changes
 type StockEvent struct {
@@ -51,3 +66,17 @@ type MonitoredStock struct {
 	AddedAt             time.Time       `json:"added_at"`
 	UpdatedAt           time.Time       `json:"updated_at"`
 }
+
+// EffectiveRSIOversoldThreshold returns the stock's own RSI oversold threshold, falling
+// back to defaultThreshold when unset
+func (m *MonitoredStock) EffectiveRSIOversoldThreshold(defaultThreshold float64) float64 {
+	if m.RSIOversoldThreshold != nil {
+		return *m.RSIOversoldThreshold
+	}
+	return defaultThreshold
+}
+
+// IsRSIOversold reports whether rsi is at or below the stock's effective oversold threshold
+func (m *MonitoredStock) IsRSIOversold(rsi, defaultThreshold float64) bool {
+	return rsi <= m.EffectiveRSIOversoldThreshold(defaultThreshold)
+}
@@ -37,3 +37,18 @@ type TechnicalIndicator struct {
 	Timeframe     string          `json:"timeframe"`
 	CreatedAt     time.Time       `json:"created_at"`
 }
+
+// RSIReading is the latest RSI for a symbol along with how many daily candles
+// existed when it was computed
+type RSIReading struct {
+	Symbol     string          `json:"symbol"`
+	Date       time.Time       `json:"date"`
+	Value      decimal.Decimal `json:"value"`
+	DataPoints int             `json:"data_points"`
+}
+
+// IsReliable reports whether the reading was computed from at least minDataPoints
+// candles. A minimum of zero or less accepts every reading.
+func (r *RSIReading) IsReliable(minDataPoints int) bool {
+	return minDataPoints <= 0 || r.DataPoints >= minDataPoints
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/shopspring/decimal"
@@ -12,6 +15,20 @@ const (
 	TradeTypeSell = "SELL"
 )
 
+// NormalizeTradeSide trims surrounding whitespace and uppercases a trade side
+func NormalizeTradeSide(side string) string {
+	return strings.ToUpper(strings.TrimSpace(side))
+}
+
+// IsValidTradeSide reports whether side is BUY or SELL, ignoring case and padding
+func IsValidTradeSide(side string) bool {
+	switch NormalizeTradeSide(side) {
+	case TradeTypeBuy, TradeTypeSell:
+		return true
+	}
+	return false
+}
+
 // Trade grade constants
 const (
 	TradeGradeA = "A"
@@ -28,6 +45,7 @@ type TradeHistory struct {
 	TradeType          string           `json:"trade_type"`
 	Quantity           decimal.Decimal  `json:"quantity"`
 	Price              decimal.Decimal  `json:"price"`
+	EntryPrice         *decimal.Decimal `json:"entry_price,omitempty"` // Not stored; set by the chart overlay query
 	TotalCost          decimal.Decimal  `json:"total_cost"`
 	Fee                decimal.Decimal  `json:"fee"`
 	EntryDate          *time.Time       `json:"entry_date,omitempty"`
@@ -52,6 +70,23 @@ type TradeHistory struct {
 	CreatedAt          time.Time        `json:"created_at"`
 }
 
+// ImpliedEntryPrice returns the average entry price implied by a closed trade's
+// exit price, realized P&L and fees, and false when it has no shares to average
+func (t *TradeHistory) ImpliedEntryPrice() (decimal.Decimal, bool) {
+	if !t.Quantity.IsPositive() {
+		return decimal.Zero, false
+	}
+	// Realized P&L is (exit - entry) * quantity less fees
+	return t.Price.Sub(t.RealizedPnl.Add(t.Fee).Div(t.Quantity)), true
+}
+
+// ClosedLot is the trade history for shares of one buy closed by a sell, with
+// the buy's raw trade ID (zero if unknown) so both executions can be linked to it
+type ClosedLot struct {
+	Trade      *TradeHistory
+	BuyTradeID int
+}
+
 // RawTrade represents an individual trade execution from a broker
 type RawTrade struct {
 	ID             int             `json:"id"`
@@ -66,15 +101,137 @@ type RawTrade struct {
 	ExecutedAt     time.Time       `json:"executed_at"`
 	PositionID     *int            `json:"position_id,omitempty"`
 	TradeHistoryID *int            `json:"trade_history_id,omitempty"`
+	ExtendedHours  bool            `json:"extended_hours"` // executed outside regular market hours
 	CreatedAt      time.Time       `json:"created_at"`
 }
 
+// RawTradeDetail is a raw trade with the position and closed trade it's linked to,
+// so an export shows each execution's outcome. The cycle fields are set once the
+// trade's position has closed.
+type RawTradeDetail struct {
+	RawTrade
+	PositionSymbol string           `json:"position_symbol,omitempty"`
+	CycleEntryDate *time.Time       `json:"cycle_entry_date,omitempty"`
+	CycleExitDate  *time.Time       `json:"cycle_exit_date,omitempty"`
+	RealizedPnl    *decimal.Decimal `json:"realized_pnl,omitempty"`
+	RealizedPnlPct *decimal.Decimal `json:"realized_pnl_pct,omitempty"`
+}
+
+// MarketHours is a regular trading session in the exchange's local time
+type MarketHours struct {
+	Location *time.Location
+	Open     time.Duration // since local midnight
+	Close    time.Duration
+}
+
+// NewMarketHours parses a session from an IANA timezone and HH:MM open and close times
+func NewMarketHours(timezone, open, close string) (*MarketHours, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid market timezone %q: %w", timezone, err)
+	}
+	openAt, err := parseClock(open)
+	if err != nil {
+		return nil, fmt.Errorf("invalid market open %q: %w", open, err)
+	}
+	closeAt, err := parseClock(close)
+	if err != nil {
+		return nil, fmt.Errorf("invalid market close %q: %w", close, err)
+	}
+	if closeAt <= openAt {
+		return nil, fmt.Errorf("market close %s must be after open %s", close, open)
+	}
+	return &MarketHours{Location: loc, Open: openAt, Close: closeAt}, nil
+}
+
+// IsRegularHours reports whether t falls on a weekday within the session, from the
+// open up to but excluding the close. Exchange holidays are not accounted for.
+func (m *MarketHours) IsRegularHours(t time.Time) bool {
+	local := t.In(m.Location)
+	if local.Weekday() == time.Saturday || local.Weekday() == time.Sunday {
+		return false
+	}
+	// Wall-clock time rather than elapsed time, so DST changes don't shift the session
+	clock := time.Duration(local.Hour())*time.Hour +
+		time.Duration(local.Minute())*time.Minute +
+		time.Duration(local.Second())*time.Second
+	return clock >= m.Open && clock < m.Close
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// DailyPnl is the realized P&L from trades closed on one day
+type DailyPnl struct {
+	Date time.Time       `json:"date"`
+	Pnl  decimal.Decimal `json:"pnl"`
+}
+
+// FeesReport summarizes commissions and fees paid across raw trades in a window
+type FeesReport struct {
+	Start    time.Time                  `json:"start"`
+	End      time.Time                  `json:"end"`
+	Total    decimal.Decimal            `json:"total"`
+	BySymbol map[string]decimal.Decimal `json:"by_symbol"`
+}
+
+// Trade event schema versions. Events without a schema_version are treated as v1.
+const (
+	TradeEventSchemaV1 = 1
+	TradeEventSchemaV2 = 2
+)
+
 // TradeEvent represents a trade event from Kafka (e.g., from robinhood-sync)
 type TradeEvent struct {
-	EventType string         `json:"event_type"`
-	Source    string         `json:"source"`
-	Timestamp string         `json:"timestamp"`
-	Data      TradeEventData `json:"data"`
+	EventType     string         `json:"event_type"`
+	Source        string         `json:"source"`
+	Timestamp     string         `json:"timestamp"`
+	SchemaVersion int            `json:"schema_version,omitempty"`
+	Data          TradeEventData `json:"data"`
+}
+
+// UnmarshalJSON decodes the event envelope and parses data according to
+// schema_version, normalizing newer payloads into TradeEventData
+func (e *TradeEvent) UnmarshalJSON(b []byte) error {
+	var envelope struct {
+		EventType     string          `json:"event_type"`
+		Source        string          `json:"source"`
+		Timestamp     string          `json:"timestamp"`
+		SchemaVersion int             `json:"schema_version"`
+		Data          json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(b, &envelope); err != nil {
+		return err
+	}
+
+	e.EventType = envelope.EventType
+	e.Source = envelope.Source
+	e.Timestamp = envelope.Timestamp
+	e.SchemaVersion = envelope.SchemaVersion
+	e.Data = TradeEventData{}
+
+	if len(envelope.Data) == 0 || string(envelope.Data) == "null" {
+		return nil
+	}
+
+	switch envelope.SchemaVersion {
+	case 0, TradeEventSchemaV1:
+		return json.Unmarshal(envelope.Data, &e.Data)
+	case TradeEventSchemaV2:
+		var v2 TradeEventDataV2
+		if err := json.Unmarshal(envelope.Data, &v2); err != nil {
+			return err
+		}
+		e.Data = v2.toV1()
+		return nil
+	default:
+		return fmt.Errorf("unsupported trade event schema_version %d", envelope.SchemaVersion)
+	}
 }
 
 // TradeEventData contains the trade details from the event
@@ -90,3 +247,34 @@ type TradeEventData struct {
 	ExecutedAt   *string `json:"executed_at"`
 	CreatedAt    string  `json:"created_at"`
 }
+
+// TradeEventDataV2 is the schema_version 2 trade payload, which sends amounts as
+// JSON numbers and names the fill fields explicitly
+type TradeEventDataV2 struct {
+	OrderID          string      `json:"order_id"`
+	Symbol           string      `json:"symbol"`
+	Side             string      `json:"side"`
+	FilledQuantity   json.Number `json:"filled_quantity"`
+	AverageFillPrice json.Number `json:"average_fill_price"`
+	Notional         json.Number `json:"notional"`
+	Fees             json.Number `json:"fees"`
+	State            string      `json:"state"`
+	ExecutedAt       *string     `json:"executed_at"`
+	CreatedAt        string      `json:"created_at"`
+}
+
+// toV1 maps a v2 payload onto the v1 field layout used for parsing
+func (d TradeEventDataV2) toV1() TradeEventData {
+	return TradeEventData{
+		OrderID:       d.OrderID,
+		Symbol:        d.Symbol,
+		Side:          d.Side,
+		Quantity:      d.FilledQuantity.String(),
+		AveragePrice:  d.AverageFillPrice.String(),
+		TotalNotional: d.Notional.String(),
+		Fees:          d.Fees.String(),
+		State:         d.State,
+		ExecutedAt:    d.ExecutedAt,
+		CreatedAt:     d.CreatedAt,
+	}
+}
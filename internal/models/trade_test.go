@@ -0,0 +1,82 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarketHours_IsRegularHours(t *testing.T) {
+	hours, err := NewMarketHours("America/New_York", "09:30", "16:00")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		expected bool
+	}{
+		{"7am ET premarket", time.Date(2026, 1, 20, 12, 0, 0, 0, time.UTC), false},
+		{"10am ET", time.Date(2026, 1, 20, 15, 0, 0, 0, time.UTC), true},
+		{"at the open", time.Date(2026, 1, 20, 14, 30, 0, 0, time.UTC), true},
+		{"at the close", time.Date(2026, 1, 20, 21, 0, 0, 0, time.UTC), false},
+		{"Saturday 10am ET", time.Date(2026, 1, 24, 15, 0, 0, 0, time.UTC), false},
+		// Daylight time (UTC-4): 9:45am ET, which would be premarket at the winter offset
+		{"summer 9:45am ET", time.Date(2026, 7, 14, 13, 45, 0, 0, time.UTC), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, hours.IsRegularHours(tt.at))
+		})
+	}
+}
+
+func TestNewMarketHours_RejectsInvalidConfig(t *testing.T) {
+	_, err := NewMarketHours("Mars/Olympus", "09:30", "16:00")
+	assert.Error(t, err)
+
+	_, err = NewMarketHours("America/New_York", "9am", "16:00")
+	assert.Error(t, err)
+
+	_, err = NewMarketHours("America/New_York", "16:00", "09:30")
+	assert.Error(t, err)
+}
+
+func TestIsValidTradeSide(t *testing.T) {
+	assert.True(t, IsValidTradeSide("BUY"))
+	assert.True(t, IsValidTradeSide(" sell "))
+	assert.False(t, IsValidTradeSide(""))
+	assert.False(t, IsValidTradeSide("HOLD"))
+	assert.False(t, IsValidTradeSide("B UY"))
+}
+
+func TestTradeHistory_EntryPriceOmittedUnlessSet(t *testing.T) {
+	out, err := json.Marshal(&TradeHistory{Price: decimal.NewFromInt(110)})
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), "entry_price")
+
+	entry := decimal.NewFromInt(100)
+	out, err = json.Marshal(&TradeHistory{Price: decimal.NewFromInt(110), EntryPrice: &entry})
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `"entry_price":"100"`)
+}
+
+func TestTradeHistory_ImpliedEntryPrice(t *testing.T) {
+	trade := &TradeHistory{
+		Quantity:    decimal.NewFromInt(10),
+		Price:       decimal.NewFromInt(110),
+		Fee:         decimal.NewFromInt(10),
+		RealizedPnl: decimal.NewFromInt(90),
+	}
+	entry, ok := trade.ImpliedEntryPrice()
+	require.True(t, ok)
+	// (110 - 100) * 10 less the $10 fee is the $90 realized
+	assert.Equal(t, "100", entry.String())
+
+	_, ok = (&TradeHistory{Price: decimal.NewFromInt(110)}).ImpliedEntryPrice()
+	assert.False(t, ok)
+}
@@ -0,0 +1,66 @@
+// Package notify delivers fired alerts to the channels their rules ask for.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+// Notifier sends alerts recorded for one notification channel
+type Notifier interface {
+	// Channel is the notification_channel this notifier delivers, e.g. "telegram"
+	Channel() string
+	// Notify sends h's message, returning an error if it wasn't delivered
+	Notify(ctx context.Context, h *models.AlertHistory) error
+}
+
+// ErrNoNotifier is returned by a Dispatcher for a channel it has no notifier for
+var ErrNoNotifier = errors.New("no notifier for channel")
+
+// SentMarker records that an alert's notification was delivered.
+// *database.DB satisfies it.
+type SentMarker interface {
+	MarkNotificationSent(id int) error
+}
+
+// Dispatcher sends each alert through the notifier for its notification channel
+type Dispatcher struct {
+	notifiers map[string]Notifier
+}
+
+// NewDispatcher creates a dispatcher over notifiers. A later notifier for the same
+// channel replaces an earlier one.
+func NewDispatcher(notifiers ...Notifier) *Dispatcher {
+	d := &Dispatcher{notifiers: make(map[string]Notifier, len(notifiers))}
+	for _, n := range notifiers {
+		d.notifiers[n.Channel()] = n
+	}
+	return d
+}
+
+// Notify sends h through the notifier for h.NotificationChannel, or returns
+// ErrNoNotifier when there isn't one
+func (d *Dispatcher) Notify(ctx context.Context, h *models.AlertHistory) error {
+	n, ok := d.notifiers[h.NotificationChannel]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrNoNotifier, h.NotificationChannel)
+	}
+	return n.Notify(ctx, h)
+}
+
+// Deliver sends h through the notifier for its channel and then marks it sent in
+// marker. It returns ErrNoNotifier, leaving h unsent, when the channel has no
+// notifier.
+func (d *Dispatcher) Deliver(ctx context.Context, h *models.AlertHistory, marker SentMarker) error {
+	if err := d.Notify(ctx, h); err != nil {
+		return err
+	}
+	if err := marker.MarkNotificationSent(h.ID); err != nil {
+		return fmt.Errorf("failed to mark alert %d sent: %w", h.ID, err)
+	}
+	h.NotificationSent = true
+	return nil
+}
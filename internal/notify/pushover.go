@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+// DefaultPushoverAPIURL is the Pushover message API endpoint
+const DefaultPushoverAPIURL = "https://api.pushover.net/1/messages.json"
+
+// Emergency (priority 2) messages repeat until acknowledged; Pushover requires
+// the retry interval and how long to keep retrying, both in seconds
+const (
+	pushoverEmergencyRetry  = 60
+	pushoverEmergencyExpire = 3600
+)
+
+// pushoverPriorities maps alert priorities to Pushover priority levels
+var pushoverPriorities = map[string]int{
+	models.PriorityLow:      -1,
+	models.PriorityNormal:   0,
+	models.PriorityHigh:     1,
+	models.PriorityCritical: 2,
+}
+
+// PushoverPriority returns the Pushover priority level for an alert priority,
+// treating unknown priorities as normal
+func PushoverPriority(priority string) int {
+	return pushoverPriorities[priority]
+}
+
+// PushoverNotifier sends alert messages to a Pushover user through an application
+type PushoverNotifier struct {
+	apiURL   string
+	appToken string
+	userKey  string
+	client   *http.Client
+}
+
+// NewPushoverNotifier creates a notifier that sends to userKey as the application
+// with appToken, through the API at apiURL (DefaultPushoverAPIURL when empty)
+func NewPushoverNotifier(apiURL, appToken, userKey string) *PushoverNotifier {
+	if apiURL == "" {
+		apiURL = DefaultPushoverAPIURL
+	}
+	return &PushoverNotifier{
+		apiURL:   apiURL,
+		appToken: appToken,
+		userKey:  userKey,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Channel reports the pushover notification channel
+func (n *PushoverNotifier) Channel() string {
+	return models.ChannelPushover
+}
+
+// Notify sends h's message at the Pushover level for h.Priority, titled with its
+// symbol and rule type. Anything but a 200 response is an error.
+func (n *PushoverNotifier) Notify(ctx context.Context, h *models.AlertHistory) error {
+	priority := PushoverPriority(h.Priority)
+	form := url.Values{
+		"token":    {n.appToken},
+		"user":     {n.userKey},
+		"title":    {h.Symbol + " " + h.RuleType},
+		"message":  {h.Message},
+		"priority": {strconv.Itoa(priority)},
+	}
+	if priority == 2 {
+		form.Set("retry", strconv.Itoa(pushoverEmergencyRetry))
+		form.Set("expire", strconv.Itoa(pushoverEmergencyExpire))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.apiURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to build pushover request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to send pushover message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("pushover returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+func TestPushoverNotifier_Notify(t *testing.T) {
+	var got url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		require.NoError(t, r.ParseForm())
+		got = r.PostForm
+	}))
+	defer server.Close()
+
+	n := NewPushoverNotifier(server.URL, "app-token", "user-key")
+
+	tests := []struct {
+		priority string
+		expected string
+	}{
+		{models.PriorityLow, "-1"},
+		{models.PriorityNormal, "0"},
+		{models.PriorityHigh, "1"},
+		{models.PriorityCritical, "2"},
+		{"", "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.priority, func(t *testing.T) {
+			h := &models.AlertHistory{
+				Symbol:              "AAPL",
+				RuleType:            models.RuleTypePriceTarget,
+				Message:             "AAPL hit 210",
+				NotificationChannel: models.ChannelPushover,
+				Priority:            tt.priority,
+			}
+			require.NoError(t, n.Notify(context.Background(), h))
+
+			assert.Equal(t, "app-token", got.Get("token"))
+			assert.Equal(t, "user-key", got.Get("user"))
+			assert.Equal(t, "AAPL PRICE_TARGET", got.Get("title"))
+			assert.Equal(t, "AAPL hit 210", got.Get("message"))
+			assert.Equal(t, tt.expected, got.Get("priority"))
+			if tt.expected == "2" {
+				// Emergency messages must say how to retry
+				assert.Equal(t, "60", got.Get("retry"))
+				assert.Equal(t, "3600", got.Get("expire"))
+			} else {
+				assert.Empty(t, got.Get("retry"))
+			}
+		})
+	}
+}
+
+func TestDispatcher_SelectsNotifierByChannel(t *testing.T) {
+	var pushover, telegram int
+	pushoverServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { pushover++ }))
+	defer pushoverServer.Close()
+	telegramServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { telegram++ }))
+	defer telegramServer.Close()
+
+	d := NewDispatcher(
+		NewPushoverNotifier(pushoverServer.URL, "app", "user"),
+		NewTelegramNotifier(telegramServer.URL, "token", "42"),
+	)
+	ctx := context.Background()
+
+	require.NoError(t, d.Notify(ctx, &models.AlertHistory{NotificationChannel: models.ChannelPushover}))
+	require.NoError(t, d.Notify(ctx, &models.AlertHistory{NotificationChannel: models.ChannelTelegram}))
+	assert.Equal(t, 1, pushover)
+	assert.Equal(t, 1, telegram)
+
+	err := d.Notify(ctx, &models.AlertHistory{NotificationChannel: models.ChannelSMS})
+	assert.ErrorIs(t, err, ErrNoNotifier)
+}
@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+// SMTPNotifier emails alert messages through an SMTP server
+type SMTPNotifier struct {
+	addr string
+	auth smtp.Auth
+	from string
+	to   []string
+
+	// send delivers the message; smtp.SendMail outside tests
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewSMTPNotifier creates a notifier that mails alerts from from to each address
+// in to through host:port. PLAIN auth is used when username is set.
+func NewSMTPNotifier(host string, port int, username, password, from string, to []string) *SMTPNotifier {
+	n := &SMTPNotifier{
+		addr: net.JoinHostPort(host, strconv.Itoa(port)),
+		from: from,
+		to:   to,
+		send: smtp.SendMail,
+	}
+	if username != "" {
+		n.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return n
+}
+
+// Channel reports the email notification channel
+func (n *SMTPNotifier) Channel() string {
+	return models.ChannelEmail
+}
+
+// Notify emails h with its symbol, rule type and triggered value in the subject
+// and the alert message in the body
+func (n *SMTPNotifier) Notify(ctx context.Context, h *models.AlertHistory) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := n.send(n.addr, n.auth, n.from, n.to, n.message(h)); err != nil {
+		return fmt.Errorf("failed to send alert email: %w", err)
+	}
+	return nil
+}
+
+// message builds the RFC 5322 email for h
+func (n *SMTPNotifier) message(h *models.AlertHistory) []byte {
+	subject := fmt.Sprintf("%s %s: %s", h.Symbol, h.RuleType, h.TriggeredValue.String())
+	if h.Priority != "" {
+		subject = "[" + strings.ToUpper(h.Priority) + "] " + subject
+	}
+
+	triggeredAt := h.TriggeredAt
+	if triggeredAt.IsZero() {
+		triggeredAt = time.Now()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", headerValue(n.from))
+	fmt.Fprintf(&b, "To: %s\r\n", headerValue(strings.Join(n.to, ", ")))
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerValue(subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", triggeredAt.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	fmt.Fprintf(&b, "%s\r\n\r\n", h.Message)
+	fmt.Fprintf(&b, "Symbol: %s\r\n", h.Symbol)
+	fmt.Fprintf(&b, "Rule: %s\r\n", h.RuleType)
+	fmt.Fprintf(&b, "Triggered value: %s\r\n", h.TriggeredValue.String())
+	fmt.Fprintf(&b, "Triggered at: %s\r\n", triggeredAt.Format(time.RFC3339))
+	return []byte(b.String())
+}
+
+// headerValue drops line breaks so a value can't add headers of its own
+func headerValue(s string) string {
+	return strings.NewReplacer("\r", "", "\n", " ").Replace(s)
+}
@@ -0,0 +1,64 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+func TestSMTPNotifier_Notify(t *testing.T) {
+	n := NewSMTPNotifier("mail.example.com", 587, "alerts", "secret", "alerts@example.com", []string{"me@example.com"})
+
+	var gotAddr, gotFrom string
+	var gotTo []string
+	var gotMsg string
+	n.send = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		gotAddr, gotFrom, gotTo, gotMsg = addr, from, to, string(msg)
+		assert.NotNil(t, a)
+		return nil
+	}
+
+	h := &models.AlertHistory{
+		Symbol:              "AAPL",
+		RuleType:            models.RuleTypePriceTarget,
+		TriggeredValue:      decimal.RequireFromString("210.55"),
+		Message:             "AAPL crossed 200\r\nBcc: someone@example.com",
+		NotificationChannel: models.ChannelEmail,
+		Priority:            models.PriorityHigh,
+		TriggeredAt:         time.Date(2026, 4, 1, 15, 0, 0, 0, time.UTC),
+	}
+	require.NoError(t, n.Notify(context.Background(), h))
+
+	assert.Equal(t, "mail.example.com:587", gotAddr)
+	assert.Equal(t, "alerts@example.com", gotFrom)
+	assert.Equal(t, []string{"me@example.com"}, gotTo)
+
+	headers, body, found := strings.Cut(gotMsg, "\r\n\r\n")
+	require.True(t, found)
+	assert.Contains(t, headers, "Subject: [HIGH] AAPL PRICE_TARGET: 210.55\r\n")
+	assert.Contains(t, headers, "To: me@example.com\r\n")
+	assert.NotContains(t, headers, "Bcc:")
+	assert.Contains(t, body, "AAPL crossed 200")
+	assert.Contains(t, body, "Symbol: AAPL")
+	assert.Contains(t, body, "Triggered value: 210.55")
+	assert.Contains(t, body, "Triggered at: 2026-04-01T15:00:00Z")
+}
+
+func TestSMTPNotifier_SendError(t *testing.T) {
+	n := NewSMTPNotifier("mail.example.com", 25, "", "", "alerts@example.com", []string{"me@example.com"})
+	n.send = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		assert.Nil(t, a, "no auth without a username")
+		return errors.New("connection refused")
+	}
+
+	err := n.Notify(context.Background(), &models.AlertHistory{Symbol: "AAPL"})
+	assert.ErrorContains(t, err, "connection refused")
+}
@@ -0,0 +1,79 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+// DefaultTelegramAPIURL is the Telegram Bot API endpoint
+const DefaultTelegramAPIURL = "https://api.telegram.org"
+
+// TelegramNotifier posts alert messages to a chat through a Telegram bot
+type TelegramNotifier struct {
+	apiURL string
+	token  string
+	chatID string
+	client *http.Client
+}
+
+// NewTelegramNotifier creates a notifier that sends to chatID as the bot with
+// token, through the Bot API at apiURL (DefaultTelegramAPIURL when empty)
+func NewTelegramNotifier(apiURL, token, chatID string) *TelegramNotifier {
+	if apiURL == "" {
+		apiURL = DefaultTelegramAPIURL
+	}
+	return &TelegramNotifier{
+		apiURL: strings.TrimRight(apiURL, "/"),
+		token:  token,
+		chatID: chatID,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Channel reports the telegram notification channel
+func (n *TelegramNotifier) Channel() string {
+	return models.ChannelTelegram
+}
+
+// Notify sends h's message with the Bot API's sendMessage method. Anything but a
+// 200 response is an error.
+func (n *TelegramNotifier) Notify(ctx context.Context, h *models.AlertHistory) error {
+	body, err := json.Marshal(map[string]string{
+		"chat_id": n.chatID,
+		"text":    h.Message,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode telegram message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.apiURL+"/bot"+n.token+"/sendMessage", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build telegram request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// The request URL carries the bot token, so leave it out of the error
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to send telegram message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("telegram sendMessage returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+func TestTelegramNotifier_Notify(t *testing.T) {
+	var gotPath string
+	var gotBody map[string]string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&gotBody))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	n := NewTelegramNotifier(server.URL, "123:abc", "42")
+	h := &models.AlertHistory{ID: 7, Symbol: "AAPL", Message: "AAPL PRICE_TARGET: 210 above 200"}
+
+	require.NoError(t, n.Notify(context.Background(), h))
+	assert.Equal(t, "/bot123:abc/sendMessage", gotPath)
+	assert.Equal(t, map[string]string{"chat_id": "42", "text": "AAPL PRICE_TARGET: 210 above 200"}, gotBody)
+
+	status = http.StatusBadRequest
+	err := n.Notify(context.Background(), h)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "400")
+}
+
+func TestTelegramNotifier_ErrorOmitsToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close() // Connections are refused
+
+	err := NewTelegramNotifier(server.URL, "123:secret", "42").Notify(context.Background(), &models.AlertHistory{Message: "hi"})
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "secret")
+}
@@ -0,0 +1,113 @@
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+// ErrLengthMismatch is returned when paired series have different lengths
+var ErrLengthMismatch = errors.New("series lengths do not match")
+
+// ErrInsufficientData is returned when there are too few points to compute a statistic
+var ErrInsufficientData = errors.New("insufficient data points")
+
+// ErrZeroVariance is returned when a series is constant, leaving correlation undefined
+var ErrZeroVariance = errors.New("series has zero variance")
+
+// ComputeCorrelation returns the Pearson correlation coefficient of two equal-length series
+func ComputeCorrelation(seriesA, seriesB []decimal.Decimal) (decimal.Decimal, error) {
+	if len(seriesA) != len(seriesB) {
+		return decimal.Zero, fmt.Errorf("%w: %d vs %d", ErrLengthMismatch, len(seriesA), len(seriesB))
+	}
+	n := len(seriesA)
+	if n < 2 {
+		return decimal.Zero, fmt.Errorf("%w: need at least 2, got %d", ErrInsufficientData, n)
+	}
+
+	var meanA, meanB float64
+	for i := 0; i < n; i++ {
+		meanA += seriesA[i].InexactFloat64()
+		meanB += seriesB[i].InexactFloat64()
+	}
+	meanA /= float64(n)
+	meanB /= float64(n)
+
+	var cov, varA, varB float64
+	for i := 0; i < n; i++ {
+		da := seriesA[i].InexactFloat64() - meanA
+		db := seriesB[i].InexactFloat64() - meanB
+		cov += da * db
+		varA += da * da
+		varB += db * db
+	}
+	if varA == 0 || varB == 0 {
+		return decimal.Zero, ErrZeroVariance
+	}
+
+	r := cov / math.Sqrt(varA*varB)
+	// Clamp floating point drift outside [-1, 1]
+	r = math.Max(-1, math.Min(1, r))
+	return decimal.NewFromFloat(r), nil
+}
+
+// HistorySource provides the indicator and price history needed for correlation research
+type HistorySource interface {
+	GetIndicatorHistory(symbol string, indicatorType string, limit int) ([]*models.TechnicalIndicator, error)
+	GetPriceDataBySymbol(symbol string, limit int) ([]*models.PriceDataDaily, error)
+}
+
+// AlignRSIForwardReturns pairs each RSI reading with the close-to-close return over the
+// next horizon trading days. Readings without a matching close or a full horizon are dropped.
+func AlignRSIForwardReturns(rsi []*models.TechnicalIndicator, prices []*models.PriceDataDaily, horizon int) ([]decimal.Decimal, []decimal.Decimal) {
+	sorted := make([]*models.PriceDataDaily, len(prices))
+	copy(sorted, prices)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+
+	index := make(map[time.Time]int, len(sorted))
+	for i, p := range sorted {
+		index[dateKey(p.Date)] = i
+	}
+
+	var rsiSeries, returns []decimal.Decimal
+	for _, ind := range rsi {
+		i, ok := index[dateKey(ind.Date)]
+		if !ok || i+horizon >= len(sorted) || sorted[i].Close.IsZero() {
+			continue
+		}
+		ret := sorted[i+horizon].Close.Sub(sorted[i].Close).Div(sorted[i].Close)
+		rsiSeries = append(rsiSeries, ind.Value)
+		returns = append(returns, ret)
+	}
+	return rsiSeries, returns
+}
+
+// RSIForwardReturnCorrelation correlates a symbol's last lookback RSI readings with the
+// forward return over horizon trading days
+func RSIForwardReturnCorrelation(src HistorySource, symbol string, lookback, horizon int) (decimal.Decimal, error) {
+	if horizon < 1 {
+		return decimal.Zero, fmt.Errorf("horizon must be at least 1, got %d", horizon)
+	}
+
+	rsi, err := src.GetIndicatorHistory(symbol, models.IndicatorRSI14, lookback)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to get RSI history: %w", err)
+	}
+	prices, err := src.GetPriceDataBySymbol(symbol, lookback+horizon)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to get price history: %w", err)
+	}
+
+	rsiSeries, returns := AlignRSIForwardReturns(rsi, prices, horizon)
+	return ComputeCorrelation(rsiSeries, returns)
+}
+
+func dateKey(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,106 @@
+package stats
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+func decimals(values ...float64) []decimal.Decimal {
+	out := make([]decimal.Decimal, len(values))
+	for i, v := range values {
+		out[i] = decimal.NewFromFloat(v)
+	}
+	return out
+}
+
+func TestComputeCorrelation(t *testing.T) {
+	t.Run("perfect positive correlation", func(t *testing.T) {
+		r, err := ComputeCorrelation(decimals(1, 2, 3, 4, 5), decimals(2, 4, 6, 8, 10))
+		require.NoError(t, err)
+		assert.InDelta(t, 1.0, r.InexactFloat64(), 1e-9)
+	})
+
+	t.Run("perfect negative correlation", func(t *testing.T) {
+		r, err := ComputeCorrelation(decimals(1, 2, 3, 4, 5), decimals(50, 40, 30, 20, 10))
+		require.NoError(t, err)
+		assert.InDelta(t, -1.0, r.InexactFloat64(), 1e-9)
+	})
+
+	t.Run("known partial correlation", func(t *testing.T) {
+		// r = 0.8 for this textbook pair
+		r, err := ComputeCorrelation(decimals(1, 2, 3, 4, 5), decimals(2, 1, 4, 3, 5))
+		require.NoError(t, err)
+		assert.InDelta(t, 0.8, r.InexactFloat64(), 1e-9)
+	})
+
+	t.Run("mismatched lengths error", func(t *testing.T) {
+		_, err := ComputeCorrelation(decimals(1, 2, 3), decimals(1, 2))
+		assert.True(t, errors.Is(err, ErrLengthMismatch))
+	})
+
+	t.Run("too few points error", func(t *testing.T) {
+		_, err := ComputeCorrelation(decimals(1), decimals(1))
+		assert.True(t, errors.Is(err, ErrInsufficientData))
+	})
+
+	t.Run("constant series error", func(t *testing.T) {
+		_, err := ComputeCorrelation(decimals(3, 3, 3), decimals(1, 2, 3))
+		assert.True(t, errors.Is(err, ErrZeroVariance))
+	})
+}
+
+type fakeHistory struct {
+	rsi    []*models.TechnicalIndicator
+	prices []*models.PriceDataDaily
+}
+
+func (f *fakeHistory) GetIndicatorHistory(symbol, indicatorType string, limit int) ([]*models.TechnicalIndicator, error) {
+	return f.rsi, nil
+}
+
+func (f *fakeHistory) GetPriceDataBySymbol(symbol string, limit int) ([]*models.PriceDataDaily, error) {
+	return f.prices, nil
+}
+
+func TestRSIForwardReturnCorrelation(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	closes := []float64{100, 101, 103, 106, 110, 115}
+	rsiValues := []float64{30, 40, 50, 60, 70}
+
+	src := &fakeHistory{}
+	// Returned newest first, as the database does
+	for i := len(closes) - 1; i >= 0; i-- {
+		src.prices = append(src.prices, &models.PriceDataDaily{
+			Symbol: "AAPL",
+			Date:   base.AddDate(0, 0, i),
+			Close:  decimal.NewFromFloat(closes[i]),
+		})
+	}
+	for i := len(rsiValues) - 1; i >= 0; i-- {
+		src.rsi = append(src.rsi, &models.TechnicalIndicator{
+			Symbol:        "AAPL",
+			Date:          base.AddDate(0, 0, i),
+			IndicatorType: models.IndicatorRSI14,
+			Value:         decimal.NewFromFloat(rsiValues[i]),
+		})
+	}
+
+	rsiSeries, returns := AlignRSIForwardReturns(src.rsi, src.prices, 1)
+	require.Len(t, rsiSeries, 5)
+	require.Len(t, returns, 5)
+
+	// Higher RSI lines up with larger next-day gains in this series
+	r, err := RSIForwardReturnCorrelation(src, "AAPL", 5, 1)
+	require.NoError(t, err)
+	assert.Greater(t, r.InexactFloat64(), 0.9)
+
+	// Horizon longer than available history leaves nothing to correlate
+	_, err = RSIForwardReturnCorrelation(src, "AAPL", 5, 10)
+	assert.True(t, errors.Is(err, ErrInsufficientData))
+}
@@ -0,0 +1,95 @@
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+// ValuePoint is one observation of an account value series
+type ValuePoint struct {
+	Date  time.Time
+	Value decimal.Decimal
+}
+
+// Drawdown is a peak-to-trough decline in a value series
+type Drawdown struct {
+	Amount      decimal.Decimal `json:"amount"`
+	Pct         decimal.Decimal `json:"pct"` // of the peak; zero when the peak isn't positive
+	PeakValue   decimal.Decimal `json:"peak_value"`
+	TroughValue decimal.Decimal `json:"trough_value"`
+	PeakDate    time.Time       `json:"peak_date"`
+	TroughDate  time.Time       `json:"trough_date"`
+}
+
+// ComputeMaxDrawdown returns the largest decline from a running peak to a later
+// trough. The earliest such window wins ties. A series that never falls has a zero
+// drawdown starting and ending on its first point.
+func ComputeMaxDrawdown(series []ValuePoint) (*Drawdown, error) {
+	if len(series) < 2 {
+		return nil, fmt.Errorf("%w: need at least 2, got %d", ErrInsufficientData, len(series))
+	}
+
+	sorted := make([]ValuePoint, len(series))
+	copy(sorted, series)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+
+	peak := sorted[0]
+	worst := &Drawdown{
+		PeakValue:   peak.Value,
+		TroughValue: peak.Value,
+		PeakDate:    peak.Date,
+		TroughDate:  peak.Date,
+	}
+	for _, p := range sorted[1:] {
+		if p.Value.GreaterThan(peak.Value) {
+			peak = p
+			continue
+		}
+		if decline := peak.Value.Sub(p.Value); decline.GreaterThan(worst.Amount) {
+			worst.Amount = decline
+			worst.PeakValue, worst.PeakDate = peak.Value, peak.Date
+			worst.TroughValue, worst.TroughDate = p.Value, p.Date
+		}
+	}
+
+	if worst.PeakValue.IsPositive() {
+		worst.Pct = worst.Amount.Div(worst.PeakValue).Mul(decimal.NewFromInt(100)).Round(2)
+	}
+	return worst, nil
+}
+
+// CumulativePnlSeries turns daily realized P&L into an account value series that
+// starts at startingValue the day before the first close
+func CumulativePnlSeries(daily []*models.DailyPnl, startingValue decimal.Decimal) []ValuePoint {
+	if len(daily) == 0 {
+		return nil
+	}
+
+	series := make([]ValuePoint, 0, len(daily)+1)
+	series = append(series, ValuePoint{Date: daily[0].Date.AddDate(0, 0, -1), Value: startingValue})
+	value := startingValue
+	for _, d := range daily {
+		value = value.Add(d.Pnl)
+		series = append(series, ValuePoint{Date: d.Date, Value: value})
+	}
+	return series
+}
+
+// PnlSource provides the realized P&L history the account drawdown is built from
+type PnlSource interface {
+	GetDailyRealizedPnl() ([]*models.DailyPnl, error)
+}
+
+// GetWorstDrawdownPeriod returns the account's largest realized drawdown, treating
+// startingValue plus cumulative realized P&L as the account value
+func GetWorstDrawdownPeriod(src PnlSource, startingValue decimal.Decimal) (*Drawdown, error) {
+	daily, err := src.GetDailyRealizedPnl()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daily realized pnl: %w", err)
+	}
+	return ComputeMaxDrawdown(CumulativePnlSeries(daily, startingValue))
+}
@@ -0,0 +1,97 @@
+package stats
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trogers1052/stock-alert-system/internal/models"
+)
+
+func day(n int) time.Time {
+	return time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, n)
+}
+
+func valueSeries(values ...float64) []ValuePoint {
+	series := make([]ValuePoint, len(values))
+	for i, v := range values {
+		series[i] = ValuePoint{Date: day(i), Value: decimal.NewFromFloat(v)}
+	}
+	return series
+}
+
+func TestComputeMaxDrawdown(t *testing.T) {
+	t.Run("rising then falling", func(t *testing.T) {
+		// Peaks at 12,000 on day 3, bottoms at 9,000 on day 6, partly recovers
+		dd, err := ComputeMaxDrawdown(valueSeries(10000, 10500, 11000, 12000, 11500, 10000, 9000, 9500))
+		require.NoError(t, err)
+		assert.Equal(t, "3000", dd.Amount.String())
+		assert.Equal(t, "25", dd.Pct.String())
+		assert.True(t, day(3).Equal(dd.PeakDate))
+		assert.True(t, day(6).Equal(dd.TroughDate))
+	})
+
+	t.Run("picks the deepest of several declines", func(t *testing.T) {
+		// 100 -> 80 (-20), then new high 130 -> 100 (-30)
+		dd, err := ComputeMaxDrawdown(valueSeries(100, 80, 130, 100, 120))
+		require.NoError(t, err)
+		assert.Equal(t, "30", dd.Amount.String())
+		assert.True(t, day(2).Equal(dd.PeakDate))
+		assert.True(t, day(3).Equal(dd.TroughDate))
+	})
+
+	t.Run("unordered input is sorted by date", func(t *testing.T) {
+		series := valueSeries(100, 150, 90)
+		series[0], series[2] = series[2], series[0]
+		dd, err := ComputeMaxDrawdown(series)
+		require.NoError(t, err)
+		assert.Equal(t, "60", dd.Amount.String())
+	})
+
+	t.Run("never falls", func(t *testing.T) {
+		dd, err := ComputeMaxDrawdown(valueSeries(1, 2, 3))
+		require.NoError(t, err)
+		assert.True(t, dd.Amount.IsZero())
+		assert.True(t, dd.PeakDate.Equal(dd.TroughDate))
+	})
+
+	t.Run("too few points error", func(t *testing.T) {
+		_, err := ComputeMaxDrawdown(valueSeries(1))
+		assert.True(t, errors.Is(err, ErrInsufficientData))
+	})
+}
+
+type fakePnl struct {
+	days []*models.DailyPnl
+}
+
+func (f *fakePnl) GetDailyRealizedPnl() ([]*models.DailyPnl, error) {
+	return f.days, nil
+}
+
+func TestGetWorstDrawdownPeriod(t *testing.T) {
+	src := &fakePnl{days: []*models.DailyPnl{
+		{Date: day(1), Pnl: decimal.NewFromInt(-200)},
+		{Date: day(2), Pnl: decimal.NewFromInt(500)},
+		{Date: day(5), Pnl: decimal.NewFromInt(-300)},
+		{Date: day(6), Pnl: decimal.NewFromInt(-100)},
+	}}
+
+	// Account: 1000, 800, 1300, 1000, 900
+	dd, err := GetWorstDrawdownPeriod(src, decimal.NewFromInt(1000))
+	require.NoError(t, err)
+	assert.Equal(t, "400", dd.Amount.String())
+	assert.Equal(t, "30.77", dd.Pct.String())
+	assert.True(t, day(2).Equal(dd.PeakDate))
+	assert.True(t, day(6).Equal(dd.TroughDate))
+
+	// A loss on the first day is measured from the starting value
+	src.days = src.days[:1]
+	dd, err = GetWorstDrawdownPeriod(src, decimal.NewFromInt(1000))
+	require.NoError(t, err)
+	assert.Equal(t, "200", dd.Amount.String())
+	assert.True(t, day(0).Equal(dd.PeakDate))
+}